@@ -0,0 +1,654 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"image/color"
+	"log"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/nitrix/render/renderer"
+)
+
+// A model given on the command line, along with its transform.
+type modelSpec struct {
+	path      string
+	texture   string
+	translate renderer.Vertex3
+	rotate    renderer.Vertex3 // Euler angles in degrees.
+	scale     renderer.Vertex3
+	depthBias renderer.DepthBias
+}
+
+func (m *modelSpec) transform() renderer.Matrix4 {
+	return renderer.NewTransform(m.translate, m.rotate, m.scale)
+}
+
+// Every model argument or -model flag adds a model to the list.
+type modelList []*modelSpec
+
+func (l *modelList) String() string {
+	paths := make([]string, len(*l))
+	for i, m := range *l {
+		paths[i] = m.path
+	}
+	return strings.Join(paths, ",")
+}
+
+func (l *modelList) Set(value string) error {
+	*l = append(*l, &modelSpec{path: value, scale: renderer.Vertex3{X: 1, Y: 1, Z: 1}})
+	return nil
+}
+
+// Flags like -texture or -translate apply to the model preceding them.
+type modelOption struct {
+	models *modelList
+	apply  func(m *modelSpec, value string) error
+}
+
+func (o modelOption) String() string {
+	return ""
+}
+
+func (o modelOption) Set(value string) error {
+	if len(*o.models) == 0 {
+		return errors.New("must follow a model")
+	}
+	return o.apply((*o.models)[len(*o.models)-1], value)
+}
+
+// Parses vectors written as "x,y,z", a single value being used for all three components.
+func parseVertex3(value string) (renderer.Vertex3, error) {
+	parts := strings.Split(value, ",")
+	if len(parts) != 1 && len(parts) != 3 {
+		return renderer.Vertex3{}, errors.New(fmt.Sprintf("invalid vector %q, expected x,y,z", value))
+	}
+
+	components := make([]float64, len(parts))
+	for i, part := range parts {
+		f, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return renderer.Vertex3{}, errors.New(fmt.Sprintf("invalid number %q in vector %q", part, value))
+		}
+		components[i] = f
+	}
+
+	if len(components) == 1 {
+		return renderer.Vertex3{X: components[0], Y: components[0], Z: components[0]}, nil
+	}
+
+	return renderer.Vertex3{X: components[0], Y: components[1], Z: components[2]}, nil
+}
+
+// Parses depth biases written as constant or constant,slope.
+func parseDepthBias(value string) (renderer.DepthBias, error) {
+	parts := strings.Split(value, ",")
+	if len(parts) > 2 {
+		return renderer.DepthBias{}, errors.New(fmt.Sprintf("invalid depth bias %q, expected constant,slope", value))
+	}
+
+	var components [2]float64
+	for i, part := range parts {
+		f, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return renderer.DepthBias{}, errors.New(fmt.Sprintf("invalid number %q in depth bias %q", part, value))
+		}
+		components[i] = f
+	}
+
+	return renderer.DepthBias{Constant: components[0], Slope: components[1]}, nil
+}
+
+// Parses colors written as #rrggbb or #rrggbbaa, or "transparent".
+func parseColor(value string) (color.Color, error) {
+	if value == "transparent" {
+		return color.Transparent, nil
+	}
+
+	hex := strings.TrimPrefix(value, "#")
+	if len(hex) != 6 && len(hex) != 8 {
+		return nil, errors.New(fmt.Sprintf("invalid color %q, expected #rrggbb or #rrggbbaa", value))
+	}
+
+	n, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("invalid color %q, expected #rrggbb or #rrggbbaa", value))
+	}
+	if len(hex) == 6 {
+		n = n<<8 | 0xff
+	}
+
+	return color.NRGBA{R: uint8(n >> 24), G: uint8(n >> 16), B: uint8(n >> 8), A: uint8(n)}, nil
+}
+
+// Flags describing the scene and how it's drawn, shared by the commands rendering one.
+type sceneFlags struct {
+	models modelList
+
+	sceneFilename *string
+	environment   *string
+	skybox        *string
+	matcap        *string
+	debugTexture  *string
+	lods          *int
+	animation     *string
+	time          *float64
+
+	eye    *string
+	target *string
+	fov    *float64
+
+	width  *int
+	height *int
+
+	background *string
+
+	mode           *string
+	pathSamples    *int
+	bounces        *int
+	rasterizer     *string
+	depthPrepass   *bool
+	deferred       *bool
+	lightCulling   *bool
+	cull           *bool
+	frontFace      *string
+	wireframe      *string
+	grid           *float64
+	bounds         *string
+	axes           *bool
+	view           *string
+	normals        *bool
+	shadingMode    *string
+	vertexColors   *string
+	toneMapping    *string
+	exposure       *float64
+	focus          *float64
+	aperture       *float64
+	bloom          *bool
+	bloomThreshold *float64
+	bloomIntensity *float64
+	bloomRadius    *float64
+	vignette       *float64
+	grain          *float64
+	chromatic      *float64
+	lut            *string
+	antiAliasing   *string
+	samples        *int
+	workers        *int
+	shadows        *bool
+	shadowRays     *bool
+	shadowBias     *float64
+	shadowPCF      *int
+	cascades       *int
+	lightSize      *float64
+	toon           *bool
+	toonBands      *int
+	outline        *int
+}
+
+func newSceneFlags(flags *flag.FlagSet) *sceneFlags {
+	f := &sceneFlags{}
+	models := &f.models
+
+	flags.Var(models, "model", "OBJ, PLY, glTF, MD2 or MD5 model to render, or a built-in primitive like @sphere, same as giving it as an argument")
+	flags.Var(modelOption{models, func(m *modelSpec, value string) error {
+		m.texture = value
+		return nil
+	}}, "texture", "diffuse texture of the preceding model, or @checker or @uv for debug ones")
+	flags.Var(modelOption{models, func(m *modelSpec, value string) (err error) {
+		m.translate, err = parseVertex3(value)
+		return err
+	}}, "translate", "x,y,z translation of the preceding model")
+	flags.Var(modelOption{models, func(m *modelSpec, value string) (err error) {
+		m.rotate, err = parseVertex3(value)
+		return err
+	}}, "rotate", "x,y,z rotation in degrees of the preceding model")
+	flags.Var(modelOption{models, func(m *modelSpec, value string) (err error) {
+		m.scale, err = parseVertex3(value)
+		return err
+	}}, "scale", "x,y,z or uniform scale of the preceding model")
+	flags.Var(modelOption{models, func(m *modelSpec, value string) (err error) {
+		m.depthBias, err = parseDepthBias(value)
+		return err
+	}}, "depth-bias", "constant[,slope] depth bias of the preceding model, bringing it in front of coplanar surfaces like decals, in 1/255 of the depth range")
+
+	f.sceneFilename = flags.String("scene", "", "JSON scene file describing models, lights, camera and output")
+	f.environment = flags.String("environment", "", "equirectangular HDR image lighting metallic-roughness materials")
+	f.skybox = flags.String("skybox", "", "equirectangular (2:1) or cube cross (4:3) image drawn behind the scene")
+	f.matcap = flags.String("matcap", "", "image of a lit sphere shading every model instead of its materials and the lights, for previews of untextured sculpts")
+	f.debugTexture = flags.String("debug-texture", "", "texture on every model instead of its materials for checking how textures are laid out, \"checker\" or \"uv\" gradient")
+	f.lods = flags.Int("lod", 0, "levels of detail simplified from every model, drawn instead when small on screen")
+	f.animation = flags.String("animation", "", "animation of glTF, MD2 or MD5 models to pose them with, or play in the viewer, the first one by default")
+	f.time = flags.Float64("time", 0, "seconds into the animation, models staying as they are in their files without it or -animation")
+
+	f.eye = flags.String("camera", "", "x,y,z position of the camera, overriding the scene's")
+	f.target = flags.String("target", "", "x,y,z point the camera looks at, the center of the scene by default")
+	f.fov = flags.Float64("fov", 0, "vertical field of view of the camera in degrees, overriding the scene's")
+
+	f.width = flags.Int("width", 0, "width of the image in pixels, overriding the scene's")
+	flags.IntVar(f.width, "w", 0, "shorthand for -width")
+	f.height = flags.Int("height", 0, "height of the image in pixels, overriding the scene's")
+	flags.IntVar(f.height, "h", 0, "shorthand for -height")
+	f.background = flags.String("background", "", "background color as #rrggbb or #rrggbbaa, or \"transparent\" in PNG output, black by default")
+
+	f.mode = flags.String("mode", "raster", "\"raster\" to rasterize triangles, \"painter\" to draw them back to front without a depth buffer, or \"raytrace\" to path trace the scene, slower but with indirect light")
+	f.pathSamples = flags.Int("spp", 64, "paths traced per pixel with -mode raytrace, more for less noise")
+	f.bounces = flags.Int("bounces", 4, "bounces of every path with -mode raytrace, 0 for direct lighting only")
+	f.rasterizer = flags.String("rasterizer", "edge", "how triangles get filled, testing pixels against their \"edge\" functions, or \"scanline\" by rows, faster for large triangles")
+	f.depthPrepass = flags.Bool("depth-prepass", false, "draw the depth of opaque triangles first, then shade only what's visible, faster with heavy overdraw")
+	f.deferred = flags.Bool("deferred", false, "draw the surfaces of opaque triangles first, then light every pixel once, faster with many lights")
+	f.lightCulling = flags.Bool("light-culling", false, "only go through the point and spot lights within range of what gets shaded, faster with many small lights")
+	f.cull = flags.Bool("cull", true, "skip triangles facing away from the camera, hidden anyway on closed meshes")
+	f.frontFace = flags.String("front-face", "ccw", "winding of front-facing triangles on screen, \"ccw\" counter-clockwise like OBJ files, or \"cw\" clockwise")
+	f.wireframe = flags.String("wireframe", "", "draw triangle edges, \"only\" or \"overlay\" on the shaded result")
+	f.grid = flags.Float64("grid", 0, "draw a ground grid under the models with lines this far apart, -1 to space them after the size of the scene")
+	f.bounds = flags.String("bounds", "", "draw the bounding boxes of meshes, \"aabb\" along the axes of the world or \"obb\" along those of the meshes")
+	f.axes = flags.Bool("axes", false, "draw the axes of the world in the bottom-left corner")
+	f.view = flags.String("view", "lit", "what pixels show, \"lit\" surfaces, or for debugging \"depth\", \"flat\" lighting, \"textured\" without lighting, \"normals\", \"tangents\", \"uv\" coordinates or \"overdraw\"")
+	f.normals = flags.Bool("normals", false, "draw the normals of vertices as short lines, and their tangents in red")
+	f.shadingMode = flags.String("shading", "phong", "lighting computed per \"phong\" pixel, \"gouraud\" vertex or \"flat\" face")
+	f.vertexColors = flags.String("vertex-colors", "modulate", "vertex colors \"modulate\" textures, show under \"texture\" ones only, or are \"off\"")
+	f.toneMapping = flags.String("tonemap", "none", "bring highlights into the displayable range, \"reinhard\", \"aces\" or \"exposure\", or clamp them with \"none\"")
+	f.exposure = flags.Float64("exposure", 0, "exposure in stops, every one doubling the light")
+	f.focus = flags.Float64("focus", 0, "distance from the camera in focus, blurring what's nearer and farther, 0 for everything sharp")
+	f.aperture = flags.Float64("aperture", 0.01, "blur of what's infinitely far with -focus, as a fraction of the image height")
+	f.bloom = flags.Bool("bloom", false, "make bright areas glow")
+	f.bloomThreshold = flags.Float64("bloom-threshold", 0.8, "linear luminance above which areas glow, white being 1, lights and emissive surfaces going beyond")
+	f.bloomIntensity = flags.Float64("bloom-intensity", 1, "strength of the glow")
+	f.bloomRadius = flags.Float64("bloom-radius", 0.02, "spread of the glow, as a fraction of the image height")
+	f.vignette = flags.Float64("vignette", 0, "darken the corners of the image, from 0 for not at all to 1 for black")
+	f.grain = flags.Float64("grain", 0, "add film grain, its standard deviation as a fraction of white, like 0.04")
+	f.chromatic = flags.Float64("chromatic", 0, "spread red and blue apart toward the edges, by this fraction of the image height in the corners, like 0.003")
+	f.lut = flags.String("lut", "", "color grade the image through a .cube lookup table, after the other effects")
+	f.antiAliasing = flags.String("aa", "none", "anti-aliasing, \"ssaa\" supersampling, \"msaa\" multisampling or \"fxaa\"")
+	f.samples = flags.Int("samples", 4, "samples per pixel of anti-aliasing")
+	f.workers = flags.Int("workers", 0, "goroutines rasterizing in parallel, 0 for one per CPU")
+	f.shadows = flags.Bool("shadows", false, "cast shadows from directional, spot and point lights")
+	f.shadowRays = flags.Bool("shadow-rays", false, "cast shadows from every light by tracing rays towards it instead of through shadow maps, exact but slower")
+	f.shadowBias = flags.Float64("shadow-bias", 0.3, "depth offset against shadow acne, in depth buffer units")
+	f.shadowPCF = flags.Int("shadow-pcf", 1, "radius in texels of shadow filtering, 0 for hard shadows")
+	f.lightSize = flags.Float64("shadow-light-size", 0, "width of the lights for soft shadows, blurrier away from what casts them, or the angle in radians directional lights cover")
+	f.cascades = flags.Int("shadow-cascades", 0, "shadow maps of directional lights split along the view, 2 to 4, for crisp shadows near the camera in large scenes")
+	f.toon = flags.Bool("toon", false, "shade every material in flat bands of light, like cartoons")
+	f.toonBands = flags.Int("toon-bands", 3, "bands of light with -toon, from unlit to fully lit")
+	f.outline = flags.Int("outline", 0, "draw lines this many pixels wide along silhouettes and creases")
+
+	return f
+}
+
+// Models can be given as arguments in between flags, which then apply to the model before them.
+func (f *sceneFlags) parse(flags *flag.FlagSet, args []string) {
+	flags.Parse(args)
+	for flags.NArg() > 0 {
+		f.models.Set(flags.Arg(0))
+		flags.Parse(flags.Args()[1:])
+	}
+
+	if *f.sceneFilename == "" && len(f.models) == 0 {
+		fmt.Fprintln(flags.Output(), "No model or scene to render.")
+		flags.Usage()
+		os.Exit(2)
+	}
+}
+
+// Loads the scene with its models, filling in a light and a camera when it has none.
+func (f *sceneFlags) load() (*renderer.Scene, renderer.Camera, renderer.Output) {
+	scene, output, err := f.loadScene()
+	if err != nil {
+		log.Fatalln("Unable to load", err)
+	}
+
+	// Without one in the scene, frame the models from their most informative side.
+	camera, ok := scene.Camera()
+	if !ok {
+		camera = renderer.BestViewCamera(scene.Flatten())
+	}
+
+	if *f.eye != "" {
+		eye, err := parseVertex3(*f.eye)
+		if err != nil {
+			log.Fatalln("Invalid camera position:", err)
+		}
+		camera.Position = eye
+	}
+	if *f.target != "" {
+		target, err := parseVertex3(*f.target)
+		if err != nil {
+			log.Fatalln("Invalid camera target:", err)
+		}
+		camera.Target = target
+	}
+	if *f.fov > 0 {
+		camera.Fov = *f.fov * math.Pi / 180
+	}
+
+	return scene, camera, output
+}
+
+// Same as load without the camera, the scene as its files say, for loading it again when they
+// change.
+func (f *sceneFlags) loadScene() (*renderer.Scene, renderer.Output, error) {
+	scene := renderer.NewScene()
+	output := renderer.DefaultOutput()
+
+	if *f.sceneFilename != "" {
+		var err error
+		scene, output, err = renderer.LoadScene(*f.sceneFilename)
+		if err != nil {
+			return nil, output, errors.New(fmt.Sprintf("scene: %s", err))
+		}
+	}
+	if *f.width > 0 {
+		output.Width = *f.width
+	}
+	if *f.height > 0 {
+		output.Height = *f.height
+	}
+
+	for _, model := range f.models {
+		var err error
+
+		node := renderer.NewNode(model.path)
+		node.Transform = model.transform()
+
+		// Texture
+		var material *renderer.Material
+		if model.texture != "" {
+			material = renderer.DefaultMaterial()
+			material.DiffuseMap, err = renderer.LoadTexture(model.texture)
+			if err != nil {
+				return nil, output, errors.New(fmt.Sprintf("texture: %s", err))
+			}
+			if !strings.HasPrefix(model.texture, "@") {
+				scene.Files = append(scene.Files, model.texture)
+			}
+		}
+
+		// Animated models come with their own hierarchy, the texture going on all of its meshes.
+		if renderer.IsAnimated(model.path) {
+			root, clips, err := renderer.LoadAnimatedModel(model.path)
+			if err != nil {
+				return nil, output, errors.New(fmt.Sprintf("model: %s", err))
+			}
+			scene.Files = append(scene.Files, model.path)
+			if material != nil {
+				root.SetMaterial(material)
+			}
+			root.SetDepthBias(model.depthBias)
+			node.Add(root)
+			scene.Animations = append(scene.Animations, clips...)
+			scene.Root.Add(node)
+			continue
+		}
+
+		// Mesh
+		node.Mesh, err = renderer.LoadModel(model.path)
+		if err != nil {
+			return nil, output, errors.New(fmt.Sprintf("model: %s", err))
+		}
+		scene.Files = append(scene.Files, node.Mesh.Files...)
+		node.Material = material
+		node.DepthBias = model.depthBias
+
+		scene.Root.Add(node)
+	}
+
+	if *f.environment != "" {
+		var err error
+		scene.Environment, err = renderer.LoadEnvironment(*f.environment)
+		if err != nil {
+			return nil, output, errors.New(fmt.Sprintf("environment: %s", err))
+		}
+		scene.Files = append(scene.Files, *f.environment)
+	}
+
+	if *f.skybox != "" {
+		var err error
+		scene.Skybox, err = renderer.LoadSkybox(*f.skybox)
+		if err != nil {
+			return nil, output, errors.New(fmt.Sprintf("skybox: %s", err))
+		}
+		scene.Files = append(scene.Files, *f.skybox)
+	}
+
+	if *f.debugTexture != "" {
+		material := renderer.DefaultMaterial()
+		material.Name = *f.debugTexture
+		var err error
+		material.DiffuseMap, err = renderer.LoadTexture("@" + *f.debugTexture)
+		if err != nil {
+			return nil, output, errors.New(fmt.Sprintf("debug texture: %s", err))
+		}
+		scene.Root.SetMaterial(material)
+	}
+
+	if *f.matcap != "" {
+		material := renderer.DefaultMaterial()
+		material.Name = "matcap"
+		material.Model = renderer.Matcap
+		var err error
+		material.MatcapMap, err = renderer.LoadTexture(*f.matcap)
+		if err != nil {
+			return nil, output, errors.New(fmt.Sprintf("matcap: %s", err))
+		}
+		scene.Files = append(scene.Files, *f.matcap)
+		scene.Root.SetMaterial(material)
+	}
+
+	if *f.lods > 0 {
+		scene.GenerateLODs(*f.lods)
+	}
+
+	// The animation chosen goes first, for the viewer to play.
+	if *f.animation != "" {
+		found := false
+		for i, clip := range scene.Animations {
+			if clip.Name == *f.animation {
+				scene.Animations[0], scene.Animations[i] = clip, scene.Animations[0]
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, output, errors.New(fmt.Sprintf("animation %q: not in the scene", *f.animation))
+		}
+	}
+	if len(scene.Animations) > 0 && (*f.animation != "" || *f.time != 0) {
+		scene.Animations[0].Apply(*f.time)
+	}
+
+	if len(scene.Lights()) == 0 {
+		sun := renderer.NewNode("sun")
+		sun.Light = renderer.DirectionalLight{Direction: renderer.Vertex3{Z: -1}, Intensity: 1}
+		scene.Root.Add(sun)
+	}
+
+	return scene, output, nil
+}
+
+func (f *sceneFlags) options(output renderer.Output) renderer.Options {
+	options := renderer.DefaultOptions()
+	options.Width = output.Width
+	options.Height = output.Height
+
+	switch *f.mode {
+	case "raster":
+	case "painter":
+		options.Backend = renderer.Painter
+	case "raytrace":
+		options.Backend = renderer.PathTracing
+	default:
+		log.Fatalln("Unknown mode:", *f.mode)
+	}
+	options.PathTracing.Samples = *f.pathSamples
+	options.PathTracing.Bounces = *f.bounces
+
+	switch *f.rasterizer {
+	case "edge":
+	case "scanline":
+		options.Rasterizer = renderer.Scanline
+	default:
+		log.Fatalln("Unknown rasterizer:", *f.rasterizer)
+	}
+
+	options.DepthPrepass = *f.depthPrepass
+	options.Deferred = *f.deferred
+	options.LightCulling = *f.lightCulling
+	options.BackfaceCulling = *f.cull
+
+	switch *f.frontFace {
+	case "ccw":
+	case "cw":
+		options.FrontFace = renderer.Clockwise
+	default:
+		log.Fatalln("Unknown front face:", *f.frontFace)
+	}
+
+	switch *f.wireframe {
+	case "":
+	case "only":
+		options.Wireframe = renderer.WireframeOnly
+	case "overlay":
+		options.Wireframe = renderer.WireframeOverlay
+	default:
+		log.Fatalln("Unknown wireframe mode:", *f.wireframe)
+	}
+	if *f.grid != 0 {
+		options.Grid = renderer.GridOptions{Enabled: true, Spacing: math.Max(0, *f.grid)}
+	}
+	switch *f.bounds {
+	case "":
+	case "aabb":
+		options.BoundingBoxes = renderer.AxisAligned
+	case "obb":
+		options.BoundingBoxes = renderer.Oriented
+	default:
+		log.Fatalln("Unknown bounding boxes:", *f.bounds)
+	}
+	options.AxisGizmo = *f.axes
+	options.VertexNormals = *f.normals
+
+	switch *f.view {
+	case "lit":
+	case "depth":
+		options.View = renderer.ViewDepth
+	case "flat":
+		options.View = renderer.ViewFlat
+	case "textured":
+		options.View = renderer.ViewTextured
+	case "normals":
+		options.View = renderer.ViewNormals
+	case "tangents":
+		options.View = renderer.ViewTangents
+	case "uv":
+		options.View = renderer.ViewUV
+	case "overdraw":
+		options.View = renderer.ViewOverdraw
+	default:
+		log.Fatalln("Unknown view:", *f.view)
+	}
+
+	switch *f.shadingMode {
+	case "phong":
+	case "gouraud":
+		options.Shading = renderer.GouraudShading
+	case "flat":
+		options.Shading = renderer.FlatShading
+	default:
+		log.Fatalln("Unknown shading mode:", *f.shadingMode)
+	}
+
+	switch *f.vertexColors {
+	case "modulate":
+	case "texture":
+		options.VertexColors = renderer.TextureOverVertexColors
+	case "off":
+		options.VertexColors = renderer.IgnoreVertexColors
+	default:
+		log.Fatalln("Unknown vertex colors mode:", *f.vertexColors)
+	}
+
+	switch *f.toneMapping {
+	case "none":
+	case "reinhard":
+		options.ToneMapping = renderer.Reinhard
+	case "aces":
+		options.ToneMapping = renderer.ACES
+	case "exposure":
+		options.ToneMapping = renderer.ExposureToneMapping
+	default:
+		log.Fatalln("Unknown tone mapping:", *f.toneMapping)
+	}
+	options.Exposure = *f.exposure
+
+	// Effects of the scene file first, then the ones of the command line, bloom going first to glow
+	// from linear colors.
+	options.PostEffects = output.Effects
+	if *f.bloom {
+		options.PostEffects = append(options.PostEffects, &renderer.BloomEffect{
+			Threshold: *f.bloomThreshold,
+			Intensity: *f.bloomIntensity,
+			Radius:    *f.bloomRadius,
+		})
+	}
+	if *f.focus > 0 {
+		options.PostEffects = append(options.PostEffects, &renderer.DepthOfFieldEffect{
+			FocusDistance: *f.focus,
+			Aperture:      *f.aperture,
+		})
+	}
+	if *f.chromatic > 0 {
+		options.PostEffects = append(options.PostEffects, &renderer.ChromaticAberrationEffect{Strength: *f.chromatic})
+	}
+	if *f.vignette > 0 {
+		vignette := renderer.NewVignetteEffect()
+		vignette.Strength = *f.vignette
+		options.PostEffects = append(options.PostEffects, vignette)
+	}
+	if *f.grain > 0 {
+		options.PostEffects = append(options.PostEffects, &renderer.GrainEffect{Strength: *f.grain})
+	}
+	if *f.lut != "" {
+		lut, err := renderer.LoadLUT(*f.lut)
+		if err != nil {
+			log.Fatalln("Unable to load LUT:", err)
+		}
+		options.PostEffects = append(options.PostEffects, renderer.NewLUTEffect(lut))
+	}
+
+	switch *f.antiAliasing {
+	case "none":
+	case "ssaa":
+		options.AntiAliasing = renderer.Supersampling
+	case "fxaa":
+		options.AntiAliasing = renderer.FXAA
+	case "msaa":
+		options.AntiAliasing = renderer.Multisampling
+	default:
+		log.Fatalln("Unknown anti-aliasing:", *f.antiAliasing)
+	}
+
+	if *f.background != "" {
+		var err error
+		options.ClearColor, err = parseColor(*f.background)
+		if err != nil {
+			log.Fatalln("Invalid background:", err)
+		}
+	}
+
+	options.Samples = *f.samples
+	options.Workers = *f.workers
+	options.Shadows.Enabled = *f.shadows || *f.shadowRays
+	options.Shadows.RayTraced = *f.shadowRays
+	options.Shadows.Bias = *f.shadowBias
+	options.Shadows.PCF = *f.shadowPCF
+	options.Shadows.Cascades = *f.cascades
+	options.Shadows.LightSize = *f.lightSize
+	options.Toon = renderer.ToonOptions{Enabled: *f.toon, Bands: *f.toonBands, Outline: *f.outline}
+
+	return options
+}
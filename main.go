@@ -1,141 +1,437 @@
 package main
 
 import (
-	"image"
-	"image/png"
+	"flag"
+	"fmt"
+	"io"
 	"log"
 	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/nitrix/render/renderer"
 )
 
+type command struct {
+	name    string
+	summary string
+	run     func(args []string)
+}
+
+var commands = []command{
+	{"image", "render models or a scene into an image file, or a video", imageCommand},
+	{"view", "show models or a scene in a window, moving the camera with the mouse and keyboard", viewCommand},
+	{"sheet", "render models from every side into a contact sheet image, for asset catalogs", sheetCommand},
+	{"info", "print statistics of models, for checking assets", infoCommand},
+	{"bake", "bake the ambient occlusion of a model into a texture along its texture coordinates", bakeCommand},
+	{"serve", "render models sent over HTTP into PNG thumbnails", serve},
+}
+
 func main() {
-	// Output image
-	rect := image.Rectangle{Max: image.Point{X: 800, Y: 800}}
-	img := newImage(rect)
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
 
-	// Mesh
-	obj, err := loadObjFromFile("models/african_head.obj")
-	if err != nil {
-		log.Fatalln("Unable to load obj file:", err)
+	name, args := os.Args[1], os.Args[2:]
+	if isHelp(name) {
+		usage()
+		return
 	}
 
-	// Texture
-	textureFile, err := os.Open("textures/african_head_diffuse.png")
-	if err != nil {
-		log.Fatalln("Unable to read texture from file:", err)
+	// Plugins register their loaders and shaders before anything gets loaded.
+	for _, path := range filepath.SplitList(os.Getenv("RENDER_PLUGINS")) {
+		if err := renderer.LoadPlugin(path); err != nil {
+			log.Fatalln("Unable to load", err)
+		}
 	}
-	texture, err := png.Decode(textureFile)
-	if err != nil {
-		log.Fatalln("Unable to decode texture:", err)
-	}
-	texture = flipImageVertically(texture.Bounds(), texture)
-
-	// Render
-	//now := time.Now()
-	//fps := 0
-	//for time.Since(now) <= time.Second {
-	render(img, obj, texture)
-	//	fps++
-	//}
-	//fmt.Println("FPS:", fps)
-
-	// Saving
-	img = flipImageVertically(rect, img)
-	saveImage(img)
+
+	// Flags without a command render an image, like before there were commands.
+	if strings.HasPrefix(name, "-") {
+		name, args = "image", os.Args[1:]
+	}
+
+	for _, c := range commands {
+		if c.name == name {
+			c.run(args)
+			return
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "Unknown command %q.\n\n", name)
+	usage()
+	os.Exit(2)
 }
 
-func render(img *image.RGBA, obj *Obj, texture image.Image) {
-	rect := img.Bounds()
-	width := rect.Dx()
-	height := rect.Dy()
+func isHelp(arg string) bool {
+	return arg == "help" || arg == "-help" || arg == "--help" || arg == "-h"
+}
 
-	zBuffer := make([]float64, width*height)
-	for i := 0; i < len(zBuffer); i++ {
-		zBuffer[i] = -1.0
+func usage() {
+	fmt.Fprintln(os.Stderr, "Usage: render <command> [model ...] [flags]")
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Commands:")
+	for _, c := range commands {
+		fmt.Fprintf(os.Stderr, "  %-8s %s\n", c.name, c.summary)
 	}
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Run \"render <command> -help\" for the flags of a command.")
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintf(os.Stderr, "Plugins adding model formats and shaders get loaded from RENDER_PLUGINS, a list of .so files separated by %q.\n", filepath.ListSeparator)
+}
 
-	for _, face := range obj.Faces {
-		triangle := Triangle{}
+func commandFlags(name, args string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: render %s %s\n", name, args)
+		flags.PrintDefaults()
+	}
+	return flags
+}
 
-		for i := 0; i < 3; i++ {
-			localVertex := face.Vertices[i]
+func imageCommand(args []string) {
+	flags := commandFlags("image", "[model ...] [-o output.png] [flags]")
+	sceneFlags := newSceneFlags(flags)
+	outputFilename := flags.String("o", "", "image to write, PNG, JPEG, PPM, PAM or OpenEXR after its extension, overriding the scene's")
+	video := flags.String("video", "", "render the animation of the scene, or a turn around it without one, into a video file through ffmpeg, or \"-\" to stream frames to stdout")
+	videoFormat := flags.String("video-format", "raw", "format of frames streamed to stdout, \"raw\" RGBA or \"mjpeg\"")
+	frames := flags.Int("frames", 120, "frames of the video")
+	frameRate := flags.Float64("fps", 30, "frames per second of the video")
+	stagesDir := flags.String("stages", "", "also write one image per pipeline stage into this directory")
+	buffers := flags.String("buffers", "", "comma-separated buffers to also write next to the image as <name>-<buffer>.png, \"depth\", \"normals\", \"ids\" of objects or \"hdr\" colors")
+	stereo := flags.String("stereo", "", "render for both eyes, as a red-cyan \"anaglyph\" or \"sbs\" side by side, twice as wide")
+	separation := flags.Float64("eye-separation", 0, "distance between the eyes with -stereo, 0 for a thirtieth of the distance to the target")
+	panorama := flags.Bool("panorama", false, "render all around the camera into an equirectangular panorama, half as high as wide, for photo sphere viewers")
+	layout := flags.String("layout", "", "\"quad\" to split the image into top, front and right orthographic views and the perspective one")
+	preview := flags.String("preview", "", "with path tracing, write the image so far to this file after every pass of samples, to follow long renders")
+	printStats := flags.Bool("stats", false, "print what rendering the image took, triangles culled, clipped and drawn, fragments shaded and time per stage")
+	sceneFlags.parse(flags, args)
 
-			// Embed the 3D coordinate into 4D temporarily.
-			vertex4 := Vertex4{
-				X: localVertex.X,
-				Y: localVertex.Y,
-				Z: localVertex.Z,
-				W: 1,
+	scene, camera, output := sceneFlags.load()
+	options := sceneFlags.options(output)
+	if *outputFilename != "" {
+		output.File = *outputFilename
+	}
+
+	if *video != "" {
+		var format renderer.VideoFormat
+		switch *videoFormat {
+		case "raw":
+		case "mjpeg":
+			format = renderer.MJPEG
+		default:
+			log.Fatalln("Unknown video format:", *videoFormat)
+		}
+
+		// ffmpeg gets raw frames, sparing it from decoding JPEGs.
+		var w io.Writer = os.Stdout
+		var encoder *renderer.FFmpegEncoder
+		if *video != "-" {
+			var err error
+			encoder, err = renderer.StartFFmpeg(*video, output.Width, output.Height, *frameRate)
+			if err != nil {
+				log.Fatalln("Unable to encode video:", err)
 			}
+			w, format = encoder, renderer.RawVideo
+		}
 
-			// Map from an object's local coordinate space into world coordinate space.
-			cos90 := -0.44807361613
-			sin90 := 0.8939966636
-			modelMatrix := Matrix4{
-				cos90, 0, sin90, 0,
-				0, 1, 0, 0,
-				-sin90, 0, cos90, 0,
-				0, 0, 0, 1,
+		var err error
+		if len(scene.Animations) > 0 {
+			player := renderer.NewAnimationPlayer(scene.Animations[0])
+			player.Time = *sceneFlags.time
+			err = renderer.RenderAnimation(w, format, *frames, *frameRate, player, scene, camera, options)
+		} else {
+			err = renderer.RenderTurntable(w, format, *frames, scene, camera, options)
+		}
+		if err != nil {
+			log.Fatalln("Unable to write video:", err)
+		}
+		if encoder != nil {
+			if err := encoder.Close(); err != nil {
+				log.Fatalln("Unable to encode video:", err)
 			}
+		}
+		return
+	}
 
-			// Map from world space to camera space.
-			cameraMatrix := Identity4()
+	if *printStats {
+		options.Stats = &renderer.RenderStats{}
+		defer fmt.Println(options.Stats)
+	}
 
-			// Map from camera space to screen.
-			screenMatrix := genScreenMatrix(0, 0, width, height)
+	if *panorama {
+		img, err := renderer.RenderPanorama(scene, camera, options)
+		if err != nil {
+			log.Fatalln("Unable to render:", err)
+		}
+		if err := renderer.SaveImage(img, output.File); err != nil {
+			log.Fatalln("Unable to write image:", err)
+		}
+		return
+	}
 
-			vertex4.transform(modelMatrix)
-			vertex4.transform(cameraMatrix)
-			vertex4.transform(screenMatrix)
+	if *stereo != "" {
+		var mode renderer.StereoMode
+		switch *stereo {
+		case "anaglyph":
+		case "sbs":
+			mode = renderer.SideBySide
+		default:
+			log.Fatalln("Unknown stereo mode:", *stereo)
+		}
 
-			// Bring back 4D into 3D.
-			vertex3 := vertex4.lower()
+		img, err := renderer.RenderStereo(scene, camera, mode, *separation, options)
+		if err != nil {
+			log.Fatalln("Unable to render:", err)
+		}
+		if err := renderer.SaveImage(img, output.File); err != nil {
+			log.Fatalln("Unable to write image:", err)
+		}
+		return
+	}
 
-			triangle.points[i].X = int(vertex3.X)
-			triangle.points[i].Y = int(vertex3.Y)
+	switch *layout {
+	case "":
+		if *preview != "" {
+			options.Progress = func(p renderer.Progress) {
+				log.Printf("Pass %d of %d, %d samples per pixel", p.Pass, p.Passes, p.Samples)
+				if err := renderer.SaveImage(p.Image, *preview); err != nil {
+					log.Fatalln("Unable to write preview:", err)
+				}
+			}
 		}
 
-		drawTriangle(
-			img,
-			triangle,
-			zBuffer,
-			texture,
-			face,
-		)
+		// Render
+		if err := renderer.RenderFile(output.File, scene, camera, options); err != nil {
+			log.Fatalln("Unable to render:", err)
+		}
+
+	case "quad":
+		viewports := renderer.QuadViewports(scene, camera, options.Width, options.Height)
+		img, err := renderer.RenderViewports(scene, viewports, options)
+		if err != nil {
+			log.Fatalln("Unable to render:", err)
+		}
+		if err := renderer.SaveImage(img, output.File); err != nil {
+			log.Fatalln("Unable to write image:", err)
+		}
+
+	default:
+		log.Fatalln("Unknown layout:", *layout)
+	}
+
+	// Buffers and stages get rendered again, outside of the stats and previews.
+	options.Stats = nil
+	options.Progress = nil
+
+	if *buffers != "" {
+		framebuffer := renderer.NewFramebuffer(options.Width, options.Height)
+		var attachments []renderer.Attachment
+		for _, name := range strings.Split(*buffers, ",") {
+			attachment, ok := map[string]renderer.Attachment{
+				"depth":   renderer.DepthAttachment,
+				"normals": renderer.NormalAttachment,
+				"ids":     renderer.ObjectIDAttachment,
+				"hdr":     renderer.HDRAttachment,
+			}[name]
+			if !ok {
+				log.Fatalln("Unknown buffer:", name)
+			}
+			framebuffer.Attach(attachment)
+			attachments = append(attachments, attachment)
+		}
+
+		if err := renderer.RenderFramebuffer(framebuffer, scene, camera, options); err != nil {
+			log.Fatalln("Unable to render:", err)
+		}
+		base := strings.TrimSuffix(output.File, filepath.Ext(output.File))
+		for _, attachment := range attachments {
+			if err := framebuffer.Save(attachment, fmt.Sprintf("%s-%s.png", base, attachment)); err != nil {
+				log.Fatalln("Unable to write buffer:", err)
+			}
+		}
+	}
+
+	if *stagesDir != "" {
+		for i, stage := range renderer.RenderStages(scene, camera, options) {
+			filename := filepath.Join(*stagesDir, fmt.Sprintf("%02d-%s.png", i+1, stage.Name))
+			if err := renderer.SaveImage(stage.Image, filename); err != nil {
+				log.Fatalln("Unable to write stage:", err)
+			}
+		}
+	}
+}
+
+func viewCommand(args []string) {
+	flags := commandFlags("view", "[model ...] [flags]")
+	sceneFlags := newSceneFlags(flags)
+	controls := flags.String("controls", "orbit", "camera controls, \"orbit\" around the scene or \"fly\" through it")
+	flySpeed := flags.Float64("fly-speed", 1, "world units per second flown with -controls fly")
+	invertY := flags.Bool("invert-y", false, "look up when moving the mouse down with -controls fly")
+	backend := flags.String("backend", "auto", "window backend, \"glfw\" or \"shiny\" without cgo, when built in with the tag of the same name")
+	maxFPS := flags.Float64("max-fps", 0, "frame rate cap, 0 for none")
+	vsync := flags.Bool("vsync", true, "wait for the display to refresh between frames")
+	watch := flags.Bool("watch", true, "load the scene again when its files change on disk, models, materials, textures or the scene file")
+	sceneFlags.parse(flags, args)
+
+	scene, camera, output := sceneFlags.load()
+	options := sceneFlags.options(output)
+
+	var controller renderer.Controller
+	switch *controls {
+	case "orbit":
+		controller = renderer.NewOrbitController(camera)
+	case "fly":
+		fly := renderer.NewFlyController(camera)
+		fly.Speed, fly.InvertY = *flySpeed, *invertY
+		controller = fly
+	default:
+		log.Fatalln("Unknown camera controls:", *controls)
+	}
+
+	// Files being written are likely to fail loading, they get loaded again once written.
+	var reload func() (*renderer.Scene, error)
+	if *watch {
+		reload = func() (*renderer.Scene, error) {
+			scene, _, err := sceneFlags.loadScene()
+			if err != nil {
+				log.Println("Unable to reload", err)
+				return nil, err
+			}
+			log.Println("Reloaded the scene")
+			return scene, nil
+		}
+	}
+
+	err := renderer.RunWindow(*backend, "Rendoo", output.Width, output.Height, *vsync, func(window renderer.Window) {
+		renderer.RunViewer(window, scene, camera, controller, options, *maxFPS, reload)
+	})
+	if err != nil {
+		log.Fatalln("Unable to open window:", err)
+	}
+}
+
+func sheetCommand(args []string) {
+	flags := commandFlags("sheet", "[model ...] [-o sheet.png] [flags]")
+	sceneFlags := newSceneFlags(flags)
+	outputFilename := flags.String("o", "", "image to write, PNG, JPEG, PPM or PAM after its extension, overriding the scene's")
+	cell := flags.Int("cell", 256, "width and height in pixels of every view, the sheet being four views wide and two high")
+	sceneFlags.parse(flags, args)
+
+	scene, camera, output := sceneFlags.load()
+	options := sceneFlags.options(output)
+	if *outputFilename != "" {
+		output.File = *outputFilename
+	}
+	if *cell <= 0 {
+		log.Fatalln("Invalid cell size:", *cell)
+	}
+
+	// Named after the scene file, or the models.
+	title := filepath.Base(*sceneFlags.sceneFilename)
+	if *sceneFlags.sceneFilename == "" {
+		var names []string
+		for _, m := range sceneFlags.models {
+			names = append(names, filepath.Base(m.path))
+		}
+		title = strings.Join(names, "\n")
+	}
+
+	img, err := renderer.RenderContactSheet(scene, camera, title, *cell, options)
+	if err != nil {
+		log.Fatalln("Unable to render:", err)
+	}
+	if err := renderer.SaveImage(img, output.File); err != nil {
+		log.Fatalln("Unable to write image:", err)
 	}
 }
 
-func genScreenMatrix(x, y, w, h int) Matrix4 {
-	d := 255
+func infoCommand(args []string) {
+	flags := commandFlags("info", "model ...")
+	flags.Parse(args)
+	if flags.NArg() == 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	for i, path := range flags.Args() {
+		mesh, err := renderer.LoadModel(path)
+		if err != nil {
+			log.Fatalln("Unable to load model:", err)
+		}
+		info := mesh.Info()
 
-	return Matrix4{
-		float64(w) / 2.0, 0, 0, float64(x) + float64(w)/2.0,
-		0, float64(h) / 2.0, 0, float64(y) + float64(h)/2.0,
-		0, 0, float64(d) / 2.0, float64(d) / 2.0,
-		0, 0, 0, 1,
+		if i > 0 {
+			fmt.Println()
+		}
+		fmt.Println(path)
+		fmt.Printf("  Triangles:              %d\n", info.Triangles)
+		fmt.Printf("  Vertices:               %d\n", info.Vertices)
+		fmt.Printf("  Bounds:                 %.4g, %.4g, %.4g to %.4g, %.4g, %.4g\n",
+			info.Bounds.Min.X, info.Bounds.Min.Y, info.Bounds.Min.Z,
+			info.Bounds.Max.X, info.Bounds.Max.Y, info.Bounds.Max.Z)
+		fmt.Printf("  Size:                   %.4g x %.4g x %.4g\n",
+			info.Bounds.Max.X-info.Bounds.Min.X, info.Bounds.Max.Y-info.Bounds.Min.Y, info.Bounds.Max.Z-info.Bounds.Min.Z)
+		fmt.Printf("  UV coverage:            %.1f%%\n", info.UVCoverage*100)
+		fmt.Printf("  Non-manifold edges:     %d\n", info.NonManifoldEdges)
+		fmt.Printf("  Boundary edges:         %d\n", info.BoundaryEdges)
+		fmt.Printf("  Degenerate triangles:   %d\n", info.DegenerateTriangles)
+
+		names := make([]string, 0, len(info.Materials))
+		for name := range info.Materials {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		fmt.Printf("  Materials:              %d\n", len(names))
+		for _, name := range names {
+			label := name
+			if label == "" {
+				label = "(none)"
+			}
+			fmt.Printf("    %-22s%d triangles\n", label, info.Materials[name])
+		}
 	}
 }
 
-func genCameraMatrix(eye Vertex3, center Vertex3, up Vertex3) Matrix4 {
-	z := eye.minus(center).normalize(1.0)
-	x := up.cross(z).normalize(1.0)
-	y := z.cross(x).normalize(1.0)
-
-	minv := Identity4()
-	tr := Identity4()
-
-	minv.m11 = x.X
-	minv.m21 = y.X
-	minv.m31 = z.X
-	minv.m12 = x.Y
-	minv.m22 = y.Y
-	minv.m32 = z.Y
-	minv.m13 = x.Z
-	minv.m23 = y.Z
-	minv.m33 = z.Z
-
-	tr.m13 = -center.X
-	tr.m23 = -center.Y
-	tr.m33 = -center.Z
-
-	return minv.Dot(tr)
-}
\ No newline at end of file
+func bakeCommand(args []string) {
+	flags := commandFlags("bake", "model [-o occlusion.png] [flags]")
+	defaults := renderer.DefaultBakeOptions()
+	outputFilename := flags.String("o", "occlusion.png", "texture to write, PNG, JPEG, PPM or PAM after its extension")
+	size := flags.Int("size", defaults.Size, "width and height of the texture in texels")
+	samples := flags.Int("samples", defaults.Samples, "rays cast from every texel, more for less noise")
+	distance := flags.Float64("distance", defaults.Distance, "distance past which surfaces don't occlude, 0 for any")
+	workers := flags.Int("workers", 0, "goroutines baking in parallel, 0 for one per CPU")
+	flags.Parse(args)
+
+	// The model can come before the flags.
+	var model string
+	if flags.NArg() > 0 {
+		model = flags.Arg(0)
+		flags.Parse(flags.Args()[1:])
+	}
+	if model == "" || flags.NArg() > 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	mesh, err := renderer.LoadModel(model)
+	if err != nil {
+		log.Fatalln("Unable to load model:", err)
+	}
+
+	texture, err := renderer.BakeAmbientOcclusion(mesh, renderer.BakeOptions{
+		Size:     *size,
+		Samples:  *samples,
+		Distance: *distance,
+		Workers:  *workers,
+	})
+	if err != nil {
+		log.Fatalln("Unable to bake:", err)
+	}
+
+	if err := renderer.SaveImage(texture, *outputFilename); err != nil {
+		log.Fatalln("Unable to write texture:", err)
+	}
+}
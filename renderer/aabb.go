@@ -0,0 +1,49 @@
+package renderer
+
+// Axis-aligned bounding box.
+type AABB struct {
+	Min Vertex3
+	Max Vertex3
+}
+
+func (obj *Obj) aabb() AABB {
+	min, max := obj.bounds()
+	return AABB{Min: min, Max: max}
+}
+
+func (b AABB) corners() [8]Vertex3 {
+	var corners [8]Vertex3
+	for i := range corners {
+		corners[i] = b.Min
+		if i&1 != 0 {
+			corners[i].X = b.Max.X
+		}
+		if i&2 != 0 {
+			corners[i].Y = b.Max.Y
+		}
+		if i&4 != 0 {
+			corners[i].Z = b.Max.Z
+		}
+	}
+	return corners
+}
+
+// Whether the box, brought to clip space by m, lies entirely outside of one of the planes.
+// The planes being linear in clip space, that's the case as soon as all the corners are.
+func (b AABB) outside(m Matrix4, planes []clipPlane) bool {
+	for _, plane := range planes {
+		outside := true
+		for _, corner := range b.corners() {
+			v := Vertex4{X: corner.X, Y: corner.Y, Z: corner.Z, W: 1}
+			v.transform(m)
+			if plane(v) >= 0 {
+				outside = false
+				break
+			}
+		}
+		if outside {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,296 @@
+package renderer
+
+import (
+	"math"
+	"sort"
+)
+
+// Property of a node an animation channel drives.
+type AnimationPath int
+
+const (
+	TranslationPath AnimationPath = iota
+	RotationPath
+	ScalePath
+	// Weights of the morph targets of the mesh of the node.
+	WeightsPath
+	// Intensity of the light of the node, driven by scripts only.
+	IntensityPath
+)
+
+// How values get filled in between keyframes.
+type Interpolation int
+
+const (
+	LinearInterpolation Interpolation = iota
+	// The value of the previous keyframe holds until the next one.
+	StepInterpolation
+	// Hermite splines, every keyframe having tangents on both sides.
+	CubicSplineInterpolation
+)
+
+// Keyframes of one property of a node.
+type AnimationChannel struct {
+	Node          *Node
+	Path          AnimationPath
+	Interpolation Interpolation
+	// Seconds, in increasing order.
+	Times []float64
+	// One per keyframe, rotations being quaternions and the others using X, Y and Z only. Weights
+	// have one per morph target and keyframe instead, in X. Cubic splines have three times as
+	// many: the incoming tangents, the values and the outgoing tangents of every keyframe.
+	Values []Vertex4
+}
+
+// Property of a node set by expressions of the time rather than keyframes, like a light pulsing
+// with "1 + sin(t)".
+type AnimationScript struct {
+	Node *Node
+	// Translation, rotation, scale or intensity.
+	Path AnimationPath
+	// X, Y and Z, Euler angles in degrees for rotations. Intensities only use X.
+	Values [3]*Expression
+}
+
+// Channels animated together, like a walk cycle moving every joint of a skeleton.
+type AnimationClip struct {
+	Name     string
+	Channels []AnimationChannel
+	// Applied after the channels, overriding what they drive.
+	Scripts []AnimationScript
+	// How players play the clip unless told otherwise, at normal speed when 0.
+	Loop  bool
+	Speed float64
+}
+
+// Time of the last keyframe.
+func (c *AnimationClip) Duration() float64 {
+	duration := 0.0
+	for _, channel := range c.Channels {
+		if len(channel.Times) > 0 {
+			duration = math.Max(duration, channel.Times[len(channel.Times)-1])
+		}
+	}
+	return duration
+}
+
+// Poses the nodes as they are at a time of the clip, in seconds. What channels don't drive stays
+// as it was, times before the first keyframe and after the last one holding them.
+func (c *AnimationClip) Apply(time float64) {
+	type pose struct {
+		translation Vertex3
+		rotation    Quaternion
+		scale       Vertex3
+	}
+
+	poses := map[*Node]*pose{}
+	var nodes []*Node
+	poseOf := func(node *Node) *pose {
+		p, ok := poses[node]
+		if !ok {
+			p = &pose{}
+			p.translation, p.rotation, p.scale = decomposeTransform(node.Transform)
+			poses[node] = p
+			nodes = append(nodes, node)
+		}
+		return p
+	}
+
+	for _, channel := range c.Channels {
+		if channel.Node == nil || len(channel.Times) == 0 {
+			continue
+		}
+
+		if channel.Path == WeightsPath {
+			weights := make([]float64, channel.stride())
+			for i := range weights {
+				weights[i] = channel.sample(time, i).X
+			}
+			channel.Node.MorphWeights = weights
+			continue
+		}
+
+		p := poseOf(channel.Node)
+		value := channel.sample(time, 0)
+		switch channel.Path {
+		case TranslationPath:
+			p.translation = Vertex3{X: value.X, Y: value.Y, Z: value.Z}
+		case RotationPath:
+			p.rotation = Quaternion{X: value.X, Y: value.Y, Z: value.Z, W: value.W}.normalize()
+		case ScalePath:
+			p.scale = Vertex3{X: value.X, Y: value.Y, Z: value.Z}
+		}
+	}
+
+	for _, script := range c.Scripts {
+		if script.Node == nil {
+			continue
+		}
+
+		var values [3]float64
+		for i, e := range script.Values {
+			if e != nil {
+				values[i] = e.Eval(time)
+			}
+		}
+		value := Vertex3{X: values[0], Y: values[1], Z: values[2]}
+
+		switch script.Path {
+		case TranslationPath:
+			poseOf(script.Node).translation = value
+		case RotationPath:
+			poseOf(script.Node).rotation = quaternionFromMatrix(NewTransform(Vertex3{}, value, Vertex3{X: 1, Y: 1, Z: 1}))
+		case ScalePath:
+			poseOf(script.Node).scale = value
+		case IntensityPath:
+			script.Node.Light = withIntensity(script.Node.Light, value.X)
+		}
+	}
+
+	for _, node := range nodes {
+		p := poses[node]
+		node.Transform = composeTransform(p.translation, p.rotation, p.scale)
+	}
+}
+
+// The same light, shining as bright as the intensity. Lights of other kinds stay as they are.
+func withIntensity(light Light, intensity float64) Light {
+	switch l := light.(type) {
+	case DirectionalLight:
+		l.Intensity = intensity
+		return l
+	case PointLight:
+		l.Intensity = intensity
+		return l
+	case SpotLight:
+		l.Intensity = intensity
+		return l
+	}
+	return light
+}
+
+// Values per keyframe, the number of morph targets for weights and 1 for the others.
+func (c *AnimationChannel) stride() int {
+	n := len(c.Values) / len(c.Times)
+	if c.Interpolation == CubicSplineInterpolation {
+		n /= 3
+	}
+	return n
+}
+
+// The ith value of the channel at a time, held before the first keyframe and after the last one.
+func (c *AnimationChannel) sample(time float64, i int) Vertex4 {
+	n := c.stride()
+	// Of keyframe k, the ith value being part of a spline with its tangents.
+	value := func(k int) Vertex4 {
+		if c.Interpolation == CubicSplineInterpolation {
+			return c.Values[3*k*n+n+i]
+		}
+		return c.Values[k*n+i]
+	}
+	inTangent := func(k int) Vertex4 { return c.Values[3*k*n+i] }
+	outTangent := func(k int) Vertex4 { return c.Values[3*k*n+2*n+i] }
+
+	last := len(c.Times) - 1
+	if time <= c.Times[0] {
+		return value(0)
+	}
+	if time >= c.Times[last] {
+		return value(last)
+	}
+
+	// Keyframes on both sides of the time.
+	next := sort.SearchFloat64s(c.Times, time)
+	if c.Times[next] == time {
+		return value(next)
+	}
+	previous := next - 1
+	dt := c.Times[next] - c.Times[previous]
+	t := (time - c.Times[previous]) / dt
+
+	switch c.Interpolation {
+	case StepInterpolation:
+		return value(previous)
+
+	case CubicSplineInterpolation:
+		t2, t3 := t*t, t*t*t
+		v := value(previous).scale(2*t3 - 3*t2 + 1).
+			plus(outTangent(previous).scale((t3 - 2*t2 + t) * dt)).
+			plus(value(next).scale(-2*t3 + 3*t2)).
+			plus(inTangent(next).scale((t3 - t2) * dt))
+		if c.Path == RotationPath {
+			q := Quaternion{X: v.X, Y: v.Y, Z: v.Z, W: v.W}.normalize()
+			v = Vertex4{X: q.X, Y: q.Y, Z: q.Z, W: q.W}
+		}
+		return v
+	}
+
+	a, b := value(previous), value(next)
+	if c.Path == RotationPath {
+		q := Quaternion{X: a.X, Y: a.Y, Z: a.Z, W: a.W}.slerp(Quaternion{X: b.X, Y: b.Y, Z: b.Z, W: b.W}, t)
+		return Vertex4{X: q.X, Y: q.Y, Z: q.Z, W: q.W}
+	}
+	return a.lerp(b, t)
+}
+
+// Plays a clip as time goes by, for interactive viewers and videos.
+type AnimationPlayer struct {
+	Clip *AnimationClip
+	// Seconds into the clip.
+	Time float64
+	// 1 for the speed the clip was authored at, negative to play it backwards.
+	Speed float64
+	// Starts over at the end, instead of stopping there.
+	Loop bool
+}
+
+func NewAnimationPlayer(clip *AnimationClip) *AnimationPlayer {
+	speed := clip.Speed
+	if speed == 0 {
+		speed = 1
+	}
+	return &AnimationPlayer{Clip: clip, Speed: speed, Loop: clip.Loop}
+}
+
+// Moves the time forward and poses the nodes accordingly.
+func (p *AnimationPlayer) Update(dt float64) {
+	duration := p.Clip.Duration()
+	p.Time += dt * p.Speed
+
+	switch {
+	case p.Loop && duration > 0:
+		p.Time = math.Mod(p.Time, duration)
+		if p.Time < 0 {
+			p.Time += duration
+		}
+	case duration > 0 || len(p.Clip.Scripts) == 0:
+		p.Time = math.Max(0, math.Min(duration, p.Time))
+	default:
+		// Clips of scripts only have no last keyframe, and play on forever.
+		p.Time = math.Max(0, p.Time)
+	}
+
+	p.Clip.Apply(p.Time)
+}
+
+// Cubic spline values going smoothly through every keyframe, Catmull-Rom style, the tangents
+// following the keyframes on both sides.
+func catmullRom(times []float64, values []Vertex4) []Vertex4 {
+	spline := make([]Vertex4, 0, 3*len(values))
+	for k, value := range values {
+		previous, next := k-1, k+1
+		if previous < 0 {
+			previous = k
+		}
+		if next >= len(values) {
+			next = k
+		}
+
+		var tangent Vertex4
+		if dt := times[next] - times[previous]; dt > 0 {
+			tangent = values[next].plus(values[previous].scale(-1)).scale(1 / dt)
+		}
+		spline = append(spline, tangent, value, tangent)
+	}
+	return spline
+}
@@ -0,0 +1,249 @@
+package renderer
+
+import (
+	"image"
+	"image/color"
+	"math"
+)
+
+// Sample positions relative to pixel centers, from the standard Direct3D patterns. Rotated grids
+// catch nearly horizontal and vertical edges better than regular ones.
+var samplePatterns = map[int][]Vertex2{
+	2: {{X: 4.0 / 16, Y: 4.0 / 16}, {X: -4.0 / 16, Y: -4.0 / 16}},
+	4: {{X: -2.0 / 16, Y: -6.0 / 16}, {X: 6.0 / 16, Y: -2.0 / 16}, {X: -6.0 / 16, Y: 2.0 / 16}, {X: 2.0 / 16, Y: 6.0 / 16}},
+	8: {
+		{X: 1.0 / 16, Y: -3.0 / 16}, {X: -1.0 / 16, Y: 3.0 / 16}, {X: 5.0 / 16, Y: 1.0 / 16}, {X: -3.0 / 16, Y: -5.0 / 16},
+		{X: -5.0 / 16, Y: 5.0 / 16}, {X: -7.0 / 16, Y: -1.0 / 16}, {X: 3.0 / 16, Y: 7.0 / 16}, {X: 7.0 / 16, Y: -7.0 / 16},
+	},
+}
+
+// Pixels per side of the blocks supersampled images get averaged over.
+func supersamplingFactor(samples int) int {
+	return int(math.Ceil(math.Sqrt(float64(samples))))
+}
+
+// Averages blocks of factor × factor pixels of the source into the destination.
+func downsample(dst, src *image.RGBA, factor int) {
+	rect := dst.Bounds()
+	n := uint32(factor * factor)
+
+	for y := 0; y < rect.Dy(); y++ {
+		for x := 0; x < rect.Dx(); x++ {
+			var r, g, b, a uint32
+			for sy := 0; sy < factor; sy++ {
+				for sx := 0; sx < factor; sx++ {
+					c := src.RGBAAt(x*factor+sx, y*factor+sy)
+					r, g, b, a = r+uint32(c.R), g+uint32(c.G), b+uint32(c.B), a+uint32(c.A)
+				}
+			}
+			dst.SetRGBA(x, y, color.RGBA{R: uint8((r + n/2) / n), G: uint8((g + n/2) / n), B: uint8((b + n/2) / n), A: uint8((a + n/2) / n)})
+		}
+	}
+}
+
+// Nearest depth of every block of samples.
+func downsampleDepth(zBuffer []float64, width, height, factor int) []float64 {
+	small := make([]float64, width*height)
+	large := width * factor
+
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			nearest := math.Inf(-1)
+			for sy := 0; sy < factor; sy++ {
+				for sx := 0; sx < factor; sx++ {
+					nearest = math.Max(nearest, zBuffer[(y*factor+sy)*large+x*factor+sx])
+				}
+			}
+			small[y*width+x] = nearest
+		}
+	}
+
+	return small
+}
+
+// Color and depth of every sample of every pixel, for multisampling.
+type sampleBuffer struct {
+	width   int
+	height  int
+	offsets []Vertex2
+	colors  []color.RGBA
+	// Linear colors of the samples, nil without an HDR image to resolve them into.
+	linear []Vertex3
+	// Nil without depth testing.
+	depths []float64
+}
+
+// Samples start out with the color already in the images, like a skybox.
+func newSampleBuffer(img *image.RGBA, hdr *hdrImage, offsets []Vertex2, depthTest bool) *sampleBuffer {
+	rect := img.Bounds()
+	b := &sampleBuffer{
+		width:   rect.Dx(),
+		height:  rect.Dy(),
+		offsets: offsets,
+		colors:  make([]color.RGBA, rect.Dx()*rect.Dy()*len(offsets)),
+	}
+
+	for y := 0; y < b.height; y++ {
+		for x := 0; x < b.width; x++ {
+			c := img.RGBAAt(x, y)
+			for k := range offsets {
+				b.colors[(y*b.width+x)*len(offsets)+k] = c
+			}
+		}
+	}
+
+	if hdr != nil {
+		b.linear = make([]Vertex3, len(b.colors))
+		for i, c := range hdr.pixels {
+			for k := range offsets {
+				b.linear[i*len(offsets)+k] = c
+			}
+		}
+	}
+
+	if depthTest {
+		b.depths = make([]float64, len(b.colors))
+		fillFloat64s(b.depths, math.Inf(-1))
+	}
+
+	return b
+}
+
+// Like drawTriangle, with coverage and depth tested per sample. Fragments get shaded once, at
+// the center of the pixel or at a covered sample when the center is outside of the triangle,
+// and written to the auxiliary buffers of the framebuffer as they are.
+func (b *sampleBuffer) drawTriangle(fb *Framebuffer, triangle Triangle, s shading, transparent bool, region image.Rectangle) {
+	v1, v2, v3 := triangle.points[0], triangle.points[1], triangle.points[2]
+	n := len(b.offsets)
+	region = region.Intersect(image.Rect(0, 0, b.width, b.height))
+
+	// Samples away from the centers of pixels can be inside the triangle when the centers aren't.
+	min, max := triangle.pixelBounds()
+	min, max = min.Sub(image.Point{X: 1, Y: 1}), max.Add(image.Point{X: 1, Y: 1})
+	min.X, min.Y = maxInt(min.X, region.Min.X), maxInt(min.Y, region.Min.Y)
+	max.X, max.Y = minInt(max.X, region.Max.X-1), minInt(max.Y, region.Max.Y-1)
+	if min.X > max.X || min.Y > max.Y {
+		return
+	}
+	rule := triangle.fillRule()
+
+	if s.mode != PhongShading && s.view == ViewLit {
+		triangle.lit = s.shadeVertices(triangle)
+	}
+
+	covered := make([]bool, n)
+	depths := make([]float64, n)
+	shaded := 0
+
+	for x := min.X; x <= max.X; x++ {
+		for y := min.Y; y <= max.Y; y++ {
+			pixel := (y*b.width + x) * n
+			any := false
+			var sw1, sw2, sw3 float64
+
+			for k, o := range b.offsets {
+				covered[k] = false
+
+				w1, w2, w3 := barycentricAt(float64(x)+0.5+o.X, float64(y)+0.5+o.Y, v1, v2, v3)
+				if !rule.covers(w1, w2, w3) {
+					continue
+				}
+
+				depth := w1*triangle.depths[0] + w2*triangle.depths[1] + w3*triangle.depths[2]
+				if b.depths != nil && b.depths[pixel+k] >= depth {
+					continue
+				}
+
+				covered[k], depths[k] = true, depth
+				if !any {
+					sw1, sw2, sw3 = w1, w2, w3
+					any = true
+				}
+			}
+
+			if !any {
+				continue
+			}
+
+			if w1, w2, w3 := barycentricAt(float64(x)+0.5, float64(y)+0.5, v1, v2, v3); w1 >= 0 && w2 >= 0 && w3 >= 0 {
+				sw1, sw2, sw3 = w1, w2, w3
+			}
+			depth := sw1*triangle.depths[0] + sw2*triangle.depths[1] + sw3*triangle.depths[2]
+
+			p1, p2, p3 := sw1*triangle.invW[0], sw2*triangle.invW[1], sw3*triangle.invW[2]
+			sum := p1 + p2 + p3
+			linear, alpha := s.shadeLinear(triangle, p1/sum, p2/sum, p3/sum, depth)
+			c := s.encode(linear, alpha)
+			shaded++
+			if c.A == 0 {
+				continue
+			}
+			if !transparent {
+				fb.writeFragment(x, y, triangle, p1/sum, p2/sum, p3/sum)
+			}
+
+			for k := range b.offsets {
+				if !covered[k] {
+					continue
+				}
+				if b.depths != nil && !transparent {
+					b.depths[pixel+k] = depths[k]
+				}
+				if triangle.material.Blend == AdditiveBlend {
+					b.colors[pixel+k] = blendAdd(c, b.colors[pixel+k])
+				} else if c.A < 255 {
+					b.colors[pixel+k] = blendOver(c, b.colors[pixel+k])
+				} else {
+					b.colors[pixel+k] = c
+				}
+				if b.linear != nil {
+					b.linear[pixel+k] = blendLinear(b.linear[pixel+k], linear, alpha, triangle.material.Blend)
+				}
+			}
+		}
+	}
+	s.stats.addFragments(shaded)
+}
+
+// Averages the samples of every pixel into the images, the HDR one when given. Returns the nearest
+// depth of every pixel, nil without depth testing.
+func (b *sampleBuffer) resolve(img *image.RGBA, hdr *hdrImage) []float64 {
+	n := len(b.offsets)
+
+	var zBuffer []float64
+	if b.depths != nil {
+		zBuffer = make([]float64, b.width*b.height)
+	}
+
+	for y := 0; y < b.height; y++ {
+		for x := 0; x < b.width; x++ {
+			pixel := y*b.width + x
+
+			var r, g, bl, a uint32
+			for k := 0; k < n; k++ {
+				c := b.colors[pixel*n+k]
+				r, g, bl, a = r+uint32(c.R), g+uint32(c.G), bl+uint32(c.B), a+uint32(c.A)
+			}
+			u := uint32(n)
+			img.SetRGBA(x, y, color.RGBA{R: uint8((r + u/2) / u), G: uint8((g + u/2) / u), B: uint8((bl + u/2) / u), A: uint8((a + u/2) / u)})
+
+			if hdr != nil && b.linear != nil {
+				var sum Vertex3
+				for k := 0; k < n; k++ {
+					sum = sum.plus(b.linear[pixel*n+k])
+				}
+				hdr.set(x, y, sum.scale(1/float64(n)))
+			}
+
+			if zBuffer != nil {
+				nearest := math.Inf(-1)
+				for k := 0; k < n; k++ {
+					nearest = math.Max(nearest, b.depths[pixel*n+k])
+				}
+				zBuffer[pixel] = nearest
+			}
+		}
+	}
+
+	return zBuffer
+}
@@ -0,0 +1,239 @@
+package renderer
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"runtime"
+	"sync"
+)
+
+// Texels around the baked areas that get filled with their neighbours, so that filtering and
+// mipmapping don't bleed the empty space in between UV islands into the edges.
+const bakePadding = 4
+
+type BakeOptions struct {
+	// Width and height of the texture, in texels.
+	Size int
+	// Rays cast from every texel.
+	Samples int
+	// Surfaces further away than this don't occlude, 0 for any distance.
+	Distance float64
+	// Goroutines baking in parallel, as many as GOMAXPROCS when 0.
+	Workers int
+}
+
+func DefaultBakeOptions() BakeOptions {
+	return BakeOptions{Size: 1024, Samples: 64}
+}
+
+// Point of the surface seen by a texel.
+type bakeTexel struct {
+	position Vertex3
+	normal   Vertex3
+	// Normal of the face itself, rays leaving from its front side.
+	geometric Vertex3
+}
+
+// Bakes ambient occlusion into a texture laid out along the texture coordinates of the mesh,
+// white where the surface sees the whole sky and darker where the mesh hides it from itself.
+// Rays are cast from the surface seen through every texel, the unoccluded fraction weighted by
+// the cosine like diffuse light is. Where texture coordinates overlap, the first face wins.
+//
+// The texture is in the usual top-down orientation, ready to be saved and used in other engines.
+func BakeAmbientOcclusion(obj *Obj, options BakeOptions) (*image.Gray, error) {
+	if options.Size <= 0 {
+		return nil, errors.New(fmt.Sprintf("invalid texture size %d", options.Size))
+	}
+	if options.Samples <= 0 {
+		return nil, errors.New(fmt.Sprintf("invalid number of samples %d", options.Samples))
+	}
+	if !obj.textured() {
+		return nil, errors.New("the model has no texture coordinates to bake along")
+	}
+
+	size := options.Size
+	bvh := NewBVH(obj.Faces)
+	bounds := bvh.Bounds()
+	epsilon := math.Max(1e-6, bounds.Max.minus(bounds.Min).length()*1e-5)
+
+	distance := options.Distance
+	if distance <= 0 {
+		distance = math.Inf(1)
+	}
+
+	texels := make([]*bakeTexel, size*size)
+	for _, face := range obj.Faces {
+		rasterizeUV(face, size, func(x, y int, w1, w2, w3 float64) {
+			if texels[y*size+x] != nil {
+				return
+			}
+
+			geometric := faceNormal(face)
+			normal := face.Normals[0].scale(w1).plus(face.Normals[1].scale(w2)).plus(face.Normals[2].scale(w3))
+			if normal.length() < 1e-12 {
+				normal = geometric
+			}
+			normal = normal.normalize(1.0)
+			if normal.dot(geometric) < 0 {
+				geometric = geometric.scale(-1)
+			}
+
+			texels[y*size+x] = &bakeTexel{
+				position:  interpolatePosition(face, w1, w2, w3),
+				normal:    normal,
+				geometric: geometric,
+			}
+		})
+	}
+
+	occlusion := make([]float64, size*size)
+	covered := make([]bool, size*size)
+
+	workers := options.Workers
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+
+	rows := make(chan int, size)
+	for y := 0; y < size; y++ {
+		rows <- y
+	}
+	close(rows)
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for y := range rows {
+				for x := 0; x < size; x++ {
+					texel := texels[y*size+x]
+					if texel == nil {
+						continue
+					}
+
+					random := &splitMix{state: uint64(y*size + x)}
+					origin := texel.position.plus(texel.geometric.scale(epsilon))
+					visible := 0
+					for s := 0; s < options.Samples; s++ {
+						direction := sampleCosine(texel.normal, random)
+						if direction.dot(texel.geometric) <= 0 {
+							continue
+						}
+						if _, hit := bvh.intersect(Ray{Origin: origin, Direction: direction}, distance); !hit {
+							visible++
+						}
+					}
+
+					occlusion[y*size+x] = float64(visible) / float64(options.Samples)
+					covered[y*size+x] = true
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	dilate(occlusion, covered, size, bakePadding)
+
+	// Texture coordinates go up, images go down.
+	img := image.NewGray(image.Rect(0, 0, size, size))
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			v := 1.0
+			if covered[y*size+x] {
+				v = occlusion[y*size+x]
+			}
+			img.SetGray(x, size-1-y, color.Gray{Y: uint8(math.Max(0, math.Min(1, v))*255 + 0.5)})
+		}
+	}
+
+	return img, nil
+}
+
+// Calls fn with the barycentric weights of every texel whose center is inside the face in texture
+// space, wrapping around texture coordinates outside of 0 to 1.
+func rasterizeUV(face Face, size int, fn func(x, y int, w1, w2, w3 float64)) {
+	var uv [3]Vertex2
+	for i, t := range face.Textures {
+		uv[i] = t.scale(float64(size))
+	}
+
+	area := (uv[1].X-uv[0].X)*(uv[2].Y-uv[0].Y) - (uv[2].X-uv[0].X)*(uv[1].Y-uv[0].Y)
+	if area == 0 {
+		return
+	}
+
+	minX := int(math.Floor(math.Min(uv[0].X, math.Min(uv[1].X, uv[2].X))))
+	minY := int(math.Floor(math.Min(uv[0].Y, math.Min(uv[1].Y, uv[2].Y))))
+	maxX := int(math.Ceil(math.Max(uv[0].X, math.Max(uv[1].X, uv[2].X))))
+	maxY := int(math.Ceil(math.Max(uv[0].Y, math.Max(uv[1].Y, uv[2].Y))))
+	maxX = minInt(maxX, minX+size)
+	maxY = minInt(maxY, minY+size)
+
+	for y := minY; y < maxY; y++ {
+		for x := minX; x < maxX; x++ {
+			p := Vertex2{X: float64(x) + 0.5, Y: float64(y) + 0.5}
+			if !insideUV(uv, p, area) {
+				continue
+			}
+
+			// Sub-triangle areas opposite to every vertex.
+			w1 := ((uv[1].X-p.X)*(uv[2].Y-p.Y) - (uv[2].X-p.X)*(uv[1].Y-p.Y)) / area
+			w2 := ((uv[2].X-p.X)*(uv[0].Y-p.Y) - (uv[0].X-p.X)*(uv[2].Y-p.Y)) / area
+			fn(mod(x, size), mod(y, size), w1, w2, 1-w1-w2)
+		}
+	}
+}
+
+// Grows the covered texels by the given number of texels, every new one taking the average of its
+// covered neighbours.
+func dilate(values []float64, covered []bool, size, texels int) {
+	for pass := 0; pass < texels; pass++ {
+		var grown []int
+		var grownValues []float64
+
+		for y := 0; y < size; y++ {
+			for x := 0; x < size; x++ {
+				if covered[y*size+x] {
+					continue
+				}
+
+				sum, n := 0.0, 0
+				for dy := -1; dy <= 1; dy++ {
+					for dx := -1; dx <= 1; dx++ {
+						nx, ny := x+dx, y+dy
+						if nx < 0 || ny < 0 || nx >= size || ny >= size || !covered[ny*size+nx] {
+							continue
+						}
+						sum += values[ny*size+nx]
+						n++
+					}
+				}
+
+				if n > 0 {
+					grown = append(grown, y*size+x)
+					grownValues = append(grownValues, sum/float64(n))
+				}
+			}
+		}
+
+		for i, k := range grown {
+			values[k], covered[k] = grownValues[i], true
+		}
+	}
+}
+
+func (obj *Obj) textured() bool {
+	for _, face := range obj.Faces {
+		for _, t := range face.Textures {
+			if t != (Vertex2{}) {
+				return true
+			}
+		}
+	}
+	return false
+}
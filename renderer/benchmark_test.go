@@ -0,0 +1,323 @@
+package renderer
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Meshes of a few sizes, from props to scanned models, built from spheres with the given number
+// of segments and half as many rings.
+var benchmarkMeshes = []struct {
+	name     string
+	segments int
+}{
+	{"small", 16},
+	{"medium", 64},
+	{"large", 256},
+}
+
+// Writes the mesh as an OBJ file with positions, texture coordinates and normals, like exporters
+// do, returning its name.
+func writeBenchmarkObj(b *testing.B, obj *Obj) string {
+	b.Helper()
+
+	filename := filepath.Join(b.TempDir(), "mesh.obj")
+	file, err := os.Create(filename)
+	if err != nil {
+		b.Fatal(err)
+	}
+	w := bufio.NewWriter(file)
+
+	for _, face := range obj.Faces {
+		for i := 0; i < 3; i++ {
+			v, t, n := face.Vertices[i], face.Textures[i], face.Normals[i]
+			fmt.Fprintf(w, "v %f %f %f\nvt %f %f\nvn %f %f %f\n", v.X, v.Y, v.Z, t.X, t.Y, n.X, n.Y, n.Z)
+		}
+	}
+	for k := range obj.Faces {
+		v1, v2, v3 := 3*k+1, 3*k+2, 3*k+3
+		fmt.Fprintf(w, "f %d/%d/%d %d/%d/%d %d/%d/%d\n", v1, v1, v1, v2, v2, v2, v3, v3, v3)
+	}
+
+	if err := w.Flush(); err != nil {
+		b.Fatal(err)
+	}
+	if err := file.Close(); err != nil {
+		b.Fatal(err)
+	}
+	return filename
+}
+
+func BenchmarkLoadObj(b *testing.B) {
+	for _, mesh := range benchmarkMeshes {
+		b.Run(mesh.name, func(b *testing.B) {
+			filename := writeBenchmarkObj(b, NewSphere(mesh.segments, mesh.segments/2))
+			info, err := os.Stat(filename)
+			if err != nil {
+				b.Fatal(err)
+			}
+
+			b.SetBytes(info.Size())
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := loadObjFromFile(context.Background(), filename); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+
+	// Materials and textures come along with the model.
+	b.Run("textured", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := LoadModel(filepath.Join("testdata", "models", "crate.obj")); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkTransformVertices(b *testing.B) {
+	camera := NewCamera(Vertex3{Z: 3}, Vertex3{})
+	m := camera.projectionMatrix(1).Multiply(camera.viewMatrix())
+
+	for _, n := range []int{1 << 10, 1 << 16} {
+		source := make([]Vertex4, n)
+		for i := range source {
+			source[i] = Vertex4{X: float64(i), Y: float64(-i), Z: 1, W: 1}
+		}
+		// Transformed from the same vertices every time, as transforming them again and again
+		// would end up with infinities, both timings including the copy.
+		vertices := make([]Vertex4, n)
+
+		b.Run(fmt.Sprintf("scalar/%d", n), func(b *testing.B) {
+			b.SetBytes(int64(n * 32))
+			for i := 0; i < b.N; i++ {
+				copy(vertices, source)
+				transformVerticesGeneric(m, vertices)
+			}
+		})
+		// Whatever the processor supports, like rendering does.
+		b.Run(fmt.Sprintf("simd/%d", n), func(b *testing.B) {
+			b.SetBytes(int64(n * 32))
+			for i := 0; i < b.N; i++ {
+				copy(vertices, source)
+				transformVertices(m, vertices)
+			}
+		})
+	}
+}
+
+// The whole geometry stage, from model space to screen space triangles, clipping and culling
+// included.
+func BenchmarkProjectTriangles(b *testing.B) {
+	rect := image.Rect(0, 0, 640, 480)
+	camera := NewCamera(Vertex3{Z: 3}, Vertex3{})
+	options := DefaultOptions()
+
+	for _, mesh := range benchmarkMeshes {
+		b.Run(mesh.name, func(b *testing.B) {
+			obj := NewSphere(mesh.segments, mesh.segments/2)
+			b.ReportMetric(float64(len(obj.Faces)), "faces/op")
+			for i := 0; i < b.N; i++ {
+				projectTriangles(obj, nil, Identity4(), camera, rect, options, nil)
+			}
+		})
+	}
+}
+
+// Shading of fragments for the fill rate benchmarks, lit by a single light like most models are.
+func benchmarkShading(view View, mode ShadingMode) shading {
+	return shading{
+		lights:  []Light{DirectionalLight{Direction: Vertex3{X: -1, Y: -1, Z: -1}, Intensity: 1}},
+		ambient: Vertex3{X: 0.1, Y: 0.1, Z: 0.1},
+		eye:     Vertex3{Z: 3},
+		view:    view,
+		mode:    mode,
+	}
+}
+
+// Triangle of the given screen size in pixels, half of a square of that side, facing the camera.
+func benchmarkTriangle(size int, material *Material) Triangle {
+	t := Triangle{material: material}
+	t.points = [3]image.Point{{X: 0, Y: 0}, {X: size, Y: 0}, {X: 0, Y: size}}
+	t.face.Textures = [3]Vertex2{{X: 0, Y: 0}, {X: 1, Y: 0}, {X: 0, Y: 1}}
+	for i := 0; i < 3; i++ {
+		t.depths[i] = 128
+		t.invW[i] = 1
+		t.face.Vertices[i] = Vertex3{X: float64(t.points[i].X), Y: float64(t.points[i].Y)}
+		t.face.Normals[i] = Vertex3{Z: 1}
+		t.face.Tangents[i] = Vertex4{X: 1, W: 1}
+		t.face.Colors[i] = Vertex3{X: 1, Y: 1, Z: 1}
+	}
+	return t
+}
+
+// Pixels filled per second by drawTriangle, for triangles from a few pixels wide to the whole
+// screen, as the cost of setting up triangles weighs more the smaller they are, with both
+// rasterizers.
+func BenchmarkFillRate(b *testing.B) {
+	textured := DefaultMaterial()
+	textured.DiffuseMap = benchmarkTexture(image.NewRGBA(image.Rect(0, 0, 256, 256)))
+
+	cases := []struct {
+		name     string
+		material *Material
+		shading  shading
+	}{
+		{"depth", DefaultMaterial(), benchmarkShading(ViewDepth, PhongShading)},
+		{"flat", DefaultMaterial(), benchmarkShading(ViewLit, FlatShading)},
+		{"phong", DefaultMaterial(), benchmarkShading(ViewLit, PhongShading)},
+		{"textured", textured, benchmarkShading(ViewLit, PhongShading)},
+	}
+
+	rasterizers := []struct {
+		name   string
+		raster triangleRasterizer
+	}{
+		{"edge", edgeFunctionRasterizer{}},
+		{"scanline", scanlineRasterizer{}},
+	}
+
+	for _, c := range cases {
+		for _, r := range rasterizers {
+			for _, size := range []int{4, 32, 512} {
+				b.Run(fmt.Sprintf("%s/%s/%d", c.name, r.name, size), func(b *testing.B) {
+					img := image.NewRGBA(image.Rect(0, 0, size+1, size+1))
+					zBuffer := make([]float64, len(img.Pix)/4)
+					triangle := benchmarkTriangle(size, c.material)
+
+					// The depth test would reject every fragment after the first triangle.
+					drawTriangle(wrapFramebuffer(img, nil), triangle, nil, c.shading, false, img.Bounds(), r.raster)
+					pixels := 0
+					for i := 0; i < len(img.Pix); i += 4 {
+						if img.Pix[i+3] != 0 {
+							pixels++
+						}
+					}
+
+					fb := wrapFramebuffer(img, nil)
+					b.ResetTimer()
+					for i := 0; i < b.N; i++ {
+						fillFloat64s(zBuffer, math.Inf(-1))
+						drawTriangle(fb, triangle, zBuffer, c.shading, false, img.Bounds(), r.raster)
+					}
+					b.ReportMetric(float64(b.Elapsed().Nanoseconds())/float64(b.N*pixels), "ns/pixel")
+				})
+			}
+		}
+	}
+}
+
+// Fills the image with a pattern, so that samples don't all come from the same color.
+func benchmarkTexture(img image.Image) image.Image {
+	rect := img.Bounds()
+	set, ok := img.(interface{ Set(x, y int, c color.Color) })
+	if !ok {
+		return img
+	}
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			set.Set(x, y, color.NRGBA{R: uint8(x), G: uint8(y), B: uint8(x ^ y), A: 255})
+		}
+	}
+	return img
+}
+
+// Textures come as whatever image type their decoder gives, some being faster to read than
+// others.
+func BenchmarkSampleTexture(b *testing.B) {
+	rect := image.Rect(0, 0, 1024, 1024)
+	textures := []struct {
+		name    string
+		texture image.Image
+	}{
+		{"rgba", benchmarkTexture(image.NewRGBA(rect))},
+		{"nrgba", benchmarkTexture(image.NewNRGBA(rect))},
+		{"gray", benchmarkTexture(image.NewGray(rect))},
+		{"ycbcr", image.NewYCbCr(rect, image.YCbCrSubsampleRatio420)},
+	}
+
+	// Texture coordinates spread all over the texture, like a minified surface would sample it.
+	uvs := make([]Vertex2, 4096)
+	for i := range uvs {
+		uvs[i] = Vertex2{X: math.Mod(float64(i)*0.618034, 1), Y: math.Mod(float64(i)*0.414214, 1)}
+	}
+
+	for _, t := range textures {
+		b.Run(t.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				sampleTexture(t.texture, uvs[i%len(uvs)])
+			}
+		})
+	}
+}
+
+// A whole frame, for comparison with the stages benchmarked on their own.
+func BenchmarkRender(b *testing.B) {
+	scene, output, err := LoadScene(filepath.Join("testdata", "scenes", "basic.json"))
+	if err != nil {
+		b.Fatal(err)
+	}
+	camera, _ := scene.Camera()
+	options := DefaultOptions()
+	options.Width, options.Height = output.Width*4, output.Height*4
+
+	for i := 0; i < b.N; i++ {
+		if _, err := Render(scene, camera, options); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// Floor lit by a grid of small point lights, every one of them only lighting the part of it
+// below.
+func benchmarkLightsScene(side int) *Scene {
+	scene := NewScene()
+	scene.Ambient = Vertex3{X: 0.05, Y: 0.05, Z: 0.05}
+
+	floor := NewNode("floor")
+	floor.Mesh = NewPlane()
+	floor.Transform = ScaleMatrix(Vertex3{X: float64(side), Y: 1, Z: float64(side)})
+	scene.Root.Add(floor)
+
+	for z := 0; z < side; z++ {
+		for x := 0; x < side; x++ {
+			light := newPointLight(Vertex3{X: float64(x-side/2) + 0.5, Y: 0.5, Z: float64(z-side/2) + 0.5})
+			light.Linear, light.Quadratic = 0, 20
+			node := NewNode("light")
+			node.Light = light
+			scene.Root.Add(node)
+		}
+	}
+
+	return scene
+}
+
+// Shading goes through every light without culling, only the ones in range with it.
+func BenchmarkLightCulling(b *testing.B) {
+	scene := benchmarkLightsScene(16)
+	camera := NewCamera(Vertex3{Y: 12, Z: 12}, Vertex3{})
+
+	for _, culling := range []bool{false, true} {
+		b.Run(fmt.Sprintf("culling=%t", culling), func(b *testing.B) {
+			options := DefaultOptions()
+			options.Width, options.Height = 256, 256
+			options.AntiAliasing = NoAntiAliasing
+			options.LightCulling = culling
+
+			for i := 0; i < b.N; i++ {
+				if _, err := Render(scene, camera, options); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
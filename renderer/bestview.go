@@ -0,0 +1,67 @@
+package renderer
+
+import "math"
+
+// Picks the direction from which a model shows the most of itself, for when no camera was specified.
+// Candidate viewpoints are spread evenly on a sphere around the model (Fibonacci lattice) and each
+// one is scored by the projected area of the faces visible from it, which is the silhouette area
+// for closed meshes. A slight bias towards front and elevated views breaks the tie between
+// opposite viewpoints of symmetrical models, as models are usually authored facing +Z with +Y up.
+func bestViewDirection(obj *Obj, samples int) Vertex3 {
+	best := Vertex3{Z: 1}
+	bestScore := math.Inf(-1)
+
+	goldenAngle := math.Pi * (3 - math.Sqrt(5))
+
+	for i := 0; i < samples; i++ {
+		y := 1 - (float64(i)+0.5)/float64(samples)*2
+		radius := math.Sqrt(1 - y*y)
+		theta := goldenAngle * float64(i)
+		direction := Vertex3{X: math.Cos(theta) * radius, Y: y, Z: math.Sin(theta) * radius}
+
+		score := 0.0
+		for _, face := range obj.Faces {
+			e1 := face.Vertices[1].minus(face.Vertices[0])
+			e2 := face.Vertices[2].minus(face.Vertices[0])
+			n := e1.cross(e2) // Length is twice the area of the face.
+
+			if visibility := n.dot(direction); visibility > 0 {
+				score += visibility / 2
+			}
+		}
+		score *= 1 + 0.25*direction.Z + 0.1*direction.Y
+
+		if score > bestScore {
+			best, bestScore = direction, score
+		}
+	}
+
+	return best
+}
+
+// Camera looking at the model from its best view direction, far enough for the model to fit the screen.
+func BestViewCamera(obj *Obj) Camera {
+	min, max := obj.bounds()
+	center := min.plus(max).scale(0.5)
+	radius := max.minus(min).length() / 2
+	if radius == 0 {
+		radius = 1
+	}
+
+	direction := bestViewDirection(obj, 256)
+
+	camera := NewCamera(center, center)
+
+	// Far enough for the bounding sphere to fit in the field of view.
+	distance := radius / math.Sin(camera.Fov/2)
+	camera.Position = center.plus(direction.scale(distance))
+	camera.Near = math.Max(distance-radius, distance/1000)
+	camera.Far = distance + radius
+	camera.OrthoSize = radius
+
+	if math.Abs(direction.Y) > 0.99 {
+		camera.Up = Vertex3{Z: -1}
+	}
+
+	return camera
+}
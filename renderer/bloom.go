@@ -0,0 +1,166 @@
+package renderer
+
+import (
+	"image"
+	"math"
+)
+
+// Bright areas get blurred at a fraction of the resolution, which looks the same for a glow and
+// is much cheaper.
+const bloomDownsample = 4
+
+// Light bleeding around bright areas, like in camera lenses and eyes: what's above the threshold
+// gets blurred and added back on top of the image. Frames keeping their linear colors glow from
+// them, before tone mapping, lights and emissive surfaces glowing as much as they're bright.
+type BloomEffect struct {
+	// Linear luminance above which pixels glow, white being 1.
+	Threshold float64
+	// How much of the glow gets added back to the image.
+	Intensity float64
+	// Spread of the glow, as a fraction of the height of the image.
+	Radius float64
+}
+
+func NewBloomEffect() *BloomEffect {
+	return &BloomEffect{Threshold: 0.8, Intensity: 1, Radius: 0.02}
+}
+
+func (b *BloomEffect) Apply(frame *Frame) {
+	if frame.hdr == nil || frame.toneMapped {
+		bloom(frame.Image, *b)
+		return
+	}
+
+	hdr := frame.hdr
+	glow := bloomGlow(hdr.width, hdr.height, *b, hdr.at)
+	for y := 0; y < hdr.height; y++ {
+		for x := 0; x < hdr.width; x++ {
+			hdr.set(x, y, hdr.at(x, y).plus(glow(x, y)))
+		}
+	}
+	frame.develop(toneMapper{exposure: 1})
+}
+
+// Glow of the image, from its colors clamped to white.
+func bloom(img *image.RGBA, options BloomEffect) {
+	rect := img.Bounds()
+	glow := bloomGlow(rect.Dx(), rect.Dy(), options, func(x, y int) Vertex3 {
+		return decodeSRGB(img.RGBAAt(rect.Min.X+x, rect.Min.Y+y))
+	})
+
+	for y := 0; y < rect.Dy(); y++ {
+		for x := 0; x < rect.Dx(); x++ {
+			g := glow(x, y)
+			if g == (Vertex3{}) {
+				continue
+			}
+
+			c := img.RGBAAt(rect.Min.X+x, rect.Min.Y+y)
+			lit := toRGBA(linearToSRGB(decodeSRGB(c).plus(g)))
+			lit.A = c.A
+			img.SetRGBA(rect.Min.X+x, rect.Min.Y+y, lit)
+		}
+	}
+}
+
+// Light added to every pixel by the glow of the linear colors, scaled by the intensity.
+func bloomGlow(width, height int, options BloomEffect, at func(x, y int) Vertex3) func(x, y int) Vertex3 {
+	if width == 0 || height == 0 {
+		return func(x, y int) Vertex3 { return Vertex3{} }
+	}
+
+	// Bright pass, averaging blocks of pixels.
+	smallWidth := (width + bloomDownsample - 1) / bloomDownsample
+	smallHeight := (height + bloomDownsample - 1) / bloomDownsample
+	bright := make([]Vertex3, smallWidth*smallHeight)
+
+	for sy := 0; sy < smallHeight; sy++ {
+		for sx := 0; sx < smallWidth; sx++ {
+			var sum Vertex3
+			n := 0
+			for y := sy * bloomDownsample; y < minInt((sy+1)*bloomDownsample, height); y++ {
+				for x := sx * bloomDownsample; x < minInt((sx+1)*bloomDownsample, width); x++ {
+					c := at(x, y)
+					if l := luminance(c); l > options.Threshold {
+						sum = sum.plus(c.scale((l - options.Threshold) / l))
+					}
+					n++
+				}
+			}
+			bright[sy*smallWidth+sx] = sum.scale(1 / float64(n))
+		}
+	}
+
+	sigma := options.Radius * float64(height) / bloomDownsample
+	gaussianBlur(bright, smallWidth, smallHeight, sigma)
+
+	// Bilinearly upsampled.
+	return func(x, y int) Vertex3 {
+		glow := sampleBilinear(bright, smallWidth, smallHeight,
+			(float64(x)+0.5)/bloomDownsample-0.5, (float64(y)+0.5)/bloomDownsample-0.5)
+		return glow.scale(options.Intensity)
+	}
+}
+
+// Relative luminance of a linear color, with the Rec. 709 primaries sRGB uses.
+func luminance(c Vertex3) float64 {
+	return 0.2126*c.X + 0.7152*c.Y + 0.0722*c.Z
+}
+
+// Separable Gaussian blur, in place, edges clamped.
+func gaussianBlur(pixels []Vertex3, width, height int, sigma float64) {
+	if sigma <= 0 {
+		return
+	}
+
+	radius := int(math.Ceil(3 * sigma))
+	kernel := make([]float64, 2*radius+1)
+	total := 0.0
+	for i := range kernel {
+		d := float64(i - radius)
+		kernel[i] = math.Exp(-d * d / (2 * sigma * sigma))
+		total += kernel[i]
+	}
+	for i := range kernel {
+		kernel[i] /= total
+	}
+
+	row := make([]Vertex3, maxInt(width, height))
+	blur := func(n int, at func(i int) *Vertex3) {
+		for i := 0; i < n; i++ {
+			var sum Vertex3
+			for k, weight := range kernel {
+				j := minInt(maxInt(i+k-radius, 0), n-1)
+				sum = sum.plus(at(j).scale(weight))
+			}
+			row[i] = sum
+		}
+		for i := 0; i < n; i++ {
+			*at(i) = row[i]
+		}
+	}
+
+	for y := 0; y < height; y++ {
+		blur(width, func(i int) *Vertex3 { return &pixels[y*width+i] })
+	}
+	for x := 0; x < width; x++ {
+		blur(height, func(i int) *Vertex3 { return &pixels[i*width+x] })
+	}
+}
+
+// Between pixel centers, edges clamped.
+func sampleBilinear(pixels []Vertex3, width, height int, x, y float64) Vertex3 {
+	x0, y0 := math.Floor(x), math.Floor(y)
+	fx, fy := x-x0, y-y0
+
+	at := func(x, y int) Vertex3 {
+		x = minInt(maxInt(x, 0), width-1)
+		y = minInt(maxInt(y, 0), height-1)
+		return pixels[y*width+x]
+	}
+
+	ix, iy := int(x0), int(y0)
+	top := at(ix, iy).lerp(at(ix+1, iy), fx)
+	bottom := at(ix, iy+1).lerp(at(ix+1, iy+1), fx)
+	return top.lerp(bottom, fy)
+}
@@ -0,0 +1,353 @@
+package renderer
+
+import "math"
+
+const (
+	// Buckets the centers of triangles get sorted into along an axis, the candidate splits being
+	// between them.
+	bvhBins = 16
+	// Cost of visiting a node, relative to testing a ray against a triangle.
+	bvhTraversalCost = 1.0
+	// Leaves never hold more triangles than this, even when splitting them doesn't pay off.
+	bvhMaxLeafSize = 8
+)
+
+type Ray struct {
+	Origin Vertex3
+	// Normalized, for distances along the ray to be in world units.
+	Direction Vertex3
+}
+
+func (r Ray) At(distance float64) Vertex3 {
+	return r.Origin.plus(r.Direction.scale(distance))
+}
+
+// Where a ray hits a face.
+type Hit struct {
+	Distance float64
+	// Index of the face in the Faces of the BVH.
+	Face int
+	// Barycentric weights of the second and third vertices of the face, the first one getting
+	// the rest.
+	U, V float64
+}
+
+type bvhNode struct {
+	bounds AABB
+	// First face of leaves, first of the two consecutive children of inner nodes.
+	first int
+	// Faces of leaves, 0 for inner nodes.
+	count int
+}
+
+// Bounding volume hierarchy over faces, so that rays only get tested against the few whose boxes
+// they go through. Used for ray tracing, picking and collision queries.
+type BVH struct {
+	// Faces reordered so that every leaf holds a contiguous range of them.
+	Faces []Face
+
+	nodes []bvhNode
+	// Index of every face in the faces the hierarchy was built from.
+	order []int
+}
+
+// Builds the hierarchy with the surface area heuristic, splitting nodes where the odds of a ray
+// going through each half, times the faces it then gets tested against, are the lowest. The faces
+// get copied.
+func NewBVH(faces []Face) *BVH {
+	b := &BVH{Faces: append([]Face(nil), faces...), order: make([]int, len(faces))}
+	for i := range b.order {
+		b.order[i] = i
+	}
+	if len(faces) > 0 {
+		b.nodes = append(b.nodes, bvhNode{})
+		b.build(0, 0, len(faces))
+	}
+	return b
+}
+
+// Hierarchy over the faces of the scene in world space, nodes' materials replacing the ones of
+// the faces like when rendering.
+func (s *Scene) BVH() *BVH {
+	faces, _ := s.worldFaces()
+	return NewBVH(faces)
+}
+
+// Where a face of the scene comes from.
+type faceSource struct {
+	node *Node
+	// Index in the faces of the node's mesh.
+	face int
+	// Index in the instances of the node, 0 without any.
+	instance int
+}
+
+// Faces of the scene in world space with the material they get drawn with, if any, and the node
+// and index in its mesh of every one.
+func (s *Scene) worldFaces() ([]Face, []faceSource) {
+	var faces []Face
+	var sources []faceSource
+
+	s.walkInstances(func(node *Node, mesh *Obj, world Matrix4, instance int) {
+		normalMatrix := genNormalMatrix(world)
+		for i, face := range mesh.Faces {
+			face = node.tint(instance, face.transform(world, normalMatrix))
+			if node.Material != nil {
+				face.Material = node.Material
+			}
+			faces = append(faces, face)
+			sources = append(sources, faceSource{node: node, face: i, instance: instance})
+		}
+	})
+
+	return faces, sources
+}
+
+// Box around all the faces, empty without any.
+func (b *BVH) Bounds() AABB {
+	if len(b.nodes) == 0 {
+		return AABB{}
+	}
+	return b.nodes[0].bounds
+}
+
+// Closest face hit by the ray, whichever side of it.
+func (b *BVH) Intersect(r Ray) (Hit, bool) {
+	return b.intersect(r, math.Inf(1))
+}
+
+func (b *BVH) build(node, first, count int) {
+	faces := b.Faces[first : first+count]
+	order := b.order[first : first+count]
+
+	bounds, centers := emptyAABB(), emptyAABB()
+	for _, face := range faces {
+		for _, v := range face.Vertices {
+			bounds = bounds.extend(v)
+		}
+		centers = centers.extend(faceCenter(face))
+	}
+	b.nodes[node] = bvhNode{bounds: bounds, first: first, count: count}
+
+	if count <= 2 {
+		return
+	}
+
+	axis, split, cost := bestSplit(faces, bounds, centers)
+	if split < 0 {
+		// All the centers in the same place, nothing to split them on.
+		if count <= bvhMaxLeafSize {
+			return
+		}
+		split = count / 2
+	} else {
+		if cost >= float64(count) && count <= bvhMaxLeafSize {
+			return
+		}
+
+		// Faces of the bins before the split go first.
+		lower, extent := axisOf(centers.Min, axis), axisOf(centers.Max, axis)-axisOf(centers.Min, axis)
+		i, j := 0, count-1
+		for i <= j {
+			if centerBin(faceCenter(faces[i]), axis, lower, extent) < split {
+				i++
+			} else {
+				faces[i], faces[j] = faces[j], faces[i]
+				order[i], order[j] = order[j], order[i]
+				j--
+			}
+		}
+		split = i
+	}
+
+	children := len(b.nodes)
+	b.nodes = append(b.nodes, bvhNode{}, bvhNode{})
+	b.nodes[node].first, b.nodes[node].count = children, 0
+
+	b.build(children, first, split)
+	b.build(children+1, first+split, count-split)
+}
+
+// Cheapest way to split faces between bins along an axis, as the axis, the first bin of the
+// second half and the expected cost relative to testing a single face. The split is -1 when the
+// faces can't be split.
+func bestSplit(faces []Face, bounds, centers AABB) (int, int, float64) {
+	bestAxis, bestSplit, bestCost := 0, -1, math.Inf(1)
+
+	for axis := 0; axis < 3; axis++ {
+		lower, extent := axisOf(centers.Min, axis), axisOf(centers.Max, axis)-axisOf(centers.Min, axis)
+		if extent <= 0 {
+			continue
+		}
+
+		var bins [bvhBins]struct {
+			bounds AABB
+			count  int
+		}
+		for i := range bins {
+			bins[i].bounds = emptyAABB()
+		}
+		for _, face := range faces {
+			bin := &bins[centerBin(faceCenter(face), axis, lower, extent)]
+			bin.count++
+			for _, v := range face.Vertices {
+				bin.bounds = bin.bounds.extend(v)
+			}
+		}
+
+		// Area and faces of everything below every split, then above it.
+		var belowArea [bvhBins]float64
+		var belowCount [bvhBins]int
+		below, n := emptyAABB(), 0
+		for i := 1; i < bvhBins; i++ {
+			below = below.union(bins[i-1].bounds)
+			n += bins[i-1].count
+			belowArea[i], belowCount[i] = below.surfaceArea(), n
+		}
+
+		above, n := emptyAABB(), 0
+		for i := bvhBins - 1; i > 0; i-- {
+			above = above.union(bins[i].bounds)
+			n += bins[i].count
+			if belowCount[i] == 0 || n == 0 {
+				continue
+			}
+
+			cost := belowArea[i]*float64(belowCount[i]) + above.surfaceArea()*float64(n)
+			if cost < bestCost {
+				bestAxis, bestSplit, bestCost = axis, i, cost
+			}
+		}
+	}
+
+	if bestSplit < 0 {
+		return 0, -1, 0
+	}
+
+	// Relative to the chances of a ray going through the parent.
+	if area := bounds.surfaceArea(); area > 0 {
+		bestCost = bvhTraversalCost + bestCost/area
+	}
+
+	return bestAxis, bestSplit, bestCost
+}
+
+func centerBin(center Vertex3, axis int, lower, extent float64) int {
+	bin := int((axisOf(center, axis) - lower) / extent * bvhBins)
+	return minInt(maxInt(bin, 0), bvhBins-1)
+}
+
+// Closest face hit by the ray, nearer than the maximum distance.
+func (b *BVH) intersect(r Ray, maxDistance float64) (Hit, bool) {
+	hit := Hit{Distance: maxDistance, Face: -1}
+	if len(b.nodes) == 0 {
+		return hit, false
+	}
+
+	inverse := Vertex3{X: 1 / r.Direction.X, Y: 1 / r.Direction.Y, Z: 1 / r.Direction.Z}
+
+	var storage [64]int
+	stack := append(storage[:0], 0)
+	for len(stack) > 0 {
+		node := &b.nodes[stack[len(stack)-1]]
+		stack = stack[:len(stack)-1]
+
+		if !node.bounds.hitBy(r.Origin, inverse, hit.Distance) {
+			continue
+		}
+
+		if node.count == 0 {
+			stack = append(stack, node.first, node.first+1)
+			continue
+		}
+
+		for i := node.first; i < node.first+node.count; i++ {
+			distance, u, v, ok := intersectTriangle(r, &b.Faces[i])
+			if ok && distance < hit.Distance {
+				hit = Hit{Distance: distance, Face: i, U: u, V: v}
+			}
+		}
+	}
+
+	return hit, hit.Face >= 0
+}
+
+// Möller–Trumbore ray-triangle intersection, hitting both sides of the triangle.
+func intersectTriangle(r Ray, face *Face) (float64, float64, float64, bool) {
+	e1 := face.Vertices[1].minus(face.Vertices[0])
+	e2 := face.Vertices[2].minus(face.Vertices[0])
+	h := r.Direction.cross(e2)
+	a := e1.dot(h)
+	if math.Abs(a) < 1e-12 {
+		return 0, 0, 0, false
+	}
+
+	f := 1 / a
+	s := r.Origin.minus(face.Vertices[0])
+	u := f * s.dot(h)
+	if u < 0 || u > 1 {
+		return 0, 0, 0, false
+	}
+
+	q := s.cross(e1)
+	v := f * r.Direction.dot(q)
+	if v < 0 || u+v > 1 {
+		return 0, 0, 0, false
+	}
+
+	distance := f * e2.dot(q)
+	return distance, u, v, distance > 0
+}
+
+func faceCenter(face Face) Vertex3 {
+	v := face.Vertices
+	return v[0].plus(v[1]).plus(v[2]).scale(1.0 / 3)
+}
+
+// Box containing nothing, that extending by a point turns into a box around that point.
+func emptyAABB() AABB {
+	inf := math.Inf(1)
+	return AABB{Min: Vertex3{X: inf, Y: inf, Z: inf}, Max: Vertex3{X: -inf, Y: -inf, Z: -inf}}
+}
+
+func (b AABB) extend(p Vertex3) AABB {
+	return AABB{
+		Min: Vertex3{X: math.Min(b.Min.X, p.X), Y: math.Min(b.Min.Y, p.Y), Z: math.Min(b.Min.Z, p.Z)},
+		Max: Vertex3{X: math.Max(b.Max.X, p.X), Y: math.Max(b.Max.Y, p.Y), Z: math.Max(b.Max.Z, p.Z)},
+	}
+}
+
+func (b AABB) union(o AABB) AABB {
+	return b.extend(o.Min).extend(o.Max)
+}
+
+// 0 for empty boxes.
+func (b AABB) surfaceArea() float64 {
+	size := b.Max.minus(b.Min)
+	if size.X < 0 || size.Y < 0 || size.Z < 0 {
+		return 0
+	}
+	return 2 * (size.X*size.Y + size.Y*size.Z + size.Z*size.X)
+}
+
+func axisOf(v Vertex3, axis int) float64 {
+	switch axis {
+	case 0:
+		return v.X
+	case 1:
+		return v.Y
+	}
+	return v.Z
+}
+
+// Slab test of a ray, given by its origin and the inverse of its direction, against the box.
+func (b AABB) hitBy(origin, inverse Vertex3, maxDistance float64) bool {
+	x1, x2 := (b.Min.X-origin.X)*inverse.X, (b.Max.X-origin.X)*inverse.X
+	y1, y2 := (b.Min.Y-origin.Y)*inverse.Y, (b.Max.Y-origin.Y)*inverse.Y
+	z1, z2 := (b.Min.Z-origin.Z)*inverse.Z, (b.Max.Z-origin.Z)*inverse.Z
+
+	near := math.Max(math.Max(math.Min(x1, x2), math.Min(y1, y2)), math.Min(z1, z2))
+	far := math.Min(math.Min(math.Max(x1, x2), math.Max(y1, y2)), math.Max(z1, z2))
+
+	return far >= math.Max(near, 0) && near < maxDistance
+}
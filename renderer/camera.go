@@ -0,0 +1,84 @@
+package renderer
+
+import "math"
+
+type Projection int
+
+const (
+	Perspective Projection = iota
+	Orthographic
+)
+
+type Camera struct {
+	Position Vertex3
+	Target   Vertex3
+	Up       Vertex3
+
+	Projection Projection
+	// Vertical field of view in radians, for perspective projections.
+	Fov float64
+	// Half the height of the visible area, for orthographic projections.
+	OrthoSize float64
+
+	Near float64
+	Far  float64
+}
+
+func NewCamera(position, target Vertex3) Camera {
+	return Camera{
+		Position:   position,
+		Target:     target,
+		Up:         Vertex3{Y: 1},
+		Projection: Perspective,
+		Fov:        math.Pi / 4,
+		OrthoSize:  1,
+		Near:       0.1,
+		Far:        100,
+	}
+}
+
+// Map from world space to camera space.
+func (c Camera) viewMatrix() Matrix4 {
+	return LookAtMatrix(c.Position, c.Target, c.Up)
+}
+
+// Distance along the view direction of what's at a depth of the depth buffer, undoing the
+// projection and screen matrices. Infinite where nothing got drawn.
+func (c Camera) viewDistance(depth float64) float64 {
+	if math.IsInf(depth, -1) {
+		return math.Inf(1)
+	}
+
+	ndc := depth/255*2 - 1
+	if c.Projection == Orthographic {
+		return (c.Far + c.Near - ndc*(c.Far-c.Near)) / 2
+	}
+
+	a := (c.Far + c.Near) / (c.Far - c.Near)
+	b := 2 * c.Far * c.Near / (c.Far - c.Near)
+	return b / (ndc + a)
+}
+
+// Depth in the depth buffer of what's at a distance along the view direction, the inverse of
+// viewDistance.
+func (c Camera) depth(distance float64) float64 {
+	var ndc float64
+	if c.Projection == Orthographic {
+		ndc = (c.Far + c.Near - 2*distance) / (c.Far - c.Near)
+	} else {
+		a := (c.Far + c.Near) / (c.Far - c.Near)
+		b := 2 * c.Far * c.Near / (c.Far - c.Near)
+		ndc = b/distance - a
+	}
+
+	return (ndc + 1) / 2 * 255
+}
+
+// Map from camera space to clip space, for a viewport of the given width over height ratio.
+func (c Camera) projectionMatrix(aspect float64) Matrix4 {
+	if c.Projection == Orthographic {
+		return OrthographicMatrix(-c.OrthoSize*aspect, c.OrthoSize*aspect, -c.OrthoSize, c.OrthoSize, c.Near, c.Far)
+	}
+
+	return PerspectiveMatrix(c.Fov, aspect, c.Near, c.Far)
+}
@@ -0,0 +1,136 @@
+package renderer
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+)
+
+// 2D drawing over an image, for annotations, crosshairs and simple interfaces on top of renders.
+// Coordinates are in pixels with Y going down, like in image files and rendered images. Colors
+// blend over what's already there according to their alpha, and drawing outside the image is
+// clipped.
+type Canvas struct {
+	Image draw.Image
+}
+
+func NewCanvas(img draw.Image) *Canvas {
+	return &Canvas{Image: img}
+}
+
+// Canvas over an image as it is while rendering, bottom row first, so that post effects and the
+// viewer can draw with Y going down too.
+func newFlippedCanvas(img *image.RGBA) *Canvas {
+	return &Canvas{Image: flippedImage{img}}
+}
+
+func (c *Canvas) DrawLine(from, to image.Point, col color.Color) {
+	drawLine(blendingImage{c.Image}, from.X, from.Y, to.X, to.Y, col)
+}
+
+// Outline of the pixels FillRect fills.
+func (c *Canvas) DrawRect(r image.Rectangle, col color.Color) {
+	r = r.Canon()
+	if r.Empty() {
+		return
+	}
+
+	right, bottom := r.Max.X-1, r.Max.Y-1
+	c.DrawLine(r.Min, image.Point{X: right, Y: r.Min.Y}, col)
+	if bottom > r.Min.Y {
+		c.DrawLine(image.Point{X: r.Min.X, Y: bottom}, image.Point{X: right, Y: bottom}, col)
+	}
+	if bottom-1 > r.Min.Y {
+		c.DrawLine(image.Point{X: r.Min.X, Y: r.Min.Y + 1}, image.Point{X: r.Min.X, Y: bottom - 1}, col)
+		if right > r.Min.X {
+			c.DrawLine(image.Point{X: right, Y: r.Min.Y + 1}, image.Point{X: right, Y: bottom - 1}, col)
+		}
+	}
+}
+
+// Pixels of the rectangle, its maximum point excluded like everywhere else in the image package.
+func (c *Canvas) FillRect(r image.Rectangle, col color.Color) {
+	draw.Draw(c.Image, r.Canon(), image.NewUniform(col), image.Point{}, draw.Over)
+}
+
+// Outline of the circle, with the midpoint circle algorithm.
+func (c *Canvas) DrawCircle(center image.Point, radius int, col color.Color) {
+	if radius < 0 {
+		return
+	}
+
+	// Octants meet on the axes and diagonals, where points would otherwise get blended twice.
+	points := map[image.Point]bool{}
+	x, y, e := radius, 0, 1-radius
+	for x >= y {
+		for _, p := range [8]image.Point{{x, y}, {y, x}, {-y, x}, {-x, y}, {-x, -y}, {-y, -x}, {y, -x}, {x, -y}} {
+			points[center.Add(p)] = true
+		}
+
+		y++
+		if e < 0 {
+			e += 2*y + 1
+		} else {
+			x--
+			e += 2*(y-x) + 1
+		}
+	}
+
+	img := blendingImage{c.Image}
+	for p := range points {
+		img.Set(p.X, p.Y, col)
+	}
+}
+
+// Pixels whose centers are within the radius.
+func (c *Canvas) FillCircle(center image.Point, radius int, col color.Color) {
+	img := blendingImage{c.Image}
+	for dy := -radius; dy <= radius; dy++ {
+		for dx := -radius; dx <= radius; dx++ {
+			if dx*dx+dy*dy <= radius*radius {
+				img.Set(center.X+dx, center.Y+dy, col)
+			}
+		}
+	}
+}
+
+// Draws the image over the canvas with its top-left corner at the point given, blending it
+// according to its alpha.
+func (c *Canvas) Blit(src image.Image, at image.Point) {
+	bounds := src.Bounds()
+	draw.Draw(c.Image, bounds.Sub(bounds.Min).Add(at), src, bounds.Min, draw.Over)
+}
+
+// Text with its first line starting at the top-left corner given, with the built-in font when nil.
+func (c *Canvas) DrawText(font *Font, at image.Point, text string, col color.Color) {
+	if font == nil {
+		font = overlayFont
+	}
+	font.Draw(c.Image, at, text, col)
+}
+
+// Image whose pixels get blended over instead of replaced when set.
+type blendingImage struct {
+	draw.Image
+}
+
+func (b blendingImage) Set(x, y int, c color.Color) {
+	if !(image.Point{X: x, Y: y}).In(b.Bounds()) {
+		return
+	}
+
+	sr, sg, sb, sa := c.RGBA()
+	if sa == 0xffff {
+		b.Image.Set(x, y, c)
+		return
+	}
+
+	dr, dg, db, da := b.Image.At(x, y).RGBA()
+	a := 0xffff - sa
+	b.Image.Set(x, y, color.RGBA64{
+		R: uint16(sr + dr*a/0xffff),
+		G: uint16(sg + dg*a/0xffff),
+		B: uint16(sb + db*a/0xffff),
+		A: uint16(sa + da*a/0xffff),
+	})
+}
@@ -0,0 +1,111 @@
+package renderer
+
+// A vertex on its way through clipping, carrying everything that needs to be interpolated
+// when an edge gets cut.
+type clipVertex struct {
+	position Vertex4 // Clip space
+	vertex   Vertex3 // Model space
+	texture  Vertex2
+	normal   Vertex3
+	tangent  Vertex4
+	color    Vertex3
+}
+
+// Signed distance of a clip space position to a plane, positive on the inside.
+type clipPlane func(v Vertex4) float64
+
+// Depth grows towards the camera, so the near plane is at z = w.
+var nearPlanes = []clipPlane{
+	func(v Vertex4) float64 { return v.W - v.Z },
+	func(v Vertex4) float64 { return v.W - 1e-5 }, // Nothing ever goes through a division by zero.
+}
+
+var frustumPlanes = append([]clipPlane{
+	func(v Vertex4) float64 { return v.W + v.X },
+	func(v Vertex4) float64 { return v.W - v.X },
+	func(v Vertex4) float64 { return v.W + v.Y },
+	func(v Vertex4) float64 { return v.W - v.Y },
+	func(v Vertex4) float64 { return v.W + v.Z },
+}, nearPlanes...)
+
+// Planes to cull against, which beyond the ones clipped against include the sides of the
+// frustum: whatever is entirely past them would only be drawn off-screen.
+func cullingPlanes(clipping []clipPlane) []clipPlane {
+	return append(append([]clipPlane{}, frustumPlanes[:4]...), clipping...)
+}
+
+// Whether a polygon lies entirely outside of one of the planes.
+func allOutside(polygon []clipVertex, planes []clipPlane) bool {
+	for _, plane := range planes {
+		outside := true
+		for _, v := range polygon {
+			if plane(v.position) >= 0 {
+				outside = false
+				break
+			}
+		}
+		if outside {
+			return true
+		}
+	}
+	return false
+}
+
+// Sutherland–Hodgman: the polygon is cut by every plane in turn, keeping the inside part.
+// A triangle can come out with up to 3 + len(planes) vertices, or none at all.
+func clipPolygon(polygon []clipVertex, planes []clipPlane) []clipVertex {
+	if allInside(polygon, planes) {
+		return polygon
+	}
+
+	for _, plane := range planes {
+		if len(polygon) == 0 {
+			break
+		}
+
+		output := make([]clipVertex, 0, len(polygon)+1)
+
+		for i := range polygon {
+			current := polygon[i]
+			next := polygon[(i+1)%len(polygon)]
+
+			dc := plane(current.position)
+			dn := plane(next.position)
+
+			if dc >= 0 {
+				output = append(output, current)
+			}
+
+			// The edge crosses the plane, keep the intersection.
+			if (dc >= 0) != (dn >= 0) {
+				output = append(output, current.lerp(next, dc/(dc-dn)))
+			}
+		}
+
+		polygon = output
+	}
+
+	return polygon
+}
+
+func allInside(polygon []clipVertex, planes []clipPlane) bool {
+	for _, v := range polygon {
+		for _, plane := range planes {
+			if plane(v.position) < 0 {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func (v clipVertex) lerp(o clipVertex, t float64) clipVertex {
+	return clipVertex{
+		position: v.position.lerp(o.position, t),
+		vertex:   v.vertex.lerp(o.vertex, t),
+		texture:  v.texture.lerp(o.texture, t),
+		normal:   v.normal.lerp(o.normal, t),
+		tangent:  v.tangent.lerp(o.tangent, t),
+		color:    v.color.lerp(o.color, t),
+	}
+}
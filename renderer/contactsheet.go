@@ -0,0 +1,84 @@
+package renderer
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+)
+
+// Orthographic views of contact sheets, looking at the scene along their direction.
+var contactSheetViews = []struct {
+	label     string
+	direction Vertex3
+}{
+	{"Front", Vertex3{Z: -1}},
+	{"Back", Vertex3{Z: 1}},
+	{"Left", Vertex3{X: 1}},
+	{"Right", Vertex3{X: -1}},
+	{"Top", Vertex3{Y: -1}},
+	{"Bottom", Vertex3{Y: 1}},
+}
+
+// Renders the scene from the front, back, left, right, top and bottom with orthographic cameras,
+// then through the camera, in square cells of the given size over a grid of four by two, for asset
+// catalogs. The last cell holds the title, the triangle count and the size of the scene. The
+// size of the image in the options gets replaced by the one of the grid.
+//
+// Lights of the scene usually leave some sides in the dark, so orthographic views get lit from
+// the viewer instead, only the perspective one keeping them.
+func RenderContactSheet(scene *Scene, camera Camera, title string, cell int, options Options) (*image.RGBA, error) {
+	const columns = 4
+
+	cellRect := func(i int) image.Rectangle {
+		at := image.Point{X: i % columns * cell, Y: i / columns * cell}
+		return image.Rectangle{Min: at, Max: at.Add(image.Point{X: cell, Y: cell})}
+	}
+
+	options.Width, options.Height = columns*cell, 2*cell
+	img := newImage(options.rect())
+	for i, view := range contactSheetViews {
+		viewCamera := OrthographicCamera(scene, view.direction, 1)
+		viewport := []Viewport{{Rect: cellRect(i), Camera: viewCamera, Label: view.label}}
+		if err := renderViewports(img, headlitScene(scene, viewCamera), viewport, options); err != nil {
+			return nil, err
+		}
+	}
+	viewport := []Viewport{{Rect: cellRect(len(contactSheetViews)), Camera: camera, Label: "Perspective"}}
+	if err := renderViewports(img, scene, viewport, options); err != nil {
+		return nil, err
+	}
+
+	info := fmt.Sprintf("%s\n\n%d triangles", title, scene.faceCount())
+	if bounds, ok := scene.bounds(); ok {
+		size := bounds.Max.minus(bounds.Min)
+		info += fmt.Sprintf("\n%.4g x %.4g x %.4g", size.X, size.Y, size.Z)
+	}
+
+	rect := cellRect(len(contactSheetViews) + 1)
+	canvas := NewCanvas(img)
+	canvas.DrawRect(rect, viewportBorderColor)
+	canvas.DrawText(overlayFont, rect.Min.Add(image.Point{X: 8, Y: 8}), info, color.White)
+
+	return img, nil
+}
+
+// The meshes of the scene as they are drawn, without its lights but a light shining from over the
+// shoulder of the camera instead.
+func headlitScene(scene *Scene, camera Camera) *Scene {
+	lit := &Scene{Root: NewNode(scene.Root.Name), Ambient: scene.Ambient, Environment: scene.Environment}
+	scene.walkInstances(func(node *Node, mesh *Obj, world Matrix4, instance int) {
+		n := NewNode(node.Name)
+		n.Transform, n.Mesh, n.Material = world, mesh, node.Material
+		if instance < len(node.Instances) {
+			n.Instances = []Instance{{Transform: Identity4(), Color: node.Instances[instance].Color, Colored: node.Instances[instance].Colored}}
+		}
+		lit.Root.Add(n)
+	})
+
+	forward := camera.Target.minus(camera.Position).normalize(1.0)
+	headlight := NewNode("headlight")
+	headlight.Light = DirectionalLight{Direction: forward.minus(camera.Up.normalize(0.5)), Intensity: 1}
+	lit.Root.Add(headlight)
+
+	return lit
+}
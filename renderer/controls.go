@@ -0,0 +1,261 @@
+package renderer
+
+import (
+	"image"
+	"math"
+)
+
+type MouseButton int
+
+const (
+	MouseLeft MouseButton = iota
+	MouseMiddle
+	MouseRight
+)
+
+type Key int
+
+const (
+	KeyW Key = iota
+	KeyA
+	KeyS
+	KeyD
+	KeyQ
+	KeyE
+	KeyShift
+	KeyTab
+	KeyEscape
+	KeyF3
+	KeyG
+	KeyB
+	KeyX
+	KeyN
+	KeyV
+	KeyF11
+	KeyF12
+	KeyF1
+)
+
+// What window backends report user input to.
+type Input interface {
+	// The mouse moved from one point to another of the viewport with a button held down.
+	Drag(button MouseButton, from, to image.Point, viewport image.Rectangle)
+	// A button got pressed and released without the mouse moving in between.
+	Click(button MouseButton, at image.Point, viewport image.Rectangle)
+	Scroll(delta float64)
+	Key(key Key, pressed bool)
+}
+
+// Input also told about files dropped onto the window, by the backends supporting it, GLFW.
+type DropInput interface {
+	Input
+	// Paths of the files dropped.
+	Drop(files []string)
+}
+
+// Turns user input, as reported by a window backend, into camera movement.
+type Controller interface {
+	Input
+	Update(camera *Camera, dt float64)
+}
+
+// Arcball orbiting around a target with the left button, panning with the middle button
+// and zooming with the scroll wheel.
+type OrbitController struct {
+	Target   Vertex3
+	Distance float64
+
+	PanSpeed  float64
+	ZoomSpeed float64
+
+	// Columns are the camera's right, up and backward axes in world space.
+	orientation Matrix4
+}
+
+func NewOrbitController(camera Camera) *OrbitController {
+	z := camera.Position.minus(camera.Target).normalize(1.0)
+	x := camera.Up.cross(z).normalize(1.0)
+	y := z.cross(x)
+
+	orientation := Identity4()
+	orientation.m11, orientation.m21, orientation.m31 = x.X, x.Y, x.Z
+	orientation.m12, orientation.m22, orientation.m32 = y.X, y.Y, y.Z
+	orientation.m13, orientation.m23, orientation.m33 = z.X, z.Y, z.Z
+
+	return &OrbitController{
+		Target:      camera.Target,
+		Distance:    camera.Position.minus(camera.Target).length(),
+		PanSpeed:    1,
+		ZoomSpeed:   0.1,
+		orientation: orientation,
+	}
+}
+
+// Orbits around the target of the camera again, keeping the speeds.
+func (o *OrbitController) reset(camera Camera) {
+	pan, zoom := o.PanSpeed, o.ZoomSpeed
+	*o = *NewOrbitController(camera)
+	o.PanSpeed, o.ZoomSpeed = pan, zoom
+}
+
+func (o *OrbitController) Drag(button MouseButton, from, to image.Point, viewport image.Rectangle) {
+	switch button {
+	case MouseLeft:
+		o.orbit(arcballPoint(from, viewport), arcballPoint(to, viewport))
+
+	case MouseMiddle:
+		// Panning moves the target by as much as the mouse moved on the screen at the target's distance.
+		scale := o.PanSpeed * o.Distance / float64(viewport.Dy())
+		right := o.orientation.transformDirection(Vertex3{X: 1})
+		up := o.orientation.transformDirection(Vertex3{Y: 1})
+		o.Target = o.Target.minus(right.scale(float64(to.X-from.X) * scale))
+		o.Target = o.Target.plus(up.scale(float64(to.Y-from.Y) * scale))
+	}
+}
+
+func (o *OrbitController) Click(button MouseButton, at image.Point, viewport image.Rectangle) {}
+
+func (o *OrbitController) Scroll(delta float64) {
+	o.Distance *= math.Exp(-delta * o.ZoomSpeed)
+}
+
+func (o *OrbitController) Key(key Key, pressed bool) {}
+
+func (o *OrbitController) Update(camera *Camera, dt float64) {
+	camera.Target = o.Target
+	camera.Position = o.Target.plus(o.orientation.transformDirection(Vertex3{Z: o.Distance}))
+	camera.Up = o.orientation.transformDirection(Vertex3{Y: 1})
+}
+
+// Dragging on the arcball turns it around the axis perpendicular to both points, so the camera
+// has to turn the opposite way around the target for the model to follow the mouse.
+func (o *OrbitController) orbit(from, to Vertex3) {
+	axis := from.cross(to)
+	if axis.length() < 1e-9 {
+		return
+	}
+
+	angle := math.Acos(math.Max(-1, math.Min(1, from.dot(to))))
+	world := o.orientation.transformDirection(axis)
+	o.orientation = RotationMatrix(world, -angle).Multiply(o.orientation)
+}
+
+// Maps a point of the viewport onto a unit sphere in front of it, points outside of the sphere
+// sliding on its silhouette. Screen Y goes down, camera Y goes up.
+func arcballPoint(p image.Point, viewport image.Rectangle) Vertex3 {
+	size := float64(minInt(viewport.Dx(), viewport.Dy()))
+	x := (2*float64(p.X-viewport.Min.X) - float64(viewport.Dx())) / size
+	y := -(2*float64(p.Y-viewport.Min.Y) - float64(viewport.Dy())) / size
+
+	d := x*x + y*y
+	if d > 1 {
+		n := math.Sqrt(d)
+		return Vertex3{X: x / n, Y: y / n}
+	}
+
+	return Vertex3{X: x, Y: y, Z: math.Sqrt(1 - d)}
+}
+
+// First-person camera, looking around with the mouse and moving with WASD, Q and E going down and
+// up, and shift to go faster. Meant for walking through scene-scale models.
+type FlyController struct {
+	Position Vertex3
+	// Radians, zero looking down -Z.
+	Yaw   float64
+	Pitch float64
+
+	// World units per second.
+	Speed float64
+	// Radians per pixel of mouse movement.
+	Sensitivity float64
+	InvertY     bool
+
+	pressed map[Key]bool
+}
+
+func NewFlyController(camera Camera) *FlyController {
+	d := camera.Target.minus(camera.Position).normalize(1.0)
+
+	return &FlyController{
+		Position:    camera.Position,
+		Yaw:         math.Atan2(d.X, -d.Z),
+		Pitch:       math.Asin(math.Max(-1, math.Min(1, d.Y))),
+		Speed:       1,
+		Sensitivity: 0.005,
+		pressed:     map[Key]bool{},
+	}
+}
+
+// Flies from the camera again, keeping the speed and how the mouse moves it.
+func (f *FlyController) reset(camera Camera) {
+	speed, sensitivity, invert := f.Speed, f.Sensitivity, f.InvertY
+	*f = *NewFlyController(camera)
+	f.Speed, f.Sensitivity, f.InvertY = speed, sensitivity, invert
+}
+
+func (f *FlyController) Drag(button MouseButton, from, to image.Point, viewport image.Rectangle) {
+	dy := float64(to.Y - from.Y)
+	if f.InvertY {
+		dy = -dy
+	}
+
+	f.Yaw += float64(to.X-from.X) * f.Sensitivity
+	f.Pitch -= dy * f.Sensitivity
+
+	// Looking straight up or down would flip the camera over.
+	limit := math.Pi/2 - 0.01
+	f.Pitch = math.Max(-limit, math.Min(limit, f.Pitch))
+}
+
+func (f *FlyController) Click(button MouseButton, at image.Point, viewport image.Rectangle) {}
+
+// Scrolling adjusts the speed, as the scale of scenes varies wildly.
+func (f *FlyController) Scroll(delta float64) {
+	f.Speed *= math.Exp(delta * 0.1)
+}
+
+func (f *FlyController) Key(key Key, pressed bool) {
+	f.pressed[key] = pressed
+}
+
+func (f *FlyController) Update(camera *Camera, dt float64) {
+	forward := Vertex3{
+		X: math.Sin(f.Yaw) * math.Cos(f.Pitch),
+		Y: math.Sin(f.Pitch),
+		Z: -math.Cos(f.Yaw) * math.Cos(f.Pitch),
+	}
+	right := Vertex3{X: math.Cos(f.Yaw), Z: math.Sin(f.Yaw)}
+	up := Vertex3{Y: 1}
+
+	move := Vertex3{}
+	if f.pressed[KeyW] {
+		move = move.plus(forward)
+	}
+	if f.pressed[KeyS] {
+		move = move.minus(forward)
+	}
+	if f.pressed[KeyD] {
+		move = move.plus(right)
+	}
+	if f.pressed[KeyA] {
+		move = move.minus(right)
+	}
+	if f.pressed[KeyE] {
+		move = move.plus(up)
+	}
+	if f.pressed[KeyQ] {
+		move = move.minus(up)
+	}
+
+	if move.length() > 0 {
+		speed := f.Speed
+		if f.pressed[KeyShift] {
+			speed *= 4
+		}
+		f.Position = f.Position.plus(move.normalize(speed * dt))
+	}
+
+	camera.Position = f.Position
+	camera.Target = f.Position.plus(forward)
+	camera.Up = up
+}
@@ -0,0 +1,95 @@
+package renderer
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+)
+
+// Cells along each side of debug textures, like in the UV grids of modeling tools.
+const debugTextureCells = 8
+
+// Textures made up rather than loaded, for looking at how texture coordinates are laid out on
+// models, missing textures or not.
+type DebugTexture int
+
+const (
+	// Light and dark cells, each labeled with its column letter and row number, showing
+	// stretching, seams and mirrored or rotated islands.
+	CheckerTexture DebugTexture = iota
+	// U in red and V in green, from 0 to 1, with lines between the cells of the checker.
+	UVGradientTexture
+)
+
+func (t DebugTexture) String() string {
+	switch t {
+	case CheckerTexture:
+		return "checker"
+	case UVGradientTexture:
+		return "uv"
+	}
+	return fmt.Sprintf("DebugTexture(%d)", int(t))
+}
+
+// Size of the debug textures LoadTexture makes up, enough for labels to read well up close.
+const debugTextureSize = 1024
+
+func loadDebugTexture(name string) (image.Image, error) {
+	for _, t := range []DebugTexture{CheckerTexture, UVGradientTexture} {
+		if t.String() == name {
+			return NewDebugTexture(t, debugTextureSize), nil
+		}
+	}
+	return nil, errors.New(fmt.Sprintf("unknown debug texture %q", "@"+name))
+}
+
+// The texture, size pixels wide and high, laid out like loaded textures with V going up.
+func NewDebugTexture(t DebugTexture, size int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, size, size))
+	canvas := newFlippedCanvas(img)
+	cell := float64(size) / debugTextureCells
+
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			u, v := (float64(x)+0.5)/float64(size), (float64(y)+0.5)/float64(size)
+			column, row := int(u*debugTextureCells), int(v*debugTextureCells)
+
+			var c color.RGBA
+			switch t {
+			case UVGradientTexture:
+				c = toRGBA(Vertex3{X: u, Y: v, Z: 0.2})
+				// A line along the edges of every cell, a pixel or two wide.
+				width := math.Max(1, cell/64)
+				if math.Mod(float64(x), cell) < width || math.Mod(float64(y), cell) < width {
+					c = color.RGBA{R: 255, G: 255, B: 255, A: 255}
+				}
+			default:
+				c = color.RGBA{R: 200, G: 200, B: 200, A: 255}
+				if (column+row)%2 == 1 {
+					c = color.RGBA{R: 70, G: 70, B: 70, A: 255}
+				}
+			}
+			img.SetRGBA(x, y, c)
+		}
+	}
+
+	if t == CheckerTexture {
+		font := BitmapFont(int(math.Max(1, cell/4/bitmapGlyphHeight)))
+		for row := 0; row < debugTextureCells; row++ {
+			for column := 0; column < debugTextureCells; column++ {
+				label := fmt.Sprintf("%c%d", 'A'+column, row+1)
+				textColor := color.RGBA{R: 220, G: 60, B: 40, A: 255}
+				if (column+row)%2 == 1 {
+					textColor = color.RGBA{R: 250, G: 200, B: 60, A: 255}
+				}
+				// Top-left corner of the cell on the canvas, drawing downward with V going up.
+				at := image.Point{X: int(float64(column)*cell + cell/8), Y: size - int(float64(row+1)*cell) + int(cell/8)}
+				canvas.DrawText(font, at, label, textColor)
+			}
+		}
+	}
+
+	return img
+}
@@ -0,0 +1,21 @@
+package renderer
+
+import "testing"
+
+func TestDebugTexture(t *testing.T) {
+	img := NewDebugTexture(UVGradientTexture, 64)
+	// Texture coordinates start from the bottom, like in loaded textures.
+	if c := img.RGBAAt(61, 3); c.R < 200 || c.G > 50 {
+		t.Errorf("UV gradient is %v near u=1, v=0", c)
+	}
+	if c := img.RGBAAt(3, 61); c.R > 50 || c.G < 200 {
+		t.Errorf("UV gradient is %v near u=0, v=1", c)
+	}
+
+	if _, err := LoadTexture("@checker"); err != nil {
+		t.Error(err)
+	}
+	if _, err := LoadTexture("@unknown"); err == nil {
+		t.Error("expected an error for an unknown debug texture")
+	}
+}
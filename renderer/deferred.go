@@ -0,0 +1,140 @@
+package renderer
+
+import (
+	"image"
+	"runtime"
+	"sync"
+)
+
+// Nearest opaque surface of every pixel, as drawn by the geometry pass of deferred shading, for the
+// lighting pass to light it once. Depths stay in the z-buffer.
+type gBuffer struct {
+	width, height int
+	pixels        []gBufferPixel
+}
+
+type gBufferPixel struct {
+	// Nil where nothing got drawn.
+	material *Material
+	albedo   Vertex3
+	normal   Vertex3
+	// World space position, kept rather than recovered from the depth.
+	position Vertex3
+	uv       Vertex2
+	// Only set for metallic-roughness materials.
+	metallic, roughness float64
+}
+
+func newGBuffer(width, height int) *gBuffer {
+	return &gBuffer{width: width, height: height, pixels: make([]gBufferPixel, width*height)}
+}
+
+// Same as drawTriangle for opaque triangles, writing what lighting needs of their surface instead
+// of shading it.
+func (g *gBuffer) drawTriangle(fb *Framebuffer, triangle Triangle, zBuffer []float64, s shading, region image.Rectangle, raster triangleRasterizer) {
+	region = region.Intersect(image.Rect(0, 0, g.width, g.height))
+
+	min, max := triangle.pixelBounds()
+	min.X, min.Y = maxInt(min.X, region.Min.X), maxInt(min.Y, region.Min.Y)
+	max.X, max.Y = minInt(max.X, region.Max.X-1), minInt(max.Y, region.Max.Y-1)
+	if min.X > max.X || min.Y > max.Y {
+		return
+	}
+
+	face := triangle.face
+	material := triangle.material
+
+	raster.rasterize(triangle, min, max, func(x, y int, w1, w2, w3 float64) {
+		i := g.width*y + x
+		depth := w1*triangle.depths[0] + w2*triangle.depths[1] + w3*triangle.depths[2]
+		if s.depthEqual {
+			if zBuffer[i] != depth {
+				return
+			}
+		} else if zBuffer[i] >= depth {
+			return
+		}
+
+		p1, p2, p3 := w1*triangle.invW[0], w2*triangle.invW[1], w3*triangle.invW[2]
+		sum := p1 + p2 + p3
+		p1, p2, p3 = p1/sum, p2/sum, p3/sum
+
+		uv := Vertex2{
+			X: p1*face.Textures[0].X + p2*face.Textures[1].X + p3*face.Textures[2].X,
+			Y: p1*face.Textures[0].Y + p2*face.Textures[1].Y + p3*face.Textures[2].Y,
+		}
+		// Fragments discarded by alpha testing, or fully transparent, leave everything untouched
+		// like they do when shaded.
+		alpha := material.alpha(uv)
+		if material.AlphaCutoff > 0 {
+			if alpha < material.AlphaCutoff {
+				return
+			}
+		} else if toPremultipliedRGBA(Vertex3{}, alpha).A == 0 {
+			return
+		}
+
+		pixel := gBufferPixel{
+			material: material,
+			albedo:   material.Diffuse.multiply(surfaceTexel(material, face, uv, p1, p2, p3, s.vertexColors)),
+			normal:   surfaceNormal(material, face, uv, p1, p2, p3),
+			position: interpolatePosition(face, p1, p2, p3),
+			uv:       uv,
+		}
+		if material.Model == MetallicRoughness {
+			pixel.metallic, pixel.roughness = material.metallicRoughness(uv)
+		}
+
+		g.pixels[i] = pixel
+		zBuffer[i] = depth
+		fb.writeFragment(x, y, triangle, p1, p2, p3)
+	})
+}
+
+// Lights every pixel drawn, into the image and the HDR image when there's one, rows in parallel.
+func (g *gBuffer) light(fb *Framebuffer, s shading, workers int) {
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+
+	rows := make(chan int, g.height)
+	for y := 0; y < g.height; y++ {
+		rows <- y
+	}
+	close(rows)
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			shaded := 0
+			for y := range rows {
+				for x := 0; x < g.width; x++ {
+					p := g.pixels[y*g.width+x]
+					if p.material == nil {
+						continue
+					}
+
+					// Metallic-roughness parameters got sampled already.
+					var c Vertex3
+					if p.material.Model == MetallicRoughness && p.material.Shader == nil && !s.isToon(p.material) {
+						c = s.cookTorrance(p.material, p.albedo, p.metallic, p.roughness, p.normal, p.position)
+					} else {
+						c = s.light(p.material, p.albedo, p.uv, p.normal, p.position)
+					}
+					c = s.fogged(p.material, c.plus(p.material.emission(p.uv)), p.position)
+
+					fb.color.SetRGBA(x, y, s.encode(c, 1))
+					if fb.hdr != nil {
+						fb.hdr.set(x, y, c)
+					}
+					shaded++
+				}
+			}
+			s.stats.addFragments(shaded)
+		}()
+	}
+	wg.Wait()
+}
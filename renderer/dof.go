@@ -0,0 +1,95 @@
+package renderer
+
+import (
+	"image"
+	"math"
+)
+
+const (
+	// Most samples taken per pixel, the largest blurs get somewhat grainy.
+	dofMaxSamples = 64
+	// Blur in front of the focus distance can grow past the one at infinity, up to this many times.
+	dofMaxNearBlur = 2
+)
+
+// Thin lens depth of field: every pixel gets blurred over its circle of confusion, which grows
+// with the distance to the focus plane. Needs a depth buffer.
+type DepthOfFieldEffect struct {
+	// Distance from the camera which is in focus, in world units.
+	FocusDistance float64
+	// Radius of the blur of what's infinitely far, as a fraction of the height of the image. The
+	// wider the aperture, the shallower the depth of field.
+	Aperture float64
+}
+
+func NewDepthOfFieldEffect(focusDistance float64) *DepthOfFieldEffect {
+	return &DepthOfFieldEffect{FocusDistance: focusDistance, Aperture: 0.01}
+}
+
+func (d *DepthOfFieldEffect) Apply(frame *Frame) {
+	if frame.Depth != nil {
+		depthOfField(frame.Image, frame.Depth, frame.Camera, *d)
+	}
+}
+
+func depthOfField(img *image.RGBA, zBuffer []float64, camera Camera, options DepthOfFieldEffect) {
+	rect := img.Bounds()
+	width, height := rect.Dx(), rect.Dy()
+	maxRadius := options.Aperture * float64(height)
+
+	colors := make([]Vertex3, width*height)
+	alphas := make([]float64, width*height)
+	coc := make([]float64, width*height)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			i := y*width + x
+			c := img.RGBAAt(x, y)
+			colors[i], alphas[i] = decodeSRGB(c), float64(c.A)/255
+
+			d := camera.viewDistance(zBuffer[i])
+			switch {
+			case math.IsInf(d, 1):
+				coc[i] = maxRadius
+			case d > 0:
+				coc[i] = math.Min(maxRadius*math.Abs(d-options.FocusDistance)/d, maxRadius*dofMaxNearBlur)
+			}
+		}
+	}
+
+	goldenAngle := math.Pi * (3 - math.Sqrt(5))
+
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			i := y*width + x
+			radius := coc[i]
+			if radius < 0.5 {
+				continue
+			}
+
+			samples := minInt(dofMaxSamples, maxInt(8, int(radius*radius)))
+			sum, alpha, weight := colors[i], alphas[i], 1.0
+
+			// Spread evenly over the disc, along a sunflower spiral.
+			for k := 0; k < samples; k++ {
+				r := radius * math.Sqrt((float64(k)+0.5)/float64(samples))
+				angle := float64(k) * goldenAngle
+				sx := minInt(maxInt(x+int(math.Round(r*math.Cos(angle))), 0), width-1)
+				sy := minInt(maxInt(y+int(math.Round(r*math.Sin(angle))), 0), height-1)
+				j := sy*width + sx
+
+				// Sharper things in front don't bleed onto the blur behind them.
+				if zBuffer[j] > zBuffer[i] && coc[j] < r {
+					continue
+				}
+
+				sum = sum.plus(colors[j])
+				alpha += alphas[j]
+				weight++
+			}
+
+			c := toRGBA(linearToSRGB(sum.scale(1 / weight)))
+			c.A = uint8(alpha/weight*255 + 0.5)
+			img.SetRGBA(x, y, c)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package renderer
+
+import "math"
+
+// Light coming from every direction, as an equirectangular (latitude-longitude) map of linear
+// radiance, like a photographed studio or sky. Metallic-roughness materials get lit by it instead of
+// the flat ambient light, through maps precomputed on load.
+type Environment struct {
+	Radiance  *hdrImage
+	Intensity float64
+
+	// Diffuse light reaching surfaces facing each direction, already divided by π.
+	irradiance *hdrImage
+	// Radiance blurred by increasingly rough specular lobes, roughness going from 0 to 1.
+	specular []*hdrImage
+}
+
+func LoadEnvironment(filename string) (*Environment, error) {
+	radiance, err := loadHDRImage(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	return newEnvironment(radiance), nil
+}
+
+func newEnvironment(radiance *hdrImage) *Environment {
+	e := &Environment{Radiance: radiance, Intensity: 1}
+
+	// Diffuse lighting varies slowly, a low resolution does for both the source and the result.
+	e.irradiance = convolveEnvironment(radiance.downsample(64, 32), 32, 16, func(cosine float64) float64 {
+		return math.Max(cosine, 0)
+	})
+
+	// Mirror-like reflections use the radiance itself.
+	e.specular = []*hdrImage{radiance.downsample(512, 256)}
+
+	for _, roughness := range []float64{0.25, 0.5, 0.75, 1} {
+		source, width := radiance.downsample(64, 32), 32
+		if roughness < 0.5 {
+			source, width = radiance.downsample(128, 64), 64
+		}
+
+		// GGX distribution of the halfway vector, weighted by the cosine of the incoming light, with
+		// the view direction taken as the reflected direction (split-sum approximation).
+		a2 := math.Pow(roughness, 4)
+		e.specular = append(e.specular, convolveEnvironment(source, width, width/2, func(cosine float64) float64 {
+			if cosine <= 0 {
+				return 0
+			}
+			nDotH := math.Sqrt((1 + cosine) / 2)
+			d := nDotH*nDotH*(a2-1) + 1
+			return a2 / (d * d) * cosine
+		}))
+	}
+
+	return e
+}
+
+// For every direction of the output, the weighted average of the source radiance around it, the
+// kernel being a function of the cosine between both directions.
+func convolveEnvironment(source *hdrImage, width, height int, kernel func(cosine float64) float64) *hdrImage {
+	// Direction and solid angle of every source texel, texels shrinking towards the poles.
+	directions := make([]Vertex3, len(source.pixels))
+	solidAngles := make([]float64, len(source.pixels))
+	for y := 0; y < source.height; y++ {
+		for x := 0; x < source.width; x++ {
+			uv := Vertex2{X: (float64(x) + 0.5) / float64(source.width), Y: (float64(y) + 0.5) / float64(source.height)}
+			directions[y*source.width+x] = equirectangularDirection(uv)
+			solidAngles[y*source.width+x] = 2 * math.Pi * math.Pi / float64(source.width*source.height) * math.Sin(math.Pi*uv.Y)
+		}
+	}
+
+	result := newHDRImage(width, height)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			n := equirectangularDirection(Vertex2{X: (float64(x) + 0.5) / float64(width), Y: (float64(y) + 0.5) / float64(height)})
+
+			sum, weights := Vertex3{}, 0.0
+			for i, l := range directions {
+				w := kernel(n.dot(l)) * solidAngles[i]
+				if w > 0 {
+					sum = sum.plus(source.pixels[i].scale(w))
+					weights += w
+				}
+			}
+
+			if weights > 0 {
+				result.set(x, y, sum.scale(1/weights))
+			}
+		}
+	}
+
+	return result
+}
+
+// Equirectangular maps have +Y at their top and -Z at their center.
+func equirectangularUV(direction Vertex3) Vertex2 {
+	d := direction.normalize(1.0)
+	return Vertex2{
+		X: 0.5 + math.Atan2(d.X, -d.Z)/(2*math.Pi),
+		Y: math.Acos(math.Max(-1, math.Min(1, d.Y))) / math.Pi,
+	}
+}
+
+func equirectangularDirection(uv Vertex2) Vertex3 {
+	phi := (uv.X - 0.5) * 2 * math.Pi
+	theta := uv.Y * math.Pi
+	return Vertex3{X: math.Sin(theta) * math.Sin(phi), Y: math.Cos(theta), Z: -math.Sin(theta) * math.Cos(phi)}
+}
+
+// Ambient light of a metallic-roughness surface: diffuse irradiance plus prefiltered reflections,
+// scaled by an analytical fit of the environment BRDF (Karis, Unreal Engine 4 mobile) rather than
+// a lookup table.
+func (e *Environment) lighting(albedo, f0 Vertex3, metallic, roughness float64, normal, toEye Vertex3) Vertex3 {
+	nDotV := math.Max(normal.dot(toEye), 1e-4)
+	reflected := normal.scale(2 * nDotV).minus(toEye)
+
+	c0 := [4]float64{-1, -0.0275, -0.572, 0.022}
+	c1 := [4]float64{1, 0.0425, 1.04, -0.04}
+	var r [4]float64
+	for i := range r {
+		r[i] = roughness*c0[i] + c1[i]
+	}
+	a004 := math.Min(r[0]*r[0], math.Exp2(-9.28*nDotV))*r[0] + r[1]
+	scale, bias := a004*-1.04+r[2], a004*1.04+r[3]
+
+	specularColor := f0.scale(scale).plus(Vertex3{X: bias, Y: bias, Z: bias})
+	kd := Vertex3{X: 1, Y: 1, Z: 1}.minus(specularColor).scale(1 - metallic)
+
+	diffuse := kd.multiply(albedo).multiply(e.irradiance.sample(equirectangularUV(normal)))
+	specular := specularColor.multiply(e.reflection(reflected, roughness))
+
+	return diffuse.plus(specular).scale(e.Intensity)
+}
+
+// Prefiltered radiance coming from a direction, blending the two closest roughness levels.
+func (e *Environment) reflection(direction Vertex3, roughness float64) Vertex3 {
+	uv := equirectangularUV(direction)
+
+	level := roughness * float64(len(e.specular)-1)
+	lower := int(math.Floor(level))
+	if lower >= len(e.specular)-1 {
+		return e.specular[len(e.specular)-1].sample(uv)
+	}
+
+	return e.specular[lower].sample(uv).lerp(e.specular[lower+1].sample(uv), level-float64(lower))
+}
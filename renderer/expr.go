@@ -0,0 +1,299 @@
+package renderer
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"unicode"
+)
+
+// Arithmetic of the time t in seconds, like "2 * sin(t * pi)", for scripts animating scenes
+// without recompiling. Numbers, t and pi, + - * / % and ^ for powers, parentheses and the
+// functions of expressionFunctions are all there is.
+type Expression struct {
+	source string
+	eval   func(t float64) float64
+}
+
+// Math functions expressions can call, by name, along with how many arguments they take.
+var expressionFunctions = map[string]struct {
+	arity int
+	fn    func(args []float64) float64
+}{
+	"sin":   {1, func(a []float64) float64 { return math.Sin(a[0]) }},
+	"cos":   {1, func(a []float64) float64 { return math.Cos(a[0]) }},
+	"tan":   {1, func(a []float64) float64 { return math.Tan(a[0]) }},
+	"asin":  {1, func(a []float64) float64 { return math.Asin(a[0]) }},
+	"acos":  {1, func(a []float64) float64 { return math.Acos(a[0]) }},
+	"atan":  {1, func(a []float64) float64 { return math.Atan(a[0]) }},
+	"atan2": {2, func(a []float64) float64 { return math.Atan2(a[0], a[1]) }},
+	"abs":   {1, func(a []float64) float64 { return math.Abs(a[0]) }},
+	"sqrt":  {1, func(a []float64) float64 { return math.Sqrt(a[0]) }},
+	"exp":   {1, func(a []float64) float64 { return math.Exp(a[0]) }},
+	"log":   {1, func(a []float64) float64 { return math.Log(a[0]) }},
+	"floor": {1, func(a []float64) float64 { return math.Floor(a[0]) }},
+	"ceil":  {1, func(a []float64) float64 { return math.Ceil(a[0]) }},
+	"fract": {1, func(a []float64) float64 { return a[0] - math.Floor(a[0]) }},
+	"min":   {2, func(a []float64) float64 { return math.Min(a[0], a[1]) }},
+	"max":   {2, func(a []float64) float64 { return math.Max(a[0], a[1]) }},
+	"clamp": {3, func(a []float64) float64 { return math.Max(a[1], math.Min(a[2], a[0])) }},
+	// Linear interpolation from a to b, mix(a, b, x).
+	"mix": {3, func(a []float64) float64 { return a[0] + (a[1]-a[0])*a[2] }},
+	// 0 below the edge, 1 from it on, step(edge, x).
+	"step": {2, func(a []float64) float64 {
+		if a[1] < a[0] {
+			return 0
+		}
+		return 1
+	}},
+	// Eased from 0 to 1 between the edges, smoothstep(edge0, edge1, x).
+	"smoothstep": {3, func(a []float64) float64 {
+		x := math.Max(0, math.Min(1, (a[2]-a[0])/(a[1]-a[0])))
+		return x * x * (3 - 2*x)
+	}},
+}
+
+// Tells where the expression is wrong when it is.
+func ParseExpression(source string) (*Expression, error) {
+	p := &expressionParser{source: source}
+	p.next()
+
+	eval, err := p.sum()
+	if err == nil && p.token != "" {
+		err = p.errorf("unexpected %q", p.token)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &Expression{source: source, eval: eval}, nil
+}
+
+// Value at a time, in seconds.
+func (e *Expression) Eval(t float64) float64 {
+	return e.eval(t)
+}
+
+func (e *Expression) String() string {
+	return e.source
+}
+
+// Recursive descent, every rule compiling what it parsed into a closure.
+type expressionParser struct {
+	source string
+	// Current token, empty at the end, and where the next one starts.
+	token    string
+	position int
+	offset   int
+}
+
+// Reads the next token: a number, a name or a single character.
+func (p *expressionParser) next() {
+	for p.offset < len(p.source) && unicode.IsSpace(rune(p.source[p.offset])) {
+		p.offset++
+	}
+	p.position = p.offset
+	if p.offset == len(p.source) {
+		p.token = ""
+		return
+	}
+
+	c := rune(p.source[p.offset])
+	switch {
+	case unicode.IsDigit(c) || c == '.':
+		for p.offset < len(p.source) && (unicode.IsDigit(rune(p.source[p.offset])) || p.source[p.offset] == '.') {
+			p.offset++
+		}
+		// Exponents, like 1e-3.
+		if p.offset < len(p.source) && (p.source[p.offset] == 'e' || p.source[p.offset] == 'E') {
+			p.offset++
+			if p.offset < len(p.source) && (p.source[p.offset] == '-' || p.source[p.offset] == '+') {
+				p.offset++
+			}
+			for p.offset < len(p.source) && unicode.IsDigit(rune(p.source[p.offset])) {
+				p.offset++
+			}
+		}
+	case unicode.IsLetter(c) || c == '_':
+		for p.offset < len(p.source) && (unicode.IsLetter(rune(p.source[p.offset])) || unicode.IsDigit(rune(p.source[p.offset])) || p.source[p.offset] == '_') {
+			p.offset++
+		}
+	default:
+		p.offset++
+	}
+	p.token = p.source[p.position:p.offset]
+}
+
+func (p *expressionParser) errorf(format string, a ...interface{}) error {
+	return errors.New(fmt.Sprintf("%s at %d of expression %q", fmt.Sprintf(format, a...), p.position+1, p.source))
+}
+
+// Terms added or subtracted.
+func (p *expressionParser) sum() (func(t float64) float64, error) {
+	left, err := p.product()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.token == "+" || p.token == "-" {
+		op := p.token
+		p.next()
+		right, err := p.product()
+		if err != nil {
+			return nil, err
+		}
+
+		a, b := left, right
+		if op == "+" {
+			left = func(t float64) float64 { return a(t) + b(t) }
+		} else {
+			left = func(t float64) float64 { return a(t) - b(t) }
+		}
+	}
+
+	return left, nil
+}
+
+// Factors multiplied, divided, or the remainder of their division.
+func (p *expressionParser) product() (func(t float64) float64, error) {
+	left, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.token == "*" || p.token == "/" || p.token == "%" {
+		op := p.token
+		p.next()
+		right, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+
+		a, b := left, right
+		switch op {
+		case "*":
+			left = func(t float64) float64 { return a(t) * b(t) }
+		case "/":
+			left = func(t float64) float64 { return a(t) / b(t) }
+		default:
+			left = func(t float64) float64 { return math.Mod(a(t), b(t)) }
+		}
+	}
+
+	return left, nil
+}
+
+func (p *expressionParser) unary() (func(t float64) float64, error) {
+	if p.token == "-" || p.token == "+" {
+		negate := p.token == "-"
+		p.next()
+		operand, err := p.unary()
+		if err != nil || !negate {
+			return operand, err
+		}
+		return func(t float64) float64 { return -operand(t) }, nil
+	}
+
+	return p.power()
+}
+
+// Powers group from the right, like 2^3^2 being 2^9, and bind tighter than signs on their left,
+// like -2^2 being -4.
+func (p *expressionParser) power() (func(t float64) float64, error) {
+	base, err := p.primary()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.token != "^" {
+		return base, nil
+	}
+	p.next()
+	exponent, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	return func(t float64) float64 { return math.Pow(base(t), exponent(t)) }, nil
+}
+
+func (p *expressionParser) primary() (func(t float64) float64, error) {
+	token := p.token
+	switch {
+	case token == "":
+		return nil, p.errorf("unexpected end")
+
+	case token == "(":
+		p.next()
+		inner, err := p.sum()
+		if err != nil {
+			return nil, err
+		}
+		if p.token != ")" {
+			return nil, p.errorf("missing )")
+		}
+		p.next()
+		return inner, nil
+
+	case unicode.IsDigit(rune(token[0])) || token[0] == '.':
+		value, err := strconv.ParseFloat(token, 64)
+		if err != nil {
+			return nil, p.errorf("invalid number %q", token)
+		}
+		p.next()
+		return func(t float64) float64 { return value }, nil
+
+	case unicode.IsLetter(rune(token[0])) || token[0] == '_':
+		p.next()
+		if p.token == "(" {
+			return p.call(token)
+		}
+
+		switch token {
+		case "t":
+			return func(t float64) float64 { return t }, nil
+		case "pi":
+			return func(t float64) float64 { return math.Pi }, nil
+		}
+		return nil, p.errorf("unknown variable %q", token)
+	}
+
+	return nil, p.errorf("unexpected %q", token)
+}
+
+// Arguments of a function, between parentheses.
+func (p *expressionParser) call(name string) (func(t float64) float64, error) {
+	function, ok := expressionFunctions[name]
+	if !ok {
+		return nil, p.errorf("unknown function %q", name)
+	}
+	p.next()
+
+	var args []func(t float64) float64
+	for p.token != ")" {
+		if len(args) > 0 {
+			if p.token != "," {
+				return nil, p.errorf("expected , or ) in %s()", name)
+			}
+			p.next()
+		}
+		arg, err := p.sum()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+	}
+	p.next()
+
+	if len(args) != function.arity {
+		return nil, p.errorf("%s() takes %d arguments, not %d", name, function.arity, len(args))
+	}
+
+	return func(t float64) float64 {
+		values := make([]float64, len(args))
+		for i, arg := range args {
+			values[i] = arg(t)
+		}
+		return function.fn(values)
+	}, nil
+}
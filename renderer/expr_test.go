@@ -0,0 +1,36 @@
+package renderer
+
+import (
+	"math"
+	"testing"
+)
+
+func TestExpressions(t *testing.T) {
+	for source, want := range map[string]float64{
+		"1 + 2 * 3":             7,
+		"(1 + 2) * 3":           9,
+		"-2^2":                  -4,
+		"2^3^2":                 512,
+		"7 % 4":                 3,
+		"2 * t":                 3,
+		"max(t, 2) - min(1, t)": 1,
+		"clamp(t * 10, 0, 1)":   1,
+		"sin(pi / 2)":           1,
+		"1e-1 * 10":             1,
+	} {
+		e, err := ParseExpression(source)
+		if err != nil {
+			t.Errorf("%s: %v", source, err)
+			continue
+		}
+		if got := e.Eval(1.5); math.Abs(got-want) > 1e-9 {
+			t.Errorf("%s: got %v, want %v", source, got, want)
+		}
+	}
+
+	for _, source := range []string{"", "1 +", "(1", "x", "sin(1, 2)", "foo(1)", "1 2"} {
+		if _, err := ParseExpression(source); err == nil {
+			t.Errorf("%q: parsed", source)
+		}
+	}
+}
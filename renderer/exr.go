@@ -0,0 +1,102 @@
+package renderer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+	"os"
+)
+
+// Uncompressed OpenEXR, with 32-bit float R, G and B channels, one scanline per block. Row 0 of
+// the image is the top one.
+func encodeEXR(w io.Writer, h *hdrImage) error {
+	le := binary.LittleEndian
+
+	// Magic number, then version 2 for single-part scanline images.
+	header := &bytes.Buffer{}
+	header.Write([]byte{0x76, 0x2f, 0x31, 0x01, 2, 0, 0, 0})
+
+	attribute := func(name, kind string, value []byte) {
+		header.WriteString(name + "\x00" + kind + "\x00")
+		binary.Write(header, le, int32(len(value)))
+		header.Write(value)
+	}
+	ints := func(values ...int32) []byte {
+		b := make([]byte, 4*len(values))
+		for i, v := range values {
+			le.PutUint32(b[4*i:], uint32(v))
+		}
+		return b
+	}
+	floats := func(values ...float32) []byte {
+		b := make([]byte, 4*len(values))
+		for i, v := range values {
+			le.PutUint32(b[4*i:], math.Float32bits(v))
+		}
+		return b
+	}
+
+	// Channels have to be in alphabetical order: pixel type 2 for float, linear flag and padding,
+	// then sampling.
+	var channels []byte
+	for _, name := range []string{"B", "G", "R"} {
+		channels = append(channels, name+"\x00"...)
+		channels = append(channels, ints(2)...)
+		channels = append(channels, 0, 0, 0, 0)
+		channels = append(channels, ints(1, 1)...)
+	}
+	channels = append(channels, 0)
+
+	window := ints(0, 0, int32(h.width-1), int32(h.height-1))
+	attribute("channels", "chlist", channels)
+	attribute("compression", "compression", []byte{0})
+	attribute("dataWindow", "box2i", window)
+	attribute("displayWindow", "box2i", window)
+	attribute("lineOrder", "lineOrder", []byte{0})
+	attribute("pixelAspectRatio", "float", floats(1))
+	attribute("screenWindowCenter", "v2f", floats(0, 0))
+	attribute("screenWindowWidth", "float", floats(1))
+	header.WriteByte(0)
+
+	out := bufio.NewWriter(w)
+	out.Write(header.Bytes())
+
+	// Offsets of the scanlines from the start of the file, which come after these.
+	lineSize := 8 + 3*4*int64(h.width)
+	start := int64(header.Len()) + 8*int64(h.height)
+	for y := 0; y < h.height; y++ {
+		binary.Write(out, le, uint64(start+int64(y)*lineSize))
+	}
+
+	line := make([]byte, 3*4*h.width)
+	for y := 0; y < h.height; y++ {
+		binary.Write(out, le, int32(y))
+		binary.Write(out, le, int32(len(line)))
+
+		for x := 0; x < h.width; x++ {
+			c := h.at(x, y)
+			le.PutUint32(line[4*x:], math.Float32bits(float32(c.Z)))
+			le.PutUint32(line[4*(h.width+x):], math.Float32bits(float32(c.Y)))
+			le.PutUint32(line[4*(2*h.width+x):], math.Float32bits(float32(c.X)))
+		}
+		out.Write(line)
+	}
+
+	return out.Flush()
+}
+
+func saveEXR(h *hdrImage, filename string) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+
+	if err := encodeEXR(file, h); err != nil {
+		file.Close()
+		return err
+	}
+
+	return file.Close()
+}
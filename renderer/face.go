@@ -0,0 +1,78 @@
+package renderer
+
+import "image"
+
+type Face struct {
+	Vertices [3]Vertex3
+	Textures [3]Vertex2
+	Normals  [3]Vertex3
+	// Direction of growing U texture coordinates, with W telling the handedness of the bitangent.
+	Tangents [3]Vertex4
+	// RGB between 0 and 1, for models with vertex colors only.
+	Colors  [3]Vertex3
+	Colored bool
+	// Nil when the model doesn't specify one.
+	Material *Material
+}
+
+// The trick to Barycentric Coordinates is to find the weights for V1, V2, and V3 that balance the following system of equations:
+// Px = Wv1 * Xv1 + Wx2 * Xv2 + Wv3 * Xv3
+// Py = Wx1 * Yv1 + Wv2 * Yv2 + Wv3 * Yv3
+// Wv1 + Wv2 + Wv3 = 1
+// Basically, for a given point P, we're finding weights that tell us how much of P's X coordinate is made of V1, V2, and V3, and also the same for P's Y coordinate.
+// With a lot of re-arranging, we can solve Wv1, Wv2 and Wv3.
+// Another thing about barycentric coordinates, is that if P is actually outside of the triangle, then at least one of W1, W2, or W3 will be negative!
+//
+// Points are in fixed point, like the ones of triangles, while x and y are in pixels, usually at
+// the center of one. Signs of the weights are exact, and so are zeros right on edges, which the
+// fill rule relies on.
+func barycentricAt(x, y float64, p1, p2, p3 image.Point) (float64, float64, float64) {
+	p := Vertex3{X: x, Y: y}
+	v1 := Vertex3{X: float64(p1.X) / subpixelScale, Y: float64(p1.Y) / subpixelScale}
+	v2 := Vertex3{X: float64(p2.X) / subpixelScale, Y: float64(p2.Y) / subpixelScale}
+	v3 := Vertex3{X: float64(p3.X) / subpixelScale, Y: float64(p3.Y) / subpixelScale}
+
+	// With positions on a grid and not too far off-screen, these are computed without any rounding.
+	denominator := (v2.Y-v3.Y)*(v1.X-v3.X) + (v3.X-v2.X)*(v1.Y-v3.Y)
+	weightV1 := (v2.Y-v3.Y)*(p.X-v3.X) + (v3.X-v2.X)*(p.Y-v3.Y)
+	weightV2 := (v3.Y-v1.Y)*(p.X-v3.X) + (v1.X-v3.X)*(p.Y-v3.Y)
+	weightV3 := denominator - weightV1 - weightV2
+
+	return weightV1 / denominator, weightV2 / denominator, weightV3 / denominator
+}
+
+func faceArea(face Face) float64 {
+	e1 := face.Vertices[1].minus(face.Vertices[0])
+	e2 := face.Vertices[2].minus(face.Vertices[0])
+	return e1.cross(e2).length() / 2
+}
+
+func faceNormal(face Face) Vertex3 {
+	e1 := face.Vertices[1].minus(face.Vertices[0])
+	e2 := face.Vertices[2].minus(face.Vertices[0])
+	return e1.cross(e2).normalize(1.0)
+}
+
+func (f Face) transform(m Matrix4, normalMatrix Matrix4) Face {
+	for i := 0; i < 3; i++ {
+		f.Vertices[i] = m.transformPoint(f.Vertices[i])
+		f.Normals[i] = normalMatrix.transformDirection(f.Normals[i]).normalize(1.0)
+		f.Tangents[i] = transformTangent(m, f.Tangents[i])
+	}
+	return f
+}
+
+func interpolatePosition(f Face, w1, w2, w3 float64) Vertex3 {
+	return f.Vertices[0].scale(w1).plus(f.Vertices[1].scale(w2)).plus(f.Vertices[2].scale(w3))
+}
+
+// Interpolated from the normals of the vertices based on barycentric weights, then normalized.
+func interpolateNormal(f Face, w1, w2, w3 float64) Vertex3 {
+	return f.Normals[0].scale(w1).plus(f.Normals[1].scale(w2)).plus(f.Normals[2].scale(w3)).normalize(1.0)
+}
+
+// Tangents lie on the surface, so they transform like positions rather than like normals.
+func transformTangent(m Matrix4, t Vertex4) Vertex4 {
+	v := m.transformDirection(Vertex3{X: t.X, Y: t.Y, Z: t.Z})
+	return Vertex4{X: v.X, Y: v.Y, Z: v.Z, W: t.W}
+}
@@ -0,0 +1,79 @@
+package renderer
+
+import (
+	"fmt"
+	"math"
+)
+
+// How fog thickens with the distance from the camera.
+type FogMode int
+
+const (
+	// From none at the start distance to full at the end one, easy to tune for a scene of known
+	// size.
+	LinearFog FogMode = iota
+	// Thickening steadily with the distance, like in real air, never completely hiding anything.
+	ExponentialFog
+	// Like exponential fog, but staying clear longer before thickening quickly.
+	ExponentialSquaredFog
+)
+
+func (m FogMode) String() string {
+	switch m {
+	case LinearFog:
+		return "linear"
+	case ExponentialFog:
+		return "exponential"
+	case ExponentialSquaredFog:
+		return "exponential2"
+	}
+	return fmt.Sprintf("FogMode(%d)", int(m))
+}
+
+// Haze between the camera and surfaces, fading them into its color with distance, conveying
+// depth in large scenes. The background isn't fogged, setting the clear color to the fog's hides
+// the horizon.
+type Fog struct {
+	Mode FogMode
+	// Linear light, like the lights'.
+	Color Vertex3
+	// Distances from the camera, in world units, where linear fog starts and becomes opaque.
+	Start, End float64
+	// Thickness of exponential fog, per world unit.
+	Density float64
+}
+
+// Fraction of the light replaced by the fog's at the distance from the camera, between 0 and 1.
+func (f *Fog) amount(distance float64) float64 {
+	var amount float64
+	switch f.Mode {
+	case ExponentialFog:
+		amount = 1 - math.Exp(-f.Density*distance)
+	case ExponentialSquaredFog:
+		d := f.Density * distance
+		amount = 1 - math.Exp(-d*d)
+	default:
+		if f.End <= f.Start {
+			if distance < f.Start {
+				return 0
+			}
+			return 1
+		}
+		amount = (distance - f.Start) / (f.End - f.Start)
+	}
+	return math.Max(0, math.Min(1, amount))
+}
+
+// Color of a surface at the position seen through the fog. Additive surfaces fade out instead,
+// the fog being already there behind them.
+func (s shading) fogged(material *Material, c, position Vertex3) Vertex3 {
+	if s.fog == nil {
+		return c
+	}
+
+	amount := s.fog.amount(position.minus(s.eye).length())
+	if material.Blend == AdditiveBlend {
+		return c.scale(1 - amount)
+	}
+	return c.lerp(s.fog.Color, amount)
+}
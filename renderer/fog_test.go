@@ -0,0 +1,38 @@
+package renderer
+
+import (
+	"math"
+	"testing"
+)
+
+func TestFog(t *testing.T) {
+	for _, test := range []struct {
+		fog      Fog
+		distance float64
+		want     float64
+	}{
+		{Fog{Mode: LinearFog, Start: 2, End: 6}, 1, 0},
+		{Fog{Mode: LinearFog, Start: 2, End: 6}, 3, 0.25},
+		{Fog{Mode: LinearFog, Start: 2, End: 6}, 10, 1},
+		{Fog{Mode: ExponentialFog, Density: 0.5}, 2, 1 - math.Exp(-1)},
+		{Fog{Mode: ExponentialSquaredFog, Density: 0.5}, 4, 1 - math.Exp(-4)},
+	} {
+		if amount := test.fog.amount(test.distance); math.Abs(amount-test.want) > 1e-9 {
+			t.Errorf("%v fog at %g: %g, expected %g", test.fog.Mode, test.distance, amount, test.want)
+		}
+	}
+
+	// Surfaces past the end of the fog take its color.
+	scene, _, camera, options := sphereTestScene()
+	scene.Fog = &Fog{Mode: LinearFog, Color: Vertex3{X: 1}, Start: 0, End: 0.1}
+	for _, deferred := range []bool{false, true} {
+		options.Deferred = deferred
+		img, err := Render(scene, camera, options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c := img.RGBAAt(16, 16); c.R == 0 || c.G != 0 || c.B != 0 {
+			t.Errorf("fogged surface %v, expected red (deferred %v)", c, deferred)
+		}
+	}
+}
@@ -0,0 +1,249 @@
+package renderer
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+)
+
+// Glyphs baked into an atlas of coverage masks, for drawing labels and annotations into images.
+type Font struct {
+	atlas  *image.Alpha
+	glyphs map[rune]fontGlyph
+	// Pixels from the top of a line to its baseline, from the baseline to the bottom, and from one
+	// baseline to the next.
+	ascent, descent, lineHeight int
+}
+
+type fontGlyph struct {
+	// Area of the atlas the glyph takes.
+	bounds image.Rectangle
+	// From the pen, on the baseline, to the top-left corner of the glyph.
+	offset  image.Point
+	advance int
+}
+
+const (
+	bitmapGlyphWidth  = 5
+	bitmapGlyphHeight = 7
+)
+
+// Built-in bitmap font covering printable ASCII, from the space onward. Every glyph is 5 columns
+// of 7 pixels, the lowest bit being the top one.
+var bitmapGlyphs = [95][bitmapGlyphWidth]uint8{
+	{0x00, 0x00, 0x00, 0x00, 0x00}, // ' '
+	{0x00, 0x00, 0x5f, 0x00, 0x00}, // !
+	{0x00, 0x07, 0x00, 0x07, 0x00}, // "
+	{0x14, 0x7f, 0x14, 0x7f, 0x14}, // #
+	{0x24, 0x2a, 0x7f, 0x2a, 0x12}, // $
+	{0x23, 0x13, 0x08, 0x64, 0x62}, // %
+	{0x36, 0x49, 0x55, 0x22, 0x50}, // &
+	{0x00, 0x05, 0x03, 0x00, 0x00}, // '
+	{0x00, 0x1c, 0x22, 0x41, 0x00}, // (
+	{0x00, 0x41, 0x22, 0x1c, 0x00}, // )
+	{0x08, 0x2a, 0x1c, 0x2a, 0x08}, // *
+	{0x08, 0x08, 0x3e, 0x08, 0x08}, // +
+	{0x00, 0x50, 0x30, 0x00, 0x00}, // ,
+	{0x08, 0x08, 0x08, 0x08, 0x08}, // -
+	{0x00, 0x60, 0x60, 0x00, 0x00}, // .
+	{0x20, 0x10, 0x08, 0x04, 0x02}, // /
+	{0x3e, 0x51, 0x49, 0x45, 0x3e}, // 0
+	{0x00, 0x42, 0x7f, 0x40, 0x00}, // 1
+	{0x42, 0x61, 0x51, 0x49, 0x46}, // 2
+	{0x21, 0x41, 0x45, 0x4b, 0x31}, // 3
+	{0x18, 0x14, 0x12, 0x7f, 0x10}, // 4
+	{0x27, 0x45, 0x45, 0x45, 0x39}, // 5
+	{0x3c, 0x4a, 0x49, 0x49, 0x30}, // 6
+	{0x01, 0x71, 0x09, 0x05, 0x03}, // 7
+	{0x36, 0x49, 0x49, 0x49, 0x36}, // 8
+	{0x06, 0x49, 0x49, 0x29, 0x1e}, // 9
+	{0x00, 0x36, 0x36, 0x00, 0x00}, // :
+	{0x00, 0x56, 0x36, 0x00, 0x00}, // ;
+	{0x08, 0x14, 0x22, 0x41, 0x00}, // <
+	{0x14, 0x14, 0x14, 0x14, 0x14}, // =
+	{0x00, 0x41, 0x22, 0x14, 0x08}, // >
+	{0x02, 0x01, 0x51, 0x09, 0x06}, // ?
+	{0x32, 0x49, 0x79, 0x41, 0x3e}, // @
+	{0x7e, 0x11, 0x11, 0x11, 0x7e}, // A
+	{0x7f, 0x49, 0x49, 0x49, 0x36}, // B
+	{0x3e, 0x41, 0x41, 0x41, 0x22}, // C
+	{0x7f, 0x41, 0x41, 0x22, 0x1c}, // D
+	{0x7f, 0x49, 0x49, 0x49, 0x41}, // E
+	{0x7f, 0x09, 0x09, 0x09, 0x01}, // F
+	{0x3e, 0x41, 0x49, 0x49, 0x7a}, // G
+	{0x7f, 0x08, 0x08, 0x08, 0x7f}, // H
+	{0x00, 0x41, 0x7f, 0x41, 0x00}, // I
+	{0x20, 0x40, 0x41, 0x3f, 0x01}, // J
+	{0x7f, 0x08, 0x14, 0x22, 0x41}, // K
+	{0x7f, 0x40, 0x40, 0x40, 0x40}, // L
+	{0x7f, 0x02, 0x0c, 0x02, 0x7f}, // M
+	{0x7f, 0x04, 0x08, 0x10, 0x7f}, // N
+	{0x3e, 0x41, 0x41, 0x41, 0x3e}, // O
+	{0x7f, 0x09, 0x09, 0x09, 0x06}, // P
+	{0x3e, 0x41, 0x51, 0x21, 0x5e}, // Q
+	{0x7f, 0x09, 0x19, 0x29, 0x46}, // R
+	{0x46, 0x49, 0x49, 0x49, 0x31}, // S
+	{0x01, 0x01, 0x7f, 0x01, 0x01}, // T
+	{0x3f, 0x40, 0x40, 0x40, 0x3f}, // U
+	{0x1f, 0x20, 0x40, 0x20, 0x1f}, // V
+	{0x3f, 0x40, 0x38, 0x40, 0x3f}, // W
+	{0x63, 0x14, 0x08, 0x14, 0x63}, // X
+	{0x07, 0x08, 0x70, 0x08, 0x07}, // Y
+	{0x61, 0x51, 0x49, 0x45, 0x43}, // Z
+	{0x00, 0x7f, 0x41, 0x41, 0x00}, // [
+	{0x02, 0x04, 0x08, 0x10, 0x20}, // \
+	{0x00, 0x41, 0x41, 0x7f, 0x00}, // ]
+	{0x04, 0x02, 0x01, 0x02, 0x04}, // ^
+	{0x40, 0x40, 0x40, 0x40, 0x40}, // _
+	{0x00, 0x01, 0x02, 0x04, 0x00}, // `
+	{0x20, 0x54, 0x54, 0x54, 0x78}, // a
+	{0x7f, 0x48, 0x44, 0x44, 0x38}, // b
+	{0x38, 0x44, 0x44, 0x44, 0x20}, // c
+	{0x38, 0x44, 0x44, 0x48, 0x7f}, // d
+	{0x38, 0x54, 0x54, 0x54, 0x18}, // e
+	{0x08, 0x7e, 0x09, 0x01, 0x02}, // f
+	{0x0c, 0x52, 0x52, 0x52, 0x3e}, // g
+	{0x7f, 0x08, 0x04, 0x04, 0x78}, // h
+	{0x00, 0x44, 0x7d, 0x40, 0x00}, // i
+	{0x20, 0x40, 0x44, 0x3d, 0x00}, // j
+	{0x7f, 0x10, 0x28, 0x44, 0x00}, // k
+	{0x00, 0x41, 0x7f, 0x40, 0x00}, // l
+	{0x7c, 0x04, 0x18, 0x04, 0x78}, // m
+	{0x7c, 0x08, 0x04, 0x04, 0x78}, // n
+	{0x38, 0x44, 0x44, 0x44, 0x38}, // o
+	{0x7c, 0x14, 0x14, 0x14, 0x08}, // p
+	{0x08, 0x14, 0x14, 0x18, 0x7c}, // q
+	{0x7c, 0x08, 0x04, 0x04, 0x08}, // r
+	{0x48, 0x54, 0x54, 0x54, 0x20}, // s
+	{0x04, 0x3f, 0x44, 0x40, 0x20}, // t
+	{0x3c, 0x40, 0x40, 0x20, 0x7c}, // u
+	{0x1c, 0x20, 0x40, 0x20, 0x1c}, // v
+	{0x3c, 0x40, 0x30, 0x40, 0x3c}, // w
+	{0x44, 0x28, 0x10, 0x28, 0x44}, // x
+	{0x0c, 0x50, 0x50, 0x50, 0x3c}, // y
+	{0x44, 0x64, 0x54, 0x4c, 0x44}, // z
+	{0x00, 0x08, 0x36, 0x41, 0x00}, // {
+	{0x00, 0x00, 0x7f, 0x00, 0x00}, // |
+	{0x00, 0x41, 0x36, 0x08, 0x00}, // }
+	{0x08, 0x04, 0x08, 0x10, 0x08}, // ~
+}
+
+// Loads a TrueType or OpenType font file, baking its printable ASCII glyphs at a size in pixels.
+// Set by the file of the truetype build tag, as it needs a library the renderer doesn't otherwise
+// depend on.
+var loadFont func(filename string, size float64) (*Font, error)
+
+func LoadFont(filename string, size float64) (*Font, error) {
+	if loadFont == nil {
+		return nil, errors.New("built without TrueType fonts, rebuild with -tags truetype")
+	}
+	return loadFont(filename, size)
+}
+
+// The built-in font, 5 by 7 pixels, every one of them drawn as a square of scale pixels.
+func BitmapFont(scale int) *Font {
+	if scale < 1 {
+		scale = 1
+	}
+
+	width, height := bitmapGlyphWidth*scale, bitmapGlyphHeight*scale
+	f := &Font{
+		atlas:      image.NewAlpha(image.Rect(0, 0, len(bitmapGlyphs)*width, height)),
+		glyphs:     map[rune]fontGlyph{},
+		ascent:     height,
+		lineHeight: (bitmapGlyphHeight + 2) * scale,
+	}
+
+	for i, columns := range bitmapGlyphs {
+		bounds := image.Rect(i*width, 0, (i+1)*width, height)
+		for column, bits := range columns {
+			for row := 0; row < bitmapGlyphHeight; row++ {
+				if bits&(1<<uint(row)) == 0 {
+					continue
+				}
+				pixel := image.Rect(column*scale, row*scale, (column+1)*scale, (row+1)*scale).Add(bounds.Min)
+				draw.Draw(f.atlas, pixel, image.Opaque, image.Point{}, draw.Src)
+			}
+		}
+
+		f.glyphs[rune(' '+i)] = fontGlyph{
+			bounds:  bounds,
+			offset:  image.Point{Y: -height},
+			advance: (bitmapGlyphWidth + 1) * scale,
+		}
+	}
+
+	return f
+}
+
+// Font of the debug overlay.
+var overlayFont = BitmapFont(1)
+
+// Draws text over the image, its first line starting at the top-left corner given. Lines break on
+// newlines, and characters the font doesn't have show as question marks.
+func (f *Font) Draw(dst draw.Image, at image.Point, text string, c color.Color) {
+	src := image.NewUniform(c)
+	pen := image.Point{X: at.X, Y: at.Y + f.ascent}
+
+	for _, r := range text {
+		if r == '\n' {
+			pen = image.Point{X: at.X, Y: pen.Y + f.lineHeight}
+			continue
+		}
+
+		g := f.glyph(r)
+		area := g.bounds.Sub(g.bounds.Min).Add(pen.Add(g.offset))
+		draw.DrawMask(dst, area, src, image.Point{}, f.atlas, g.bounds.Min, draw.Over)
+		pen.X += g.advance
+	}
+}
+
+// Width and height in pixels of text drawn with the font.
+func (f *Font) Measure(text string) image.Point {
+	width, longest, lines := 0, 0, 1
+	for _, r := range text {
+		if r == '\n' {
+			width = 0
+			lines++
+			continue
+		}
+		width += f.glyph(r).advance
+		longest = maxInt(longest, width)
+	}
+
+	return image.Point{X: longest, Y: (lines-1)*f.lineHeight + f.ascent + f.descent}
+}
+
+func (f *Font) glyph(r rune) fontGlyph {
+	if g, ok := f.glyphs[r]; ok {
+		return g
+	}
+	return f.glyphs['?']
+}
+
+// Rendered images seen the right way up, for drawing into them with Y going down.
+type flippedImage struct {
+	img *image.RGBA
+}
+
+func (f flippedImage) ColorModel() color.Model {
+	return f.img.ColorModel()
+}
+
+func (f flippedImage) Bounds() image.Rectangle {
+	return f.img.Bounds()
+}
+
+func (f flippedImage) At(x, y int) color.Color {
+	return f.img.At(x, f.flip(y))
+}
+
+func (f flippedImage) Set(x, y int, c color.Color) {
+	f.img.Set(x, f.flip(y), c)
+}
+
+func (f flippedImage) flip(y int) int {
+	rect := f.img.Bounds()
+	return rect.Min.Y + rect.Max.Y - 1 - y
+}
@@ -0,0 +1,69 @@
+//go:build truetype
+
+package renderer
+
+import (
+	"image"
+	"image/draw"
+	"os"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+)
+
+func init() {
+	loadFont = loadTrueTypeFont
+}
+
+// Rasterizes the glyphs once, drawing text then being a matter of copying them around.
+func loadTrueTypeFont(filename string, size float64) (*Font, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	parsed, err := opentype.Parse(data)
+	if err != nil {
+		return nil, err
+	}
+
+	face, err := opentype.NewFace(parsed, &opentype.FaceOptions{Size: size, DPI: 72, Hinting: font.HintingFull})
+	if err != nil {
+		return nil, err
+	}
+	defer face.Close()
+
+	metrics := face.Metrics()
+	f := &Font{
+		glyphs:     map[rune]fontGlyph{},
+		ascent:     metrics.Ascent.Ceil(),
+		descent:    metrics.Descent.Ceil(),
+		lineHeight: metrics.Height.Ceil(),
+	}
+
+	// Faces reuse the same mask for every glyph, so the atlas gets sized first and filled after.
+	width, height := 0, 0
+	for r := ' '; r <= '~'; r++ {
+		if dr, _, _, _, ok := face.Glyph(fixed.Point26_6{}, r); ok {
+			width += dr.Dx() + 1
+			height = maxInt(height, dr.Dy())
+		}
+	}
+
+	f.atlas = image.NewAlpha(image.Rect(0, 0, maxInt(width, 1), maxInt(height, 1)))
+	x := 0
+	for r := ' '; r <= '~'; r++ {
+		dr, mask, maskp, advance, ok := face.Glyph(fixed.Point26_6{}, r)
+		if !ok {
+			continue
+		}
+
+		bounds := image.Rect(x, 0, x+dr.Dx(), dr.Dy())
+		draw.Draw(f.atlas, bounds, mask, maskp, draw.Src)
+		f.glyphs[r] = fontGlyph{bounds: bounds, offset: dr.Min, advance: advance.Round()}
+		x += dr.Dx() + 1
+	}
+
+	return f, nil
+}
@@ -0,0 +1,276 @@
+package renderer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+)
+
+// Buffers of a framebuffer, besides the color one which is always there.
+type Attachment int
+
+const (
+	ColorAttachment Attachment = iota
+	// Linear colors before tone mapping, unclamped.
+	HDRAttachment
+	// Depth between 0 for the far plane and 255 for the near one, like ViewDepth shows it,
+	// negative infinity where nothing got drawn.
+	DepthAttachment
+	// World space normals of the surfaces, zero where nothing got drawn.
+	NormalAttachment
+	// Which mesh every pixel shows, numbered from 1 in the order of the scene graph, children
+	// after their parent and every instance counting as a mesh, 0 where nothing got drawn.
+	ObjectIDAttachment
+)
+
+func (a Attachment) String() string {
+	switch a {
+	case ColorAttachment:
+		return "color"
+	case HDRAttachment:
+		return "hdr"
+	case DepthAttachment:
+		return "depth"
+	case NormalAttachment:
+		return "normals"
+	case ObjectIDAttachment:
+		return "ids"
+	}
+	return fmt.Sprintf("Attachment(%d)", int(a))
+}
+
+// Everything a frame gets rendered into: colors, and whichever other buffers are attached, one
+// value per pixel each, for techniques needing more than colors or for inspecting them. Buffers
+// stay attached from a frame to the next, reused when the size doesn't change.
+//
+// Like images drawn internally, rows go from the bottom up.
+type Framebuffer struct {
+	width  int
+	height int
+
+	color   *image.RGBA
+	hdr     *hdrImage
+	depth   []float64
+	normals []Vertex3
+	objects []int
+}
+
+func NewFramebuffer(width, height int) *Framebuffer {
+	return &Framebuffer{width: width, height: height, color: newImage(image.Rect(0, 0, width, height))}
+}
+
+// Framebuffer drawing into the image, and into the HDR image when there's one.
+func wrapFramebuffer(img *image.RGBA, hdr *hdrImage) *Framebuffer {
+	return &Framebuffer{width: img.Bounds().Dx(), height: img.Bounds().Dy(), color: img, hdr: hdr}
+}
+
+func (f *Framebuffer) Size() image.Point {
+	return image.Point{X: f.width, Y: f.height}
+}
+
+// Adds the buffer, cleared, unless it's attached already.
+func (f *Framebuffer) Attach(a Attachment) {
+	if f.Attached(a) {
+		return
+	}
+
+	n := f.width * f.height
+	switch a {
+	case HDRAttachment:
+		f.hdr = newHDRImage(f.width, f.height)
+	case DepthAttachment:
+		f.depth = make([]float64, n)
+		fillFloat64s(f.depth, math.Inf(-1))
+	case NormalAttachment:
+		f.normals = make([]Vertex3, n)
+	case ObjectIDAttachment:
+		f.objects = make([]int, n)
+	}
+}
+
+// Removes the buffer, which doesn't get drawn anymore. The color one stays.
+func (f *Framebuffer) Detach(a Attachment) {
+	switch a {
+	case HDRAttachment:
+		f.hdr = nil
+	case DepthAttachment:
+		f.depth = nil
+	case NormalAttachment:
+		f.normals = nil
+	case ObjectIDAttachment:
+		f.objects = nil
+	}
+}
+
+func (f *Framebuffer) Attached(a Attachment) bool {
+	switch a {
+	case ColorAttachment:
+		return true
+	case HDRAttachment:
+		return f.hdr != nil
+	case DepthAttachment:
+		return f.depth != nil
+	case NormalAttachment:
+		return f.normals != nil
+	case ObjectIDAttachment:
+		return f.objects != nil
+	}
+	return false
+}
+
+// Depth of the pixel, counting rows from the bottom, or negative infinity without a depth buffer.
+func (f *Framebuffer) Depth(x, y int) float64 {
+	if f.depth == nil {
+		return math.Inf(-1)
+	}
+	return f.depth[y*f.width+x]
+}
+
+// World space normal of the pixel, counting rows from the bottom, zero without a normal buffer.
+func (f *Framebuffer) Normal(x, y int) Vertex3 {
+	if f.normals == nil {
+		return Vertex3{}
+	}
+	return f.normals[y*f.width+x]
+}
+
+// Mesh the pixel shows, counting rows from the bottom, 0 without an object ID buffer.
+func (f *Framebuffer) ObjectID(x, y int) int {
+	if f.objects == nil {
+		return 0
+	}
+	return f.objects[y*f.width+x]
+}
+
+// The buffer as an image, top row first, for looking at it. Depth shows as shades of gray,
+// normals like ViewNormals does and every object ID with a color of its own.
+func (f *Framebuffer) Image(a Attachment) (*image.RGBA, error) {
+	if !f.Attached(a) {
+		return nil, errors.New(fmt.Sprintf("no %s buffer attached", a))
+	}
+
+	rect := image.Rect(0, 0, f.width, f.height)
+	if a == ColorAttachment {
+		return flipImageVertically(rect, f.color), nil
+	}
+
+	img := newImage(rect)
+	for y := 0; y < f.height; y++ {
+		for x := 0; x < f.width; x++ {
+			i := y*f.width + x
+			var c color.RGBA
+
+			switch a {
+			case HDRAttachment:
+				c = toRGBA(linearToSRGB(f.hdr.pixels[i]))
+			case DepthAttachment:
+				d := uint8(math.Max(0, math.Min(255, f.depth[i])))
+				c = color.RGBA{R: d, G: d, B: d, A: 255}
+			case NormalAttachment:
+				c = toRGBA(encodeDirection(f.normals[i]))
+			case ObjectIDAttachment:
+				c = objectColor(f.objects[i])
+			}
+
+			img.SetRGBA(x, f.height-1-y, c)
+		}
+	}
+
+	return img, nil
+}
+
+// Saves the buffer as an image file, like SaveImage.
+func (f *Framebuffer) Save(a Attachment, filename string) error {
+	img, err := f.Image(a)
+	if err != nil {
+		return err
+	}
+	return SaveImage(img, filename)
+}
+
+// Colors far apart for consecutive IDs, black for none.
+func objectColor(id int) color.RGBA {
+	if id == 0 {
+		return color.RGBA{A: 255}
+	}
+
+	// Hues a golden angle apart never repeat and stay distinct.
+	hue := math.Mod(float64(id)*0.618034, 1) * 6
+	x := 1 - math.Abs(math.Mod(hue, 2)-1)
+	rgb := [6]Vertex3{{X: 1, Y: x}, {X: x, Y: 1}, {Y: 1, Z: x}, {Y: x, Z: 1}, {X: x, Z: 1}, {X: 1, Z: x}}[int(hue)]
+	return toRGBA(rgb.scale(0.8).plus(Vertex3{X: 0.2, Y: 0.2, Z: 0.2}))
+}
+
+// Empties the attached buffers for a new frame, the color and HDR ones aside which the frame
+// clears itself.
+func (f *Framebuffer) clear() {
+	if f.depth != nil {
+		fillFloat64s(f.depth, math.Inf(-1))
+	}
+	for i := range f.normals {
+		f.normals[i] = Vertex3{}
+	}
+	for i := range f.objects {
+		f.objects[i] = 0
+	}
+}
+
+// Writes what the fragment of the triangle with these perspective-correct weights shows to the
+// auxiliary buffers attached.
+func (f *Framebuffer) writeFragment(x, y int, triangle Triangle, w1, w2, w3 float64) {
+	i := y*f.width + x
+	if f.normals != nil {
+		f.normals[i] = interpolateNormal(triangle.face, w1, w2, w3)
+	}
+	if f.objects != nil {
+		f.objects[i] = triangle.object
+	}
+}
+
+// Resolves the auxiliary buffers of the larger framebuffer, rendered factor times wider and
+// higher, into this one. Normals get averaged and object IDs come from the first pixel of every
+// block.
+func (f *Framebuffer) downsampleAttributes(large *Framebuffer, factor int) {
+	for y := 0; y < f.height; y++ {
+		for x := 0; x < f.width; x++ {
+			if f.normals != nil {
+				var sum Vertex3
+				for dy := 0; dy < factor; dy++ {
+					for dx := 0; dx < factor; dx++ {
+						sum = sum.plus(large.normals[(y*factor+dy)*large.width+x*factor+dx])
+					}
+				}
+				if sum.length() > 1e-12 {
+					sum = sum.normalize(1.0)
+				}
+				f.normals[y*f.width+x] = sum
+			}
+			if f.objects != nil {
+				f.objects[y*f.width+x] = large.objects[y*factor*large.width+x*factor]
+			}
+		}
+	}
+}
+
+// Renders the scene into the framebuffer, at its size, drawing into the attached buffers.
+// Path tracing only fills the color, HDR and depth buffers.
+func RenderFramebuffer(framebuffer *Framebuffer, scene *Scene, camera Camera, options Options) error {
+	options.Width, options.Height = framebuffer.width, framebuffer.height
+	if err := options.validate(); err != nil {
+		return err
+	}
+
+	// Outside of the viewport and scissor, buffers keep what they had.
+	if _, _, ok := options.regions(framebuffer.width, framebuffer.height); !ok {
+		framebuffer.clear()
+	}
+	zBuffer := renderFrame(context.Background(), framebuffer, scene, camera, options, options.Stats)
+	if framebuffer.depth != nil && zBuffer != nil {
+		copy(framebuffer.depth, zBuffer)
+	}
+
+	return nil
+}
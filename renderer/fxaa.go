@@ -0,0 +1,171 @@
+package renderer
+
+import (
+	"image"
+	"image/color"
+	"math"
+)
+
+const (
+	// Contrast below which there's no edge to smooth, relative to the brightest neighbour...
+	fxaaEdgeThreshold = 0.125
+	// ...and absolute, sparing dark areas.
+	fxaaEdgeThresholdMin = 0.0312
+	// How much aliasing within pixels gets removed, at the cost of some sharpness.
+	fxaaSubpixelQuality = 0.75
+)
+
+// Lengths of the successive steps taken along edges to find their ends, longer ones far away.
+var fxaaSteps = []float64{1, 1, 1, 1, 1, 1.5, 2, 2, 2, 2, 4, 8}
+
+// Fast approximate anti-aliasing (Lottes), a post-process finding edges from luma contrast in the
+// final image and blending across them. Much cheaper than sampling triangles several times, but
+// blind to sub-pixel geometry and slightly blurry.
+func fxaa(img *image.RGBA) {
+	rect := img.Bounds()
+	width, height := rect.Dx(), rect.Dy()
+
+	src := image.NewRGBA(rect)
+	copy(src.Pix, img.Pix)
+
+	luma := make([]float64, width*height)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			c := src.RGBAAt(x, y)
+			luma[y*width+x] = (0.299*float64(c.R) + 0.587*float64(c.G) + 0.114*float64(c.B)) / 255
+		}
+	}
+
+	at := func(x, y int) float64 {
+		x = minInt(maxInt(x, 0), width-1)
+		y = minInt(maxInt(y, 0), height-1)
+		return luma[y*width+x]
+	}
+
+	// Bilinear luma between pixels.
+	lumaAt := func(x, y float64) float64 {
+		x0, y0 := math.Floor(x), math.Floor(y)
+		fx, fy := x-x0, y-y0
+		ix, iy := int(x0), int(y0)
+		top := at(ix, iy)*(1-fx) + at(ix+1, iy)*fx
+		bottom := at(ix, iy+1)*(1-fx) + at(ix+1, iy+1)*fx
+		return top*(1-fy) + bottom*fy
+	}
+
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			m := at(x, y)
+			n, s, w, e := at(x, y-1), at(x, y+1), at(x-1, y), at(x+1, y)
+
+			lo := math.Min(m, math.Min(math.Min(n, s), math.Min(w, e)))
+			hi := math.Max(m, math.Max(math.Max(n, s), math.Max(w, e)))
+			contrast := hi - lo
+			if contrast < math.Max(fxaaEdgeThresholdMin, hi*fxaaEdgeThreshold) {
+				continue
+			}
+
+			nw, ne, sw, se := at(x-1, y-1), at(x+1, y-1), at(x-1, y+1), at(x+1, y+1)
+
+			// Whether the edge runs horizontally or vertically, from second derivatives.
+			horizontal := math.Abs(-2*w+nw+sw)+2*math.Abs(-2*m+n+s)+math.Abs(-2*e+ne+se) >=
+				math.Abs(-2*n+nw+ne)+2*math.Abs(-2*m+w+e)+math.Abs(-2*s+sw+se)
+
+			// Which side of the pixel the edge is on, the one with the steepest gradient.
+			luma1, luma2 := w, e
+			if horizontal {
+				luma1, luma2 = n, s
+			}
+			gradient1, gradient2 := luma1-m, luma2-m
+
+			step := 1.0
+			localAverage := (luma2 + m) / 2
+			if math.Abs(gradient1) >= math.Abs(gradient2) {
+				step = -1
+				localAverage = (luma1 + m) / 2
+			}
+			scaledGradient := math.Max(math.Abs(gradient1), math.Abs(gradient2)) / 4
+
+			// Walk along the edge, half a pixel towards it, both ways until the luma changes.
+			cx, cy := float64(x), float64(y)
+			dx, dy := 0.0, 1.0
+			if horizontal {
+				cy += step / 2
+				dx, dy = 1, 0
+			} else {
+				cx += step / 2
+			}
+
+			x1, y1, x2, y2 := cx-dx, cy-dy, cx+dx, cy+dy
+			end1, end2 := lumaAt(x1, y1)-localAverage, lumaAt(x2, y2)-localAverage
+			reached1, reached2 := math.Abs(end1) >= scaledGradient, math.Abs(end2) >= scaledGradient
+
+			for _, length := range fxaaSteps {
+				if reached1 && reached2 {
+					break
+				}
+				if !reached1 {
+					x1, y1 = x1-dx*length, y1-dy*length
+					end1 = lumaAt(x1, y1) - localAverage
+					reached1 = math.Abs(end1) >= scaledGradient
+				}
+				if !reached2 {
+					x2, y2 = x2+dx*length, y2+dy*length
+					end2 = lumaAt(x2, y2) - localAverage
+					reached2 = math.Abs(end2) >= scaledGradient
+				}
+			}
+
+			distance1, distance2 := cx-x1+cy-y1, x2-cx+y2-cy
+			closest, end := distance1, end1
+			if distance2 < distance1 {
+				closest, end = distance2, end2
+			}
+
+			// The closer to the end of the edge, the less of a blend, unless the end goes the wrong way.
+			offset := 0.0
+			if (end < 0) != (m < localAverage) {
+				offset = 0.5 - closest/(distance1+distance2)
+			}
+
+			// Isolated bright or dark pixels get blended too.
+			average := (2*(n+s+w+e) + nw + ne + sw + se) / 12
+			subpixel := math.Max(0, math.Min(1, math.Abs(average-m)/contrast))
+			subpixel = (-2*subpixel + 3) * subpixel * subpixel
+			offset = math.Max(offset, subpixel*subpixel*fxaaSubpixelQuality)
+
+			if horizontal {
+				img.SetRGBA(x, y, bilinearRGBA(src, float64(x), float64(y)+offset*step))
+			} else {
+				img.SetRGBA(x, y, bilinearRGBA(src, float64(x)+offset*step, float64(y)))
+			}
+		}
+	}
+}
+
+// Color between pixels, the closest pixels being used past the borders.
+func bilinearRGBA(img *image.RGBA, x, y float64) color.RGBA {
+	rect := img.Bounds()
+	x0, y0 := math.Floor(x), math.Floor(y)
+	fx, fy := x-x0, y-y0
+
+	texel := func(x, y int) color.RGBA {
+		x = minInt(maxInt(x, 0), rect.Dx()-1)
+		y = minInt(maxInt(y, 0), rect.Dy()-1)
+		return img.RGBAAt(x, y)
+	}
+
+	c00, c10 := texel(int(x0), int(y0)), texel(int(x0)+1, int(y0))
+	c01, c11 := texel(int(x0), int(y0)+1), texel(int(x0)+1, int(y0)+1)
+
+	channel := func(a, b, c, d uint8) uint8 {
+		v := (float64(a)*(1-fx)+float64(b)*fx)*(1-fy) + (float64(c)*(1-fx)+float64(d)*fx)*fy
+		return uint8(v + 0.5)
+	}
+
+	return color.RGBA{
+		R: channel(c00.R, c10.R, c01.R, c11.R),
+		G: channel(c00.G, c10.G, c01.G, c11.G),
+		B: channel(c00.B, c10.B, c01.B, c11.B),
+		A: channel(c00.A, c10.A, c01.A, c11.A),
+	}
+}
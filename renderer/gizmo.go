@@ -0,0 +1,196 @@
+package renderer
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"sort"
+)
+
+type BoundingBoxes int
+
+const (
+	BoundingBoxesOff BoundingBoxes = iota
+	// Boxes along the axes of the world around every mesh as placed in the scene.
+	AxisAligned
+	// Boxes of the meshes in their own space, turned and scaled along with them, which are the
+	// ones culling tests against the view frustum.
+	Oriented
+)
+
+var (
+	axisAlignedColor = color.RGBA{R: 255, G: 220, B: 0, A: 255}
+	orientedColor    = color.RGBA{G: 220, B: 255, A: 255}
+
+	axisColors = [3]color.RGBA{
+		{R: 230, G: 50, B: 50, A: 255},
+		{R: 80, G: 200, B: 60, A: 255},
+		{R: 60, G: 110, B: 240, A: 255},
+	}
+	axisNames = [3]string{"X", "Y", "Z"}
+)
+
+// Pairs of corners, as numbered by AABB.corners, joined by the edges of a box.
+var boxEdges = [12][2]int{
+	{0, 1}, {2, 3}, {4, 5}, {6, 7},
+	{0, 2}, {1, 3}, {4, 6}, {5, 7},
+	{0, 4}, {1, 5}, {2, 6}, {3, 7},
+}
+
+// Debugging aids drawn over the finished frame, the axis gizmo, bounding boxes and vertex normals,
+// when enabled.
+func drawGuides(img *image.RGBA, zBuffer []float64, scene *Scene, camera Camera, options Options) {
+	if options.VertexNormals {
+		drawVertexNormals(img, zBuffer, scene, camera)
+	}
+	if options.BoundingBoxes != BoundingBoxesOff {
+		drawBoundingBoxes(img, scene, camera, options.BoundingBoxes)
+	}
+	if options.AxisGizmo {
+		drawAxisGizmo(img, camera)
+	}
+}
+
+// Boxes show through what's in front of them, so that those of hidden or culled meshes can be
+// made out too.
+func drawBoundingBoxes(img *image.RGBA, scene *Scene, camera Camera, mode BoundingBoxes) {
+	scene.walkMeshes(func(node *Node, mesh *Obj, world Matrix4) {
+		var corners [8]Vertex3
+		col := orientedColor
+
+		if mode == AxisAligned {
+			var bounds AABB
+			for i, corner := range mesh.Bounds.corners() {
+				p := world.transformPoint(corner)
+				if i == 0 {
+					bounds = AABB{Min: p, Max: p}
+				}
+				bounds.Min = Vertex3{X: math.Min(bounds.Min.X, p.X), Y: math.Min(bounds.Min.Y, p.Y), Z: math.Min(bounds.Min.Z, p.Z)}
+				bounds.Max = Vertex3{X: math.Max(bounds.Max.X, p.X), Y: math.Max(bounds.Max.Y, p.Y), Z: math.Max(bounds.Max.Z, p.Z)}
+			}
+			corners, col = bounds.corners(), axisAlignedColor
+		} else {
+			for i, corner := range mesh.Bounds.corners() {
+				corners[i] = world.transformPoint(corner)
+			}
+		}
+
+		for _, edge := range boxEdges {
+			drawWorldLine(img, nil, camera, corners[edge[0]], corners[edge[1]], col)
+		}
+	})
+}
+
+// Short lines out of every vertex along its normal, colored after its direction like in the
+// normals view, and along its tangent in red when it has one. They are as long as a few hundredths
+// of the size of the mesh.
+func drawVertexNormals(img *image.RGBA, zBuffer []float64, scene *Scene, camera Camera) {
+	tangentColor := color.RGBA{R: 255, G: 60, B: 60, A: 255}
+
+	scene.walkMeshes(func(node *Node, mesh *Obj, world Matrix4) {
+		normalMatrix := genNormalMatrix(world)
+		length := 0.03 * world.transformPoint(mesh.Bounds.Max).minus(world.transformPoint(mesh.Bounds.Min)).length()
+
+		// Vertices get repeated by every face using them.
+		type vertex struct{ position, normal Vertex3 }
+		seen := map[vertex]bool{}
+
+		for _, face := range mesh.Faces {
+			for i, v := range face.Vertices {
+				if seen[vertex{v, face.Normals[i]}] {
+					continue
+				}
+				seen[vertex{v, face.Normals[i]}] = true
+
+				p := world.transformPoint(v)
+				n := normalMatrix.transformDirection(face.Normals[i])
+				if n.length() > 1e-12 {
+					c := toRGBA(encodeDirection(n))
+					drawWorldLine(img, zBuffer, camera, p, p.plus(n.normalize(length)), c)
+				}
+
+				t := transformTangent(world, face.Tangents[i])
+				if t3 := (Vertex3{X: t.X, Y: t.Y, Z: t.Z}); t3.length() > 1e-12 {
+					drawWorldLine(img, zBuffer, camera, p, p.plus(t3.normalize(length/2)), tangentColor)
+				}
+			}
+		}
+	})
+}
+
+// Draws a line between two points of world space, cut where it goes behind the near plane. Given
+// a z-buffer, the parts hidden behind triangles are left out.
+func drawWorldLine(img *image.RGBA, zBuffer []float64, camera Camera, from, to Vertex3, col color.RGBA) {
+	rect := img.Bounds()
+	aspect := float64(rect.Dx()) / float64(rect.Dy())
+	cameraMatrix := camera.projectionMatrix(aspect).Multiply(camera.viewMatrix())
+	screenMatrix := genScreenMatrix(0, 0, rect.Dx(), rect.Dy())
+
+	a := Vertex4{X: from.X, Y: from.Y, Z: from.Z, W: 1}
+	b := Vertex4{X: to.X, Y: to.Y, Z: to.Z, W: 1}
+	a.transform(cameraMatrix)
+	b.transform(cameraMatrix)
+
+	for _, plane := range nearPlanes {
+		da, db := plane(a), plane(b)
+		switch {
+		case da < 0 && db < 0:
+			return
+		case da < 0:
+			a = a.lerp(b, da/(da-db))
+		case db < 0:
+			b = b.lerp(a, db/(db-da))
+		}
+	}
+
+	a.transform(screenMatrix)
+	b.transform(screenMatrix)
+	pa, pb := a.lower(), b.lower()
+	// Lines way off-screen would take forever to walk pixel by pixel.
+	limit := float64(4 * (rect.Dx() + rect.Dy()))
+	if math.Max(math.Abs(pa.X), math.Abs(pa.Y)) > limit || math.Max(math.Abs(pb.X), math.Abs(pb.Y)) > limit {
+		return
+	}
+
+	p1, p2 := image.Point{X: int(pa.X), Y: int(pa.Y)}, image.Point{X: int(pb.X), Y: int(pb.Y)}
+	if zBuffer == nil {
+		drawLine(img, p1.X, p1.Y, p2.X, p2.Y, col)
+		return
+	}
+	drawDepthLine(img, zBuffer, p1, p2, pa.Z, pb.Z, col)
+}
+
+// The axes of the world as the camera sees them, in the bottom-left corner, X being red, Y green
+// and Z blue.
+func drawAxisGizmo(img *image.RGBA, camera Camera) {
+	const radius, margin = 28, 12
+	rect := img.Bounds()
+	center := image.Point{X: rect.Min.X + margin + radius, Y: rect.Min.Y + margin + radius}
+	view := camera.viewMatrix()
+
+	type axis struct {
+		index     int
+		direction Vertex3
+	}
+	var axes []axis
+	for i, direction := range []Vertex3{{X: 1}, {Y: 1}, {Z: 1}} {
+		axes = append(axes, axis{i, view.transformDirection(direction).normalize(1.0)})
+	}
+	// Farthest first, the nearest one ending up on top.
+	sort.Slice(axes, func(i, j int) bool {
+		return axes[i].direction.Z < axes[j].direction.Z
+	})
+
+	canvas := newFlippedCanvas(img)
+	for _, a := range axes {
+		tip := center.Add(image.Point{X: int(math.Round(a.direction.X * radius)), Y: int(math.Round(a.direction.Y * radius))})
+		drawLine(img, center.X, center.Y, tip.X, tip.Y, axisColors[a.index])
+
+		// Labels go a little past the tips, centered on where the axes point.
+		name := axisNames[a.index]
+		size := overlayFont.Measure(name)
+		label := center.Add(image.Point{X: int(math.Round(a.direction.X * (radius + 8))), Y: int(math.Round(a.direction.Y * (radius + 8)))})
+		at := image.Point{X: label.X - size.X/2, Y: rect.Max.Y - 1 - label.Y - size.Y/2}
+		canvas.DrawText(overlayFont, at, name, axisColors[a.index])
+	}
+}
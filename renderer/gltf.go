@@ -0,0 +1,962 @@
+package renderer
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"math"
+	"net/url"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	glbMagic     = 0x46546c67 // "glTF"
+	glbJSONChunk = 0x4e4f534a // "JSON"
+	glbBINChunk  = 0x004e4942 // "BIN\0"
+)
+
+type gltfFile struct {
+	Scene       *int             `json:"scene"`
+	Scenes      []gltfScene      `json:"scenes"`
+	Nodes       []gltfNode       `json:"nodes"`
+	Meshes      []gltfMesh       `json:"meshes"`
+	Skins       []gltfSkin       `json:"skins"`
+	Animations  []gltfAnimation  `json:"animations"`
+	Materials   []gltfMaterial   `json:"materials"`
+	Textures    []gltfTexture    `json:"textures"`
+	Images      []gltfImage      `json:"images"`
+	Accessors   []gltfAccessor   `json:"accessors"`
+	BufferViews []gltfBufferView `json:"bufferViews"`
+	Buffers     []gltfBuffer     `json:"buffers"`
+	Required    []string         `json:"extensionsRequired"`
+}
+
+type gltfScene struct {
+	Nodes []int `json:"nodes"`
+}
+
+type gltfNode struct {
+	Name     string `json:"name"`
+	Children []int  `json:"children"`
+	Mesh     *int   `json:"mesh"`
+	Skin     *int   `json:"skin"`
+	// Column-major, or the translation, rotation and scale.
+	Matrix      []float64 `json:"matrix"`
+	Translation []float64 `json:"translation"`
+	Rotation    []float64 `json:"rotation"`
+	Scale       []float64 `json:"scale"`
+	// Of the morph targets, overriding those of the mesh.
+	Weights []float64 `json:"weights"`
+}
+
+type gltfMesh struct {
+	Name       string          `json:"name"`
+	Primitives []gltfPrimitive `json:"primitives"`
+	Weights    []float64       `json:"weights"`
+	// Names of the morph targets, by convention.
+	Extras struct {
+		TargetNames []string `json:"targetNames"`
+	} `json:"extras"`
+}
+
+type gltfPrimitive struct {
+	Attributes map[string]int `json:"attributes"`
+	Indices    *int           `json:"indices"`
+	Material   *int           `json:"material"`
+	Mode       *int           `json:"mode"`
+	// Morph targets, with the displacements of the attributes they change.
+	Targets []map[string]int `json:"targets"`
+}
+
+type gltfSkin struct {
+	Joints              []int `json:"joints"`
+	InverseBindMatrices *int  `json:"inverseBindMatrices"`
+}
+
+type gltfAnimation struct {
+	Name     string `json:"name"`
+	Channels []struct {
+		Sampler int `json:"sampler"`
+		Target  struct {
+			Node *int   `json:"node"`
+			Path string `json:"path"`
+		} `json:"target"`
+	} `json:"channels"`
+	Samplers []struct {
+		Input         int    `json:"input"`
+		Output        int    `json:"output"`
+		Interpolation string `json:"interpolation"`
+	} `json:"samplers"`
+}
+
+type gltfMaterial struct {
+	Name string `json:"name"`
+	PBR  *struct {
+		BaseColorFactor          []float64       `json:"baseColorFactor"`
+		BaseColorTexture         *gltfTextureRef `json:"baseColorTexture"`
+		MetallicFactor           *float64        `json:"metallicFactor"`
+		RoughnessFactor          *float64        `json:"roughnessFactor"`
+		MetallicRoughnessTexture *gltfTextureRef `json:"metallicRoughnessTexture"`
+	} `json:"pbrMetallicRoughness"`
+	NormalTexture *gltfTextureRef `json:"normalTexture"`
+	AlphaMode     string          `json:"alphaMode"`
+	AlphaCutoff   *float64        `json:"alphaCutoff"`
+	DoubleSided   bool            `json:"doubleSided"`
+
+	EmissiveFactor  []float64       `json:"emissiveFactor"`
+	EmissiveTexture *gltfTextureRef `json:"emissiveTexture"`
+	Extensions      struct {
+		// Emission brighter than the factor's 1 limit allows, for bloom.
+		EmissiveStrength *struct {
+			Strength *float64 `json:"emissiveStrength"`
+		} `json:"KHR_materials_emissive_strength"`
+	} `json:"extensions"`
+}
+
+type gltfTextureRef struct {
+	Index int `json:"index"`
+}
+
+type gltfTexture struct {
+	Source *int `json:"source"`
+}
+
+type gltfImage struct {
+	URI        string `json:"uri"`
+	BufferView *int   `json:"bufferView"`
+}
+
+type gltfAccessor struct {
+	BufferView    *int            `json:"bufferView"`
+	ByteOffset    int             `json:"byteOffset"`
+	ComponentType int             `json:"componentType"`
+	Normalized    bool            `json:"normalized"`
+	Count         int             `json:"count"`
+	Type          string          `json:"type"`
+	Sparse        json.RawMessage `json:"sparse"`
+}
+
+type gltfBufferView struct {
+	Buffer     int `json:"buffer"`
+	ByteOffset int `json:"byteOffset"`
+	ByteLength int `json:"byteLength"`
+	ByteStride int `json:"byteStride"`
+}
+
+type gltfBuffer struct {
+	URI        string `json:"uri"`
+	ByteLength int    `json:"byteLength"`
+}
+
+type gltfLoader struct {
+	ctx      context.Context
+	file     gltfFile
+	filename string
+	dir      string
+	buffers  [][]byte
+
+	meshes    map[int]*Obj
+	materials map[int]*Material
+	textures  map[int]image.Image
+}
+
+// Loads a glTF 2.0 file, .gltf with its buffers and images embedded or next to it or binary .glb,
+// as a node holding its default scene. Meshes come with their metallic-roughness materials and
+// skins and morph targets, along with the animations of the file. Cameras and lights are left out.
+func LoadGLTF(filename string) (*Node, []*AnimationClip, error) {
+	return LoadGLTFContext(context.Background(), filename)
+}
+
+// Same as LoadGLTF, giving up with the error of the context once it's canceled.
+func LoadGLTFContext(ctx context.Context, filename string) (*Node, []*AnimationClip, error) {
+	data, err := readFileContext(ctx, filename)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	l := &gltfLoader{
+		ctx:       ctx,
+		filename:  filename,
+		dir:       filepath.Dir(filename),
+		meshes:    map[int]*Obj{},
+		materials: map[int]*Material{},
+		textures:  map[int]image.Image{},
+	}
+
+	var bin []byte
+	if len(data) >= 12 && binary.LittleEndian.Uint32(data) == glbMagic {
+		data, bin, err = splitGLB(data)
+		if err != nil {
+			return nil, nil, l.errorf("%s", err)
+		}
+	}
+
+	if err := json.Unmarshal(data, &l.file); err != nil {
+		return nil, nil, l.errorf("%s", err)
+	}
+	if len(l.file.Required) > 0 {
+		return nil, nil, l.errorf("unsupported extension %s", l.file.Required[0])
+	}
+
+	for i, buffer := range l.file.Buffers {
+		// The binary chunk of .glb files is the first buffer, without a URI.
+		data := bin
+		if buffer.URI != "" || i > 0 || bin == nil {
+			if data, err = l.resource(buffer.URI); err != nil {
+				return nil, nil, err
+			}
+		}
+		if len(data) < buffer.ByteLength {
+			return nil, nil, l.errorf("buffer %d is %d bytes long instead of %d", i, len(data), buffer.ByteLength)
+		}
+		l.buffers = append(l.buffers, data)
+	}
+
+	nodes := make([]*Node, len(l.file.Nodes))
+	for i, n := range l.file.Nodes {
+		nodes[i] = NewNode(n.Name)
+		nodes[i].Transform, err = l.transform(n)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	skins := make([]*Skin, len(l.file.Skins))
+	for i, s := range l.file.Skins {
+		skin := &Skin{}
+		for _, joint := range s.Joints {
+			if joint < 0 || joint >= len(nodes) {
+				return nil, nil, l.errorf("skin %d has joint %d out of range", i, joint)
+			}
+			skin.Joints = append(skin.Joints, nodes[joint])
+		}
+
+		if s.InverseBindMatrices != nil {
+			matrices, err := l.accessor(*s.InverseBindMatrices)
+			if err != nil {
+				return nil, nil, err
+			}
+			for _, m := range matrices {
+				if len(m) != 16 {
+					return nil, nil, l.errorf("inverse bind matrices of skin %d aren't 4x4 matrices", i)
+				}
+				skin.InverseBindMatrices = append(skin.InverseBindMatrices, gltfMatrix(m))
+			}
+		}
+		for len(skin.InverseBindMatrices) < len(skin.Joints) {
+			skin.InverseBindMatrices = append(skin.InverseBindMatrices, Identity4())
+		}
+		skins[i] = skin
+	}
+
+	for i, n := range l.file.Nodes {
+		for _, child := range n.Children {
+			if child < 0 || child >= len(nodes) {
+				return nil, nil, l.errorf("node %d has child %d out of range", i, child)
+			}
+			nodes[i].Add(nodes[child])
+		}
+
+		if err := ctx.Err(); err != nil {
+			return nil, nil, err
+		}
+		if n.Mesh != nil {
+			nodes[i].Mesh, err = l.mesh(*n.Mesh)
+			if err != nil {
+				return nil, nil, err
+			}
+			nodes[i].MorphWeights = gltfMorphWeights(n.Weights, l.file.Meshes[*n.Mesh].Weights, len(nodes[i].Mesh.Targets))
+		}
+		if n.Skin != nil {
+			if *n.Skin < 0 || *n.Skin >= len(skins) {
+				return nil, nil, l.errorf("node %d has skin %d out of range", i, *n.Skin)
+			}
+			nodes[i].Skin = skins[*n.Skin]
+		}
+	}
+
+	root := NewNode(filepath.Base(filename))
+	for _, index := range l.roots() {
+		if index < 0 || index >= len(nodes) {
+			return nil, nil, l.errorf("scene has node %d out of range", index)
+		}
+		root.Add(nodes[index])
+	}
+
+	var clips []*AnimationClip
+	for i, a := range l.file.Animations {
+		clip, err := l.animation(a, nodes)
+		if err != nil {
+			return nil, nil, err
+		}
+		if clip.Name == "" {
+			clip.Name = fmt.Sprintf("animation %d", i)
+		}
+		clips = append(clips, clip)
+	}
+
+	return root, clips, nil
+}
+
+// The JSON and binary chunks of a .glb file.
+func splitGLB(data []byte) ([]byte, []byte, error) {
+	length := int(binary.LittleEndian.Uint32(data[8:]))
+	if length > len(data) {
+		return nil, nil, errors.New("truncated binary glTF")
+	}
+
+	var jsonChunk, binChunk []byte
+	for offset := 12; offset+8 <= length; {
+		size := int(binary.LittleEndian.Uint32(data[offset:]))
+		kind := binary.LittleEndian.Uint32(data[offset+4:])
+		if offset+8+size > length {
+			return nil, nil, errors.New("truncated binary glTF chunk")
+		}
+
+		chunk := data[offset+8 : offset+8+size]
+		switch {
+		case kind == glbJSONChunk && jsonChunk == nil:
+			jsonChunk = chunk
+		case kind == glbBINChunk && binChunk == nil:
+			binChunk = chunk
+		}
+		// Chunks are aligned to 4 bytes.
+		offset += 8 + (size+3)&^3
+	}
+
+	if jsonChunk == nil {
+		return nil, nil, errors.New("binary glTF without JSON")
+	}
+	return jsonChunk, binChunk, nil
+}
+
+func (l *gltfLoader) errorf(format string, args ...interface{}) error {
+	return errors.New(fmt.Sprintf("invalid glTF file %s: %s", l.filename, fmt.Sprintf(format, args...)))
+}
+
+// Contents of a data URI, or of a file relative to the glTF file.
+func (l *gltfLoader) resource(uri string) ([]byte, error) {
+	if strings.HasPrefix(uri, "data:") {
+		comma := strings.Index(uri, ",")
+		if comma < 0 || !strings.HasSuffix(uri[:comma], ";base64") {
+			return nil, l.errorf("unsupported data URI")
+		}
+		return base64.StdEncoding.DecodeString(uri[comma+1:])
+	}
+
+	path, err := url.PathUnescape(uri)
+	if err != nil {
+		return nil, l.errorf("invalid URI %q", uri)
+	}
+	return readFileContext(l.ctx, filepath.Join(l.dir, filepath.FromSlash(path)))
+}
+
+// Nodes of the default scene, or every node without a parent when the file has no scenes.
+func (l *gltfLoader) roots() []int {
+	if len(l.file.Scenes) > 0 {
+		scene := 0
+		if l.file.Scene != nil && *l.file.Scene >= 0 && *l.file.Scene < len(l.file.Scenes) {
+			scene = *l.file.Scene
+		}
+		return l.file.Scenes[scene].Nodes
+	}
+
+	children := map[int]bool{}
+	for _, n := range l.file.Nodes {
+		for _, child := range n.Children {
+			children[child] = true
+		}
+	}
+
+	var roots []int
+	for i := range l.file.Nodes {
+		if !children[i] {
+			roots = append(roots, i)
+		}
+	}
+	return roots
+}
+
+func (l *gltfLoader) transform(n gltfNode) (Matrix4, error) {
+	if n.Matrix != nil {
+		if len(n.Matrix) != 16 {
+			return Matrix4{}, l.errorf("node %q has a matrix of %d values", n.Name, len(n.Matrix))
+		}
+		return gltfMatrix(n.Matrix), nil
+	}
+
+	translation, rotation, scale := Vertex3{}, IdentityQuaternion(), Vertex3{X: 1, Y: 1, Z: 1}
+	if len(n.Translation) == 3 {
+		translation = Vertex3{X: n.Translation[0], Y: n.Translation[1], Z: n.Translation[2]}
+	}
+	if len(n.Rotation) == 4 {
+		rotation = Quaternion{X: n.Rotation[0], Y: n.Rotation[1], Z: n.Rotation[2], W: n.Rotation[3]}.normalize()
+	}
+	if len(n.Scale) == 3 {
+		scale = Vertex3{X: n.Scale[0], Y: n.Scale[1], Z: n.Scale[2]}
+	}
+	return composeTransform(translation, rotation, scale), nil
+}
+
+// glTF matrices are stored column by column.
+func gltfMatrix(m []float64) Matrix4 {
+	return Matrix4{
+		m[0], m[4], m[8], m[12],
+		m[1], m[5], m[9], m[13],
+		m[2], m[6], m[10], m[14],
+		m[3], m[7], m[11], m[15],
+	}
+}
+
+// Elements of an accessor, as many floats each as the components of its type. Normalized integers
+// are brought between 0 and 1, or -1 and 1 when signed.
+func (l *gltfLoader) accessor(index int) ([][]float64, error) {
+	if index < 0 || index >= len(l.file.Accessors) {
+		return nil, l.errorf("accessor %d out of range", index)
+	}
+	a := l.file.Accessors[index]
+	if a.Sparse != nil {
+		return nil, l.errorf("sparse accessors are not supported")
+	}
+
+	components := map[string]int{"SCALAR": 1, "VEC2": 2, "VEC3": 3, "VEC4": 4, "MAT4": 16}[a.Type]
+	size := map[int]int{5120: 1, 5121: 1, 5122: 2, 5123: 2, 5125: 4, 5126: 4}[a.ComponentType]
+	if components == 0 || size == 0 {
+		return nil, l.errorf("accessor %d has unsupported type %s of component type %d", index, a.Type, a.ComponentType)
+	}
+
+	values := make([][]float64, a.Count)
+	for i := range values {
+		values[i] = make([]float64, components)
+	}
+	// Without a buffer view, everything is 0.
+	if a.BufferView == nil {
+		return values, nil
+	}
+
+	if *a.BufferView < 0 || *a.BufferView >= len(l.file.BufferViews) {
+		return nil, l.errorf("accessor %d has buffer view %d out of range", index, *a.BufferView)
+	}
+	view := l.file.BufferViews[*a.BufferView]
+	if view.Buffer < 0 || view.Buffer >= len(l.buffers) {
+		return nil, l.errorf("buffer view %d has buffer %d out of range", *a.BufferView, view.Buffer)
+	}
+
+	stride := view.ByteStride
+	if stride == 0 {
+		stride = components * size
+	}
+	start := view.ByteOffset + a.ByteOffset
+	if a.Count > 0 {
+		end := start + (a.Count-1)*stride + components*size
+		if end > view.ByteOffset+view.ByteLength || end > len(l.buffers[view.Buffer]) {
+			return nil, l.errorf("accessor %d goes past the end of its buffer", index)
+		}
+	}
+
+	data := l.buffers[view.Buffer]
+	for i := range values {
+		for c := range values[i] {
+			b := data[start+i*stride+c*size:]
+			var v float64
+			switch a.ComponentType {
+			case 5120:
+				v = float64(int8(b[0]))
+				if a.Normalized {
+					v = math.Max(v/127, -1)
+				}
+			case 5121:
+				v = float64(b[0])
+				if a.Normalized {
+					v /= 255
+				}
+			case 5122:
+				v = float64(int16(binary.LittleEndian.Uint16(b)))
+				if a.Normalized {
+					v = math.Max(v/32767, -1)
+				}
+			case 5123:
+				v = float64(binary.LittleEndian.Uint16(b))
+				if a.Normalized {
+					v /= 65535
+				}
+			case 5125:
+				v = float64(binary.LittleEndian.Uint32(b))
+			case 5126:
+				v = float64(math.Float32frombits(binary.LittleEndian.Uint32(b)))
+			}
+			values[i][c] = v
+		}
+	}
+
+	return values, nil
+}
+
+// All the primitives of a mesh made of triangles go into the same model, points and lines being
+// left out.
+func (l *gltfLoader) mesh(index int) (*Obj, error) {
+	if obj, ok := l.meshes[index]; ok {
+		return obj, nil
+	}
+	if index < 0 || index >= len(l.file.Meshes) {
+		return nil, l.errorf("mesh %d out of range", index)
+	}
+
+	obj := &Obj{Materials: map[string]*Material{}}
+	skinned, tangents := false, true
+
+	// Primitives are meant to have the same targets, those lacking some getting none of them.
+	m := l.file.Meshes[index]
+	for _, primitive := range m.Primitives {
+		for len(obj.Targets) < len(primitive.Targets) {
+			target := MorphTarget{}
+			if i := len(obj.Targets); i < len(m.Extras.TargetNames) {
+				target.Name = m.Extras.TargetNames[i]
+			}
+			obj.Targets = append(obj.Targets, target)
+		}
+	}
+
+	for _, primitive := range m.Primitives {
+		mode := 4
+		if primitive.Mode != nil {
+			mode = *primitive.Mode
+		}
+		if mode < 4 {
+			continue
+		}
+
+		attribute := func(name string) ([][]float64, error) {
+			accessor, ok := primitive.Attributes[name]
+			if !ok {
+				return nil, nil
+			}
+			return l.accessor(accessor)
+		}
+
+		positions, err := attribute("POSITION")
+		if err != nil {
+			return nil, err
+		}
+		if positions == nil {
+			return nil, l.errorf("mesh %d has a primitive without positions", index)
+		}
+
+		normals, err := attribute("NORMAL")
+		if err != nil {
+			return nil, err
+		}
+		uvs, err := attribute("TEXCOORD_0")
+		if err != nil {
+			return nil, err
+		}
+		colors, err := attribute("COLOR_0")
+		if err != nil {
+			return nil, err
+		}
+		primitiveTangents, err := attribute("TANGENT")
+		if err != nil {
+			return nil, err
+		}
+		joints, err := attribute("JOINTS_0")
+		if err != nil {
+			return nil, err
+		}
+		weights, err := attribute("WEIGHTS_0")
+		if err != nil {
+			return nil, err
+		}
+
+		// Displacements of the positions and normals by every target.
+		targetPositions := make([][][]float64, len(obj.Targets))
+		targetNormals := make([][][]float64, len(obj.Targets))
+		for t, target := range primitive.Targets {
+			for name, values := range map[string]*[][]float64{"POSITION": &targetPositions[t], "NORMAL": &targetNormals[t]} {
+				accessor, ok := target[name]
+				if !ok {
+					continue
+				}
+				if *values, err = l.accessor(accessor); err != nil {
+					return nil, err
+				}
+				if len(*values) != len(positions) {
+					return nil, l.errorf("mesh %d has target %d with %d values instead of %d", index, t, len(*values), len(positions))
+				}
+			}
+		}
+
+		var material *Material
+		if primitive.Material != nil {
+			material, err = l.material(*primitive.Material)
+			if err != nil {
+				return nil, err
+			}
+			obj.Materials[material.Name] = material
+		}
+
+		indices := make([]int, len(positions))
+		for i := range indices {
+			indices[i] = i
+		}
+		if primitive.Indices != nil {
+			values, err := l.accessor(*primitive.Indices)
+			if err != nil {
+				return nil, err
+			}
+			indices = indices[:0]
+			for _, v := range values {
+				indices = append(indices, int(v[0]))
+			}
+		}
+
+		// Vertex ids of the primitive start after those of the previous ones, for tangents.
+		offset := len(obj.vertices)
+		for _, p := range positions {
+			obj.vertices = append(obj.vertices, Vertex3{X: p[0], Y: p[1], Z: p[2]})
+		}
+
+		for _, triangle := range gltfTriangles(indices, mode) {
+			var face Face
+			var faceWeights [3]JointWeights
+			for j, id := range triangle {
+				if id < 0 || id >= len(positions) {
+					return nil, l.errorf("mesh %d has index %d out of range", index, id)
+				}
+
+				face.Vertices[j] = obj.vertices[offset+id]
+				if normals != nil {
+					face.Normals[j] = Vertex3{X: normals[id][0], Y: normals[id][1], Z: normals[id][2]}
+				}
+				// Texture coordinates start from the top in glTF.
+				if uvs != nil {
+					face.Textures[j] = Vertex2{X: uvs[id][0], Y: 1 - uvs[id][1]}
+				}
+				if colors != nil {
+					face.Colors[j] = Vertex3{X: colors[id][0], Y: colors[id][1], Z: colors[id][2]}
+					face.Colored = true
+				}
+				if primitiveTangents != nil {
+					t := primitiveTangents[id]
+					face.Tangents[j] = Vertex4{X: t[0], Y: t[1], Z: t[2], W: t[3]}
+				}
+				if joints != nil && weights != nil {
+					faceWeights[j] = gltfJointWeights(joints[id], weights[id])
+				}
+			}
+
+			// Flat shading without normals.
+			if normals == nil {
+				n := faceNormal(face)
+				face.Normals = [3]Vertex3{n, n, n}
+			}
+			face.Material = material
+
+			obj.Faces = append(obj.Faces, face)
+			obj.faceVertexIds = append(obj.faceVertexIds, [3]int{offset + triangle[0] + 1, offset + triangle[1] + 1, offset + triangle[2] + 1})
+			obj.Weights = append(obj.Weights, faceWeights)
+
+			for t := range obj.Targets {
+				target := &obj.Targets[t]
+				var positions, normals [3]Vertex3
+				for j, id := range triangle {
+					if p := targetPositions[t]; p != nil {
+						positions[j] = Vertex3{X: p[id][0], Y: p[id][1], Z: p[id][2]}
+					}
+					if n := targetNormals[t]; n != nil {
+						normals[j] = Vertex3{X: n[id][0], Y: n[id][1], Z: n[id][2]}
+					}
+				}
+				target.Positions = append(target.Positions, positions)
+				// Left nil until a primitive displaces them.
+				if target.Normals == nil && targetNormals[t] != nil {
+					target.Normals = make([][3]Vertex3, len(target.Positions)-1)
+				}
+				if target.Normals != nil {
+					target.Normals = append(target.Normals, normals)
+				}
+			}
+		}
+
+		skinned = skinned || joints != nil && weights != nil
+		tangents = tangents && (primitiveTangents != nil || uvs == nil)
+	}
+
+	// Generated for the whole mesh as soon as a textured primitive lacks them.
+	if !tangents {
+		obj.generateTangents()
+	}
+	if !skinned {
+		obj.Weights = nil
+	}
+	obj.Bounds = obj.aabb()
+
+	obj.vertices = nil
+	obj.faceVertexIds = nil
+
+	l.meshes[index] = obj
+	return obj, nil
+}
+
+// Weights of the targets of a mesh, those of its node taking precedence and missing ones being 0.
+func gltfMorphWeights(node, mesh []float64, targets int) []float64 {
+	if targets == 0 {
+		return nil
+	}
+
+	weights := make([]float64, targets)
+	if node != nil {
+		copy(weights, node)
+	} else {
+		copy(weights, mesh)
+	}
+	return weights
+}
+
+// Vertex ids of every triangle of a primitive, strips and fans included.
+func gltfTriangles(indices []int, mode int) [][3]int {
+	var triangles [][3]int
+	switch mode {
+	case 4:
+		for i := 0; i+2 < len(indices); i += 3 {
+			triangles = append(triangles, [3]int{indices[i], indices[i+1], indices[i+2]})
+		}
+	case 5:
+		// Every other triangle of strips is flipped, to keep the winding.
+		for i := 0; i+2 < len(indices); i++ {
+			if i%2 == 0 {
+				triangles = append(triangles, [3]int{indices[i], indices[i+1], indices[i+2]})
+			} else {
+				triangles = append(triangles, [3]int{indices[i+1], indices[i], indices[i+2]})
+			}
+		}
+	case 6:
+		for i := 1; i+1 < len(indices); i++ {
+			triangles = append(triangles, [3]int{indices[0], indices[i], indices[i+1]})
+		}
+	}
+	return triangles
+}
+
+// Weights normalized to add up to 1, as exporters don't always make sure of it.
+func gltfJointWeights(joints, weights []float64) JointWeights {
+	var w JointWeights
+	total := 0.0
+	for i := 0; i < 4 && i < len(joints) && i < len(weights); i++ {
+		w.Joints[i] = int(joints[i])
+		w.Weights[i] = weights[i]
+		total += weights[i]
+	}
+	if total > 0 {
+		for i := range w.Weights {
+			w.Weights[i] /= total
+		}
+	}
+	return w
+}
+
+func (l *gltfLoader) material(index int) (*Material, error) {
+	if material, ok := l.materials[index]; ok {
+		return material, nil
+	}
+	if index < 0 || index >= len(l.file.Materials) {
+		return nil, l.errorf("material %d out of range", index)
+	}
+	m := l.file.Materials[index]
+
+	// glTF's defaults, a white metal.
+	material := DefaultMaterial()
+	material.Name = m.Name
+	if material.Name == "" {
+		material.Name = fmt.Sprintf("material %d", index)
+	}
+	material.Model = MetallicRoughness
+	material.Metallic = 1
+
+	var err error
+	if pbr := m.PBR; pbr != nil {
+		if len(pbr.BaseColorFactor) == 4 {
+			material.Diffuse = Vertex3{X: pbr.BaseColorFactor[0], Y: pbr.BaseColorFactor[1], Z: pbr.BaseColorFactor[2]}
+			material.Ambient = material.Diffuse
+			material.Opacity = pbr.BaseColorFactor[3]
+		}
+		if pbr.MetallicFactor != nil {
+			material.Metallic = *pbr.MetallicFactor
+		}
+		if pbr.RoughnessFactor != nil {
+			material.Roughness = *pbr.RoughnessFactor
+		}
+		if pbr.BaseColorTexture != nil {
+			if material.DiffuseMap, err = l.texture(pbr.BaseColorTexture.Index); err != nil {
+				return nil, err
+			}
+		}
+		if pbr.MetallicRoughnessTexture != nil {
+			if material.MetallicRoughnessMap, err = l.texture(pbr.MetallicRoughnessTexture.Index); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if m.NormalTexture != nil {
+		if material.NormalMap, err = l.texture(m.NormalTexture.Index); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(m.EmissiveFactor) == 3 {
+		material.Emissive = Vertex3{X: m.EmissiveFactor[0], Y: m.EmissiveFactor[1], Z: m.EmissiveFactor[2]}
+	}
+	if e := m.Extensions.EmissiveStrength; e != nil && e.Strength != nil {
+		material.Emissive = material.Emissive.scale(*e.Strength)
+	}
+	if m.EmissiveTexture != nil {
+		if material.EmissiveMap, err = l.texture(m.EmissiveTexture.Index); err != nil {
+			return nil, err
+		}
+	}
+
+	material.TwoSided = m.DoubleSided
+
+	switch m.AlphaMode {
+	case "MASK":
+		material.AlphaCutoff = 0.5
+		if m.AlphaCutoff != nil {
+			material.AlphaCutoff = *m.AlphaCutoff
+		}
+	case "BLEND":
+	default:
+		material.Opacity = 1
+	}
+
+	l.materials[index] = material
+	return material, nil
+}
+
+// Images are flipped like the textures of other models, as texture coordinates got flipped too.
+func (l *gltfLoader) texture(index int) (image.Image, error) {
+	if texture, ok := l.textures[index]; ok {
+		return texture, nil
+	}
+	if err := l.ctx.Err(); err != nil {
+		return nil, err
+	}
+	if index < 0 || index >= len(l.file.Textures) || l.file.Textures[index].Source == nil {
+		return nil, l.errorf("texture %d out of range", index)
+	}
+	source := *l.file.Textures[index].Source
+	if source < 0 || source >= len(l.file.Images) {
+		return nil, l.errorf("image %d out of range", source)
+	}
+	i := l.file.Images[source]
+
+	var data []byte
+	var err error
+	if i.BufferView != nil {
+		if *i.BufferView < 0 || *i.BufferView >= len(l.file.BufferViews) {
+			return nil, l.errorf("image %d has buffer view %d out of range", source, *i.BufferView)
+		}
+		view := l.file.BufferViews[*i.BufferView]
+		if view.Buffer < 0 || view.Buffer >= len(l.buffers) || view.ByteOffset+view.ByteLength > len(l.buffers[view.Buffer]) {
+			return nil, l.errorf("image %d goes past the end of its buffer", source)
+		}
+		data = l.buffers[view.Buffer][view.ByteOffset : view.ByteOffset+view.ByteLength]
+	} else if data, err = l.resource(i.URI); err != nil {
+		return nil, err
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, l.errorf("image %d: %s", source, err)
+	}
+
+	texture := flipImageVertically(img.Bounds(), img)
+	l.textures[index] = texture
+	return texture, nil
+}
+
+// Keyframes of translations, rotations, scales and morph target weights.
+func (l *gltfLoader) animation(a gltfAnimation, nodes []*Node) (*AnimationClip, error) {
+	// glTF doesn't tell whether clips loop, viewers usually do.
+	clip := &AnimationClip{Name: a.Name, Loop: true, Speed: 1}
+
+	for _, c := range a.Channels {
+		if c.Target.Node == nil {
+			continue
+		}
+		if *c.Target.Node < 0 || *c.Target.Node >= len(nodes) {
+			return nil, l.errorf("animation %q targets node %d out of range", a.Name, *c.Target.Node)
+		}
+		if c.Sampler < 0 || c.Sampler >= len(a.Samplers) {
+			return nil, l.errorf("animation %q has sampler %d out of range", a.Name, c.Sampler)
+		}
+		sampler := a.Samplers[c.Sampler]
+
+		channel := AnimationChannel{Node: nodes[*c.Target.Node]}
+		switch c.Target.Path {
+		case "translation":
+			channel.Path = TranslationPath
+		case "rotation":
+			channel.Path = RotationPath
+		case "scale":
+			channel.Path = ScalePath
+		case "weights":
+			channel.Path = WeightsPath
+		default:
+			continue
+		}
+
+		switch sampler.Interpolation {
+		case "STEP":
+			channel.Interpolation = StepInterpolation
+		case "CUBICSPLINE":
+			channel.Interpolation = CubicSplineInterpolation
+		}
+
+		times, err := l.accessor(sampler.Input)
+		if err != nil {
+			return nil, err
+		}
+		values, err := l.accessor(sampler.Output)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, t := range times {
+			channel.Times = append(channel.Times, t[0])
+		}
+		for _, v := range values {
+			value := Vertex4{X: v[0]}
+			if len(v) > 2 {
+				value.Y, value.Z = v[1], v[2]
+			}
+			if len(v) > 3 {
+				value.W = v[3]
+			}
+			channel.Values = append(channel.Values, value)
+		}
+
+		// As many values per keyframe as the mesh has targets, for weights.
+		stride := 1
+		if channel.Path == WeightsPath {
+			if channel.Node.Mesh == nil || len(channel.Node.Mesh.Targets) == 0 {
+				continue
+			}
+			stride = len(channel.Node.Mesh.Targets)
+		}
+		if channel.Interpolation == CubicSplineInterpolation {
+			stride *= 3
+		}
+		if len(channel.Values) != len(channel.Times)*stride {
+			return nil, l.errorf("animation %q has %d values for %d times", a.Name, len(channel.Values), len(channel.Times))
+		}
+
+		clip.Channels = append(clip.Channels, channel)
+	}
+
+	return clip, nil
+}
@@ -0,0 +1,274 @@
+package renderer
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Run "go test -run Golden -update" after changes meant to alter the output, then look at the
+// golden images before committing them.
+var update = flag.Bool("update", false, "rewrite the golden images of the regression tests with what gets rendered")
+
+const (
+	// Color difference, as CIE76 ΔE, under which pixels count as the same. Around 2.3 is the
+	// smallest difference people notice.
+	goldenPixelTolerance = 2.3
+	// Share of the pixels allowed to differ, as edges move by a pixel with small changes of the
+	// rasterizer.
+	goldenDifferentPixels = 0.01
+	// Average difference over the whole image allowed, catching shading drifting everywhere a
+	// little.
+	goldenMeanTolerance = 0.5
+)
+
+// Renders the scene file of the testdata directory, its camera and size, with the default options
+// changed by options when given.
+func renderGolden(t *testing.T, scene string, options func(o *Options)) *image.RGBA {
+	t.Helper()
+
+	s, output, err := LoadScene(filepath.Join("testdata", "scenes", scene))
+	if err != nil {
+		t.Fatal(err)
+	}
+	camera, ok := s.Camera()
+	if !ok {
+		t.Fatalf("%s has no camera", scene)
+	}
+
+	o := DefaultOptions()
+	o.Width, o.Height = output.Width, output.Height
+	o.PostEffects = output.Effects
+	if options != nil {
+		options(&o)
+	}
+
+	img, err := Render(s, camera, o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return img
+}
+
+// A sphere seen from its best view, in frames small enough to check pixel by pixel, without
+// anti-aliasing blurring its edges.
+func sphereTestScene() (*Scene, *Node, Camera, Options) {
+	scene := NewScene()
+	node := NewNode("sphere")
+	node.Mesh = NewSphere(16, 8)
+	scene.Root.Add(node)
+
+	options := DefaultOptions()
+	options.Width, options.Height = 32, 32
+	options.AntiAliasing = NoAntiAliasing
+	return scene, node, BestViewCamera(node.Mesh), options
+}
+
+// Compares the image with testdata/golden/<name>.png, within perceptual tolerances. When they
+// differ, the image and the differences get written to the temporary directory for inspection.
+func checkGolden(t *testing.T, name string, img *image.RGBA) {
+	t.Helper()
+
+	filename := filepath.Join("testdata", "golden", name+".png")
+	if *update {
+		if err := SaveImage(img, filename); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	file, err := os.Open(filename)
+	if err != nil {
+		t.Fatalf("%v, run with -update to create it", err)
+	}
+	// Not LoadTexture, which flips images.
+	golden, err := png.Decode(file)
+	file.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if golden.Bounds().Size() != img.Bounds().Size() {
+		t.Fatalf("rendered %v, golden image is %v", img.Bounds().Size(), golden.Bounds().Size())
+	}
+
+	diff, different, mean := compareImages(img, golden)
+	if float64(different) <= goldenDifferentPixels*float64(len(img.Pix)/4) && mean <= goldenMeanTolerance {
+		return
+	}
+
+	dir := filepath.Join(os.TempDir(), "render-golden")
+	if err := os.MkdirAll(dir, 0755); err == nil {
+		SaveImage(img, filepath.Join(dir, name+".png"))
+		SaveImage(diff, filepath.Join(dir, name+"-diff.png"))
+	}
+	t.Errorf("%s: %d pixels differ, mean ΔE %.2f, see %s", name, different, mean, dir)
+}
+
+// Pixels noticeably different between the images, how many there are, and the mean difference
+// over all of them. Differences are ΔE in CIELAB space, closer to how different colors look than
+// differences of sRGB values are.
+func compareImages(a, b image.Image) (*image.RGBA, int, float64) {
+	rect := a.Bounds()
+	diff := image.NewRGBA(image.Rect(0, 0, rect.Dx(), rect.Dy()))
+	different, total := 0, 0.0
+
+	for y := 0; y < rect.Dy(); y++ {
+		for x := 0; x < rect.Dx(); x++ {
+			ca := color.NRGBAModel.Convert(a.At(rect.Min.X+x, rect.Min.Y+y)).(color.NRGBA)
+			cb := color.NRGBAModel.Convert(b.At(b.Bounds().Min.X+x, b.Bounds().Min.Y+y)).(color.NRGBA)
+
+			d := cieLab(ca).minus(cieLab(cb)).length()
+			// Transparent pixels differ by their opacity, whatever the color.
+			d = math.Max(d, math.Abs(float64(ca.A)-float64(cb.A))/255*100)
+			total += d
+
+			if d > goldenPixelTolerance {
+				different++
+				diff.SetRGBA(x, y, color.RGBA{R: 255, A: 255})
+			} else {
+				g := uint8((int(ca.R) + int(ca.G) + int(ca.B)) / 3 / 4)
+				diff.SetRGBA(x, y, color.RGBA{R: g, G: g, B: g, A: 255})
+			}
+		}
+	}
+
+	return diff, different, total / float64(rect.Dx()*rect.Dy())
+}
+
+// CIELAB coordinates of an sRGB color, L being the lightness from 0 to 100, under a D65 white.
+func cieLab(c color.NRGBA) Vertex3 {
+	linear := srgbToLinear(Vertex3{X: float64(c.R) / 255, Y: float64(c.G) / 255, Z: float64(c.B) / 255})
+
+	x := (0.4124*linear.X + 0.3576*linear.Y + 0.1805*linear.Z) / 0.95047
+	y := 0.2126*linear.X + 0.7152*linear.Y + 0.0722*linear.Z
+	z := (0.0193*linear.X + 0.1192*linear.Y + 0.9505*linear.Z) / 1.08883
+
+	f := func(t float64) float64 {
+		if t > 216.0/24389 {
+			return math.Cbrt(t)
+		}
+		return (24389.0/27*t + 16) / 116
+	}
+
+	return Vertex3{X: 116*f(y) - 16, Y: 500 * (f(x) - f(y)), Z: 200 * (f(y) - f(z))}
+}
+
+func TestGolden(t *testing.T) {
+	cases := []struct {
+		name    string
+		scene   string
+		options func(o *Options)
+	}{
+		{"basic", "basic.json", nil},
+		{"basic-gouraud", "basic.json", func(o *Options) { o.Shading = GouraudShading }},
+		{"basic-flat", "basic.json", func(o *Options) { o.Shading = FlatShading }},
+		{"basic-msaa", "basic.json", func(o *Options) { o.AntiAliasing = Multisampling }},
+		{"basic-ssaa", "basic.json", func(o *Options) { o.AntiAliasing = Supersampling }},
+		{"basic-fxaa", "basic.json", func(o *Options) { o.PostEffects = append(o.PostEffects, FXAAEffect{}) }},
+		{"basic-shadows", "basic.json", func(o *Options) { o.Shadows.Enabled = true }},
+		{"basic-wireframe", "basic.json", func(o *Options) { o.Wireframe = WireframeOverlay }},
+		{"basic-painter", "basic.json", func(o *Options) { o.Backend = Painter }},
+		{"basic-prepass", "basic.json", func(o *Options) { o.DepthPrepass = true }},
+		{"basic-depth", "basic.json", func(o *Options) { o.View = ViewDepth }},
+		{"basic-normals", "basic.json", func(o *Options) { o.View = ViewNormals }},
+		{"basic-uv", "basic.json", func(o *Options) { o.View = ViewUV }},
+		{"basic-toon", "basic.json", func(o *Options) { o.Toon = ToonOptions{Enabled: true, Outline: 1} }},
+		{"lights", "lights.json", nil},
+		{"lights-shadows", "lights.json", func(o *Options) { o.Shadows.Enabled = true }},
+		{"lights-deferred", "lights.json", func(o *Options) { o.Deferred = true }},
+		{"pbr", "pbr.json", nil},
+		{"pbr-aces", "pbr.json", func(o *Options) { o.ToneMapping = ACES }},
+		{"transparent", "transparent.json", nil},
+		{"instances", "instances.json", nil},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			checkGolden(t, c.name, renderGolden(t, c.scene, c.options))
+		})
+	}
+}
+
+// Rendering with one goroutine or many has to give the same image.
+func TestGoldenWorkers(t *testing.T) {
+	one := renderGolden(t, "basic.json", func(o *Options) { o.Workers = 1 })
+	many := renderGolden(t, "basic.json", func(o *Options) { o.Workers = 8 })
+
+	if !bytes.Equal(one.Pix, many.Pix) {
+		t.Error("images differ between one worker and eight")
+	}
+}
+
+// Path tracing in passes has to give the same image as in one go, the last pass having taken all
+// the samples.
+func TestPathTracingPasses(t *testing.T) {
+	pathTracing := func(o *Options) {
+		o.Backend = PathTracing
+		o.PathTracing.Samples = 6
+	}
+	whole := renderGolden(t, "basic.json", pathTracing)
+
+	var passes []Progress
+	img := renderGolden(t, "basic.json", func(o *Options) {
+		pathTracing(o)
+		o.Progress = func(p Progress) { passes = append(passes, p) }
+	})
+
+	if len(passes) != 4 || passes[3].Pass != 4 || passes[3].Passes != 4 || passes[3].Samples != 6 {
+		t.Fatalf("passes: %+v", passes)
+	}
+	if !bytes.Equal(whole.Pix, img.Pix) {
+		t.Error("images differ between one pass and many")
+	}
+}
+
+// Canceled renders give up with the error of the context.
+func TestRenderContextCanceled(t *testing.T) {
+	s, output, err := LoadScene(filepath.Join("testdata", "scenes", "basic.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	camera, _ := s.Camera()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	for _, backend := range []Backend{ZBuffer, PathTracing} {
+		o := DefaultOptions()
+		o.Width, o.Height = output.Width, output.Height
+		o.Backend = backend
+		if _, err := RenderContext(ctx, s, camera, o); err != context.Canceled {
+			t.Errorf("backend %d: got %v, want %v", backend, err, context.Canceled)
+		}
+	}
+}
+
+// Canceled loads give up with the error of the context too, rather than the one of the parser
+// missing the rest of the file.
+func TestLoadModelContextCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := LoadModelContext(ctx, filepath.Join("testdata", "models", "crate.obj")); err != context.Canceled {
+		t.Errorf("got %v, want %v", err, context.Canceled)
+	}
+
+	// Animated models stop being read too.
+	filename := filepath.Join(t.TempDir(), "empty.gltf")
+	if err := os.WriteFile(filename, []byte(`{"asset": {"version": "2.0"}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := LoadAnimatedModel(filename); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := LoadAnimatedModelContext(ctx, filename); err != context.Canceled {
+		t.Errorf("got %v, want %v", err, context.Canceled)
+	}
+}
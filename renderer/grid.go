@@ -0,0 +1,146 @@
+package renderer
+
+import (
+	"image"
+	"math"
+)
+
+// Ground grid under the scene for a sense of scale and orientation, like in the viewports of
+// modeling tools. It fades out in the distance, looking endless.
+type GridOptions struct {
+	Enabled bool
+	// Between minor lines, every tenth line being a major one. 0 picks a power of 10 after the
+	// size of the scene.
+	Spacing float64
+}
+
+var (
+	gridMinorColor = Vertex3{X: 0.35, Y: 0.35, Z: 0.35}
+	gridMajorColor = Vertex3{X: 0.6, Y: 0.6, Z: 0.6}
+	// The X axis is red and the Z axis blue, like in most modeling tools.
+	gridXAxisColor = Vertex3{X: 0.85, Y: 0.2, Z: 0.2}
+	gridZAxisColor = Vertex3{X: 0.2, Y: 0.35, Z: 0.85}
+)
+
+// Draws the grid at the height of the bottom of the scene, behind what's in front of it after the
+// depth buffer. Lines are antialiased after the size of pixels on the ground, fading away where
+// they would get too close together to make out.
+func drawGrid(img *image.RGBA, hdr *hdrImage, zBuffer []float64, scene *Scene, camera Camera, options GridOptions) {
+	rect := img.Bounds()
+	aspect := float64(rect.Dx()) / float64(rect.Dy())
+
+	// From clip space back to world space.
+	inverse, ok := camera.projectionMatrix(aspect).Multiply(camera.viewMatrix()).Inverse()
+	if !ok {
+		return
+	}
+
+	height, spacing := 0.0, 1.0
+	if bounds, ok := scene.bounds(); ok {
+		height = bounds.Min.Y
+		size := bounds.Max.minus(bounds.Min)
+		if extent := math.Max(size.X, math.Max(size.Y, size.Z)); extent > 0 {
+			spacing = math.Pow(10, math.Floor(math.Log10(extent))) / 10
+		}
+	}
+	if options.Spacing > 0 {
+		spacing = options.Spacing
+	}
+	// Fading out over the second half of the way to the far plane, where it would otherwise end in
+	// a straight line.
+	fade := math.Min(500*spacing, camera.Far)
+
+	forward := camera.Target.minus(camera.Position).normalize(1.0)
+
+	// Where the ray through a point of the screen meets the ground, and how far along from the near
+	// plane to the far plane.
+	hit := func(x, y float64) (Vertex3, float64, bool) {
+		ndcX := x/float64(rect.Dx())*2 - 1
+		ndcY := y/float64(rect.Dy())*2 - 1
+
+		near := Vertex4{X: ndcX, Y: ndcY, Z: 1, W: 1}
+		far := Vertex4{X: ndcX, Y: ndcY, Z: -1, W: 1}
+		near.transform(inverse)
+		far.transform(inverse)
+
+		origin := near.lower()
+		direction := far.lower().minus(origin)
+		if math.Abs(direction.Y) < 1e-12 {
+			return Vertex3{}, 0, false
+		}
+		t := (height - origin.Y) / direction.Y
+		return origin.plus(direction.scale(t)), t, t >= 0
+	}
+
+	for y := 0; y < rect.Dy(); y++ {
+		for x := 0; x < rect.Dx(); x++ {
+			p, t, ok := hit(float64(x)+0.5, float64(y)+0.5)
+			if !ok || t > 1 {
+				continue
+			}
+
+			distance := p.minus(camera.Position).dot(forward)
+			if zBuffer != nil && zBuffer[y*rect.Dx()+x] >= camera.depth(distance) {
+				continue
+			}
+
+			// How much the coordinates on the ground change from one pixel to the next, like
+			// fwidth in shaders. Lines along one axis stay thin while receding along the other.
+			right, _, okRight := hit(float64(x)+1.5, float64(y)+0.5)
+			up, _, okUp := hit(float64(x)+0.5, float64(y)+1.5)
+			if !okRight || !okUp {
+				continue
+			}
+			footprintX := math.Max(math.Abs(right.X-p.X)+math.Abs(up.X-p.X), 1e-12)
+			footprintZ := math.Max(math.Abs(right.Z-p.Z)+math.Abs(up.Z-p.Z), 1e-12)
+
+			// Lines fade away before getting too close together to make out.
+			lines := func(spacing, width float64) float64 {
+				x := gridLine(p.X, spacing, footprintX, width) * math.Max(0, math.Min(1, spacing/footprintX/4-1))
+				z := gridLine(p.Z, spacing, footprintZ, width) * math.Max(0, math.Min(1, spacing/footprintZ/4-1))
+				return math.Max(x, z)
+			}
+			minor, major := lines(spacing, 1), lines(10*spacing, 1.5)
+
+			c, alpha := gridMinorColor, 0.4*minor
+			if 0.7*major > alpha {
+				c, alpha = gridMajorColor, 0.7*major
+			}
+			if axis := gridLine(p.Z, math.Inf(1), footprintZ, 2); axis > 0 {
+				c, alpha = gridXAxisColor, math.Max(alpha, axis)
+			}
+			if axis := gridLine(p.X, math.Inf(1), footprintX, 2); axis > 0 {
+				c, alpha = gridZAxisColor, math.Max(alpha, axis)
+			}
+
+			alpha *= math.Max(0, math.Min(1, 2-2*distance/fade))
+			if alpha <= 0 {
+				continue
+			}
+
+			// Premultiplied, the grid showing over transparent backgrounds too.
+			dst := img.RGBAAt(x, y)
+			src := toRGBA(linearToSRGB(c))
+			blend := func(src, dst uint8) uint8 {
+				return uint8(float64(src)*alpha + float64(dst)*(1-alpha) + 0.5)
+			}
+			dst.R, dst.G, dst.B, dst.A = blend(src.R, dst.R), blend(src.G, dst.G), blend(src.B, dst.B), blend(255, dst.A)
+			img.SetRGBA(x, y, dst)
+
+			if hdr != nil {
+				hdr.set(x, y, hdr.at(x, y).lerp(c, alpha))
+			}
+		}
+	}
+}
+
+// How much of a pixel a line every spacing covers along a coordinate, the line being width
+// pixels wide and the pixel footprint wide on the ground along that coordinate. An infinite
+// spacing is a single line at 0.
+func gridLine(coordinate, spacing, footprint, width float64) float64 {
+	d := math.Abs(coordinate)
+	if !math.IsInf(spacing, 1) {
+		d = math.Abs(coordinate/spacing-math.Round(coordinate/spacing)) * spacing
+	}
+	return math.Max(0, math.Min(1, (width*footprint/2+footprint/2-d)/footprint))
+}
@@ -0,0 +1,209 @@
+package renderer
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+)
+
+const (
+	// Width of the panel, labels taking the left part of it, in pixels.
+	guiWidth, guiLabelWidth = 180, 70
+	guiMargin, guiPadding   = 4, 3
+)
+
+var (
+	guiBackground = color.RGBA{A: 160}
+	guiControl    = color.RGBA{R: 60, G: 60, B: 60, A: 255}
+	guiHighlight  = color.RGBA{R: 90, G: 140, B: 220, A: 255}
+)
+
+// Immediate-mode widgets, in a panel in the top-right corner of frames. Every frame, widgets get
+// declared again along with the values they show, drawn right away and changing the values from
+// the input received since the last frame. Nothing but the layout of the last frame gets kept
+// from one to the next, for telling which input goes to which widget.
+type gui struct {
+	// Where the panel and every widget were in the last frame, in pixels of the image.
+	panel   image.Rectangle
+	widgets []image.Rectangle
+	size    image.Point
+
+	// Widget being dragged, -1 for none, and whether the drag started over the panel, keeping it
+	// from going any further. Drags go on for as long as they start where the last one ended.
+	active  int
+	grabbed bool
+	last    image.Point
+
+	// Input received since the last frame, then the one of the frame being drawn.
+	events, pending []guiEvent
+
+	// Frame being drawn.
+	canvas *Canvas
+	cursor image.Point
+	bounds image.Rectangle
+	rects  []image.Rectangle
+}
+
+type guiEvent struct {
+	widget int
+	at     image.Point
+	drag   bool
+}
+
+func newGUI() *gui {
+	return &gui{active: -1}
+}
+
+// Window coordinates to pixels of the image, as they differ on high density displays.
+func (g *gui) toImage(p image.Point, viewport image.Rectangle) image.Point {
+	if viewport.Dx() == 0 || viewport.Dy() == 0 {
+		return p
+	}
+	return image.Point{
+		X: (p.X - viewport.Min.X) * g.size.X / viewport.Dx(),
+		Y: (p.Y - viewport.Min.Y) * g.size.Y / viewport.Dy(),
+	}
+}
+
+// Whether the click was for the panel, which then gets it.
+func (g *gui) click(at image.Point, viewport image.Rectangle) bool {
+	p := g.toImage(at, viewport)
+	if !p.In(g.panel) {
+		return false
+	}
+
+	for i, r := range g.widgets {
+		if p.In(r) {
+			g.events = append(g.events, guiEvent{widget: i, at: p})
+		}
+	}
+	return true
+}
+
+// Whether the drag was for the panel, which then gets it.
+func (g *gui) drag(from, to image.Point, viewport image.Rectangle) bool {
+	if from != g.last {
+		p := g.toImage(from, viewport)
+		g.active, g.grabbed = -1, p.In(g.panel)
+		for i, r := range g.widgets {
+			if p.In(r) {
+				g.active = i
+			}
+		}
+	}
+	g.last = to
+
+	if g.active >= 0 {
+		g.events = append(g.events, guiEvent{widget: g.active, at: g.toImage(to, viewport), drag: true})
+	}
+	return g.grabbed
+}
+
+// Starts a frame of widgets drawn over the image, as it is while rendering.
+func (g *gui) begin(img *image.RGBA) {
+	g.size = img.Bounds().Size()
+	g.canvas = newFlippedCanvas(img)
+	g.cursor = image.Point{X: g.size.X - guiMargin - guiWidth, Y: guiMargin}
+	g.bounds = image.Rectangle{}
+	g.rects = g.rects[:0]
+	g.pending, g.events = g.events, nil
+}
+
+func (g *gui) end() {
+	// Rows leave the padding below the last one out.
+	if !g.bounds.Empty() {
+		bottom := image.Rect(g.bounds.Min.X, g.bounds.Max.Y, g.bounds.Max.X, g.bounds.Max.Y+guiPadding)
+		g.canvas.FillRect(bottom, guiBackground)
+		g.bounds = g.bounds.Union(bottom)
+	}
+
+	g.panel = g.bounds
+	g.widgets = append(g.widgets[:0], g.rects...)
+}
+
+// Lays out the next widget, drawing its label. Returns its index and the rectangle of its control.
+func (g *gui) row(label string) (int, image.Rectangle) {
+	height := overlayFont.Measure("M").Y + 2*guiPadding
+	row := image.Rect(g.cursor.X, g.cursor.Y, g.cursor.X+guiWidth, g.cursor.Y+height)
+	g.cursor.Y += height + guiPadding
+
+	// Along with the padding above it, which is the one below the row before.
+	background := image.Rect(row.Min.X-guiPadding, row.Min.Y-guiPadding, row.Max.X+guiPadding, row.Max.Y)
+	g.canvas.FillRect(background, guiBackground)
+	g.bounds = g.bounds.Union(background)
+	g.canvas.DrawText(overlayFont, row.Min.Add(image.Point{Y: guiPadding}), label, color.White)
+
+	id := len(g.rects)
+	control := image.Rect(row.Min.X+guiLabelWidth, row.Min.Y, row.Max.X, row.Max.Y)
+	g.rects = append(g.rects, control)
+	return id, control
+}
+
+// Value between min and max, set by clicking or dragging along the track. Reports whether it
+// changed.
+func (g *gui) slider(label string, value *float64, min, max float64) bool {
+	id, track := g.row(label)
+
+	changed := false
+	for _, e := range g.pending {
+		if e.widget == id {
+			t := math.Max(0, math.Min(1, float64(e.at.X-track.Min.X)/float64(track.Dx()-1)))
+			*value = min + t*(max-min)
+			changed = true
+		}
+	}
+
+	t := math.Max(0, math.Min(1, (*value-min)/(max-min)))
+	filled := track
+	filled.Max.X = track.Min.X + int(t*float64(track.Dx())+0.5)
+	g.canvas.FillRect(track, guiControl)
+	g.canvas.FillRect(filled, guiHighlight)
+	g.canvas.DrawText(overlayFont, track.Min.Add(image.Point{X: guiPadding, Y: guiPadding}), fmt.Sprintf("%.2f", *value), color.White)
+
+	return changed
+}
+
+// Toggled by clicks. Reports whether it changed.
+func (g *gui) checkbox(label string, value *bool) bool {
+	id, control := g.row(label)
+
+	changed := false
+	for _, e := range g.pending {
+		if e.widget == id && !e.drag {
+			*value = !*value
+			changed = true
+		}
+	}
+
+	size := control.Dy()
+	box := image.Rect(control.Min.X, control.Min.Y, control.Min.X+size, control.Max.Y)
+	g.canvas.FillRect(box, guiControl)
+	if *value {
+		g.canvas.FillRect(box.Inset(guiPadding), guiHighlight)
+	}
+
+	return changed
+}
+
+// One of the choices, the next one on every click. Reports whether it changed.
+func (g *gui) choice(label string, value *int, choices []string) bool {
+	id, control := g.row(label)
+
+	changed := false
+	for _, e := range g.pending {
+		if e.widget == id && !e.drag {
+			*value = (*value + 1) % len(choices)
+			changed = true
+		}
+	}
+
+	name := "?"
+	if *value >= 0 && *value < len(choices) {
+		name = choices[*value]
+	}
+	g.canvas.FillRect(control, guiControl)
+	g.canvas.DrawText(overlayFont, control.Min.Add(image.Point{X: guiPadding, Y: guiPadding}), name, color.White)
+
+	return changed
+}
@@ -0,0 +1,247 @@
+package renderer
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Linear RGB image, without the 0 to 1 limit of regular images, for light sources like environments.
+// Row 0 is the top of the image.
+type hdrImage struct {
+	width  int
+	height int
+	pixels []Vertex3
+}
+
+func newHDRImage(width, height int) *hdrImage {
+	return &hdrImage{width: width, height: height, pixels: make([]Vertex3, width*height)}
+}
+
+func (h *hdrImage) at(x, y int) Vertex3 {
+	return h.pixels[y*h.width+x]
+}
+
+func (h *hdrImage) set(x, y int, c Vertex3) {
+	h.pixels[y*h.width+x] = c
+}
+
+// Bilinear sampling, texture coordinates wrapping around horizontally and clamped vertically.
+func (h *hdrImage) sample(uv Vertex2) Vertex3 {
+	x := uv.X*float64(h.width) - 0.5
+	y := uv.Y*float64(h.height) - 0.5
+	x0, y0 := math.Floor(x), math.Floor(y)
+	fx, fy := x-x0, y-y0
+
+	texel := func(x, y int) Vertex3 {
+		x = ((x % h.width) + h.width) % h.width
+		y = minInt(maxInt(y, 0), h.height-1)
+		return h.at(x, y)
+	}
+
+	top := texel(int(x0), int(y0)).lerp(texel(int(x0)+1, int(y0)), fx)
+	bottom := texel(int(x0), int(y0)+1).lerp(texel(int(x0)+1, int(y0)+1), fx)
+
+	return top.lerp(bottom, fy)
+}
+
+// Copy of the image upside down, for going between top to bottom and bottom to top row orders.
+func (h *hdrImage) flipVertically() *hdrImage {
+	flipped := newHDRImage(h.width, h.height)
+	for y := 0; y < h.height; y++ {
+		copy(flipped.pixels[(h.height-1-y)*h.width:(h.height-y)*h.width], h.pixels[y*h.width:(y+1)*h.width])
+	}
+	return flipped
+}
+
+// Box filtered copy of the image at a lower resolution.
+func (h *hdrImage) downsample(width, height int) *hdrImage {
+	if width >= h.width || height >= h.height {
+		return h
+	}
+
+	small := newHDRImage(width, height)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			x0, x1 := x*h.width/width, (x+1)*h.width/width
+			y0, y1 := y*h.height/height, (y+1)*h.height/height
+
+			sum := Vertex3{}
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					sum = sum.plus(h.at(sx, sy))
+				}
+			}
+			small.set(x, y, sum.scale(1/float64((x1-x0)*(y1-y0))))
+		}
+	}
+
+	return small
+}
+
+// Radiance .hdr files are read as they are, other images get decoded from sRGB to linear colors
+// between 0 and 1.
+func loadHDRImage(filename string) (*hdrImage, error) {
+	if strings.ToLower(filepath.Ext(filename)) == ".hdr" {
+		return loadRadianceHDR(filename)
+	}
+
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	img, _, err := image.Decode(file)
+	if err != nil {
+		return nil, err
+	}
+
+	bounds := img.Bounds()
+	h := newHDRImage(bounds.Dx(), bounds.Dy())
+	for y := 0; y < h.height; y++ {
+		for x := 0; x < h.width; x++ {
+			r, g, b, _ := img.At(bounds.Min.X+x, bounds.Min.Y+y).RGBA()
+			h.set(x, y, srgbToLinear(Vertex3{X: float64(r) / 0xffff, Y: float64(g) / 0xffff, Z: float64(b) / 0xffff}))
+		}
+	}
+
+	return h, nil
+}
+
+// Radiance RGBE files, flat or run-length encoded, in the usual top to bottom, left to right order.
+func loadRadianceHDR(filename string) (*hdrImage, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+
+	line, err := reader.ReadString('\n')
+	if err != nil || !strings.HasPrefix(line, "#?") {
+		return nil, errors.New(fmt.Sprintf("%s is not a Radiance HDR file", filename))
+	}
+
+	// Header variables, up to an empty line.
+	for {
+		line, err = reader.ReadString('\n')
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("truncated header in %s", filename))
+		}
+
+		line = strings.TrimSpace(line)
+		if line == "" {
+			break
+		}
+		if strings.HasPrefix(line, "FORMAT=") && line != "FORMAT=32-bit_rle_rgbe" {
+			return nil, errors.New(fmt.Sprintf("unsupported %s in %s", line, filename))
+		}
+	}
+
+	line, err = reader.ReadString('\n')
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("missing resolution in %s", filename))
+	}
+	parts := strings.Fields(line)
+	if len(parts) != 4 || parts[0] != "-Y" || parts[2] != "+X" {
+		return nil, errors.New(fmt.Sprintf("unsupported resolution %q in %s", strings.TrimSpace(line), filename))
+	}
+	height, err1 := strconv.Atoi(parts[1])
+	width, err2 := strconv.Atoi(parts[3])
+	if err1 != nil || err2 != nil || width <= 0 || height <= 0 {
+		return nil, errors.New(fmt.Sprintf("invalid resolution %q in %s", strings.TrimSpace(line), filename))
+	}
+
+	h := newHDRImage(width, height)
+	scanline := make([][4]byte, width)
+
+	for y := 0; y < height; y++ {
+		if err := readRGBEScanline(reader, scanline); err != nil {
+			return nil, errors.New(fmt.Sprintf("invalid scanline %d in %s: %s", y, filename, err))
+		}
+
+		for x, rgbe := range scanline {
+			// A shared exponent for the three mantissas.
+			if rgbe[3] == 0 {
+				continue
+			}
+			f := math.Ldexp(1, int(rgbe[3])-(128+8))
+			h.set(x, y, Vertex3{X: float64(rgbe[0]) * f, Y: float64(rgbe[1]) * f, Z: float64(rgbe[2]) * f})
+		}
+	}
+
+	return h, nil
+}
+
+func readRGBEScanline(reader *bufio.Reader, scanline [][4]byte) error {
+	width := len(scanline)
+
+	var header [4]byte
+	if _, err := io.ReadFull(reader, header[:]); err != nil {
+		return err
+	}
+
+	// Run-length encoded scanlines start with 2, 2 and their width, anything else is flat pixels.
+	if width < 8 || width > 0x7fff || header[0] != 2 || header[1] != 2 || header[2]&0x80 != 0 {
+		scanline[0] = header
+		for x := 1; x < width; x++ {
+			if _, err := io.ReadFull(reader, scanline[x][:]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if int(header[2])<<8|int(header[3]) != width {
+		return errors.New("scanline width mismatch")
+	}
+
+	// Each channel is encoded separately, as runs of one value or sequences of literal values.
+	for c := 0; c < 4; c++ {
+		for x := 0; x < width; {
+			count, err := reader.ReadByte()
+			if err != nil {
+				return err
+			}
+
+			if count > 128 {
+				run := int(count) - 128
+				if x+run > width {
+					return errors.New("run overflows the scanline")
+				}
+				value, err := reader.ReadByte()
+				if err != nil {
+					return err
+				}
+				for ; run > 0; run-- {
+					scanline[x][c] = value
+					x++
+				}
+				continue
+			}
+
+			if count == 0 || x+int(count) > width {
+				return errors.New("invalid literal count")
+			}
+			for i := 0; i < int(count); i++ {
+				value, err := reader.ReadByte()
+				if err != nil {
+					return err
+				}
+				scanline[x][c] = value
+				x++
+			}
+		}
+	}
+
+	return nil
+}
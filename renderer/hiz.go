@@ -0,0 +1,155 @@
+package renderer
+
+import (
+	"image"
+	"math"
+)
+
+// Pixels per side of the texels of the finest level of depth pyramids, every coarser level
+// doubling it up to the tile size so that workers drawing different tiles never share a texel.
+const hizBlock = 8
+
+// Depths interpolated inside a triangle can go slightly past the ones of its corners by rounding.
+const hizEpsilon = 1e-9
+
+// Coarse levels over a z-buffer, every texel holding the furthest depth of the pixels below it,
+// so that triangles behind everything drawn so far get rejected before any per-pixel work.
+// Depths only ever grow towards the camera, so texels not refreshed yet err on the safe side.
+type depthPyramid struct {
+	depths []float64
+	width  int
+	height int
+	// Depths per pixel, one after another, with multisampling.
+	samples int
+
+	levels []depthLevel
+}
+
+type depthLevel struct {
+	block   int
+	columns int
+	rows    int
+	far     []float64
+	// Texels whose pixels may have been drawn since they were last computed.
+	dirty []bool
+}
+
+func newDepthPyramid(depths []float64, width, height, samples int) *depthPyramid {
+	p := &depthPyramid{depths: depths, width: width, height: height, samples: samples}
+
+	for block := hizBlock; block <= tileSize; block *= 2 {
+		level := depthLevel{
+			block:   block,
+			columns: (width + block - 1) / block,
+			rows:    (height + block - 1) / block,
+		}
+		level.far = make([]float64, level.columns*level.rows)
+		level.dirty = make([]bool, len(level.far))
+		fillFloat64s(level.far, math.Inf(-1))
+
+		p.levels = append(p.levels, level)
+	}
+
+	return p
+}
+
+// Whether the part of the triangle inside the region is behind everything already drawn there.
+func (p *depthPyramid) occluded(triangle Triangle, region image.Rectangle) bool {
+	min, max, ok := p.bounds(triangle, region)
+	if !ok {
+		return false
+	}
+
+	nearest := math.Max(math.Max(triangle.depths[0], triangle.depths[1]), triangle.depths[2]) + hizEpsilon
+
+	top := len(p.levels) - 1
+	block := p.levels[top].block
+	for ty := min.Y / block; ty <= max.Y/block; ty++ {
+		for tx := min.X / block; tx <= max.X/block; tx++ {
+			if !p.hides(top, tx, ty, min, max, nearest) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// Marks the texels below the triangle as out of date, after it got drawn.
+func (p *depthPyramid) invalidate(triangle Triangle, region image.Rectangle) {
+	min, max, ok := p.bounds(triangle, region)
+	if !ok {
+		return
+	}
+
+	for i := range p.levels {
+		level := &p.levels[i]
+		for ty := min.Y / level.block; ty <= max.Y/level.block; ty++ {
+			for tx := min.X / level.block; tx <= max.X/level.block; tx++ {
+				level.dirty[ty*level.columns+tx] = true
+			}
+		}
+	}
+}
+
+// Pixels of the triangle's bounding box inside the region, inclusive.
+func (p *depthPyramid) bounds(triangle Triangle, region image.Rectangle) (image.Point, image.Point, bool) {
+	region = region.Intersect(image.Rect(0, 0, p.width, p.height))
+
+	min, max := triangle.pixelBounds()
+	min.X, min.Y = maxInt(min.X, region.Min.X), maxInt(min.Y, region.Min.Y)
+	max.X, max.Y = minInt(max.X, region.Max.X-1), minInt(max.Y, region.Max.Y-1)
+
+	return min, max, min.X <= max.X && min.Y <= max.Y
+}
+
+// Whether the pixels of the texel between min and max are all at least as near as depth. Texels
+// which aren't, as a whole, may still be through their children overlapping the rectangle.
+func (p *depthPyramid) hides(level, tx, ty int, min, max image.Point, depth float64) bool {
+	if p.far(level, tx, ty) >= depth {
+		return true
+	}
+	if level == 0 {
+		return false
+	}
+
+	block := p.levels[level-1].block
+	for cy := maxInt(2*ty, min.Y/block); cy <= minInt(2*ty+1, max.Y/block); cy++ {
+		for cx := maxInt(2*tx, min.X/block); cx <= minInt(2*tx+1, max.X/block); cx++ {
+			if !p.hides(level-1, cx, cy, min, max, depth) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// Furthest depth below the texel, computed again from the level below when out of date.
+func (p *depthPyramid) far(level, tx, ty int) float64 {
+	l := &p.levels[level]
+	i := ty*l.columns + tx
+	if !l.dirty[i] {
+		return l.far[i]
+	}
+
+	far := math.Inf(1)
+	if level == 0 {
+		for y := ty * l.block; y < minInt((ty+1)*l.block, p.height); y++ {
+			row := p.depths[(y*p.width+tx*l.block)*p.samples : (y*p.width+minInt((tx+1)*l.block, p.width))*p.samples]
+			for _, depth := range row {
+				far = math.Min(far, depth)
+			}
+		}
+	} else {
+		below := &p.levels[level-1]
+		for cy := 2 * ty; cy <= minInt(2*ty+1, below.rows-1); cy++ {
+			for cx := 2 * tx; cx <= minInt(2*tx+1, below.columns-1); cx++ {
+				far = math.Min(far, p.far(level-1, cx, cy))
+			}
+		}
+	}
+
+	l.far[i], l.dirty[i] = far, false
+	return far
+}
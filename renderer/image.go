@@ -0,0 +1,115 @@
+package renderer
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Quality of JPEG output, from 1 to 100.
+const jpegQuality = 90
+
+// The format follows the extension: JPEG, PPM, PAM, or PNG otherwise.
+func SaveImage(img image.Image, filename string) error {
+	output, err := os.OpenFile(filename, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0777)
+	if err != nil {
+		return err
+	}
+
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".jpg", ".jpeg":
+		err = jpeg.Encode(output, img, &jpeg.Options{Quality: jpegQuality})
+	case ".ppm":
+		err = encodePPM(output, img)
+	case ".pam":
+		err = encodePAM(output, img)
+	default:
+		err = png.Encode(output, img)
+	}
+	if err != nil {
+		output.Close()
+		return err
+	}
+
+	return output.Close()
+}
+
+// Textures are flipped on load, as texture coordinates start from the bottom. Names starting with
+// @ are debug textures instead of files, @checker or @uv.
+func LoadTexture(filename string) (image.Image, error) {
+	return LoadTextureContext(context.Background(), filename)
+}
+
+// Same as LoadTexture, giving up with the error of the context once it's canceled.
+func LoadTextureContext(ctx context.Context, filename string) (image.Image, error) {
+	if strings.HasPrefix(filename, "@") {
+		return loadDebugTexture(filename[1:])
+	}
+
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	texture, _, err := image.Decode(contextReader{ctx, file})
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return flipImageVertically(texture.Bounds(), texture), nil
+}
+
+func newImage(rect image.Rectangle) *image.RGBA {
+	img := image.NewRGBA(rect)
+	fillRGBA(img.Pix, color.RGBA{R: 0, G: 0, B: 0, A: 255})
+
+	return img
+}
+
+// Copy of the image upside down, starting at 0, 0.
+func flipImageVertically(rect image.Rectangle, img image.Image) *image.RGBA {
+	rgba := image.NewRGBA(image.Rect(0, 0, rect.Dx(), rect.Dy()))
+
+	if src, ok := img.(*image.RGBA); ok {
+		row := rect.Dx() * 4
+		for y := 0; y < rect.Dy(); y++ {
+			i := src.PixOffset(rect.Min.X, rect.Min.Y+y)
+			copy(rgba.Pix[(rect.Dy()-1-y)*rgba.Stride:], src.Pix[i:i+row])
+		}
+		return rgba
+	}
+
+	for y := 0; y < rect.Dy(); y++ {
+		for x := 0; x < rect.Dx(); x++ {
+			rgba.Set(x, rect.Dy()-1-y, img.At(rect.Min.X+x, rect.Min.Y+y))
+		}
+	}
+
+	return rgba
+}
+
+// Fills the image with the clear color, opaque black when nil, and the HDR image when there's one
+// with the same color in linear space.
+func clearImage(img *image.RGBA, hdr *hdrImage, clear color.Color) {
+	c := color.RGBA{A: 255}
+	if clear != nil {
+		c = color.RGBAModel.Convert(clear).(color.RGBA)
+	}
+	fillRGBA(img.Pix, c)
+
+	if hdr != nil {
+		linear := decodeSRGB(c)
+		for i := range hdr.pixels {
+			hdr.pixels[i] = linear
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package renderer
+
+import "math"
+
+type Light interface {
+	// Direction from a world space position towards the light, and how much of the light reaches it.
+	illuminate(position Vertex3) (Vertex3, float64)
+	// The same light, moved from its node's space to world space.
+	transform(world Matrix4) Light
+	// Sphere outside of which less than the given amount of the light reaches, of infinite radius
+	// for lights reaching everywhere.
+	reach(amount float64) (Vertex3, float64)
+}
+
+// Light coming from infinitely far away, like the sun, shining along Direction.
+type DirectionalLight struct {
+	Direction Vertex3
+	Intensity float64
+}
+
+// Light shining equally in all directions, fading with distance.
+type PointLight struct {
+	Position  Vertex3
+	Intensity float64
+	// Light gets divided by Constant + Linear * distance + Quadratic * distance².
+	Constant  float64
+	Linear    float64
+	Quadratic float64
+}
+
+// Point light restricted to a cone, fully lit inside InnerAngle and fading out up to OuterAngle.
+// Angles are in radians, from the axis of the cone.
+type SpotLight struct {
+	PointLight
+	Direction  Vertex3
+	InnerAngle float64
+	OuterAngle float64
+}
+
+func newPointLight(position Vertex3) PointLight {
+	return PointLight{
+		Position:  position,
+		Intensity: 1,
+		Constant:  1,
+		Linear:    0.09,
+		Quadratic: 0.032,
+	}
+}
+
+func newSpotLight(position, direction Vertex3) SpotLight {
+	return SpotLight{
+		PointLight: newPointLight(position),
+		Direction:  direction,
+		InnerAngle: math.Pi / 12,
+		OuterAngle: math.Pi / 8,
+	}
+}
+
+func (l DirectionalLight) illuminate(position Vertex3) (Vertex3, float64) {
+	return l.Direction.normalize(-1.0), l.Intensity
+}
+
+func (l DirectionalLight) transform(world Matrix4) Light {
+	l.Direction = world.transformDirection(l.Direction)
+	return l
+}
+
+func (l DirectionalLight) reach(amount float64) (Vertex3, float64) {
+	return Vertex3{}, math.Inf(1)
+}
+
+func (l PointLight) illuminate(position Vertex3) (Vertex3, float64) {
+	toLight := l.Position.minus(position)
+	distance := toLight.length()
+	attenuation := l.Constant + l.Linear*distance + l.Quadratic*distance*distance
+
+	return toLight.normalize(1.0), l.Intensity / math.Max(attenuation, 1e-9)
+}
+
+func (l PointLight) transform(world Matrix4) Light {
+	l.Position = world.transformPoint(l.Position)
+	return l
+}
+
+// Spot lights reach as far as the point lights they're made of, their cone leaving that unchanged.
+func (l PointLight) reach(amount float64) (Vertex3, float64) {
+	// Where the attenuation divides the intensity down to the amount.
+	a, b, c := l.Quadratic, l.Linear, l.Constant-l.Intensity/amount
+	switch {
+	case c >= 0:
+		return l.Position, 0
+	case a > 0:
+		return l.Position, (-b + math.Sqrt(b*b-4*a*c)) / (2 * a)
+	case b > 0:
+		return l.Position, -c / b
+	}
+	return l.Position, math.Inf(1)
+}
+
+func (l SpotLight) illuminate(position Vertex3) (Vertex3, float64) {
+	direction, intensity := l.PointLight.illuminate(position)
+
+	// Smooth falloff between the inner and the outer cone.
+	cosAngle := direction.scale(-1).dot(l.Direction.normalize(1.0))
+	cosInner, cosOuter := math.Cos(l.InnerAngle), math.Cos(l.OuterAngle)
+	t := math.Max(0, math.Min(1, (cosAngle-cosOuter)/math.Max(cosInner-cosOuter, 1e-9)))
+
+	return direction, intensity * t * t * (3 - 2*t)
+}
+
+func (l SpotLight) transform(world Matrix4) Light {
+	l.PointLight = l.PointLight.transform(world).(PointLight)
+	l.Direction = world.transformDirection(l.Direction)
+	return l
+}
+
+// Lambertian diffuse lighting of a surface by all the lights, capped to 1.
+func lightIntensity(lights []Light, normal, position Vertex3) float64 {
+	intensity := 0.0
+	for _, light := range lights {
+		direction, amount := light.illuminate(position)
+		intensity += math.Max(0, normal.dot(direction)) * amount
+	}
+	return math.Min(1, intensity)
+}
@@ -0,0 +1,118 @@
+package renderer
+
+import "math"
+
+// Cells along every axis of light grids.
+const lightGridSize = 16
+
+// With light culling, less light than this reaching a point leaves it unlit, less than a level
+// of 8-bit colors for a white surface.
+const lightCutoff = 1.0 / 256
+
+// Lights binned into the cells of a grid over the scene, so that shading a point only goes
+// through the lights reaching its cell rather than all of them. Light fading with distance only
+// reaches the cells within its range, light reaching everywhere goes in all of them.
+type lightGrid struct {
+	bounds AABB
+	cell   Vertex3
+	// Indices of the lights reaching every cell, in the order of the lights, x varying fastest
+	// then y.
+	cells [][]int
+	// Indices of all the lights, for points outside of the grid.
+	all []int
+}
+
+func newLightGrid(lights []Light, bounds AABB) *lightGrid {
+	g := &lightGrid{bounds: bounds, cells: make([][]int, lightGridSize*lightGridSize*lightGridSize)}
+
+	// Flat scenes still get cells of some thickness.
+	extent := bounds.Max.minus(bounds.Min)
+	g.cell = Vertex3{
+		X: math.Max(extent.X/lightGridSize, 1e-9),
+		Y: math.Max(extent.Y/lightGridSize, 1e-9),
+		Z: math.Max(extent.Z/lightGridSize, 1e-9),
+	}
+
+	for i, light := range lights {
+		g.all = append(g.all, i)
+
+		center, radius := light.reach(lightCutoff)
+		min, max := g.index(center.minus(Vertex3{X: radius, Y: radius, Z: radius})), g.index(center.plus(Vertex3{X: radius, Y: radius, Z: radius}))
+		if math.IsInf(radius, 1) {
+			min, max = [3]int{}, [3]int{lightGridSize - 1, lightGridSize - 1, lightGridSize - 1}
+		}
+
+		for z := min[2]; z <= max[2]; z++ {
+			for y := min[1]; y <= max[1]; y++ {
+				for x := min[0]; x <= max[0]; x++ {
+					if !math.IsInf(radius, 1) && g.distance(x, y, z, center) > radius {
+						continue
+					}
+					k := (z*lightGridSize+y)*lightGridSize + x
+					g.cells[k] = append(g.cells[k], i)
+				}
+			}
+		}
+	}
+
+	return g
+}
+
+// Indices of the lights that can reach the position.
+func (g *lightGrid) at(position Vertex3) []int {
+	b := g.bounds
+	if position.X < b.Min.X || position.Y < b.Min.Y || position.Z < b.Min.Z || position.X > b.Max.X || position.Y > b.Max.Y || position.Z > b.Max.Z {
+		return g.all
+	}
+
+	i := g.index(position)
+	return g.cells[(i[2]*lightGridSize+i[1])*lightGridSize+i[0]]
+}
+
+// Cell the position is in, or the nearest one outside of the grid.
+func (g *lightGrid) index(position Vertex3) [3]int {
+	cell := func(v, min, size float64) int {
+		return int(math.Max(0, math.Min(lightGridSize-1, math.Floor((v-min)/size))))
+	}
+
+	return [3]int{
+		cell(position.X, g.bounds.Min.X, g.cell.X),
+		cell(position.Y, g.bounds.Min.Y, g.cell.Y),
+		cell(position.Z, g.bounds.Min.Z, g.cell.Z),
+	}
+}
+
+// Distance from the point to the nearest point of the cell.
+func (g *lightGrid) distance(x, y, z int, p Vertex3) float64 {
+	axis := func(i int, v, min, size float64) float64 {
+		low := min + float64(i)*size
+		return math.Max(0, math.Max(low-v, v-(low+size)))
+	}
+
+	return Vertex3{
+		X: axis(x, p.X, g.bounds.Min.X, g.cell.X),
+		Y: axis(y, p.Y, g.bounds.Min.Y, g.cell.Y),
+		Z: axis(z, p.Z, g.bounds.Min.Z, g.cell.Z),
+	}.length()
+}
+
+// Calls fn for every light reaching the position, with the direction towards it and how much of
+// it reaches. With a light grid, only the lights of the cell of the position get looked at, and
+// the ones reaching less than the cutoff get left out.
+func (s shading) eachLight(position Vertex3, fn func(i int, direction Vertex3, amount float64)) {
+	if s.grid == nil {
+		for i, light := range s.lights {
+			direction, amount := light.illuminate(position)
+			fn(i, direction, amount)
+		}
+		return
+	}
+
+	for _, i := range s.grid.at(position) {
+		direction, amount := s.lights[i].illuminate(position)
+		if amount < lightCutoff {
+			continue
+		}
+		fn(i, direction, amount)
+	}
+}
@@ -1,11 +1,12 @@
-package main
+package renderer
 
 import (
-	"image"
 	"image/color"
+	"image/draw"
 )
 
-func drawLine(img *image.RGBA, x1, y1, x2, y2 int, col color.Color) {
+// Bresenham's line algorithm, setting every pixel of the line once, both ends included.
+func drawLine(img draw.Image, x1, y1, x2, y2 int, col color.Color) {
 	var dx, dy, e, slope int
 
 	if x1 > x2 {
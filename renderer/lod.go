@@ -0,0 +1,75 @@
+package renderer
+
+import (
+	"image"
+	"math"
+)
+
+// Every level of detail has about half the faces of the previous one, down to this many.
+const lodMinFaces = 64
+
+// Below this much screen area per face, the faces of a mesh are too small to make a difference
+// and a simpler version of it gets drawn instead.
+const lodPixelsPerFace = 4
+
+// Simplifies every mesh of the scene into levels of detail, once per mesh.
+func (s *Scene) GenerateLODs(levels int) {
+	s.walk(func(node *Node, world Matrix4) {
+		if node.Mesh != nil && len(node.Mesh.LODs) == 0 {
+			node.Mesh.generateLODs(levels)
+		}
+	})
+}
+
+func (obj *Obj) generateLODs(levels int) {
+	previous := obj
+	for len(obj.LODs) < levels && len(previous.Faces)/2 >= lodMinFaces {
+		previous = simplify(previous, len(previous.Faces)/2)
+		obj.LODs = append(obj.LODs, previous)
+	}
+}
+
+// The simplest version of the mesh still having enough faces for its size on screen, m bringing
+// it to clip space.
+func (obj *Obj) lod(m Matrix4, rect image.Rectangle) *Obj {
+	if len(obj.LODs) == 0 {
+		return obj
+	}
+
+	area, ok := obj.Bounds.screenArea(m, rect)
+	if !ok {
+		return obj
+	}
+
+	lod := obj
+	for _, simpler := range obj.LODs {
+		if float64(len(simpler.Faces))*lodPixelsPerFace < area {
+			break
+		}
+		lod = simpler
+	}
+
+	return lod
+}
+
+// Area of the rectangle holding the box on screen, in pixels. Boxes going behind the camera
+// don't have one.
+func (b AABB) screenArea(m Matrix4, rect image.Rectangle) (float64, bool) {
+	min := Vertex2{X: math.Inf(1), Y: math.Inf(1)}
+	max := Vertex2{X: math.Inf(-1), Y: math.Inf(-1)}
+
+	for _, corner := range b.corners() {
+		v := Vertex4{X: corner.X, Y: corner.Y, Z: corner.Z, W: 1}
+		v.transform(m)
+		if v.W <= 0 {
+			return 0, false
+		}
+
+		x, y := v.X/v.W, v.Y/v.W
+		min = Vertex2{X: math.Min(min.X, x), Y: math.Min(min.Y, y)}
+		max = Vertex2{X: math.Max(max.X, x), Y: math.Max(max.Y, y)}
+	}
+
+	// Normalized device coordinates go from -1 to 1.
+	return (max.X - min.X) / 2 * float64(rect.Dx()) * (max.Y - min.Y) / 2 * float64(rect.Dy()), true
+}
@@ -0,0 +1,82 @@
+package renderer
+
+import "time"
+
+// Main loop of interactive viewers. Updates happen at a fixed rate so that animations and camera
+// movement go at the same speed on every machine, while frames get rendered as often as the frame
+// rate cap and the display allow.
+type FrameLoop struct {
+	// Seconds of simulated time per update.
+	Timestep float64
+	// Above 0, frames per second the loop sleeps to stay under.
+	MaxFPS float64
+	// Updates per frame at most. Frames too slow to catch up with would otherwise take longer and
+	// longer, each one having more updates to run than the previous one.
+	MaxUpdates int
+
+	Update func(dt float64)
+	// Alpha is how far between the last update and the next one the frame is, from 0 to 1, for
+	// drawing moving things where they'd be in between.
+	Render func(alpha float64)
+	// Shows the rendered frame, optional. With vsync, backends wait for the vertical blank there,
+	// which caps the frame rate to the display's refresh rate.
+	Present func()
+
+	// Measured over the last second.
+	FPS float64
+}
+
+func newFrameLoop(update func(dt float64), render func(alpha float64)) *FrameLoop {
+	return &FrameLoop{
+		Timestep:   1.0 / 60,
+		MaxUpdates: 5,
+		Update:     update,
+		Render:     render,
+	}
+}
+
+// Runs frames for as long as running says so.
+func (l *FrameLoop) Run(running func() bool) {
+	step := time.Duration(l.Timestep * float64(time.Second))
+
+	previous := time.Now()
+	var lag time.Duration
+
+	frames := 0
+	second := previous
+
+	for running() {
+		start := time.Now()
+		lag += start.Sub(previous)
+		previous = start
+
+		for updates := 0; lag >= step; updates++ {
+			// Time that can't be caught up with is dropped, slowing the simulation down instead.
+			if l.MaxUpdates > 0 && updates == l.MaxUpdates {
+				lag %= step
+				break
+			}
+
+			l.Update(l.Timestep)
+			lag -= step
+		}
+
+		l.Render(float64(lag) / float64(step))
+		if l.Present != nil {
+			l.Present()
+		}
+
+		if l.MaxFPS > 0 {
+			frame := time.Duration(float64(time.Second) / l.MaxFPS)
+			if elapsed := time.Since(start); elapsed < frame {
+				time.Sleep(frame - elapsed)
+			}
+		}
+
+		frames++
+		if elapsed := time.Since(second); elapsed >= time.Second {
+			l.FPS = float64(frames) / elapsed.Seconds()
+			frames, second = 0, time.Now()
+		}
+	}
+}
@@ -0,0 +1,210 @@
+package renderer
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"image"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Lookup table mapping colors to graded ones, like the .cube files color grading tools export to
+// match a film look. Colors are the sRGB encoded values of images, between 0 and 1.
+type LUT struct {
+	Title string
+	// Entries along every axis of the 3D table, or of the three curves of a 1D one.
+	Size int
+	// Three curves, one per channel, rather than a cube.
+	OneDimensional bool
+	// Colors the first and last entries are for, 0 and 1 unless the file says otherwise.
+	Min, Max Vertex3
+	// Red changing fastest, then green, then blue, like in the files. 1D tables have one entry per
+	// step of all three curves at once.
+	Table []Vertex3
+}
+
+// Reads an Adobe or Resolve .cube file, with either a 3D table or 1D curves.
+func LoadLUT(filename string) (*LUT, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	lut := &LUT{Max: Vertex3{X: 1, Y: 1, Z: 1}}
+	lineNumber := 0
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		lineNumber++
+
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		parts := strings.Fields(line)
+		switch parts[0] {
+		case "TITLE":
+			lut.Title = strings.Trim(strings.TrimSpace(line[len("TITLE"):]), "\"")
+			continue
+
+		case "LUT_1D_SIZE", "LUT_3D_SIZE":
+			if lut.Size != 0 {
+				return nil, errors.New(fmt.Sprintf("size given twice on line %d of %s", lineNumber, filename))
+			}
+			if len(parts) != 2 {
+				return nil, errors.New(fmt.Sprintf("missing size on line %d of %s", lineNumber, filename))
+			}
+			size, err := strconv.Atoi(parts[1])
+			if err != nil || size < 2 {
+				return nil, errors.New(fmt.Sprintf("invalid size %q on line %d of %s", parts[1], lineNumber, filename))
+			}
+			lut.Size = size
+			lut.OneDimensional = parts[0] == "LUT_1D_SIZE"
+			continue
+
+		case "DOMAIN_MIN", "DOMAIN_MAX":
+			v, err := parseLUTVector(parts[1:], lineNumber, filename)
+			if err != nil {
+				return nil, err
+			}
+			if parts[0] == "DOMAIN_MIN" {
+				lut.Min = v
+			} else {
+				lut.Max = v
+			}
+			continue
+
+		// Resolve's take on domains, the same range for all channels.
+		case "LUT_1D_INPUT_RANGE", "LUT_3D_INPUT_RANGE":
+			if len(parts) != 3 {
+				return nil, errors.New(fmt.Sprintf("invalid input range on line %d of %s", lineNumber, filename))
+			}
+			min, err1 := strconv.ParseFloat(parts[1], 64)
+			max, err2 := strconv.ParseFloat(parts[2], 64)
+			if err1 != nil || err2 != nil {
+				return nil, errors.New(fmt.Sprintf("invalid input range on line %d of %s", lineNumber, filename))
+			}
+			lut.Min, lut.Max = Vertex3{X: min, Y: min, Z: min}, Vertex3{X: max, Y: max, Z: max}
+			continue
+		}
+
+		// Anything else is either an entry of the table or a keyword of some other tool.
+		if _, err := strconv.ParseFloat(parts[0], 64); err != nil {
+			continue
+		}
+		v, err := parseLUTVector(parts, lineNumber, filename)
+		if err != nil {
+			return nil, err
+		}
+		lut.Table = append(lut.Table, v)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if lut.Size == 0 {
+		return nil, errors.New(fmt.Sprintf("missing LUT_1D_SIZE or LUT_3D_SIZE in %s", filename))
+	}
+	entries := lut.Size
+	if !lut.OneDimensional {
+		entries = lut.Size * lut.Size * lut.Size
+	}
+	if len(lut.Table) != entries {
+		return nil, errors.New(fmt.Sprintf("%s has %d entries instead of %d", filename, len(lut.Table), entries))
+	}
+	if lut.Max.X <= lut.Min.X || lut.Max.Y <= lut.Min.Y || lut.Max.Z <= lut.Min.Z {
+		return nil, errors.New(fmt.Sprintf("invalid domain in %s", filename))
+	}
+
+	return lut, nil
+}
+
+func parseLUTVector(parts []string, lineNumber int, filename string) (Vertex3, error) {
+	if len(parts) != 3 {
+		return Vertex3{}, errors.New(fmt.Sprintf("expected 3 values on line %d of %s", lineNumber, filename))
+	}
+
+	var v [3]float64
+	for i, part := range parts {
+		var err error
+		v[i], err = strconv.ParseFloat(part, 64)
+		if err != nil {
+			return Vertex3{}, errors.New(fmt.Sprintf("invalid value %q on line %d of %s", part, lineNumber, filename))
+		}
+	}
+	return Vertex3{X: v[0], Y: v[1], Z: v[2]}, nil
+}
+
+// Graded color, interpolated between the entries around it. Colors outside of the domain get the
+// ones of its edges.
+func (l *LUT) Lookup(c Vertex3) Vertex3 {
+	// Position in the table, in entries.
+	position := func(v, min, max float64) float64 {
+		return math.Max(0, math.Min(1, (v-min)/(max-min))) * float64(l.Size-1)
+	}
+	r, g, b := position(c.X, l.Min.X, l.Max.X), position(c.Y, l.Min.Y, l.Max.Y), position(c.Z, l.Min.Z, l.Max.Z)
+
+	if l.OneDimensional {
+		curve := func(p float64, channel func(v Vertex3) float64) float64 {
+			i := math.Min(math.Floor(p), float64(l.Size-2))
+			t := p - i
+			return channel(l.Table[int(i)])*(1-t) + channel(l.Table[int(i)+1])*t
+		}
+		return Vertex3{
+			X: curve(r, func(v Vertex3) float64 { return v.X }),
+			Y: curve(g, func(v Vertex3) float64 { return v.Y }),
+			Z: curve(b, func(v Vertex3) float64 { return v.Z }),
+		}
+	}
+
+	// Trilinear, between the 8 entries of the cell the color falls in.
+	ri, gi, bi := math.Min(math.Floor(r), float64(l.Size-2)), math.Min(math.Floor(g), float64(l.Size-2)), math.Min(math.Floor(b), float64(l.Size-2))
+	rt, gt, bt := r-ri, g-gi, b-bi
+	at := func(dr, dg, db int) Vertex3 {
+		return l.Table[(int(ri)+dr)+(int(gi)+dg)*l.Size+(int(bi)+db)*l.Size*l.Size]
+	}
+
+	c00 := at(0, 0, 0).lerp(at(1, 0, 0), rt)
+	c10 := at(0, 1, 0).lerp(at(1, 1, 0), rt)
+	c01 := at(0, 0, 1).lerp(at(1, 0, 1), rt)
+	c11 := at(0, 1, 1).lerp(at(1, 1, 1), rt)
+	return c00.lerp(c10, gt).lerp(c01.lerp(c11, gt), bt)
+}
+
+// Color grading through a lookup table, usually the last effect so that it grades the image as
+// it's going to be seen.
+type LUTEffect struct {
+	LUT *LUT
+	// How much of the graded colors get blended in, between 0 and 1.
+	Intensity float64
+}
+
+func NewLUTEffect(lut *LUT) *LUTEffect {
+	return &LUTEffect{LUT: lut, Intensity: 1}
+}
+
+func (e *LUTEffect) Apply(frame *Frame) {
+	applyLUT(frame.Image, *e)
+}
+
+func applyLUT(img *image.RGBA, options LUTEffect) {
+	rect := img.Bounds()
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			c := img.RGBAAt(x, y)
+			if c.A == 0 {
+				continue
+			}
+
+			// Graded without the premultiplication by alpha, then premultiplied again.
+			alpha := float64(c.A) / 255
+			original := Vertex3{X: float64(c.R), Y: float64(c.G), Z: float64(c.B)}.scale(1 / (255 * alpha))
+			graded := original.lerp(options.LUT.Lookup(original), options.Intensity)
+			img.SetRGBA(x, y, toPremultipliedRGBA(graded, alpha))
+		}
+	}
+}
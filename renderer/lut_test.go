@@ -0,0 +1,37 @@
+package renderer
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLUT(t *testing.T) {
+	// Red and blue swapped, which trilinear interpolation of 2 entries per axis gets exactly.
+	filename := filepath.Join(t.TempDir(), "swap.cube")
+	table := "TITLE \"Swap\"\nLUT_3D_SIZE 2\n"
+	for i := 0; i < 8; i++ {
+		table += fmt.Sprintf("%d %d %d\n", i>>2&1, i>>1&1, i&1)
+	}
+	if err := os.WriteFile(filename, []byte(table), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	lut, err := LoadLUT(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lut.Title != "Swap" {
+		t.Errorf("got title %q, want Swap", lut.Title)
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, 1, 1))
+	img.SetRGBA(0, 0, color.RGBA{R: 200, G: 100, B: 50, A: 255})
+	NewLUTEffect(lut).Apply(&Frame{Image: img})
+	if c, want := img.RGBAAt(0, 0), (color.RGBA{R: 50, G: 100, B: 200, A: 255}); c != want {
+		t.Errorf("got %v, want %v", c, want)
+	}
+}
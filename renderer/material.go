@@ -0,0 +1,306 @@
+package renderer
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+type ShadingModel int
+
+const (
+	BlinnPhong ShadingModel = iota
+	// Physically based metallic-roughness, like glTF materials.
+	MetallicRoughness
+	// Light in flat bands like in cartoons, see ToonOptions.
+	Toon
+	// Colors of the matcap image, picked by the direction surfaces face on screen, lights and the
+	// scene around left out. Cheap and good looking previews of untextured sculpts.
+	Matcap
+)
+
+// How transparent fragments of a material combine with what's behind them.
+type BlendMode int
+
+const (
+	// Over what's behind, as much as they're opaque.
+	AlphaBlend BlendMode = iota
+	// Light added to what's behind, brightening it, for effects like fire, sparks or light
+	// beams, which don't need sorting. Never hides anything.
+	AdditiveBlend
+)
+
+// Surface properties, as described by MTL files. Colors are RGB between 0 and 1.
+type Material struct {
+	Name  string
+	Model ShadingModel
+
+	Ambient  Vertex3 // Ka
+	Diffuse  Vertex3 // Kd
+	Specular Vertex3 // Ks
+	// Specular exponent, the higher the smaller and sharper the highlights.
+	Shininess float64 // Ns
+
+	DiffuseMap image.Image // map_Kd
+	// Tangent-space normals, the blue channel pointing out of the surface.
+	NormalMap image.Image // map_bump, bump or norm
+
+	// Light given off by the surface whether lit or not, added to the lighting, making parts of
+	// models glow. Linear colors can go beyond 1 to get bright enough for bloom.
+	Emissive    Vertex3     // Ke
+	EmissiveMap image.Image // map_Ke, multiplying the emissive color
+
+	// From 0 for invisible to 1 for opaque, multiplied by the alpha channel of the diffuse map and
+	// by the opacity map.
+	Opacity    float64     // d, or 1 - Tr
+	OpacityMap image.Image // map_d
+	// Above 0, fragments less opaque than this get discarded and the others drawn opaque. Cheaper
+	// than blending as there's no sorting involved, for cutouts like leaves or fences.
+	AlphaCutoff float64
+	// Transparent when additive, drawn after opaque surfaces without hiding them.
+	Blend BlendMode
+	// Drawn from both sides whether backface culling is on or not, back faces getting shaded with
+	// their normals flipped, for thin surfaces like leaves, cloth or paper.
+	TwoSided bool
+
+	// Metallic-roughness parameters, the diffuse color being the base color.
+	Metallic  float64 // Pm
+	Roughness float64 // Pr
+	// Metalness in the blue channel and roughness in the green channel, like glTF, multiplying
+	// the parameters above.
+	MetallicRoughnessMap image.Image
+	MetallicMap          image.Image // map_Pm
+	RoughnessMap         image.Image // map_Pr
+
+	// Sphere lit and shaded the way the surface should look, as seen from the front, for the
+	// matcap shading model. Multiplied by the diffuse color, texture and vertex colors.
+	MatcapMap image.Image
+
+	// Replaces the lighting of the shading model when set.
+	Shader Shader
+}
+
+// White and matte, for models without materials.
+func DefaultMaterial() *Material {
+	return &Material{
+		Name:     "default",
+		Ambient:  Vertex3{X: 1, Y: 1, Z: 1},
+		Diffuse:  Vertex3{X: 1, Y: 1, Z: 1},
+		Specular: Vertex3{},
+		Opacity:  1,
+
+		Roughness: 1,
+	}
+}
+
+// Texture maps are resolved relative to the MTL file, their files returned along with the
+// materials.
+func loadMtlFromFile(ctx context.Context, filename string) (map[string]*Material, []string, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer file.Close()
+
+	materials := map[string]*Material{}
+	var material *Material
+	var textures []string
+	texture := func(parts []string) (image.Image, error) {
+		path := mtlMapPath(filename, parts)
+		textures = append(textures, path)
+		return LoadTextureContext(ctx, path)
+	}
+
+	lineNumber := 0
+	scanner := bufio.NewScanner(contextReader{ctx, file})
+	for scanner.Scan() {
+		parts := strings.Fields(scanner.Text())
+		lineNumber++
+
+		if len(parts) == 0 || strings.HasPrefix(parts[0], "#") {
+			continue
+		}
+
+		if parts[0] == "newmtl" {
+			if len(parts) < 2 {
+				return nil, nil, errors.New(fmt.Sprintf("missing material name on line %d of %s", lineNumber, filename))
+			}
+			material = DefaultMaterial()
+			material.Name = parts[1]
+			materials[material.Name] = material
+			continue
+		}
+
+		if material == nil {
+			continue
+		}
+
+		switch parts[0] {
+		case "Ka":
+			material.Ambient, err = parseMtlColor(parts, lineNumber)
+		case "Kd":
+			material.Diffuse, err = parseMtlColor(parts, lineNumber)
+		case "Ks":
+			material.Specular, err = parseMtlColor(parts, lineNumber)
+		case "Ns":
+			material.Shininess, err = parseMtlFloat(parts, lineNumber)
+		case "map_Kd":
+			material.DiffuseMap, err = texture(parts)
+		case "map_bump", "map_Bump", "bump", "norm":
+			material.NormalMap, err = texture(parts)
+		case "Ke":
+			material.Emissive, err = parseMtlColor(parts, lineNumber)
+		case "map_Ke":
+			material.EmissiveMap, err = texture(parts)
+		case "d":
+			material.Opacity, err = parseMtlFloat(parts, lineNumber)
+		case "Tr":
+			var transparency float64
+			transparency, err = parseMtlFloat(parts, lineNumber)
+			material.Opacity = 1 - transparency
+		case "map_d":
+			material.OpacityMap, err = texture(parts)
+
+		// PBR extension, which switches the material to metallic-roughness shading.
+		case "Pm":
+			material.Model = MetallicRoughness
+			material.Metallic, err = parseMtlFloat(parts, lineNumber)
+		case "Pr":
+			material.Model = MetallicRoughness
+			material.Roughness, err = parseMtlFloat(parts, lineNumber)
+		case "map_Pm":
+			material.Model = MetallicRoughness
+			material.MetallicMap, err = texture(parts)
+		case "map_Pr":
+			material.Model = MetallicRoughness
+			material.RoughnessMap, err = texture(parts)
+		}
+
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	return materials, textures, nil
+}
+
+func parseMtlColor(parts []string, lineNumber int) (Vertex3, error) {
+	if len(parts) < 2 {
+		return Vertex3{}, errors.New(fmt.Sprintf("missing color in %s directive on line %d", parts[0], lineNumber))
+	}
+
+	// A single value is a shade of gray.
+	if len(parts) < 4 {
+		gray, err := strconv.ParseFloat(parts[1], 64)
+		if err != nil {
+			return Vertex3{}, errors.New(fmt.Sprintf("invalid color in %s directive on line %d", parts[0], lineNumber))
+		}
+		return Vertex3{X: gray, Y: gray, Z: gray}, nil
+	}
+
+	var c [3]float64
+	for i := range c {
+		f, err := strconv.ParseFloat(parts[i+1], 64)
+		if err != nil {
+			return Vertex3{}, errors.New(fmt.Sprintf("invalid color in %s directive on line %d", parts[0], lineNumber))
+		}
+		c[i] = f
+	}
+
+	return Vertex3{X: c[0], Y: c[1], Z: c[2]}, nil
+}
+
+func parseMtlFloat(parts []string, lineNumber int) (float64, error) {
+	if len(parts) < 2 {
+		return 0, errors.New(fmt.Sprintf("missing value in %s directive on line %d", parts[0], lineNumber))
+	}
+
+	f, err := strconv.ParseFloat(parts[1], 64)
+	if err != nil {
+		return 0, errors.New(fmt.Sprintf("invalid value in %s directive on line %d", parts[0], lineNumber))
+	}
+
+	return f, nil
+}
+
+// Map directives can have options before the filename, which always comes last.
+func mtlMapPath(mtlFilename string, parts []string) string {
+	return filepath.Join(filepath.Dir(mtlFilename), parts[len(parts)-1])
+}
+
+// Metalness and roughness at the given texture coordinates.
+func (m *Material) metallicRoughness(uv Vertex2) (float64, float64) {
+	metallic, roughness := m.Metallic, m.Roughness
+
+	if m.MetallicRoughnessMap != nil {
+		sample := sampleTexture(m.MetallicRoughnessMap, uv)
+		metallic *= sample.Z
+		roughness *= sample.Y
+	}
+	if m.MetallicMap != nil {
+		metallic *= sampleTexture(m.MetallicMap, uv).X
+	}
+	if m.RoughnessMap != nil {
+		roughness *= sampleTexture(m.RoughnessMap, uv).X
+	}
+
+	return metallic, roughness
+}
+
+// Transparent materials need blending, which is done back to front after everything opaque.
+func (m *Material) transparent() bool {
+	if m.Blend == AdditiveBlend {
+		return true
+	}
+	if m.AlphaCutoff > 0 {
+		return false
+	}
+
+	if m.Opacity < 1 || m.OpacityMap != nil {
+		return true
+	}
+
+	if m.DiffuseMap != nil {
+		if o, ok := m.DiffuseMap.(interface{ Opaque() bool }); ok {
+			return !o.Opaque()
+		}
+	}
+
+	return false
+}
+
+// Opacity at the given texture coordinates.
+func (m *Material) alpha(uv Vertex2) float64 {
+	alpha := m.Opacity
+	if m.DiffuseMap != nil {
+		alpha *= sampleAlpha(m.DiffuseMap, uv)
+	}
+	if m.OpacityMap != nil {
+		alpha *= sampleTexture(m.OpacityMap, uv).X
+	}
+
+	return alpha
+}
+
+// Linear light given off at the given texture coordinates.
+func (m *Material) emission(uv Vertex2) Vertex3 {
+	if m.EmissiveMap != nil {
+		return m.Emissive.multiply(srgbToLinear(sampleTexture(m.EmissiveMap, uv)))
+	}
+	return m.Emissive
+}
+
+// Whether the fragment at the given texture coordinates gets discarded by the alpha test.
+func (m *Material) cutout(uv Vertex2) bool {
+	return m.AlphaCutoff > 0 && m.alpha(uv) < m.AlphaCutoff
+}
@@ -0,0 +1,58 @@
+package renderer
+
+import (
+	"image/color"
+	"testing"
+)
+
+func TestEmissive(t *testing.T) {
+	scene, node, camera, options := sphereTestScene()
+	material := DefaultMaterial()
+	material.Ambient, material.Diffuse, material.Specular = Vertex3{}, Vertex3{}, Vertex3{}
+	material.Emissive = Vertex3{X: 1}
+	node.SetMaterial(material)
+
+	options.ClearColor = color.RGBA{G: 255, A: 255}
+	img, err := Render(scene, camera, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := img.RGBAAt(16, 16); c.R < 250 || c.G != 0 {
+		t.Errorf("emissive surface %v, expected red", c)
+	}
+
+	// Added to the background instead of covering it.
+	material.Blend = AdditiveBlend
+	img, err = Render(scene, camera, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := img.RGBAAt(16, 16); c.R < 250 || c.G < 250 {
+		t.Errorf("additive surface %v, expected yellow", c)
+	}
+}
+
+func TestEmissiveBloom(t *testing.T) {
+	scene, node, camera, options := sphereTestScene()
+	material := DefaultMaterial()
+	material.Ambient, material.Diffuse, material.Specular = Vertex3{}, Vertex3{}, Vertex3{}
+	node.SetMaterial(material)
+	options.PostEffects = []PostEffect{&BloomEffect{Threshold: 0.8, Intensity: 1, Radius: 0.1}}
+
+	// Both surfaces end up white, but the brighter one glows further out, beside the sphere.
+	var glow [2]uint8
+	for i, emissive := range []float64{1, 4} {
+		material.Emissive = Vertex3{X: emissive, Y: emissive, Z: emissive}
+		img, err := Render(scene, camera, options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c := img.RGBAAt(16, 16); c.R != 255 {
+			t.Errorf("emissive %g surface %v, expected white", emissive, c)
+		}
+		glow[i] = img.RGBAAt(3, 16).R
+	}
+	if glow[1] < 2*glow[0] {
+		t.Errorf("glow %d beside an emissive of 4, against %d for 1", glow[1], glow[0])
+	}
+}
@@ -0,0 +1,251 @@
+package renderer
+
+import "math"
+
+type Matrix4 struct {
+	m11, m12, m13, m14 float64
+	m21, m22, m23, m24 float64
+	m31, m32, m33, m34 float64
+	m41, m42, m43, m44 float64
+}
+
+func Identity4() Matrix4 {
+	return Matrix4{
+		m11: 1.0,
+		m22: 1.0,
+		m33: 1.0,
+		m44: 1.0,
+	}
+}
+
+func (m Matrix4) Multiply(o Matrix4) Matrix4 {
+	return Matrix4{
+		m11: (m.m11 * o.m11) + (m.m12 * o.m21) + (m.m13 * o.m31) + (m.m14 * o.m41),
+		m12: (m.m11 * o.m12) + (m.m12 * o.m22) + (m.m13 * o.m32) + (m.m14 * o.m42),
+		m13: (m.m11 * o.m13) + (m.m12 * o.m23) + (m.m13 * o.m33) + (m.m14 * o.m43),
+		m14: (m.m11 * o.m14) + (m.m12 * o.m24) + (m.m13 * o.m34) + (m.m14 * o.m44),
+
+		m21: (m.m21 * o.m11) + (m.m22 * o.m21) + (m.m23 * o.m31) + (m.m24 * o.m41),
+		m22: (m.m21 * o.m12) + (m.m22 * o.m22) + (m.m23 * o.m32) + (m.m24 * o.m42),
+		m23: (m.m21 * o.m13) + (m.m22 * o.m23) + (m.m23 * o.m33) + (m.m24 * o.m43),
+		m24: (m.m21 * o.m14) + (m.m22 * o.m24) + (m.m23 * o.m34) + (m.m24 * o.m44),
+
+		m31: (m.m31 * o.m11) + (m.m32 * o.m21) + (m.m33 * o.m31) + (m.m34 * o.m41),
+		m32: (m.m31 * o.m12) + (m.m32 * o.m22) + (m.m33 * o.m32) + (m.m34 * o.m42),
+		m33: (m.m31 * o.m13) + (m.m32 * o.m23) + (m.m33 * o.m33) + (m.m34 * o.m43),
+		m34: (m.m31 * o.m14) + (m.m32 * o.m24) + (m.m33 * o.m34) + (m.m34 * o.m44),
+
+		m41: (m.m41 * o.m11) + (m.m42 * o.m21) + (m.m43 * o.m31) + (m.m44 * o.m41),
+		m42: (m.m41 * o.m12) + (m.m42 * o.m22) + (m.m43 * o.m32) + (m.m44 * o.m42),
+		m43: (m.m41 * o.m13) + (m.m42 * o.m23) + (m.m43 * o.m33) + (m.m44 * o.m43),
+		m44: (m.m41 * o.m14) + (m.m42 * o.m24) + (m.m43 * o.m34) + (m.m44 * o.m44),
+	}
+}
+
+// Moves points by v.
+func TranslationMatrix(v Vertex3) Matrix4 {
+	m := Identity4()
+	m.m14 = v.X
+	m.m24 = v.Y
+	m.m34 = v.Z
+	return m
+}
+
+// Scales points along each axis by the matching component of v.
+func ScaleMatrix(v Vertex3) Matrix4 {
+	return Matrix4{
+		m11: v.X,
+		m22: v.Y,
+		m33: v.Z,
+		m44: 1.0,
+	}
+}
+
+// Rotation of angle radians around an arbitrary axis, using Rodrigues' rotation formula.
+func RotationMatrix(axis Vertex3, angle float64) Matrix4 {
+	a := axis.normalize(1.0)
+	c := math.Cos(angle)
+	s := math.Sin(angle)
+	t := 1 - c
+
+	return Matrix4{
+		t*a.X*a.X + c, t*a.X*a.Y - s*a.Z, t*a.X*a.Z + s*a.Y, 0,
+		t*a.X*a.Y + s*a.Z, t*a.Y*a.Y + c, t*a.Y*a.Z - s*a.X, 0,
+		t*a.X*a.Z - s*a.Y, t*a.Y*a.Z + s*a.X, t*a.Z*a.Z + c, 0,
+		0, 0, 0, 1,
+	}
+}
+
+// Matrix from its elements row after row, the last column holding translations.
+func Matrix4FromRows(rows [16]float64) Matrix4 {
+	return Matrix4{
+		rows[0], rows[1], rows[2], rows[3],
+		rows[4], rows[5], rows[6], rows[7],
+		rows[8], rows[9], rows[10], rows[11],
+		rows[12], rows[13], rows[14], rows[15],
+	}
+}
+
+// Elements of the matrix row after row, as taken by Matrix4FromRows.
+func (m Matrix4) Rows() [16]float64 {
+	return [16]float64{
+		m.m11, m.m12, m.m13, m.m14,
+		m.m21, m.m22, m.m23, m.m24,
+		m.m31, m.m32, m.m33, m.m34,
+		m.m41, m.m42, m.m43, m.m44,
+	}
+}
+
+func (m Matrix4) Transpose() Matrix4 {
+	return Matrix4{
+		m.m11, m.m21, m.m31, m.m41,
+		m.m12, m.m22, m.m32, m.m42,
+		m.m13, m.m23, m.m33, m.m43,
+		m.m14, m.m24, m.m34, m.m44,
+	}
+}
+
+// Inverse by cofactors over the adjugate. Singular matrices can't be inverted, in which case
+// the identity is returned along with false.
+func (m Matrix4) Inverse() (Matrix4, bool) {
+	a := [16]float64{
+		m.m11, m.m12, m.m13, m.m14,
+		m.m21, m.m22, m.m23, m.m24,
+		m.m31, m.m32, m.m33, m.m34,
+		m.m41, m.m42, m.m43, m.m44,
+	}
+
+	var inv [16]float64
+
+	inv[0] = a[5]*a[10]*a[15] - a[5]*a[11]*a[14] - a[9]*a[6]*a[15] + a[9]*a[7]*a[14] + a[13]*a[6]*a[11] - a[13]*a[7]*a[10]
+	inv[4] = -a[4]*a[10]*a[15] + a[4]*a[11]*a[14] + a[8]*a[6]*a[15] - a[8]*a[7]*a[14] - a[12]*a[6]*a[11] + a[12]*a[7]*a[10]
+	inv[8] = a[4]*a[9]*a[15] - a[4]*a[11]*a[13] - a[8]*a[5]*a[15] + a[8]*a[7]*a[13] + a[12]*a[5]*a[11] - a[12]*a[7]*a[9]
+	inv[12] = -a[4]*a[9]*a[14] + a[4]*a[10]*a[13] + a[8]*a[5]*a[14] - a[8]*a[6]*a[13] - a[12]*a[5]*a[10] + a[12]*a[6]*a[9]
+	inv[1] = -a[1]*a[10]*a[15] + a[1]*a[11]*a[14] + a[9]*a[2]*a[15] - a[9]*a[3]*a[14] - a[13]*a[2]*a[11] + a[13]*a[3]*a[10]
+	inv[5] = a[0]*a[10]*a[15] - a[0]*a[11]*a[14] - a[8]*a[2]*a[15] + a[8]*a[3]*a[14] + a[12]*a[2]*a[11] - a[12]*a[3]*a[10]
+	inv[9] = -a[0]*a[9]*a[15] + a[0]*a[11]*a[13] + a[8]*a[1]*a[15] - a[8]*a[3]*a[13] - a[12]*a[1]*a[11] + a[12]*a[3]*a[9]
+	inv[13] = a[0]*a[9]*a[14] - a[0]*a[10]*a[13] - a[8]*a[1]*a[14] + a[8]*a[2]*a[13] + a[12]*a[1]*a[10] - a[12]*a[2]*a[9]
+	inv[2] = a[1]*a[6]*a[15] - a[1]*a[7]*a[14] - a[5]*a[2]*a[15] + a[5]*a[3]*a[14] + a[13]*a[2]*a[7] - a[13]*a[3]*a[6]
+	inv[6] = -a[0]*a[6]*a[15] + a[0]*a[7]*a[14] + a[4]*a[2]*a[15] - a[4]*a[3]*a[14] - a[12]*a[2]*a[7] + a[12]*a[3]*a[6]
+	inv[10] = a[0]*a[5]*a[15] - a[0]*a[7]*a[13] - a[4]*a[1]*a[15] + a[4]*a[3]*a[13] + a[12]*a[1]*a[7] - a[12]*a[3]*a[5]
+	inv[14] = -a[0]*a[5]*a[14] + a[0]*a[6]*a[13] + a[4]*a[1]*a[14] - a[4]*a[2]*a[13] - a[12]*a[1]*a[6] + a[12]*a[2]*a[5]
+	inv[3] = -a[1]*a[6]*a[11] + a[1]*a[7]*a[10] + a[5]*a[2]*a[11] - a[5]*a[3]*a[10] - a[9]*a[2]*a[7] + a[9]*a[3]*a[6]
+	inv[7] = a[0]*a[6]*a[11] - a[0]*a[7]*a[10] - a[4]*a[2]*a[11] + a[4]*a[3]*a[10] + a[8]*a[2]*a[7] - a[8]*a[3]*a[6]
+	inv[11] = -a[0]*a[5]*a[11] + a[0]*a[7]*a[9] + a[4]*a[1]*a[11] - a[4]*a[3]*a[9] - a[8]*a[1]*a[7] + a[8]*a[3]*a[5]
+	inv[15] = a[0]*a[5]*a[10] - a[0]*a[6]*a[9] - a[4]*a[1]*a[10] + a[4]*a[2]*a[9] + a[8]*a[1]*a[6] - a[8]*a[2]*a[5]
+
+	det := a[0]*inv[0] + a[1]*inv[4] + a[2]*inv[8] + a[3]*inv[12]
+	if det == 0 {
+		return Identity4(), false
+	}
+
+	for i := range inv {
+		inv[i] /= det
+	}
+
+	return Matrix4{
+		inv[0], inv[1], inv[2], inv[3],
+		inv[4], inv[5], inv[6], inv[7],
+		inv[8], inv[9], inv[10], inv[11],
+		inv[12], inv[13], inv[14], inv[15],
+	}, true
+}
+
+// Rotation from Euler angles in radians, applied around X first, then Y, then Z.
+func EulerRotationMatrix(x, y, z float64) Matrix4 {
+	rx := RotationMatrix(Vertex3{X: 1}, x)
+	ry := RotationMatrix(Vertex3{Y: 1}, y)
+	rz := RotationMatrix(Vertex3{Z: 1}, z)
+	return rz.Multiply(ry).Multiply(rx)
+}
+
+// Scales, then rotates by Euler angles in degrees, then translates, like the transforms of nodes.
+func NewTransform(translate, rotate, scale Vertex3) Matrix4 {
+	toRadians := math.Pi / 180
+	rotation := EulerRotationMatrix(rotate.X*toRadians, rotate.Y*toRadians, rotate.Z*toRadians)
+	return TranslationMatrix(translate).Multiply(rotation).Multiply(ScaleMatrix(scale))
+}
+
+// Map from world space to the space of a camera at eye looking at center, with -Z going forward.
+func LookAtMatrix(eye Vertex3, center Vertex3, up Vertex3) Matrix4 {
+	z := eye.minus(center).normalize(1.0)
+	x := up.cross(z).normalize(1.0)
+	y := z.cross(x).normalize(1.0)
+
+	minv := Identity4()
+	tr := Identity4()
+
+	minv.m11 = x.X
+	minv.m21 = y.X
+	minv.m31 = z.X
+	minv.m12 = x.Y
+	minv.m22 = y.Y
+	minv.m32 = z.Y
+	minv.m13 = x.Z
+	minv.m23 = y.Z
+	minv.m33 = z.Z
+
+	tr.m14 = -eye.X
+	tr.m24 = -eye.Y
+	tr.m34 = -eye.Z
+
+	return minv.Multiply(tr)
+}
+
+// Map from camera space to clip space through a perspective projection, fovY being the vertical
+// field of view in radians. Unlike OpenGL, depth grows towards the camera: the near plane ends up
+// at z = w and the far plane at z = -w.
+func PerspectiveMatrix(fovY, aspect, near, far float64) Matrix4 {
+	f := 1 / math.Tan(fovY/2)
+
+	return Matrix4{
+		f / aspect, 0, 0, 0,
+		0, f, 0, 0,
+		0, 0, (far + near) / (far - near), 2 * far * near / (far - near),
+		0, 0, -1, 0,
+	}
+}
+
+// Map from camera space to clip space through an orthographic projection, with the same depth
+// convention as the perspective one.
+func OrthographicMatrix(left, right, bottom, top, near, far float64) Matrix4 {
+	return Matrix4{
+		2 / (right - left), 0, 0, -(right + left) / (right - left),
+		0, 2 / (top - bottom), 0, -(top + bottom) / (top - bottom),
+		0, 0, 2 / (far - near), (far + near) / (far - near),
+		0, 0, 0, 1,
+	}
+}
+
+func genScreenMatrix(x, y, w, h int) Matrix4 {
+	d := 255
+
+	return Matrix4{
+		float64(w) / 2.0, 0, 0, float64(x) + float64(w)/2.0,
+		0, float64(h) / 2.0, 0, float64(y) + float64(h)/2.0,
+		0, 0, float64(d) / 2.0, float64(d) / 2.0,
+		0, 0, 0, 1,
+	}
+}
+
+// Like transforming a vertex with a W of 0, translations are ignored.
+func (m Matrix4) transformDirection(v Vertex3) Vertex3 {
+	return Vertex3{
+		X: v.X*m.m11 + v.Y*m.m12 + v.Z*m.m13,
+		Y: v.X*m.m21 + v.Y*m.m22 + v.Z*m.m23,
+		Z: v.X*m.m31 + v.Y*m.m32 + v.Z*m.m33,
+	}
+}
+
+func (m Matrix4) transformPoint(v Vertex3) Vertex3 {
+	vertex4 := Vertex4{X: v.X, Y: v.Y, Z: v.Z, W: 1}
+	vertex4.transform(m)
+	return vertex4.lower()
+}
+
+// Normals have to be transformed by the inverse transpose to stay perpendicular to their surface
+// under non-uniform scaling.
+func genNormalMatrix(m Matrix4) Matrix4 {
+	inverse, _ := m.Inverse()
+	return inverse.Transpose()
+}
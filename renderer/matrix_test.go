@@ -0,0 +1,21 @@
+package renderer
+
+import "testing"
+
+func TestMatrix4Rows(t *testing.T) {
+	rows := [16]float64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}
+	m := Matrix4FromRows(rows)
+	if m.Rows() != rows {
+		t.Fatalf("rows came back as %v, want %v", m.Rows(), rows)
+	}
+
+	// Translations are in the last column.
+	p := TranslationMatrix(Vertex3{X: 1, Y: 2, Z: 3}).Rows()
+	if p[3] != 1 || p[7] != 2 || p[11] != 3 {
+		t.Fatalf("translation rows are %v", p)
+	}
+
+	if got := m.Transpose().Rows(); got[1] != 5 || got[4] != 2 {
+		t.Fatalf("transposed rows are %v", got)
+	}
+}
@@ -0,0 +1,200 @@
+package renderer
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	md2Magic   = 0x32504449 // "IDP2"
+	md2Version = 8
+	// Quake II plays model animations at 10 frames per second.
+	md2FrameRate = 10
+)
+
+type md2Header struct {
+	Magic, Version                                             int32
+	SkinWidth, SkinHeight, FrameSize                           int32
+	Skins, Vertices, TextureCoordinates, Triangles, GLCommands int32
+	Frames                                                     int32
+	SkinsOffset, TextureCoordinatesOffset, TrianglesOffset     int32
+	FramesOffset, GLCommandsOffset, EndOffset                  int32
+}
+
+type md2Triangle struct {
+	Vertices           [3]uint16
+	TextureCoordinates [3]uint16
+}
+
+type md2FrameHeader struct {
+	Scale     [3]float32
+	Translate [3]float32
+	Name      [16]byte
+}
+
+// Axes of id Software models, X going forward, Y to the left and Z up, turned to face +Z with Y
+// going up.
+var idTechAxes = Matrix4{
+	0, 1, 0, 0,
+	0, 0, 1, 0,
+	1, 0, 0, 0,
+	0, 0, 0, 1,
+}
+
+// Loads a Quake II MD2 model as a node, its frames being morph targets of the mesh and its
+// animations, like run or pain, clips blending from one frame to the next. Skins are left out,
+// their textures being given separately like for OBJ files.
+func loadMD2(ctx context.Context, filename string) (*Node, []*AnimationClip, error) {
+	data, err := readFileContext(ctx, filename)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var header md2Header
+	if err := binary.Read(bytes.NewReader(data), binary.LittleEndian, &header); err != nil || header.Magic != md2Magic {
+		return nil, nil, errors.New(fmt.Sprintf("%s is not an MD2 file", filename))
+	}
+	if header.Version != md2Version {
+		return nil, nil, errors.New(fmt.Sprintf("unsupported MD2 version %d in %s", header.Version, filename))
+	}
+	if header.Frames < 1 || header.Vertices < 1 {
+		return nil, nil, errors.New(fmt.Sprintf("%s has no frames", filename))
+	}
+
+	read := func(offset int32, v interface{}) error {
+		if offset < 0 || int(offset) > len(data) {
+			return errors.New(fmt.Sprintf("truncated MD2 file %s", filename))
+		}
+		if err := binary.Read(bytes.NewReader(data[offset:]), binary.LittleEndian, v); err != nil {
+			return errors.New(fmt.Sprintf("truncated MD2 file %s", filename))
+		}
+		return nil
+	}
+
+	uvs := make([][2]int16, header.TextureCoordinates)
+	if err := read(header.TextureCoordinatesOffset, uvs); err != nil {
+		return nil, nil, err
+	}
+	triangles := make([]md2Triangle, header.Triangles)
+	if err := read(header.TrianglesOffset, triangles); err != nil {
+		return nil, nil, err
+	}
+
+	// Every frame has all the vertices, compressed to a byte per coordinate.
+	frames := make([][]Vertex3, header.Frames)
+	names := make([]string, header.Frames)
+	for i := range frames {
+		offset := header.FramesOffset + int32(i)*header.FrameSize
+		var frame md2FrameHeader
+		if err := read(offset, &frame); err != nil {
+			return nil, nil, err
+		}
+		packed := make([][4]uint8, header.Vertices)
+		if err := read(offset+int32(binary.Size(frame)), packed); err != nil {
+			return nil, nil, err
+		}
+
+		name := frame.Name[:]
+		if end := bytes.IndexByte(name, 0); end >= 0 {
+			name = name[:end]
+		}
+		names[i] = strings.TrimRight(string(name), "0123456789")
+		frames[i] = make([]Vertex3, len(packed))
+		for j, p := range packed {
+			frames[i][j] = Vertex3{
+				X: float64(p[0])*float64(frame.Scale[0]) + float64(frame.Translate[0]),
+				Y: float64(p[1])*float64(frame.Scale[1]) + float64(frame.Translate[1]),
+				Z: float64(p[2])*float64(frame.Scale[2]) + float64(frame.Translate[2]),
+			}
+		}
+	}
+
+	// Clockwise, like in all id Software formats.
+	polygons := make([][]int, len(triangles))
+	for k, t := range triangles {
+		for _, id := range []uint16{t.Vertices[0], t.Vertices[2], t.Vertices[1]} {
+			if int(id) >= int(header.Vertices) {
+				return nil, nil, errors.New(fmt.Sprintf("unable to resolve vertex index %d used by face %d in %s", id, k, filename))
+			}
+			polygons[k] = append(polygons[k], int(id))
+		}
+		for _, id := range t.TextureCoordinates {
+			if int(id) >= len(uvs) {
+				return nil, nil, errors.New(fmt.Sprintf("unable to resolve texture index %d used by face %d in %s", id, k, filename))
+			}
+		}
+	}
+
+	// The first frame makes the mesh, every frame a target moving it away from there.
+	obj := &Obj{Materials: map[string]*Material{}}
+	normals := make([][]Vertex3, len(frames))
+	for i, frame := range frames {
+		normals[i] = smoothNormals(frame, polygons)
+	}
+	for k, t := range triangles {
+		var face Face
+		for j, corner := range [3]int{0, 2, 1} {
+			id := polygons[k][j]
+			uv := uvs[t.TextureCoordinates[corner]]
+			face.Vertices[j] = frames[0][id]
+			face.Normals[j] = normals[0][id]
+			face.Textures[j] = Vertex2{X: float64(uv[0]) / float64(header.SkinWidth), Y: 1 - float64(uv[1])/float64(header.SkinHeight)}
+		}
+		obj.Faces = append(obj.Faces, face)
+	}
+	for i, frame := range frames {
+		target := MorphTarget{Name: fmt.Sprintf("frame %d", i)}
+		for _, polygon := range polygons {
+			var positions, frameNormals [3]Vertex3
+			for j, id := range polygon {
+				positions[j] = frame[id].minus(frames[0][id])
+				frameNormals[j] = normals[i][id].minus(normals[0][id])
+			}
+			target.Positions = append(target.Positions, positions)
+			target.Normals = append(target.Normals, frameNormals)
+		}
+		obj.Targets = append(obj.Targets, target)
+	}
+	obj.Bounds = obj.aabb()
+
+	root := NewNode(filepath.Base(filename))
+	root.Transform = idTechAxes
+	root.Mesh = obj
+	root.MorphWeights = make([]float64, len(frames))
+
+	// Animations are runs of frames named alike, stand01 to stand40 making stand. Each one goes
+	// back to its first frame at the end, for loops.
+	var clips []*AnimationClip
+	for start := 0; start < len(frames); {
+		end := start + 1
+		for end < len(frames) && names[end] == names[start] {
+			end++
+		}
+
+		channel := AnimationChannel{Node: root, Path: WeightsPath}
+		for k := start; k <= end; k++ {
+			frame := k
+			if k == end {
+				frame = start
+			}
+			channel.Times = append(channel.Times, float64(k-start)/md2FrameRate)
+			for i := range frames {
+				weight := 0.0
+				if i == frame {
+					weight = 1
+				}
+				channel.Values = append(channel.Values, Vertex4{X: weight})
+			}
+		}
+		clips = append(clips, &AnimationClip{Name: names[start], Channels: []AnimationChannel{channel}, Loop: true, Speed: 1})
+
+		start = end
+	}
+
+	return root, clips, nil
+}
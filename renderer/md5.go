@@ -0,0 +1,605 @@
+package renderer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Reads the words, numbers, quoted strings and brackets of MD5 files one after another.
+type md5Parser struct {
+	filename string
+	tokens   []string
+	lines    []int
+	position int
+}
+
+func newMD5Parser(ctx context.Context, filename string) (*md5Parser, error) {
+	data, err := readFileContext(ctx, filename)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &md5Parser{filename: filename}
+	for n, line := range strings.Split(string(data), "\n") {
+		if i := strings.Index(line, "//"); i >= 0 {
+			line = line[:i]
+		}
+		for len(line) > 0 {
+			line = strings.TrimLeftFunc(line, unicode.IsSpace)
+			if line == "" {
+				break
+			}
+
+			var token string
+			switch {
+			case line[0] == '"':
+				end := strings.IndexByte(line[1:], '"')
+				if end < 0 {
+					return nil, errors.New(fmt.Sprintf("unterminated string on line %d of %s", n+1, filename))
+				}
+				token = line[:end+2]
+			case strings.ContainsRune("(){}", rune(line[0])):
+				token = line[:1]
+			default:
+				end := strings.IndexFunc(line, func(r rune) bool { return unicode.IsSpace(r) || strings.ContainsRune("(){}\"", r) })
+				if end < 0 {
+					end = len(line)
+				}
+				token = line[:end]
+			}
+			p.tokens = append(p.tokens, token)
+			p.lines = append(p.lines, n+1)
+			line = line[len(token):]
+		}
+	}
+
+	return p, nil
+}
+
+func (p *md5Parser) errorf(format string, args ...interface{}) error {
+	line := 0
+	if p.position < len(p.lines) {
+		line = p.lines[p.position]
+	} else if len(p.lines) > 0 {
+		line = p.lines[len(p.lines)-1]
+	}
+	return errors.New(fmt.Sprintf("%s on line %d of %s", fmt.Sprintf(format, args...), line, p.filename))
+}
+
+func (p *md5Parser) done() bool {
+	return p.position >= len(p.tokens)
+}
+
+func (p *md5Parser) next() (string, error) {
+	if p.done() {
+		return "", p.errorf("unexpected end of file")
+	}
+	p.position++
+	return p.tokens[p.position-1], nil
+}
+
+func (p *md5Parser) expect(token string) error {
+	t, err := p.next()
+	if err != nil {
+		return err
+	}
+	if t != token {
+		p.position--
+		return p.errorf("expected %s instead of %s", token, t)
+	}
+	return nil
+}
+
+func (p *md5Parser) string() (string, error) {
+	t, err := p.next()
+	if err != nil {
+		return "", err
+	}
+	if len(t) < 2 || t[0] != '"' {
+		p.position--
+		return "", p.errorf("expected a string instead of %s", t)
+	}
+	return t[1 : len(t)-1], nil
+}
+
+func (p *md5Parser) float() (float64, error) {
+	t, err := p.next()
+	if err != nil {
+		return 0, err
+	}
+	f, err := strconv.ParseFloat(t, 64)
+	if err != nil {
+		p.position--
+		return 0, p.errorf("expected a number instead of %s", t)
+	}
+	return f, nil
+}
+
+func (p *md5Parser) int() (int, error) {
+	t, err := p.next()
+	if err != nil {
+		return 0, err
+	}
+	i, err := strconv.Atoi(t)
+	if err != nil {
+		p.position--
+		return 0, p.errorf("expected an integer instead of %s", t)
+	}
+	return i, nil
+}
+
+// Numbers between parentheses.
+func (p *md5Parser) tuple(values ...*float64) error {
+	if err := p.expect("("); err != nil {
+		return err
+	}
+	for _, v := range values {
+		var err error
+		if *v, err = p.float(); err != nil {
+			return err
+		}
+	}
+	return p.expect(")")
+}
+
+// Orientations only have X, Y and Z, W being negative and making it a unit quaternion.
+func md5Quaternion(x, y, z float64) Quaternion {
+	w := 1 - x*x - y*y - z*z
+	if w < 0 {
+		return Quaternion{X: x, Y: y, Z: z}.normalize()
+	}
+	return Quaternion{X: x, Y: y, Z: z, W: -math.Sqrt(w)}
+}
+
+type md5Joint struct {
+	name        string
+	parent      int
+	position    Vertex3
+	orientation Quaternion
+}
+
+type md5Weight struct {
+	joint    int
+	bias     float64
+	position Vertex3
+}
+
+// Loads a Doom 3 MD5 model, .md5mesh, as a node with a skeleton skinning its meshes. The .md5anim
+// files next to it animating the same skeleton come along as clips, named after their files.
+// Shaders are left out, their textures being given separately like for OBJ files.
+func loadMD5(ctx context.Context, filename string) (*Node, []*AnimationClip, error) {
+	p, err := newMD5Parser(ctx, filename)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var joints []md5Joint
+	obj := &Obj{Materials: map[string]*Material{}}
+
+	for !p.done() {
+		keyword, _ := p.next()
+		switch keyword {
+		case "MD5Version":
+			version, err := p.int()
+			if err != nil {
+				return nil, nil, err
+			}
+			if version != 10 {
+				return nil, nil, p.errorf("unsupported MD5 version %d", version)
+			}
+
+		case "commandline":
+			if _, err := p.string(); err != nil {
+				return nil, nil, err
+			}
+
+		case "numJoints", "numMeshes":
+			if _, err := p.int(); err != nil {
+				return nil, nil, err
+			}
+
+		case "joints":
+			if err := p.expect("{"); err != nil {
+				return nil, nil, err
+			}
+			for p.expect("}") != nil {
+				var joint md5Joint
+				var q Vertex3
+				if joint.name, err = p.string(); err != nil {
+					return nil, nil, err
+				}
+				if joint.parent, err = p.int(); err != nil {
+					return nil, nil, err
+				}
+				if joint.parent >= len(joints) {
+					return nil, nil, p.errorf("joint %q has parent %d, not coming before it", joint.name, joint.parent)
+				}
+				if err := p.tuple(&joint.position.X, &joint.position.Y, &joint.position.Z); err != nil {
+					return nil, nil, err
+				}
+				if err := p.tuple(&q.X, &q.Y, &q.Z); err != nil {
+					return nil, nil, err
+				}
+				joint.orientation = md5Quaternion(q.X, q.Y, q.Z)
+				joints = append(joints, joint)
+			}
+
+		case "mesh":
+			if err := md5Mesh(p, obj, joints); err != nil {
+				return nil, nil, err
+			}
+
+		default:
+			return nil, nil, p.errorf("unexpected %s", keyword)
+		}
+	}
+
+	obj.generateTangents()
+	obj.Bounds = obj.aabb()
+	obj.vertices = nil
+	obj.faceVertexIds = nil
+
+	// Joints are in model space in the bind pose, nodes relative to their parent.
+	root := NewNode(filepath.Base(filename))
+	root.Transform = idTechAxes
+
+	skin := &Skin{}
+	binds := make([]Matrix4, len(joints))
+	for i, joint := range joints {
+		node := NewNode(joint.name)
+		binds[i] = composeTransform(joint.position, joint.orientation, Vertex3{X: 1, Y: 1, Z: 1})
+		node.Transform = binds[i]
+		if joint.parent >= 0 {
+			inverse, _ := binds[joint.parent].Inverse()
+			node.Transform = inverse.Multiply(binds[i])
+			skin.Joints[joint.parent].Add(node)
+		} else {
+			root.Add(node)
+		}
+
+		inverse, _ := binds[i].Inverse()
+		skin.Joints = append(skin.Joints, node)
+		skin.InverseBindMatrices = append(skin.InverseBindMatrices, inverse)
+	}
+
+	mesh := NewNode("mesh")
+	mesh.Mesh = obj
+	mesh.Skin = skin
+	root.Add(mesh)
+
+	// Animations of the same skeleton, which other models next to this one may not share.
+	animations, err := filepath.Glob(filepath.Join(filepath.Dir(filename), "*.md5anim"))
+	if err != nil {
+		return nil, nil, err
+	}
+	sort.Strings(animations)
+
+	var clips []*AnimationClip
+	for _, animation := range animations {
+		clip, err := loadMD5Animation(ctx, animation, joints, skin.Joints)
+		if err != nil {
+			return nil, nil, err
+		}
+		if clip != nil {
+			clips = append(clips, clip)
+		}
+	}
+
+	return root, clips, nil
+}
+
+// A mesh block, its vertices put together from the joints in the bind pose and added to obj.
+func md5Mesh(p *md5Parser, obj *Obj, joints []md5Joint) error {
+	type md5Vertex struct {
+		uv                 Vertex2
+		start, weightCount int
+	}
+
+	var vertices []md5Vertex
+	var triangles [][3]int
+	var weights []md5Weight
+
+	if err := p.expect("{"); err != nil {
+		return err
+	}
+	for p.expect("}") != nil {
+		keyword, err := p.next()
+		if err != nil {
+			return err
+		}
+
+		switch keyword {
+		case "shader":
+			if _, err := p.string(); err != nil {
+				return err
+			}
+
+		case "numverts", "numtris", "numweights":
+			if _, err := p.int(); err != nil {
+				return err
+			}
+
+		case "vert":
+			var v md5Vertex
+			if _, err := p.int(); err != nil {
+				return err
+			}
+			if err := p.tuple(&v.uv.X, &v.uv.Y); err != nil {
+				return err
+			}
+			// Texture coordinates start from the top.
+			v.uv.Y = 1 - v.uv.Y
+			if v.start, err = p.int(); err != nil {
+				return err
+			}
+			if v.weightCount, err = p.int(); err != nil {
+				return err
+			}
+			vertices = append(vertices, v)
+
+		case "tri":
+			var t [3]int
+			if _, err := p.int(); err != nil {
+				return err
+			}
+			for i := range t {
+				if t[i], err = p.int(); err != nil {
+					return err
+				}
+			}
+			triangles = append(triangles, t)
+
+		case "weight":
+			var w md5Weight
+			if _, err := p.int(); err != nil {
+				return err
+			}
+			if w.joint, err = p.int(); err != nil {
+				return err
+			}
+			if w.joint < 0 || w.joint >= len(joints) {
+				return p.errorf("weight on joint %d out of range", w.joint)
+			}
+			if w.bias, err = p.float(); err != nil {
+				return err
+			}
+			if err := p.tuple(&w.position.X, &w.position.Y, &w.position.Z); err != nil {
+				return err
+			}
+			weights = append(weights, w)
+
+		default:
+			return p.errorf("unexpected %s", keyword)
+		}
+	}
+
+	positions := make([]Vertex3, len(vertices))
+	jointWeights := make([]JointWeights, len(vertices))
+	for i, v := range vertices {
+		if v.start < 0 || v.weightCount < 0 || v.start+v.weightCount > len(weights) {
+			return p.errorf("vertex %d has weights out of range", i)
+		}
+		vertexWeights := append([]md5Weight{}, weights[v.start:v.start+v.weightCount]...)
+		for _, w := range vertexWeights {
+			joint := joints[w.joint]
+			offset := composeTransform(joint.position, joint.orientation, Vertex3{X: 1, Y: 1, Z: 1}).transformPoint(w.position)
+			positions[i] = positions[i].plus(offset.scale(w.bias))
+		}
+
+		// Skins only have room for the four joints weighing the most.
+		sort.Slice(vertexWeights, func(a, b int) bool { return vertexWeights[a].bias > vertexWeights[b].bias })
+		var joints, biases []float64
+		for k := 0; k < 4 && k < len(vertexWeights); k++ {
+			joints = append(joints, float64(vertexWeights[k].joint))
+			biases = append(biases, vertexWeights[k].bias)
+		}
+		jointWeights[i] = gltfJointWeights(joints, biases)
+	}
+
+	polygons := make([][]int, len(triangles))
+	for k, t := range triangles {
+		// Clockwise, like in all id Software formats.
+		polygons[k] = []int{t[0], t[2], t[1]}
+		for _, id := range polygons[k] {
+			if id < 0 || id >= len(vertices) {
+				return p.errorf("triangle %d has vertex %d out of range", k, id)
+			}
+		}
+	}
+	normals := smoothNormals(positions, polygons)
+
+	offset := len(obj.vertices)
+	obj.vertices = append(obj.vertices, positions...)
+	for _, polygon := range polygons {
+		var face Face
+		var faceWeights [3]JointWeights
+		for j, id := range polygon {
+			face.Vertices[j] = positions[id]
+			face.Normals[j] = normals[id]
+			face.Textures[j] = vertices[id].uv
+			faceWeights[j] = jointWeights[id]
+		}
+		obj.Faces = append(obj.Faces, face)
+		obj.Weights = append(obj.Weights, faceWeights)
+		obj.faceVertexIds = append(obj.faceVertexIds, [3]int{offset + polygon[0] + 1, offset + polygon[1] + 1, offset + polygon[2] + 1})
+	}
+
+	return nil
+}
+
+// Keyframes of the joints from an .md5anim file, nil when it animates another skeleton.
+func loadMD5Animation(ctx context.Context, filename string, joints []md5Joint, nodes []*Node) (*AnimationClip, error) {
+	p, err := newMD5Parser(ctx, filename)
+	if err != nil {
+		return nil, err
+	}
+
+	type hierarchy struct {
+		name                 string
+		parent, flags, start int
+	}
+
+	var frameRate float64
+	var animated []hierarchy
+	var base []md5Joint
+	frames := map[int][]float64{}
+
+	for !p.done() {
+		keyword, _ := p.next()
+		switch keyword {
+		case "MD5Version":
+			version, err := p.int()
+			if err != nil {
+				return nil, err
+			}
+			if version != 10 {
+				return nil, p.errorf("unsupported MD5 version %d", version)
+			}
+
+		case "commandline":
+			if _, err := p.string(); err != nil {
+				return nil, err
+			}
+
+		case "numFrames", "numJoints", "numAnimatedComponents":
+			if _, err := p.int(); err != nil {
+				return nil, err
+			}
+
+		case "frameRate":
+			if frameRate, err = p.float(); err != nil {
+				return nil, err
+			}
+
+		case "hierarchy":
+			if err := p.expect("{"); err != nil {
+				return nil, err
+			}
+			for p.expect("}") != nil {
+				var h hierarchy
+				if h.name, err = p.string(); err != nil {
+					return nil, err
+				}
+				for _, v := range []*int{&h.parent, &h.flags, &h.start} {
+					if *v, err = p.int(); err != nil {
+						return nil, err
+					}
+				}
+				animated = append(animated, h)
+			}
+
+		case "bounds":
+			var ignored float64
+			if err := p.expect("{"); err != nil {
+				return nil, err
+			}
+			for p.expect("}") != nil {
+				if err := p.tuple(&ignored, &ignored, &ignored); err != nil {
+					return nil, err
+				}
+			}
+
+		case "baseframe":
+			if err := p.expect("{"); err != nil {
+				return nil, err
+			}
+			for p.expect("}") != nil {
+				var joint md5Joint
+				var q Vertex3
+				if err := p.tuple(&joint.position.X, &joint.position.Y, &joint.position.Z); err != nil {
+					return nil, err
+				}
+				if err := p.tuple(&q.X, &q.Y, &q.Z); err != nil {
+					return nil, err
+				}
+				joint.orientation = Quaternion{X: q.X, Y: q.Y, Z: q.Z}
+				base = append(base, joint)
+			}
+
+		case "frame":
+			index, err := p.int()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect("{"); err != nil {
+				return nil, err
+			}
+			var components []float64
+			for p.expect("}") != nil {
+				f, err := p.float()
+				if err != nil {
+					return nil, err
+				}
+				components = append(components, f)
+			}
+			frames[index] = components
+
+		default:
+			return nil, p.errorf("unexpected %s", keyword)
+		}
+	}
+
+	if len(animated) != len(joints) {
+		return nil, nil
+	}
+	for i, h := range animated {
+		if h.name != joints[i].name || h.parent != joints[i].parent {
+			return nil, nil
+		}
+	}
+	if len(base) != len(joints) {
+		return nil, errors.New(fmt.Sprintf("base frame of %s has %d joints instead of %d", filename, len(base), len(joints)))
+	}
+	if frameRate <= 0 {
+		frameRate = 24
+	}
+
+	indices := make([]int, 0, len(frames))
+	for index := range frames {
+		indices = append(indices, index)
+	}
+	sort.Ints(indices)
+
+	name := strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename))
+	clip := &AnimationClip{Name: name, Loop: true, Speed: 1}
+	for i, h := range animated {
+		translation := AnimationChannel{Node: nodes[i], Path: TranslationPath}
+		rotation := AnimationChannel{Node: nodes[i], Path: RotationPath}
+
+		for _, index := range indices {
+			components := frames[index]
+
+			// Components the joint animates replace those of the base frame, in order.
+			values := []float64{base[i].position.X, base[i].position.Y, base[i].position.Z, base[i].orientation.X, base[i].orientation.Y, base[i].orientation.Z}
+			next := h.start
+			for bit := range values {
+				if h.flags&(1<<bit) == 0 {
+					continue
+				}
+				if next < 0 || next >= len(components) {
+					return nil, errors.New(fmt.Sprintf("frame %d of %s is missing components of joint %q", index, filename, h.name))
+				}
+				values[bit] = components[next]
+				next++
+			}
+
+			// Relative to the parent joint, or in model space for the roots.
+			q := md5Quaternion(values[3], values[4], values[5])
+			translation.Times = append(translation.Times, float64(index)/frameRate)
+			translation.Values = append(translation.Values, Vertex4{X: values[0], Y: values[1], Z: values[2]})
+			rotation.Times = append(rotation.Times, float64(index)/frameRate)
+			rotation.Values = append(rotation.Values, Vertex4{X: q.X, Y: q.Y, Z: q.Z, W: q.W})
+		}
+
+		clip.Channels = append(clip.Channels, translation, rotation)
+	}
+
+	return clip, nil
+}
@@ -0,0 +1,146 @@
+package renderer
+
+import "math"
+
+// Resolution of the grid texture coordinates are drawn into to measure their coverage.
+const uvCoverageResolution = 512
+
+// Statistics of a mesh, for a quick look at assets before using them.
+type MeshInfo struct {
+	Triangles int
+	// Distinct positions, corners in the same place counting once.
+	Vertices int
+	Bounds   AABB
+	// Faces per material name, the empty name counting those without a material.
+	Materials map[string]int
+	// Fraction of the unit texture square covered by texture coordinates, repeating ones wrapping
+	// around, 0 for meshes without any.
+	UVCoverage float64
+	// Edges shared by more than two triangles, which most mesh processing can't deal with.
+	NonManifoldEdges int
+	// Edges of a single triangle, around holes and the borders of open meshes.
+	BoundaryEdges int
+	// Triangles without any area, collapsed to a line or a point.
+	DegenerateTriangles int
+}
+
+func (obj *Obj) Info() MeshInfo {
+	info := MeshInfo{
+		Triangles: len(obj.Faces),
+		Bounds:    obj.Bounds,
+		Materials: map[string]int{},
+	}
+
+	// Faces only know about positions, so corners in the same place are taken as the same vertex.
+	ids := map[Vertex3]int{}
+	edges := map[[2]int]int{}
+	textured := false
+
+	for _, face := range obj.Faces {
+		var f [3]int
+		for i, v := range face.Vertices {
+			id, ok := ids[v]
+			if !ok {
+				id = len(ids)
+				ids[v] = id
+			}
+			f[i] = id
+
+			if face.Textures[i] != (Vertex2{}) {
+				textured = true
+			}
+		}
+
+		for i := 0; i < 3; i++ {
+			if a, b := f[i], f[(i+1)%3]; a != b {
+				edges[edgeKey(a, b)]++
+			}
+		}
+
+		p0, p1, p2 := face.Vertices[0], face.Vertices[1], face.Vertices[2]
+		if p1.minus(p0).cross(p2.minus(p0)).length() == 0 {
+			info.DegenerateTriangles++
+		}
+
+		name := ""
+		if face.Material != nil {
+			name = face.Material.Name
+		}
+		info.Materials[name]++
+	}
+
+	info.Vertices = len(ids)
+	for _, count := range edges {
+		switch {
+		case count == 1:
+			info.BoundaryEdges++
+		case count > 2:
+			info.NonManifoldEdges++
+		}
+	}
+
+	if textured {
+		info.UVCoverage = obj.uvCoverage(uvCoverageResolution)
+	}
+
+	return info
+}
+
+// Draws the texture coordinates of every face into a grid, counting the cells with their center
+// inside at least one of them.
+func (obj *Obj) uvCoverage(resolution int) float64 {
+	covered := make([]bool, resolution*resolution)
+	count := 0
+
+	for _, face := range obj.Faces {
+		var uv [3]Vertex2
+		for i, t := range face.Textures {
+			uv[i] = t.scale(float64(resolution))
+		}
+
+		area := (uv[1].X-uv[0].X)*(uv[2].Y-uv[0].Y) - (uv[2].X-uv[0].X)*(uv[1].Y-uv[0].Y)
+		if area == 0 {
+			continue
+		}
+
+		// Past a full repetition of the texture, the cells wrap around onto covered ones.
+		minX := int(math.Floor(math.Min(uv[0].X, math.Min(uv[1].X, uv[2].X))))
+		minY := int(math.Floor(math.Min(uv[0].Y, math.Min(uv[1].Y, uv[2].Y))))
+		maxX := int(math.Ceil(math.Max(uv[0].X, math.Max(uv[1].X, uv[2].X))))
+		maxY := int(math.Ceil(math.Max(uv[0].Y, math.Max(uv[1].Y, uv[2].Y))))
+		maxX = minInt(maxX, minX+resolution)
+		maxY = minInt(maxY, minY+resolution)
+
+		for y := minY; y < maxY; y++ {
+			for x := minX; x < maxX; x++ {
+				p := Vertex2{X: float64(x) + 0.5, Y: float64(y) + 0.5}
+				if !insideUV(uv, p, area) {
+					continue
+				}
+
+				cell := mod(y, resolution)*resolution + mod(x, resolution)
+				if !covered[cell] {
+					covered[cell] = true
+					count++
+				}
+			}
+		}
+	}
+
+	return float64(count) / float64(len(covered))
+}
+
+func insideUV(uv [3]Vertex2, p Vertex2, area float64) bool {
+	for i := 0; i < 3; i++ {
+		a, b := uv[i], uv[(i+1)%3]
+		edge := (b.X-a.X)*(p.Y-a.Y) - (p.X-a.X)*(b.Y-a.Y)
+		if edge*area < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func mod(a, n int) int {
+	return (a%n + n) % n
+}
@@ -0,0 +1,121 @@
+package renderer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Picks the loader from the file extension, registered ones first and OBJ being the default. The meshes of animated models
+// get merged into one, as they are without animations. Names starting with @ are built-in
+// primitives instead of files, like @sphere.
+func LoadModel(filename string) (*Obj, error) {
+	return LoadModelContext(context.Background(), filename)
+}
+
+// Same as LoadModel, giving up with the error of the context once it's canceled, files of every
+// kind, along with their textures, stopping being read right away.
+func LoadModelContext(ctx context.Context, filename string) (*Obj, error) {
+	obj, err := loadModel(ctx, filename)
+	// Whatever loading failed with, reads stop once canceled.
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	return obj, err
+}
+
+func loadModel(ctx context.Context, filename string) (*Obj, error) {
+	if strings.HasPrefix(filename, "@") {
+		primitive, ok := primitives[filename[1:]]
+		if !ok {
+			return nil, errors.New(fmt.Sprintf("unknown primitive %q", filename))
+		}
+		return primitive(), nil
+	}
+
+	if loader, ok := registeredLoader(filepath.Ext(filename)); ok {
+		obj, err := loader(ctx, filename)
+		if err != nil {
+			return nil, err
+		}
+		if len(obj.Files) == 0 {
+			obj.Files = []string{filename}
+		}
+		return obj, nil
+	}
+
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".ply":
+		return loadPlyFromFile(ctx, filename)
+	}
+
+	if IsAnimated(filename) {
+		root, _, err := LoadAnimatedModelContext(ctx, filename)
+		if err != nil {
+			return nil, err
+		}
+
+		scene := NewScene()
+		scene.Root.Add(root)
+		obj := scene.Flatten()
+		obj.Bounds = obj.aabb()
+		obj.Files = []string{filename}
+		return obj, nil
+	}
+
+	return loadObjFromFile(ctx, filename)
+}
+
+// Whether the model comes with a hierarchy of nodes and animations, to be loaded with
+// LoadAnimatedModel: glTF, MD2 or MD5 files.
+func IsAnimated(filename string) bool {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".gltf", ".glb", ".md2", ".md5mesh":
+		return true
+	}
+	return false
+}
+
+// Loads a glTF, MD2 or MD5 model as a node, along with its animations.
+func LoadAnimatedModel(filename string) (*Node, []*AnimationClip, error) {
+	return LoadAnimatedModelContext(context.Background(), filename)
+}
+
+// Same as LoadAnimatedModel, giving up with the error of the context once it's canceled.
+func LoadAnimatedModelContext(ctx context.Context, filename string) (*Node, []*AnimationClip, error) {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".md2":
+		return loadMD2(ctx, filename)
+	case ".md5mesh":
+		return loadMD5(ctx, filename)
+	}
+	return LoadGLTFContext(ctx, filename)
+}
+
+// Reads fail with the error of the context once it's canceled, stopping whatever parses them.
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.r.Read(p)
+}
+
+// Same as os.ReadFile, giving up once the context is canceled.
+func readFileContext(ctx context.Context, filename string) ([]byte, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return io.ReadAll(contextReader{ctx, file})
+}
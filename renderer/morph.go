@@ -0,0 +1,63 @@
+package renderer
+
+// Shape a mesh can be blended towards, like a smile or a blink on a face.
+type MorphTarget struct {
+	Name string
+	// Displacements of every corner of every face, added to the mesh times the weight of the
+	// target. Normals are nil for targets leaving them alone.
+	Positions [][3]Vertex3
+	Normals   [][3]Vertex3
+}
+
+// The mesh with its targets blended in, nil when all of them weigh nothing.
+func (obj *Obj) morph(weights []float64) *Obj {
+	active := false
+	for i, w := range weights {
+		active = active || w != 0 && i < len(obj.Targets)
+	}
+	if !active {
+		return nil
+	}
+
+	morphed := &Obj{Faces: make([]Face, len(obj.Faces)), Materials: obj.Materials, Weights: obj.Weights}
+	copy(morphed.Faces, obj.Faces)
+
+	for t, target := range obj.Targets {
+		if t >= len(weights) || weights[t] == 0 {
+			continue
+		}
+		w := weights[t]
+
+		for k := range morphed.Faces {
+			face := &morphed.Faces[k]
+			for i := 0; i < 3; i++ {
+				face.Vertices[i] = face.Vertices[i].plus(target.Positions[k][i].scale(w))
+				if target.Normals != nil {
+					face.Normals[i] = face.Normals[i].plus(target.Normals[k][i].scale(w))
+				}
+			}
+		}
+	}
+
+	for k := range morphed.Faces {
+		face := &morphed.Faces[k]
+		for i := 0; i < 3; i++ {
+			if face.Normals[i].length() > 0 {
+				face.Normals[i] = face.Normals[i].normalize(1.0)
+			}
+		}
+	}
+	morphed.Bounds = morphed.aabb()
+
+	return morphed
+}
+
+// Index of the target with that name, -1 when the mesh has none.
+func (obj *Obj) MorphTarget(name string) int {
+	for i, target := range obj.Targets {
+		if target.Name == name {
+			return i
+		}
+	}
+	return -1
+}
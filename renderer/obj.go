@@ -1,24 +1,45 @@
-package main
+package renderer
 
 import (
 	"bufio"
+	"context"
 	"errors"
 	"fmt"
+	"math"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 )
 
 type Obj struct {
-	Faces []Face
+	Faces     []Face
+	Materials map[string]*Material
+	// In model space, computed at load time.
+	Bounds AABB
+	// Simplified versions of the mesh, each with about half the faces of the previous one.
+	LODs []*Obj
+	// Joints moving every corner of every face, for meshes deformed by a skin.
+	Weights [][3]JointWeights
+	// Shapes blended in by the weights of the nodes showing the mesh.
+	Targets []MorphTarget
+	// Files the mesh got loaded from, along with its material libraries and their textures, none
+	// for built-in primitives.
+	Files []string
 
 	vertices []Vertex3
 	textures []Vertex2
 	normals  []Vertex3
+	// Vertex colors, white for the vertices without any when some have them.
+	colors   []Vertex3
+	colored  bool
+	material *Material
+	// Vertex ids of every face, to find out which faces share vertices.
+	faceVertexIds [][3]int
 }
 
-func loadObjFromFile(filename string) (*Obj, error) {
-	obj := Obj{}
+func loadObjFromFile(ctx context.Context, filename string) (*Obj, error) {
+	obj := Obj{Materials: map[string]*Material{}, Files: []string{filename}}
 
 	file, err := os.Open(filename)
 	if err != nil {
@@ -27,7 +48,7 @@ func loadObjFromFile(filename string) (*Obj, error) {
 	defer file.Close()
 
 	lineNumber := 0
-	scanner := bufio.NewScanner(file)
+	scanner := bufio.NewScanner(contextReader{ctx, file})
 	for scanner.Scan() {
 		line := scanner.Text()
 		lineNumber++
@@ -62,6 +83,18 @@ func loadObjFromFile(filename string) (*Obj, error) {
 			if err := obj.parseFaceLine(line, lineNumber); err != nil {
 				return nil, err
 			}
+
+		// Material library line
+		case "mtllib":
+			if err := obj.parseMaterialLibraryLine(ctx, line, filename); err != nil {
+				return nil, err
+			}
+
+		// Material line
+		case "usemtl":
+			if err := obj.parseUseMaterialLine(line, lineNumber); err != nil {
+				return nil, err
+			}
 		}
 	}
 
@@ -69,10 +102,16 @@ func loadObjFromFile(filename string) (*Obj, error) {
 		return nil, err
 	}
 
+	obj.generateTangents()
+	obj.Bounds = obj.aabb()
+
 	// Cleanup
 	obj.vertices = []Vertex3{}
 	obj.normals = []Vertex3{}
 	obj.textures = []Vertex2{}
+	obj.colors = nil
+	obj.material = nil
+	obj.faceVertexIds = nil
 
 	return &obj, nil
 }
@@ -91,6 +130,8 @@ func (obj *Obj) parseFaceLine(line string, lineNumber int) error {
 		return errors.New(fmt.Sprintf("insufficient points found in face directive on line %d", lineNumber))
 	}
 
+	var vertexIds [3]int
+
 	// First vertex
 	firstArgs := strings.Split(parts[1], "/")
 	vertexId, err := strconv.Atoi(firstArgs[0])
@@ -109,6 +150,7 @@ func (obj *Obj) parseFaceLine(line string, lineNumber int) error {
 	if err != nil {
 		return err
 	}
+	vertexIds[0] = vertexId
 	firstVertexTexture, err := obj.resolveVertexTextureId(vertexTextureId, lineNumber)
 	if err != nil {
 		return err
@@ -136,6 +178,7 @@ func (obj *Obj) parseFaceLine(line string, lineNumber int) error {
 	if err != nil {
 		return err
 	}
+	vertexIds[1] = vertexId
 	secondVertexTexture, err := obj.resolveVertexTextureId(vertexTextureId, lineNumber)
 	if err != nil {
 		return err
@@ -163,6 +206,7 @@ func (obj *Obj) parseFaceLine(line string, lineNumber int) error {
 	if err != nil {
 		return err
 	}
+	vertexIds[2] = vertexId
 	thirdVertexTexture, err := obj.resolveVertexTextureId(vertexTextureId, lineNumber)
 	if err != nil {
 		return err
@@ -188,7 +232,50 @@ func (obj *Obj) parseFaceLine(line string, lineNumber int) error {
 			secondVertexNormal,
 			thirdVertexNormal,
 		},
+		Colors: [3]Vertex3{
+			obj.colors[vertexIds[0]-1],
+			obj.colors[vertexIds[1]-1],
+			obj.colors[vertexIds[2]-1],
+		},
+		Colored:  obj.colored,
+		Material: obj.material,
 	})
+	obj.faceVertexIds = append(obj.faceVertexIds, vertexIds)
+
+	return nil
+}
+
+// Libraries are resolved relative to the OBJ file.
+func (obj *Obj) parseMaterialLibraryLine(ctx context.Context, line string, filename string) error {
+	for _, library := range strings.Fields(line)[1:] {
+		path := filepath.Join(filepath.Dir(filename), library)
+		materials, textures, err := loadMtlFromFile(ctx, path)
+		if err != nil {
+			return err
+		}
+		obj.Files = append(append(obj.Files, path), textures...)
+
+		for name, material := range materials {
+			obj.Materials[name] = material
+		}
+	}
+
+	return nil
+}
+
+func (obj *Obj) parseUseMaterialLine(line string, lineNumber int) error {
+	parts := strings.Fields(line)
+
+	if len(parts) < 2 {
+		return errors.New(fmt.Sprintf("missing material name in usemtl directive on line %d", lineNumber))
+	}
+
+	material, ok := obj.Materials[parts[1]]
+	if !ok {
+		return errors.New(fmt.Sprintf("unable to resolve material %s used on line %d", parts[1], lineNumber))
+	}
+
+	obj.material = material
 
 	return nil
 }
@@ -250,7 +337,22 @@ func (obj *Obj) parseVertexLine(line string, lineNumber int) error {
 		return errors.New(fmt.Sprintf("invalid float z coordinate found in vertex directive on line %d", lineNumber))
 	}
 
+	// Nonstandard extension: x y z r g b
+	color := Vertex3{X: 1, Y: 1, Z: 1}
+	if len(parts) >= 7 {
+		var c [3]float64
+		for i := range c {
+			c[i], err = strconv.ParseFloat(parts[4+i], 64)
+			if err != nil {
+				return errors.New(fmt.Sprintf("invalid float color found in vertex directive on line %d", lineNumber))
+			}
+		}
+		color = Vertex3{X: c[0], Y: c[1], Z: c[2]}
+		obj.colored = true
+	}
+
 	obj.vertices = append(obj.vertices, vertex)
+	obj.colors = append(obj.colors, color)
 
 	return nil
 }
@@ -330,3 +432,107 @@ func (obj *Obj) parseVertexTextureLine(line string, lineNumber int) error {
 
 	return nil
 }
+
+func (obj *Obj) bounds() (Vertex3, Vertex3) {
+	if len(obj.Faces) == 0 {
+		return Vertex3{}, Vertex3{}
+	}
+
+	min := obj.Faces[0].Vertices[0]
+	max := min
+
+	for _, face := range obj.Faces {
+		for _, v := range face.Vertices {
+			min = Vertex3{X: math.Min(min.X, v.X), Y: math.Min(min.Y, v.Y), Z: math.Min(min.Z, v.Z)}
+			max = Vertex3{X: math.Max(max.X, v.X), Y: math.Max(max.Y, v.Y), Z: math.Max(max.Z, v.Z)}
+		}
+	}
+
+	return min, max
+}
+
+// A point is inside a closed mesh when a ray cast from it crosses the surface an odd number of times.
+func (obj *Obj) contains(p Vertex3) bool {
+	direction := Vertex3{X: 1}
+	crossings := 0
+
+	for _, face := range obj.Faces {
+		e1 := face.Vertices[1].minus(face.Vertices[0])
+		e2 := face.Vertices[2].minus(face.Vertices[0])
+		h := direction.cross(e2)
+		a := e1.dot(h)
+		if math.Abs(a) < 1e-12 {
+			continue
+		}
+
+		f := 1 / a
+		s := p.minus(face.Vertices[0])
+		u := f * s.dot(h)
+		if u < 0 || u > 1 {
+			continue
+		}
+
+		q := s.cross(e1)
+		v := f * direction.dot(q)
+		if v < 0 || u+v > 1 {
+			continue
+		}
+
+		if f*e2.dot(q) > 0 {
+			crossings++
+		}
+	}
+
+	return crossings%2 == 1
+}
+
+// Tangents follow the direction in which the U texture coordinate grows, and are needed to bring
+// normals from normal maps into world space. Faces sharing a vertex average their tangents there.
+func (obj *Obj) generateTangents() {
+	tangents := make([]Vertex3, len(obj.vertices))
+	bitangents := make([]Vertex3, len(obj.vertices))
+
+	for k, face := range obj.Faces {
+		e1 := face.Vertices[1].minus(face.Vertices[0])
+		e2 := face.Vertices[2].minus(face.Vertices[0])
+		du1, dv1 := face.Textures[1].X-face.Textures[0].X, face.Textures[1].Y-face.Textures[0].Y
+		du2, dv2 := face.Textures[2].X-face.Textures[0].X, face.Textures[2].Y-face.Textures[0].Y
+
+		det := du1*dv2 - du2*dv1
+		if math.Abs(det) < 1e-12 {
+			continue
+		}
+
+		t := e1.scale(dv2).minus(e2.scale(dv1)).scale(1 / det)
+		b := e2.scale(du1).minus(e1.scale(du2)).scale(1 / det)
+
+		for _, id := range obj.faceVertexIds[k] {
+			tangents[id-1] = tangents[id-1].plus(t)
+			bitangents[id-1] = bitangents[id-1].plus(b)
+		}
+	}
+
+	for k := range obj.Faces {
+		face := &obj.Faces[k]
+
+		for i, id := range obj.faceVertexIds[k] {
+			n := face.Normals[i]
+			t := tangents[id-1]
+
+			// Gram-Schmidt, so that the tangent is perpendicular to the normal.
+			t = t.minus(n.scale(n.dot(t)))
+			if t.length() < 1e-12 {
+				continue
+			}
+			t = t.normalize(1.0)
+
+			// Mirrored texture coordinates flip the bitangent.
+			handedness := 1.0
+			if n.cross(t).dot(bitangents[id-1]) < 0 {
+				handedness = -1.0
+			}
+
+			face.Tangents[i] = Vertex4{X: t.X, Y: t.Y, Z: t.Z, W: handedness}
+		}
+	}
+}
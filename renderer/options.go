@@ -0,0 +1,321 @@
+package renderer
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+)
+
+type Winding int
+
+const (
+	CounterClockwise Winding = iota
+	Clockwise
+)
+
+type Backend int
+
+const (
+	// Per-pixel depth testing against a z-buffer.
+	ZBuffer Backend = iota
+	// Triangles drawn back to front without a z-buffer, so intersecting or cyclically overlapping
+	// triangles can come out wrong. Saves a float per pixel on memory-constrained targets.
+	Painter
+	// Rays followed from the camera as they bounce around the scene, for indirect lighting, soft
+	// reflections and exact shadows. Much slower, and noisy with few samples.
+	PathTracing
+)
+
+// How triangles get turned into pixels, the same ones either way.
+type Rasterizer int
+
+const (
+	// Every pixel of the bounding box of triangles tested against their edges, rows of pixels at
+	// once with SIMD. Best for small triangles, which leave little of their box empty.
+	EdgeFunctions Rasterizer = iota
+	// Rows filled from edge to edge, walking down triangles. Nothing gets tested outside of them,
+	// best for large or thin triangles.
+	Scanline
+)
+
+type Wireframe int
+
+const (
+	WireframeOff Wireframe = iota
+	// Edges only, instead of the shaded triangles.
+	WireframeOnly
+	// Edges drawn over the shaded triangles, minus the hidden ones.
+	WireframeOverlay
+)
+
+// How often lighting gets computed, trading quality for speed.
+type ShadingMode int
+
+const (
+	// Per pixel, with interpolated normals.
+	PhongShading ShadingMode = iota
+	// Per vertex, the lit colors being interpolated across triangles.
+	GouraudShading
+	// Once per triangle, with its geometric normal.
+	FlatShading
+)
+
+// How vertex colors combine with diffuse textures.
+type VertexColors int
+
+const (
+	// Multiplied together, like glTF's COLOR_0.
+	ModulateVertexColors VertexColors = iota
+	// Vertex colors only show on untextured surfaces.
+	TextureOverVertexColors
+	IgnoreVertexColors
+)
+
+type AntiAliasing int
+
+const (
+	NoAntiAliasing AntiAliasing = iota
+	// Renders at a higher resolution then averages blocks of pixels. Every sample gets shaded,
+	// smoothing textures and highlights too.
+	Supersampling
+	// Tests coverage and depth per sample but shades once per pixel, smoothing edges only.
+	Multisampling
+	// Smooths edges found in the final image, cheap enough for interactive framerates.
+	FXAA
+)
+
+// What fragments show, the lit result or one of the intermediate steps of shading.
+type View int
+
+const (
+	ViewLit View = iota
+	ViewDepth
+	ViewFlat
+	ViewTextured
+	// Debug views of the interpolated vertex attributes, directions in world space mapped from
+	// -1..1 to 0..1 like in normal maps, and texture coordinates wrapped between 0 and 1 in red
+	// and green.
+	ViewNormals
+	ViewTangents
+	ViewUV
+	// Heatmap of how many fragments got written to every pixel, from blue for one to white for
+	// eight or more, showing where triangles pile up on top of one another.
+	ViewOverdraw
+)
+
+type Options struct {
+	// Size of the image in pixels.
+	Width, Height int
+	// What the image starts out as before drawing, opaque black when nil. A transparent color
+	// leaves the background transparent in formats with an alpha channel, like PNG.
+	ClearColor color.Color
+	// Part of the image the view gets mapped to, in pixels from the top-left corner, for several
+	// views side by side. Pixels outside of it are left untouched, opaque black in new images. The
+	// whole image when empty.
+	Viewport image.Rectangle
+	// Only pixels inside of it get drawn and cleared, within the viewport, the view staying the
+	// same. For redrawing part of a frame, like under a window of a UI, or tile by tile. No
+	// clipping when empty.
+	Scissor image.Rectangle
+
+	View    View
+	Shading ShadingMode
+
+	VertexColors VertexColors
+	// Triangle edges, for inspecting the topology of meshes.
+	Wireframe Wireframe
+	Grid      GridOptions
+	// Boxes around meshes and the orientation of the world in a corner of the frame, for
+	// debugging transforms and culling.
+	BoundingBoxes BoundingBoxes
+	AxisGizmo     bool
+	// Lines along the normals and tangents of vertices, for inspecting imported meshes.
+	VertexNormals bool
+
+	Backend Backend
+	// Only used by the z-buffer and painter backends, without multisampling which tests coverage
+	// per sample.
+	Rasterizer Rasterizer
+	// Depth of opaque triangles gets drawn first, then only the nearest fragments get shaded. Saves
+	// shading fragments drawn over later, for scenes with lots of overlapping triangles and costly
+	// lighting. Only used with a z-buffer and without multisampling.
+	DepthPrepass bool
+	// Opaque triangles only get their surfaces drawn, into a G-buffer holding the albedo, normal,
+	// depth and material parameters of every pixel, which then gets lit once per pixel. Lighting
+	// doesn't get wasted on fragments drawn over later, for scenes with many lights. Only used with
+	// a z-buffer, the lit view and Phong shading, without multisampling.
+	Deferred bool
+	// Point and spot lights only light what's within their range, where more than 1/256 of them
+	// reaches, and shading only goes through the lights in range, binned into a grid over the
+	// scene. For scenes with many small lights, whose faint tails get cut off.
+	LightCulling bool
+	// Only used by the path tracing backend.
+	PathTracing PathTracingOptions
+
+	// Tone mapping with the exposure, as a ToneMapEffect going before the first of the post
+	// effects working on the image rather than on linear colors, unless they have one already.
+	ToneMapping ToneMapping
+	// In stops, every one doubling the light. 0 leaves colors as they are.
+	Exposure float64
+
+	// Passes over the finished image, in order, like bloom or depth of field. The HDR image only
+	// goes through the ones working on linear colors, before tone mapping.
+	PostEffects []PostEffect
+
+	AntiAliasing AntiAliasing
+	// Samples per pixel. Supersampling rounds them up to a square number, multisampling supports
+	// 2, 4 and 8.
+	Samples int
+
+	// Goroutines drawing tiles of the image in parallel, as many as GOMAXPROCS when 0.
+	Workers int
+
+	// Triangles are always clipped against the near plane, this also clips them to the sides
+	// and the far plane of the view frustum, sparing the rasterizer off-screen work.
+	FrustumClipping bool
+	// Skip triangles facing away from the camera, which are hidden anyway on closed meshes.
+	BackfaceCulling bool
+	// Winding order of front-facing triangles as seen on screen. OBJ files are counter-clockwise.
+	FrontFace Winding
+
+	Shadows ShadowOptions
+	Toon    ToonOptions
+
+	// What rendering took gets added to these stats when set.
+	Stats *RenderStats
+	// Called after every pass of path tracing, which takes its samples in passes when set, for
+	// previews of long renders. Rasterizing draws frames in one go and never calls it.
+	Progress func(Progress)
+}
+
+// What the command line renders with unless told otherwise.
+func DefaultOptions() Options {
+	output := DefaultOutput()
+
+	return Options{
+		Width:           output.Width,
+		Height:          output.Height,
+		Samples:         4,
+		Backend:         ZBuffer,
+		FrustumClipping: true,
+		BackfaceCulling: true,
+		FrontFace:       CounterClockwise,
+		Shadows: ShadowOptions{
+			Resolution: 2048,
+			Bias:       0.3,
+			PCF:        1,
+		},
+		PathTracing: PathTracingOptions{
+			Samples: 64,
+			Bounces: 4,
+		},
+	}
+}
+
+func (o *Options) rect() image.Rectangle {
+	return image.Rect(0, 0, o.Width, o.Height)
+}
+
+// The viewport, and the part of it the scissor lets through, in frames of the size with rows from
+// the bottom up. False when they cover the whole frame.
+func (o *Options) regions(width, height int) (viewport, scissor image.Rectangle, ok bool) {
+	frame := image.Rect(0, 0, width, height)
+	viewport = frame
+	if !o.Viewport.Empty() {
+		viewport = o.Viewport
+	}
+	scissor = viewport.Intersect(frame)
+	if !o.Scissor.Empty() {
+		scissor = scissor.Intersect(o.Scissor)
+	}
+	if viewport == frame && scissor == frame {
+		return frame, frame, false
+	}
+
+	flip := func(r image.Rectangle) image.Rectangle {
+		return image.Rect(r.Min.X, height-r.Max.Y, r.Max.X, height-r.Min.Y)
+	}
+	return flip(viewport), flip(scissor), true
+}
+
+func (o *Options) validate() error {
+	if o.Width <= 0 || o.Height <= 0 {
+		return errors.New(fmt.Sprintf("invalid image size %dx%d", o.Width, o.Height))
+	}
+	if o.AntiAliasing == Multisampling {
+		if _, ok := samplePatterns[o.Samples]; !ok {
+			return errors.New(fmt.Sprintf("multisampling supports 2, 4 or 8 samples, not %d", o.Samples))
+		}
+	}
+	if o.Backend == PathTracing && (o.PathTracing.Samples < 0 || o.PathTracing.Bounces < 0) {
+		return errors.New(fmt.Sprintf("invalid path tracing with %d samples and %d bounces", o.PathTracing.Samples, o.PathTracing.Bounces))
+	}
+	if o.Shadows.Enabled && !o.Shadows.RayTraced && o.Shadows.Resolution <= 0 {
+		return errors.New(fmt.Sprintf("invalid shadow map resolution %d", o.Shadows.Resolution))
+	}
+	if o.Shadows.LightSize < 0 {
+		return errors.New(fmt.Sprintf("invalid light size %g for soft shadows", o.Shadows.LightSize))
+	}
+	if o.Shadows.Cascades < 0 || o.Shadows.Cascades > 4 {
+		return errors.New(fmt.Sprintf("invalid number of shadow cascades %d, at most 4", o.Shadows.Cascades))
+	}
+
+	return nil
+}
+
+// Runtime toggles for interactive viewers, reporting whether the key was bound to one.
+func (o *Options) toggle(key Key) bool {
+	switch key {
+	case KeyTab:
+		o.Wireframe = (o.Wireframe + 1) % 3
+		return true
+	case KeyG:
+		o.Grid.Enabled = !o.Grid.Enabled
+		return true
+	case KeyB:
+		o.BoundingBoxes = (o.BoundingBoxes + 1) % 3
+		return true
+	case KeyX:
+		o.AxisGizmo = !o.AxisGizmo
+		return true
+	case KeyN:
+		o.VertexNormals = !o.VertexNormals
+		return true
+	case KeyV:
+		o.View = (o.View + 1) % (ViewOverdraw + 1)
+		return true
+	}
+
+	return false
+}
+
+type ShadowOptions struct {
+	Enabled bool
+	// Rays cast towards the lights instead of shadow maps, for exact hard shadows of every kind of
+	// light, without any bias to tune. Slower, and the other options don't apply.
+	RayTraced bool
+	// Width and height of the shadow maps, in texels.
+	Resolution int
+	// Depth offset, in the same 0 to 255 range as the depth buffer, keeping surfaces from
+	// shadowing themselves (shadow acne).
+	Bias float64
+	// Radius in texels of the percentage-closer filtering kernel, 0 for hard shadows.
+	PCF int
+	// Shadow maps of directional lights, up to 4, each covering a slice of the view further away
+	// than the previous one, for crisp shadows near the camera in large scenes without huge maps.
+	// One map over the whole scene when 0 or 1.
+	Cascades int
+	// Width of the lights, in world units, for soft shadows, sharp where they touch what casts
+	// them and blurrier further away (percentage-closer soft shadows). Directional lights being
+	// infinitely far, it's the angle they cover instead, in radians, the sun's being about 0.01.
+	// Hard shadows, only filtered by PCF, when 0.
+	LightSize float64
+}
+
+type PathTracingOptions struct {
+	// Paths traced through every pixel and averaged, each one more taking the noise down.
+	Samples int
+	// Times paths bounce off surfaces, 0 for direct lighting only.
+	Bounces int
+}
@@ -0,0 +1,36 @@
+package renderer
+
+import "image"
+
+// Colors of the overdraw heatmap for 0 to 8 fragments, the last one going for more too.
+var overdrawRamp = []Vertex3{
+	{},
+	{X: 0.1, Y: 0.1, Z: 0.8},
+	{X: 0.1, Y: 0.6, Z: 0.9},
+	{X: 0.1, Y: 0.8, Z: 0.2},
+	{X: 0.9, Y: 0.9, Z: 0.1},
+	{X: 1, Y: 0.6, Z: 0.1},
+	{X: 1, Y: 0.2, Z: 0.1},
+	{X: 1, Y: 0.5, Z: 0.6},
+	{X: 1, Y: 1, Z: 1},
+}
+
+// Replaces what got drawn with the heatmap of the fragments counted per pixel. Pixels nothing got
+// drawn to are left as they are, showing the background.
+func drawOverdraw(img *image.RGBA, hdr *hdrImage, counts []int) {
+	rect := img.Bounds()
+	for y := 0; y < rect.Dy(); y++ {
+		for x := 0; x < rect.Dx(); x++ {
+			count := counts[y*rect.Dx()+x]
+			if count == 0 {
+				continue
+			}
+
+			c := overdrawRamp[minInt(count, len(overdrawRamp)-1)]
+			img.SetRGBA(x, y, toRGBA(c))
+			if hdr != nil {
+				hdr.set(x, y, c)
+			}
+		}
+	}
+}
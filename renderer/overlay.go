@@ -0,0 +1,43 @@
+package renderer
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"time"
+)
+
+// Text boxed in the top-left corner of the frame with the frame rate, triangles and time taken by
+// every stage.
+func drawOverlay(img *image.RGBA, stats *RenderStats, fps float64, total time.Duration) {
+	text := fmt.Sprintf("%.0f fps %6.1f ms\ntriangles %d / %d", fps, milliseconds(total), stats.Drawn, stats.Triangles)
+	for stage, duration := range stats.Stages {
+		text += fmt.Sprintf("\n%-9s %6.1f ms", RenderStage(stage), milliseconds(duration))
+	}
+
+	drawTextBox(img, text, false)
+}
+
+// Text boxed in the bottom-left corner of the frame, like what the viewer has to say.
+func drawMessage(img *image.RGBA, text string) {
+	drawTextBox(img, text, true)
+}
+
+func drawTextBox(img *image.RGBA, text string, bottom bool) {
+	const margin, padding = 4, 3
+	rect := img.Bounds()
+	size := overlayFont.Measure(text)
+	box := image.Rect(margin, margin, margin+size.X+2*padding, margin+size.Y+2*padding).Add(rect.Min)
+	if bottom {
+		box = box.Add(image.Point{Y: rect.Dy() - box.Dy() - 2*margin})
+	}
+
+	// Darkens what's behind the text.
+	canvas := newFlippedCanvas(img)
+	canvas.FillRect(box, color.RGBA{A: 160})
+	canvas.DrawText(overlayFont, box.Min.Add(image.Point{X: padding, Y: padding}), text, color.White)
+}
+
+func milliseconds(d time.Duration) float64 {
+	return d.Seconds() * 1000
+}
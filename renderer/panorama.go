@@ -0,0 +1,84 @@
+package renderer
+
+import (
+	"image"
+	"math"
+)
+
+// Faces of the cube around the camera, with where they go in a cube cross like skyboxes have and
+// which way is up on them.
+var panoramaFaces = []struct {
+	cell        image.Point
+	forward, up Vertex3
+}{
+	{image.Point{X: 1, Y: 1}, Vertex3{Z: -1}, Vertex3{Y: 1}},
+	{image.Point{X: 2, Y: 1}, Vertex3{X: 1}, Vertex3{Y: 1}},
+	{image.Point{X: 3, Y: 1}, Vertex3{Z: 1}, Vertex3{Y: 1}},
+	{image.Point{X: 0, Y: 1}, Vertex3{X: -1}, Vertex3{Y: 1}},
+	{image.Point{X: 1, Y: 0}, Vertex3{Y: 1}, Vertex3{Z: 1}},
+	{image.Point{X: 1, Y: 2}, Vertex3{Y: -1}, Vertex3{Z: -1}},
+}
+
+// Renders everything around the camera into an equirectangular panorama, twice as wide as it is
+// high and as wide as the options say, for photo sphere and VR viewers. The camera looks at the
+// center of the image and its up direction is at the top, the target only giving the heading.
+//
+// The scene gets rendered six times, once for every face of a cube around the camera, and the
+// faces stitched together.
+func RenderPanorama(scene *Scene, camera Camera, options Options) (*image.RGBA, error) {
+	if err := options.validate(); err != nil {
+		return nil, err
+	}
+
+	// Faces as wide as a quarter turn of the panorama keep about the same detail.
+	size := maxInt(options.Width/4, 1)
+	faceOptions := options
+	faceOptions.Width, faceOptions.Height = size, size
+
+	// From the frame of the cube, looking down -Z with Y up, to world space.
+	up := camera.Up.normalize(1.0)
+	forward := camera.Target.minus(camera.Position)
+	forward = forward.minus(up.scale(forward.dot(up)))
+	// Looking straight up or down, any heading does.
+	if forward.length() < 1e-12 {
+		forward = up.cross(Vertex3{X: 1})
+		if forward.length() < 1e-12 {
+			forward = up.cross(Vertex3{Z: 1})
+		}
+	}
+	forward = forward.normalize(1.0)
+	right := forward.cross(up)
+	toWorld := func(d Vertex3) Vertex3 {
+		return right.scale(d.X).plus(up.scale(d.Y)).plus(forward.scale(-d.Z))
+	}
+
+	cube := &Skybox{Image: newHDRImage(4*size, 3*size), Layout: CubeCrossLayout}
+	for _, face := range panoramaFaces {
+		c := camera
+		c.Projection = Perspective
+		c.Fov = math.Pi / 2
+		c.Target = camera.Position.plus(toWorld(face.forward))
+		c.Up = toWorld(face.up)
+
+		img, err := Render(scene, c, faceOptions)
+		if err != nil {
+			return nil, err
+		}
+		for y := 0; y < size; y++ {
+			for x := 0; x < size; x++ {
+				cube.Image.set(face.cell.X*size+x, face.cell.Y*size+y, decodeSRGB(img.RGBAAt(x, y)))
+			}
+		}
+	}
+
+	width, height := options.Width, maxInt(options.Width/2, 1)
+	panorama := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			direction := equirectangularDirection(Vertex2{X: (float64(x) + 0.5) / float64(width), Y: (float64(y) + 0.5) / float64(height)})
+			panorama.SetRGBA(x, y, toRGBA(linearToSRGB(cube.sample(direction))))
+		}
+	}
+
+	return panorama, nil
+}
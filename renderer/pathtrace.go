@@ -0,0 +1,505 @@
+package renderer
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"math"
+	"runtime"
+	"sync"
+)
+
+const (
+	// Bounces after which paths start getting randomly stopped, the surviving ones making up for
+	// the others (Russian roulette).
+	rouletteBounces = 2
+	// Light picked up after bouncing gets clamped to this, trading a little energy for getting
+	// rid of fireflies, rare paths catching sharp highlights that take ages to average out.
+	maxIndirectRadiance = 4
+)
+
+// Renders the scene by following rays from the camera as they bounce off surfaces, collecting the
+// light they pick up along the way, like in photorealistic renderers. Surfaces are looked up
+// through a bounding volume hierarchy instead of being rasterized.
+type pathTracer struct {
+	*rayScene
+
+	lights      []Light
+	ambient     Vertex3
+	environment *Environment
+	skybox      *Skybox
+	fog         *Fog
+	bounces     int
+
+	vertexColors VertexColors
+}
+
+// What a ray hit, with everything needed to light it.
+type surfacePoint struct {
+	position Vertex3
+	// Shading normal, and normal of the triangle itself, both facing the side the ray came from.
+	normal    Vertex3
+	geometric Vertex3
+	uv        Vertex2
+	material  *Material
+	albedo    Vertex3
+}
+
+func newPathTracer(scene *Scene, options Options) *pathTracer {
+	return &pathTracer{
+		rayScene:     newRayScene(scene),
+		lights:       scene.Lights(),
+		ambient:      scene.Ambient,
+		environment:  scene.Environment,
+		skybox:       scene.Skybox,
+		fog:          scene.Fog,
+		bounces:      options.PathTracing.Bounces,
+		vertexColors: options.VertexColors,
+	}
+}
+
+// Path traces every pixel of the image, rows being split between workers. Returns the depth of the
+// closest surface seen through the center of every pixel, like the z-buffer of the rasterizer.
+// With a progress callback, samples get taken in passes, as many as all the previous ones every
+// time, the image being filled in after each of them. Stops after the row being traced when the
+// context gets canceled, leaving the image unfinished.
+func pathTrace(ctx context.Context, img *image.RGBA, hdr *hdrImage, scene *Scene, camera Camera, options Options) []float64 {
+	clearImage(img, hdr, options.ClearColor)
+
+	rect := img.Bounds()
+	width, height := rect.Dx(), rect.Dy()
+	aspect := float64(width) / float64(height)
+
+	// From clip space back to world space.
+	inverse, ok := camera.projectionMatrix(aspect).Multiply(camera.viewMatrix()).Inverse()
+	if !ok {
+		return nil
+	}
+
+	tracer := newPathTracer(scene, options)
+	forward := camera.Target.minus(camera.Position).normalize(1.0)
+	samples := maxInt(1, options.PathTracing.Samples)
+
+	// What shows where paths leave the scene right away, like the rasterizer clears the image.
+	clear := color.RGBA{A: 255}
+	if options.ClearColor != nil {
+		clear = color.RGBAModel.Convert(options.ClearColor).(color.RGBA)
+	}
+	clearLinear := decodeSRGB(clear)
+	clearAlpha := float64(clear.A) / 255
+
+	zBuffer := make([]float64, width*height)
+	for i := range zBuffer {
+		zBuffer[i] = math.Inf(-1)
+	}
+
+	workers := options.Workers
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+
+	// Light of the paths through every pixel that hit something, and how many of them did, as
+	// colors come premultiplied by their coverage of the pixel. Pixels keep drawing random numbers
+	// from the same sequence from a pass to the next, taking the same samples whatever the passes.
+	type accumulator struct {
+		sum, hdrSum Vertex3
+		coverage    float64
+		hits        int
+		random      splitMix
+	}
+	pixels := make([]accumulator, width*height)
+	for i := range pixels {
+		// Seeded by pixel, so that images don't depend on how rows got split.
+		pixels[i].random = splitMix{state: uint64(i)}
+	}
+
+	passes := 1
+	if options.Progress != nil {
+		for taken := 1; taken < samples; taken *= 2 {
+			passes++
+		}
+	}
+
+	taken := 0
+	for pass := 1; pass <= passes; pass++ {
+		count := samples - taken
+		if pass < passes {
+			count = maxInt(taken, 1)
+		}
+
+		rows := make(chan int, height)
+		for y := 0; y < height; y++ {
+			rows <- y
+		}
+		close(rows)
+
+		var wg sync.WaitGroup
+		for w := 0; w < workers; w++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+
+				for y := range rows {
+					if ctx.Err() != nil {
+						return
+					}
+
+					for x := 0; x < width; x++ {
+						p := &pixels[y*width+x]
+
+						center := cameraRay(inverse, x, y, 0.5, 0.5, width, height)
+						if pass == 1 {
+							if hit, ok := tracer.bvh.Intersect(center); ok {
+								zBuffer[y*width+x] = camera.depth(center.At(hit.Distance).minus(camera.Position).dot(forward))
+							}
+						}
+
+						for s := 0; s < count; s++ {
+							r := center
+							if samples > 1 {
+								r = cameraRay(inverse, x, y, p.random.float(), p.random.float(), width, height)
+							}
+
+							c, ok := tracer.trace(r, &p.random)
+							if !ok {
+								p.sum = p.sum.plus(clearLinear.scale(clearAlpha))
+								p.hdrSum = p.hdrSum.plus(clearLinear)
+								p.coverage += clearAlpha
+								continue
+							}
+
+							p.sum = p.sum.plus(c)
+							p.hdrSum = p.hdrSum.plus(c)
+							p.coverage++
+							p.hits++
+						}
+
+						// Left cleared when nothing got hit, the clear color not being tone mapped.
+						if p.hits == 0 || p.coverage == 0 {
+							continue
+						}
+
+						n := float64(taken + count)
+						c := p.sum.scale(1 / p.coverage)
+						img.SetRGBA(x, y, toPremultipliedRGBA(linearToSRGB(c), p.coverage/n))
+						if hdr != nil {
+							hdr.set(x, y, p.hdrSum.scale(1/n))
+						}
+					}
+				}
+			}()
+		}
+		wg.Wait()
+
+		if ctx.Err() != nil {
+			break
+		}
+		taken += count
+
+		if options.Progress != nil {
+			options.Progress(Progress{Pass: pass, Passes: passes, Samples: taken, Image: flipImageVertically(rect, img)})
+		}
+	}
+
+	return zBuffer
+}
+
+// Ray from the camera through a point of a pixel, the offsets going from 0 to 1 across it. Rays
+// start on the near plane, leaving out what the rasterizer clips.
+func cameraRay(inverse Matrix4, x, y int, dx, dy float64, width, height int) Ray {
+	ndcX := (float64(x)+dx)/float64(width)*2 - 1
+	ndcY := (float64(y)+dy)/float64(height)*2 - 1
+
+	near := Vertex4{X: ndcX, Y: ndcY, Z: 1, W: 1}
+	far := Vertex4{X: ndcX, Y: ndcY, Z: -1, W: 1}
+	near.transform(inverse)
+	far.transform(inverse)
+
+	return Ray{Origin: near.lower(), Direction: far.lower().minus(near.lower()).normalize(1.0)}
+}
+
+// Light coming back along the ray. Not ok when the ray went right through the scene without
+// hitting anything, not even a skybox.
+func (t *pathTracer) trace(r Ray, random *splitMix) (Vertex3, bool) {
+	var radiance Vertex3
+	throughput := Vertex3{X: 1, Y: 1, Z: 1}
+
+	// Along the ray from the camera, through transparent surfaces, for fog.
+	travelled := 0.0
+
+	for bounce, layers := 0, 0; ; {
+		hit, ok := t.bvh.Intersect(r)
+		if !ok {
+			if bounce == 0 {
+				if t.skybox == nil {
+					return radiance, false
+				}
+				return t.skybox.sample(r.Direction), true
+			}
+			return radiance.plus(clampRadiance(throughput.multiply(t.background(r.Direction)), maxIndirectRadiance)), true
+		}
+
+		s := t.surface(r, hit)
+		if bounce == 0 {
+			travelled += hit.Distance
+		}
+
+		toEye := r.Direction.scale(-1)
+		alpha := t.opacity(hit.Face, s.uv)
+
+		// Rays go through transparent surfaces as often as they're transparent, which averages
+		// out over samples, and always through additive ones, picking up their light.
+		additive := s.material.Blend == AdditiveBlend
+		if additive || alpha < 1 && random.float() >= alpha {
+			if additive {
+				light := throughput.multiply(t.direct(s, toEye).plus(s.material.emission(s.uv))).scale(alpha)
+				if bounce == 0 && t.fog != nil {
+					light = light.scale(1 - t.fog.amount(travelled))
+				}
+				if bounce > 0 {
+					light = clampRadiance(light, maxIndirectRadiance)
+				}
+				radiance = radiance.plus(light)
+			}
+			layers++
+			if layers > maxTransparentLayers {
+				return radiance, true
+			}
+			r.Origin = s.position.minus(s.geometric.scale(t.epsilon))
+			continue
+		}
+
+		// Whatever light comes back from the first surface gets partly replaced by the fog's.
+		if bounce == 0 && t.fog != nil {
+			amount := t.fog.amount(travelled)
+			radiance = radiance.plus(throughput.multiply(t.fog.Color).scale(amount))
+			throughput = throughput.scale(1 - amount)
+		}
+
+		light := throughput.multiply(t.direct(s, toEye).plus(s.material.emission(s.uv)))
+		if bounce > 0 {
+			light = clampRadiance(light, maxIndirectRadiance)
+		}
+		radiance = radiance.plus(light)
+
+		if bounce == t.bounces {
+			return radiance, true
+		}
+
+		direction, weight, ok := t.scatter(s, toEye, random)
+		if !ok {
+			return radiance, true
+		}
+		throughput = throughput.multiply(weight)
+
+		if bounce >= rouletteBounces {
+			survival := math.Min(0.95, math.Max(throughput.X, math.Max(throughput.Y, throughput.Z)))
+			if random.float() >= survival {
+				return radiance, true
+			}
+			throughput = throughput.scale(1 / survival)
+		}
+
+		r = Ray{Origin: s.position.plus(s.geometric.scale(t.epsilon)), Direction: direction}
+		bounce++
+	}
+}
+
+func (t *pathTracer) surface(r Ray, hit Hit) surfacePoint {
+	face := &t.bvh.Faces[hit.Face]
+	material := t.materials[hit.Face]
+	w1, w2, w3 := 1-hit.U-hit.V, hit.U, hit.V
+
+	uv := Vertex2{
+		X: w1*face.Textures[0].X + w2*face.Textures[1].X + w3*face.Textures[2].X,
+		Y: w1*face.Textures[0].Y + w2*face.Textures[1].Y + w3*face.Textures[2].Y,
+	}
+
+	geometric := faceNormal(*face)
+	if geometric.dot(r.Direction) > 0 {
+		geometric = geometric.scale(-1)
+	}
+
+	normal := face.Normals[0].scale(w1).plus(face.Normals[1].scale(w2)).plus(face.Normals[2].scale(w3))
+	if normal.length() < 1e-12 {
+		normal = geometric
+	}
+	normal = normal.normalize(1.0)
+	if material.NormalMap != nil {
+		tangent := face.Tangents[0].scale(w1).plus(face.Tangents[1].scale(w2)).plus(face.Tangents[2].scale(w3))
+		normal = perturbNormal(material.NormalMap, uv, normal, tangent)
+	}
+
+	// Both sides of surfaces get lit, like with the rasterizer.
+	if normal.dot(geometric) < 0 {
+		normal = normal.scale(-1)
+	}
+
+	return surfacePoint{
+		position:  r.At(hit.Distance),
+		normal:    normal,
+		geometric: geometric,
+		uv:        uv,
+		material:  material,
+		albedo:    material.Diffuse.multiply(surfaceTexel(material, *face, uv, w1, w2, w3, t.vertexColors)),
+	}
+}
+
+// Light reaching the eye straight from the lights, through shadow rays towards them.
+func (t *pathTracer) direct(s surfacePoint, toEye Vertex3) Vertex3 {
+	var c Vertex3
+	var m microfacet
+	nDotV := math.Max(s.normal.dot(toEye), 1e-4)
+	if s.material.Model == MetallicRoughness {
+		metallic, roughness := s.material.metallicRoughness(s.uv)
+		m = newMicrofacet(s.albedo, metallic, roughness)
+	}
+
+	for _, light := range t.lights {
+		direction, amount := light.illuminate(s.position)
+		lambert := s.normal.dot(direction)
+		if lambert <= 0 || direction.dot(s.geometric) <= 0 {
+			continue
+		}
+
+		amount *= t.transmittance(s.position.plus(s.geometric.scale(t.epsilon)), direction, lightDistance(light, s.position))
+		if amount == 0 {
+			continue
+		}
+
+		if s.material.Model == MetallicRoughness {
+			c = c.plus(m.reflect(s.normal, toEye, direction, nDotV, amount))
+			continue
+		}
+
+		c = c.plus(s.albedo.scale(lambert * amount))
+		if s.material.Shininess > 0 {
+			halfway := direction.plus(toEye).normalize(1.0)
+			highlight := math.Pow(math.Max(0, s.normal.dot(halfway)), s.material.Shininess)
+			c = c.plus(s.material.Specular.scale(highlight * amount))
+		}
+	}
+
+	return c
+}
+
+// Direction the path bounces off in, picked at random following the material, and the weight of
+// the light coming back from there.
+func (t *pathTracer) scatter(s surfacePoint, toEye Vertex3, random *splitMix) (Vertex3, Vertex3, bool) {
+	var direction, weight Vertex3
+
+	if s.material.Model == MetallicRoughness {
+		metallic, roughness := s.material.metallicRoughness(s.uv)
+		m := newMicrofacet(s.albedo, metallic, roughness)
+		nDotV := math.Max(s.normal.dot(toEye), 1e-4)
+
+		// Metals only reflect, so their diffuse lobe doesn't need any samples.
+		specularChance := (1 + m.metallic) / 2
+		if random.float() < specularChance {
+			// GGX distribution of the halfway vector, the light reflecting around it.
+			u := random.float()
+			cosine := math.Sqrt((1 - u) / (1 + (m.a2-1)*u))
+			halfway := sampleHemisphere(s.normal, cosine, 2*math.Pi*random.float())
+			direction = halfway.scale(2 * toEye.dot(halfway)).minus(toEye)
+
+			nDotL := s.normal.dot(direction)
+			vDotH := toEye.dot(halfway)
+			if nDotL <= 0 || vDotH <= 0 {
+				return Vertex3{}, Vertex3{}, false
+			}
+
+			geometry := (nDotV / (nDotV*(1-m.k) + m.k)) * (nDotL / (nDotL*(1-m.k) + m.k))
+			weight = m.fresnel(vDotH).scale(geometry * vDotH / (cosine * nDotV) / specularChance)
+		} else {
+			direction = sampleCosine(s.normal, random)
+			kd := Vertex3{X: 1, Y: 1, Z: 1}.minus(m.fresnel(nDotV)).scale(1 - m.metallic)
+			weight = kd.multiply(s.albedo).scale(1 / (1 - specularChance))
+		}
+	} else {
+		// Lobes picked as often as they reflect, the specular one as a normalized Phong lobe
+		// around the mirror direction.
+		diffuse := luminance(s.albedo)
+		specular := 0.0
+		if s.material.Shininess > 0 {
+			specular = luminance(s.material.Specular)
+		}
+		if diffuse+specular <= 0 {
+			return Vertex3{}, Vertex3{}, false
+		}
+
+		specularChance := specular / (diffuse + specular)
+		if random.float() < specularChance {
+			mirror := s.normal.scale(2 * s.normal.dot(toEye)).minus(toEye)
+			cosine := math.Pow(random.float(), 1/(s.material.Shininess+1))
+			direction = sampleHemisphere(mirror, cosine, 2*math.Pi*random.float())
+			weight = s.material.Specular.scale(1 / specularChance)
+		} else {
+			direction = sampleCosine(s.normal, random)
+			weight = s.albedo.scale(1 / (1 - specularChance))
+		}
+	}
+
+	// Shading normals can send rays under the surface.
+	if direction.dot(s.geometric) <= 0 {
+		return Vertex3{}, Vertex3{}, false
+	}
+
+	return direction, weight, true
+}
+
+// Light coming from far away in a direction: the environment, else the skybox, else the flat
+// ambient light of the scene.
+func (t *pathTracer) background(direction Vertex3) Vertex3 {
+	switch {
+	case t.environment != nil:
+		return t.environment.Radiance.sample(equirectangularUV(direction)).scale(t.environment.Intensity)
+	case t.skybox != nil:
+		return t.skybox.sample(direction)
+	}
+
+	return t.ambient
+}
+
+// Scales the color down so that none of its components goes over the maximum, keeping its hue.
+func clampRadiance(c Vertex3, max float64) Vertex3 {
+	if m := math.Max(c.X, math.Max(c.Y, c.Z)); m > max {
+		return c.scale(max / m)
+	}
+	return c
+}
+
+// Random direction around the normal, more likely the closer to it, following Lambert's cosine law.
+func sampleCosine(normal Vertex3, random *splitMix) Vertex3 {
+	return sampleHemisphere(normal, math.Sqrt(1-random.float()), 2*math.Pi*random.float())
+}
+
+// Direction at an angle of the given cosine from the axis, rotated by phi radians around it.
+func sampleHemisphere(axis Vertex3, cosine, phi float64) Vertex3 {
+	// Orthonormal basis around the axis (Duff et al., "Building an Orthonormal Basis, Revisited").
+	sign := math.Copysign(1, axis.Z)
+	a := -1 / (sign + axis.Z)
+	b := axis.X * axis.Y * a
+	tangent := Vertex3{X: 1 + sign*axis.X*axis.X*a, Y: sign * b, Z: -sign * axis.X}
+	bitangent := Vertex3{X: b, Y: sign + axis.Y*axis.Y*a, Z: -axis.Y}
+
+	sine := math.Sqrt(math.Max(0, 1-cosine*cosine))
+	return tangent.scale(math.Cos(phi) * sine).plus(bitangent.scale(math.Sin(phi) * sine)).plus(axis.scale(cosine))
+}
+
+// SplitMix64, a tiny and fast pseudo-random generator, enough for picking samples.
+type splitMix struct {
+	state uint64
+}
+
+func (r *splitMix) next() uint64 {
+	r.state += 0x9e3779b97f4a7c15
+	z := r.state
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+	return z ^ (z >> 31)
+}
+
+// Uniformly between 0 and 1, 1 excluded.
+func (r *splitMix) float() float64 {
+	return float64(r.next()>>11) / (1 << 53)
+}
@@ -0,0 +1,89 @@
+package renderer
+
+import "math"
+
+// Cook–Torrance microfacet BRDF with the GGX distribution, Smith-Schlick geometry and Schlick
+// Fresnel terms, as used by glTF's metallic-roughness materials. Light intensities are taken as
+// already multiplied by π, so that a light of intensity 1 lights a white surface like it does
+// with Blinn-Phong.
+func (s shading) cookTorrance(material *Material, albedo Vertex3, metallic, roughness float64, normal, position Vertex3) Vertex3 {
+	toEye := s.eye.minus(position).normalize(1.0)
+	nDotV := math.Max(normal.dot(toEye), 1e-4)
+
+	m := newMicrofacet(albedo, metallic, roughness)
+
+	c := s.ambient.multiply(material.Ambient).multiply(albedo)
+	if s.environment != nil {
+		// The ambient color then acts as ambient occlusion.
+		c = material.Ambient.multiply(s.environment.lighting(albedo, m.f0, m.metallic, m.roughness, normal, toEye))
+	}
+
+	s.eachLight(position, func(i int, direction Vertex3, amount float64) {
+		amount *= s.visibility(i, position, normal)
+
+		c = c.plus(m.reflect(normal, toEye, direction, nDotV, amount))
+	})
+
+	return c
+}
+
+// Metallic-roughness surface, with the terms of the BRDF that don't depend on directions.
+type microfacet struct {
+	albedo Vertex3
+	// Reflectance head-on.
+	f0                  Vertex3
+	metallic, roughness float64
+	a2, k               float64
+}
+
+func newMicrofacet(albedo Vertex3, metallic, roughness float64) microfacet {
+	// Perfectly smooth surfaces would have infinitely small highlights.
+	roughness = math.Max(0.04, math.Min(1, roughness))
+	metallic = math.Max(0, math.Min(1, metallic))
+
+	a := roughness * roughness
+
+	return microfacet{
+		albedo: albedo,
+		// Dielectrics reflect about 4% of the light head-on, metals reflect it tinted by their color.
+		f0:        Vertex3{X: 0.04, Y: 0.04, Z: 0.04}.lerp(albedo, metallic),
+		metallic:  metallic,
+		roughness: roughness,
+		a2:        a * a,
+		k:         (roughness + 1) * (roughness + 1) / 8,
+	}
+}
+
+// Light reflected towards the eye out of the given amount coming from a direction.
+func (m microfacet) reflect(normal, toEye, direction Vertex3, nDotV, amount float64) Vertex3 {
+	nDotL := normal.dot(direction)
+	if nDotL <= 0 {
+		return Vertex3{}
+	}
+
+	halfway := direction.plus(toEye).normalize(1.0)
+	nDotH := math.Max(normal.dot(halfway), 0)
+	vDotH := math.Max(toEye.dot(halfway), 0)
+
+	// Normal distribution: how many microfacets line up with the halfway vector.
+	d := nDotH*nDotH*(m.a2-1) + 1
+	distribution := m.a2 / (math.Pi * d * d)
+
+	// Geometry: how many of them are neither shadowed nor masked.
+	geometry := (nDotV / (nDotV*(1-m.k) + m.k)) * (nDotL / (nDotL*(1-m.k) + m.k))
+
+	// Fresnel: how much gets reflected rather than refracted.
+	fresnel := m.fresnel(vDotH)
+
+	specular := fresnel.scale(distribution * geometry / (4 * nDotV * nDotL))
+
+	// Whatever isn't reflected gets diffused, except by metals which absorb it.
+	kd := Vertex3{X: 1, Y: 1, Z: 1}.minus(fresnel).scale(1 - m.metallic)
+	diffuse := kd.multiply(m.albedo)
+
+	return diffuse.plus(specular.scale(math.Pi)).scale(nDotL * amount)
+}
+
+func (m microfacet) fresnel(cosine float64) Vertex3 {
+	return m.f0.plus(Vertex3{X: 1, Y: 1, Z: 1}.minus(m.f0).scale(math.Pow(1-cosine, 5)))
+}
@@ -0,0 +1,84 @@
+package renderer
+
+import (
+	"image"
+	"image/color"
+)
+
+// What's seen through a point of the screen.
+type Pick struct {
+	Node *Node
+	// Index of the face in the faces of the node's mesh.
+	Face int
+	// Index of the instance of the node's mesh, 0 for nodes without instances.
+	Instance int
+	// Barycentric weights of the vertices of the face at the point hit, adding up to 1.
+	Weights Vertex3
+	// Point hit in world space, and its distance from the camera.
+	Position Vertex3
+	Distance float64
+}
+
+// Finds what's under the mouse, for selecting objects, by casting rays against the scene as it was
+// when the picker got created.
+type Picker struct {
+	bvh     *BVH
+	sources []faceSource
+}
+
+func NewPicker(scene *Scene) *Picker {
+	faces, sources := scene.worldFaces()
+	return &Picker{bvh: NewBVH(faces), sources: sources}
+}
+
+// The closest surface seen through a point of the viewport, in window coordinates with Y going
+// down, like the input of windows reports them.
+func (p *Picker) Pick(camera Camera, viewport image.Rectangle, point image.Point) (Pick, bool) {
+	width, height := viewport.Dx(), viewport.Dy()
+	if width <= 0 || height <= 0 {
+		return Pick{}, false
+	}
+
+	aspect := float64(width) / float64(height)
+	inverse, ok := camera.projectionMatrix(aspect).Multiply(camera.viewMatrix()).Inverse()
+	if !ok {
+		return Pick{}, false
+	}
+
+	x, y := point.X-viewport.Min.X, point.Y-viewport.Min.Y
+	r := cameraRay(inverse, x, height-1-y, 0.5, 0.5, width, height)
+
+	hit, ok := p.bvh.Intersect(r)
+	if !ok {
+		return Pick{}, false
+	}
+
+	source := p.sources[p.bvh.order[hit.Face]]
+	return Pick{
+		Node:     source.node,
+		Face:     source.face,
+		Instance: source.instance,
+		Weights:  Vertex3{X: 1 - hit.U - hit.V, Y: hit.U, Z: hit.V},
+		Position: r.At(hit.Distance),
+		Distance: hit.Distance,
+	}, true
+}
+
+// Outlines the edges of the picked node that aren't hidden, and the picked face brighter.
+func drawSelection(img *image.RGBA, zBuffer []float64, scene *Scene, camera Camera, pick Pick, options Options) {
+	scene.walkInstances(func(node *Node, mesh *Obj, world Matrix4, instance int) {
+		if node != pick.Node || instance != pick.Instance {
+			return
+		}
+
+		o := options
+		o.BackfaceCulling = true
+		triangles := projectTriangles(mesh, node.Material, world, camera, img.Bounds(), o, nil)
+		drawWireframe(img, triangles, zBuffer, color.RGBA{R: 255, G: 160, B: 0, A: 255})
+
+		face := &Obj{Faces: []Face{mesh.Faces[pick.Face]}}
+		face.Bounds = face.aabb()
+		o.BackfaceCulling = false
+		drawWireframe(img, projectTriangles(face, node.Material, world, camera, img.Bounds(), o, nil), zBuffer, color.RGBA{R: 255, G: 255, B: 255, A: 255})
+	})
+}
@@ -0,0 +1,330 @@
+package renderer
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+type plyProperty struct {
+	name string
+	kind string
+	// Lists are prefixed by their length, of countKind.
+	list      bool
+	countKind string
+}
+
+type plyElement struct {
+	name       string
+	count      int
+	properties []plyProperty
+}
+
+// Reads values one by one from the body of a PLY file, whatever its format.
+type plyReader interface {
+	read(kind string) (float64, error)
+}
+
+// Stanford PLY meshes, ASCII or binary, with optional normals, texture coordinates and vertex colors.
+// Polygons get split into triangles.
+func loadPlyFromFile(ctx context.Context, filename string) (*Obj, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(contextReader{ctx, file})
+
+	line, err := reader.ReadString('\n')
+	if err != nil || strings.TrimSpace(line) != "ply" {
+		return nil, errors.New(fmt.Sprintf("%s is not a PLY file", filename))
+	}
+
+	var format string
+	var elements []*plyElement
+
+	for lineNumber := 2; ; lineNumber++ {
+		line, err = reader.ReadString('\n')
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("truncated header in %s", filename))
+		}
+
+		parts := strings.Fields(line)
+		if len(parts) == 0 {
+			continue
+		}
+		if parts[0] == "end_header" {
+			break
+		}
+
+		switch parts[0] {
+		case "format":
+			if len(parts) < 2 {
+				return nil, errors.New(fmt.Sprintf("missing format on line %d of %s", lineNumber, filename))
+			}
+			format = parts[1]
+
+		case "element":
+			if len(parts) < 3 {
+				return nil, errors.New(fmt.Sprintf("invalid element on line %d of %s", lineNumber, filename))
+			}
+			count, err := strconv.Atoi(parts[2])
+			if err != nil || count < 0 {
+				return nil, errors.New(fmt.Sprintf("invalid element count on line %d of %s", lineNumber, filename))
+			}
+			elements = append(elements, &plyElement{name: parts[1], count: count})
+
+		case "property":
+			if len(elements) == 0 {
+				return nil, errors.New(fmt.Sprintf("property outside of an element on line %d of %s", lineNumber, filename))
+			}
+			element := elements[len(elements)-1]
+
+			if len(parts) == 5 && parts[1] == "list" {
+				element.properties = append(element.properties, plyProperty{name: parts[4], kind: parts[3], list: true, countKind: parts[2]})
+			} else if len(parts) == 3 {
+				element.properties = append(element.properties, plyProperty{name: parts[2], kind: parts[1]})
+			} else {
+				return nil, errors.New(fmt.Sprintf("invalid property on line %d of %s", lineNumber, filename))
+			}
+		}
+	}
+
+	var values plyReader
+	switch format {
+	case "ascii":
+		scanner := bufio.NewScanner(reader)
+		scanner.Split(bufio.ScanWords)
+		values = plyASCIIReader{scanner}
+	case "binary_little_endian":
+		values = plyBinaryReader{reader, binary.LittleEndian}
+	case "binary_big_endian":
+		values = plyBinaryReader{reader, binary.BigEndian}
+	default:
+		return nil, errors.New(fmt.Sprintf("unsupported format %q in %s", format, filename))
+	}
+
+	obj := Obj{Materials: map[string]*Material{}, Files: []string{filename}}
+	var polygons [][]int
+	hasNormals, hasTextures := false, false
+
+	for _, element := range elements {
+		for i := 0; i < element.count; i++ {
+			vertex := Vertex3{}
+			normal := Vertex3{}
+			texture := Vertex2{}
+			color := Vertex3{X: 1, Y: 1, Z: 1}
+			var indices []int
+
+			for _, property := range element.properties {
+				if property.list {
+					count, err := values.read(property.countKind)
+					if err != nil {
+						return nil, errors.New(fmt.Sprintf("truncated %s %d in %s", element.name, i, filename))
+					}
+					list := make([]int, int(count))
+					for k := range list {
+						value, err := values.read(property.kind)
+						if err != nil {
+							return nil, errors.New(fmt.Sprintf("truncated %s %d in %s", element.name, i, filename))
+						}
+						list[k] = int(value)
+					}
+					if property.name == "vertex_indices" || property.name == "vertex_index" {
+						indices = list
+					}
+					continue
+				}
+
+				value, err := values.read(property.kind)
+				if err != nil {
+					return nil, errors.New(fmt.Sprintf("truncated %s %d in %s", element.name, i, filename))
+				}
+
+				switch property.name {
+				case "x":
+					vertex.X = value
+				case "y":
+					vertex.Y = value
+				case "z":
+					vertex.Z = value
+				case "nx":
+					normal.X, hasNormals = value, true
+				case "ny":
+					normal.Y = value
+				case "nz":
+					normal.Z = value
+				case "u", "s", "texture_u":
+					texture.X, hasTextures = value, true
+				case "v", "t", "texture_v":
+					texture.Y = value
+				case "red", "green", "blue":
+					// Integer channels go up to 255, floating point ones up to 1.
+					if property.kind != "float" && property.kind != "float32" && property.kind != "double" && property.kind != "float64" {
+						value /= 255
+					}
+					switch property.name {
+					case "red":
+						color.X = value
+					case "green":
+						color.Y = value
+					case "blue":
+						color.Z = value
+					}
+					obj.colored = true
+				}
+			}
+
+			switch element.name {
+			case "vertex":
+				obj.vertices = append(obj.vertices, vertex)
+				obj.normals = append(obj.normals, normal)
+				obj.textures = append(obj.textures, texture)
+				obj.colors = append(obj.colors, color)
+			case "face":
+				polygons = append(polygons, indices)
+			}
+		}
+	}
+
+	if !hasNormals {
+		obj.normals = smoothNormals(obj.vertices, polygons)
+	}
+
+	for k, polygon := range polygons {
+		for _, index := range polygon {
+			if index < 0 || index >= len(obj.vertices) {
+				return nil, errors.New(fmt.Sprintf("unable to resolve vertex index %d used by face %d in %s", index, k, filename))
+			}
+		}
+
+		// Fan triangulation, polygons being convex.
+		for i := 1; i+1 < len(polygon); i++ {
+			ids := [3]int{polygon[0], polygon[i], polygon[i+1]}
+
+			var face Face
+			for j, id := range ids {
+				face.Vertices[j] = obj.vertices[id]
+				face.Normals[j] = obj.normals[id]
+				face.Textures[j] = obj.textures[id]
+				face.Colors[j] = obj.colors[id]
+			}
+			face.Colored = obj.colored
+
+			obj.Faces = append(obj.Faces, face)
+			obj.faceVertexIds = append(obj.faceVertexIds, [3]int{ids[0] + 1, ids[1] + 1, ids[2] + 1})
+		}
+	}
+
+	if hasTextures {
+		obj.generateTangents()
+	}
+	obj.Bounds = obj.aabb()
+
+	// Cleanup
+	obj.vertices = []Vertex3{}
+	obj.normals = []Vertex3{}
+	obj.textures = []Vertex2{}
+	obj.colors = nil
+	obj.faceVertexIds = nil
+
+	return &obj, nil
+}
+
+// Normals of the vertices, as the area-weighted average of the normals of the polygons around them.
+func smoothNormals(vertices []Vertex3, polygons [][]int) []Vertex3 {
+	normals := make([]Vertex3, len(vertices))
+
+	for _, polygon := range polygons {
+		for i := 1; i+1 < len(polygon); i++ {
+			a, b, c := polygon[0], polygon[i], polygon[i+1]
+			if a < 0 || b < 0 || c < 0 || a >= len(vertices) || b >= len(vertices) || c >= len(vertices) {
+				continue
+			}
+
+			// Length is twice the area of the triangle.
+			n := vertices[b].minus(vertices[a]).cross(vertices[c].minus(vertices[a]))
+			normals[a] = normals[a].plus(n)
+			normals[b] = normals[b].plus(n)
+			normals[c] = normals[c].plus(n)
+		}
+	}
+
+	for i, n := range normals {
+		if n.length() > 0 {
+			normals[i] = n.normalize(1.0)
+		}
+	}
+
+	return normals
+}
+
+type plyASCIIReader struct {
+	scanner *bufio.Scanner
+}
+
+func (r plyASCIIReader) read(kind string) (float64, error) {
+	if !r.scanner.Scan() {
+		if err := r.scanner.Err(); err != nil {
+			return 0, err
+		}
+		return 0, io.ErrUnexpectedEOF
+	}
+
+	return strconv.ParseFloat(r.scanner.Text(), 64)
+}
+
+type plyBinaryReader struct {
+	reader *bufio.Reader
+	order  binary.ByteOrder
+}
+
+func (r plyBinaryReader) read(kind string) (float64, error) {
+	var buffer [8]byte
+
+	size := 0
+	switch kind {
+	case "char", "int8", "uchar", "uint8":
+		size = 1
+	case "short", "int16", "ushort", "uint16":
+		size = 2
+	case "int", "int32", "uint", "uint32", "float", "float32":
+		size = 4
+	case "double", "float64":
+		size = 8
+	default:
+		return 0, errors.New(fmt.Sprintf("unknown property type %q", kind))
+	}
+
+	if _, err := io.ReadFull(r.reader, buffer[:size]); err != nil {
+		return 0, err
+	}
+	b := buffer[:size]
+
+	switch kind {
+	case "char", "int8":
+		return float64(int8(b[0])), nil
+	case "uchar", "uint8":
+		return float64(b[0]), nil
+	case "short", "int16":
+		return float64(int16(r.order.Uint16(b))), nil
+	case "ushort", "uint16":
+		return float64(r.order.Uint16(b)), nil
+	case "int", "int32":
+		return float64(int32(r.order.Uint32(b))), nil
+	case "uint", "uint32":
+		return float64(r.order.Uint32(b)), nil
+	case "float", "float32":
+		return float64(math.Float32frombits(r.order.Uint32(b))), nil
+	}
+
+	return math.Float64frombits(r.order.Uint64(b)), nil
+}
@@ -0,0 +1,114 @@
+package renderer
+
+import "image"
+
+// The finished frame, at its final resolution, handed to post effects.
+type Frame struct {
+	// Bottom row first, like while rendering.
+	Image *image.RGBA
+	// Depth of every pixel, growing toward the camera, nil without depth testing.
+	Depth  []float64
+	Camera Camera
+
+	// Linear colors the image got encoded from, premultiplied by its alpha, when effects need
+	// them. Effects changing them bring the image up to date.
+	hdr *hdrImage
+	// Whether the image got tone mapped already, effects working on linear colors going back to
+	// the image from then on.
+	toneMapped bool
+}
+
+// A pass over the finished frame, like bloom or depth of field. Effects work in place.
+type PostEffect interface {
+	Apply(frame *Frame)
+}
+
+// Anti-aliasing as a post effect, to run it at some point of a chain. Same as AntiAliasing
+// set to FXAA, which runs it last.
+type FXAAEffect struct{}
+
+func (FXAAEffect) Apply(frame *Frame) {
+	fxaa(frame.Image)
+}
+
+// Effects working on the linear colors of the frame rather than on its image, before tone
+// mapping.
+func linearEffect(effect PostEffect) bool {
+	switch effect.(type) {
+	case *BloomEffect, *ToneMapEffect:
+		return true
+	}
+	return false
+}
+
+// The post effects of the options, in order. Tone mapping set in the options goes before the
+// first effect working on the image, unless the effects tone map already.
+func postEffects(options Options) []PostEffect {
+	effects := options.PostEffects
+
+	toneMapping := options.ToneMapping != NoToneMapping || options.Exposure != 0
+	for _, effect := range effects {
+		if _, ok := effect.(*ToneMapEffect); ok {
+			toneMapping = false
+		}
+	}
+	if toneMapping && (options.View == ViewLit || options.View == ViewTextured) {
+		i := 0
+		for i < len(effects) && linearEffect(effects[i]) {
+			i++
+		}
+		toneMap := &ToneMapEffect{Operator: options.ToneMapping, Exposure: options.Exposure}
+		effects = append(append(append([]PostEffect{}, effects[:i]...), toneMap), effects[i:]...)
+	}
+
+	if options.AntiAliasing == FXAA {
+		effects = append(effects[:len(effects):len(effects)], FXAAEffect{})
+	}
+	return effects
+}
+
+// Whether the frame needs its linear colors kept for the post effects.
+func needsLinear(options Options) bool {
+	if options.View != ViewLit && options.View != ViewTextured {
+		return false
+	}
+	for _, effect := range postEffects(options) {
+		if linearEffect(effect) {
+			return true
+		}
+	}
+	return false
+}
+
+// Runs the post effects of the options one after another. Linear colors, when given, are the ones
+// of the image, which effects working on them get until another effect changes the image.
+func postProcess(img *image.RGBA, hdr *hdrImage, zBuffer []float64, camera Camera, options Options) {
+	frame := &Frame{Image: img, Depth: zBuffer, Camera: camera}
+	if options.View == ViewLit || options.View == ViewTextured {
+		frame.hdr = hdr
+	}
+
+	for _, effect := range postEffects(options) {
+		effect.Apply(frame)
+		if !linearEffect(effect) {
+			frame.hdr = nil
+		}
+	}
+}
+
+// Encodes the linear colors into the image again, tone mapped, keeping its alpha.
+func (f *Frame) develop(t toneMapper) {
+	rect := f.Image.Bounds()
+	for y := 0; y < f.hdr.height; y++ {
+		for x := 0; x < f.hdr.width; x++ {
+			c := f.Image.RGBAAt(rect.Min.X+x, rect.Min.Y+y)
+			if c.A == 0 {
+				continue
+			}
+
+			alpha := float64(c.A) / 255
+			linear := f.hdr.at(x, y).scale(1 / alpha)
+			f.Image.SetRGBA(rect.Min.X+x, rect.Min.Y+y, toPremultipliedRGBA(linearToSRGB(t.apply(linear)), alpha))
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package renderer
+
+import (
+	"bufio"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+)
+
+// Binary PPM, about the simplest image format there is: a text header and RGB bytes, alpha left out.
+func encodePPM(w io.Writer, img image.Image) error {
+	rect := img.Bounds()
+	out := bufio.NewWriter(w)
+	fmt.Fprintf(out, "P6\n%d %d\n255\n", rect.Dx(), rect.Dy())
+
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+			out.Write([]byte{c.R, c.G, c.B})
+		}
+	}
+
+	return out.Flush()
+}
+
+// PAM, the extension of PPM with an alpha channel. Not premultiplied.
+func encodePAM(w io.Writer, img image.Image) error {
+	rect := img.Bounds()
+	out := bufio.NewWriter(w)
+	fmt.Fprintf(out, "P7\nWIDTH %d\nHEIGHT %d\nDEPTH 4\nMAXVAL 255\nTUPLTYPE RGB_ALPHA\nENDHDR\n", rect.Dx(), rect.Dy())
+
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+			out.Write([]byte{c.R, c.G, c.B, c.A})
+		}
+	}
+
+	return out.Flush()
+}
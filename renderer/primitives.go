@@ -0,0 +1,256 @@
+package renderer
+
+import "math"
+
+// Built-in models, usable from scene files and the command line by prefixing their name with @.
+// All of them fit in a unit cube centered on the origin.
+var primitives = map[string]func() *Obj{
+	"plane":     NewPlane,
+	"cube":      NewCube,
+	"sphere":    func() *Obj { return NewSphere(32, 16) },
+	"icosphere": func() *Obj { return NewIcosphere(3) },
+	"cylinder":  func() *Obj { return NewCylinder(32) },
+	"cone":      func() *Obj { return NewCone(32) },
+	"torus":     func() *Obj { return NewTorus(0.35, 0.15, 48, 24) },
+}
+
+// Puts meshes together vertex by vertex, faces sharing them for smooth tangents.
+type meshBuilder struct {
+	obj *Obj
+}
+
+func newMeshBuilder() *meshBuilder {
+	return &meshBuilder{obj: &Obj{Materials: map[string]*Material{}}}
+}
+
+func (b *meshBuilder) vertex(position, normal Vertex3, uv Vertex2) int {
+	b.obj.vertices = append(b.obj.vertices, position)
+	b.obj.normals = append(b.obj.normals, normal)
+	b.obj.textures = append(b.obj.textures, uv)
+	return len(b.obj.vertices) - 1
+}
+
+// Turned to face the way the normals of its vertices go, degenerate ones being left out.
+func (b *meshBuilder) triangle(ids ...int) {
+	obj := b.obj
+	face := Face{}
+	for j, id := range ids {
+		face.Vertices[j] = obj.vertices[id]
+		face.Normals[j] = obj.normals[id]
+		face.Textures[j] = obj.textures[id]
+	}
+
+	n := face.Vertices[1].minus(face.Vertices[0]).cross(face.Vertices[2].minus(face.Vertices[0]))
+	if n.length() < 1e-12 {
+		return
+	}
+	if n.dot(face.Normals[0].plus(face.Normals[1]).plus(face.Normals[2])) < 0 {
+		ids[1], ids[2] = ids[2], ids[1]
+		face.Vertices[1], face.Vertices[2] = face.Vertices[2], face.Vertices[1]
+		face.Normals[1], face.Normals[2] = face.Normals[2], face.Normals[1]
+		face.Textures[1], face.Textures[2] = face.Textures[2], face.Textures[1]
+	}
+
+	obj.Faces = append(obj.Faces, face)
+	obj.faceVertexIds = append(obj.faceVertexIds, [3]int{ids[0] + 1, ids[1] + 1, ids[2] + 1})
+}
+
+// Surface over a grid of parameters between 0 and 1, which are also its texture coordinates,
+// u going around segments times and v along rings times.
+func (b *meshBuilder) grid(segments, rings int, surface func(u, v float64) (Vertex3, Vertex3)) {
+	start := len(b.obj.vertices)
+	for j := 0; j <= rings; j++ {
+		for i := 0; i <= segments; i++ {
+			u, v := float64(i)/float64(segments), float64(j)/float64(rings)
+			position, normal := surface(u, v)
+			b.vertex(position, normal, Vertex2{X: u, Y: v})
+		}
+	}
+
+	for j := 0; j < rings; j++ {
+		for i := 0; i < segments; i++ {
+			a := start + j*(segments+1) + i
+			c := a + segments + 1
+			b.triangle(a, a+1, c+1)
+			b.triangle(a, c+1, c)
+		}
+	}
+}
+
+// Flat disk of radius r at height y, facing up or down.
+func (b *meshBuilder) disk(segments int, r, y float64, up bool) {
+	normal := Vertex3{Y: -1}
+	if up {
+		normal = Vertex3{Y: 1}
+	}
+
+	center := b.vertex(Vertex3{Y: y}, normal, Vertex2{X: 0.5, Y: 0.5})
+	start := len(b.obj.vertices)
+	for i := 0; i <= segments; i++ {
+		angle := 2 * math.Pi * float64(i) / float64(segments)
+		x, z := math.Cos(angle), math.Sin(angle)
+		b.vertex(Vertex3{X: r * x, Y: y, Z: r * z}, normal, Vertex2{X: 0.5 + x/2, Y: 0.5 + z/2})
+	}
+	for i := 0; i < segments; i++ {
+		b.triangle(center, start+i, start+i+1)
+	}
+}
+
+func (b *meshBuilder) build() *Obj {
+	obj := b.obj
+	obj.generateTangents()
+	obj.Bounds = obj.aabb()
+
+	obj.vertices = nil
+	obj.normals = nil
+	obj.textures = nil
+	obj.faceVertexIds = nil
+
+	return obj
+}
+
+// Unit square on the XZ plane, facing up.
+func NewPlane() *Obj {
+	b := newMeshBuilder()
+	b.grid(1, 1, func(u, v float64) (Vertex3, Vertex3) {
+		return Vertex3{X: u - 0.5, Z: 0.5 - v}, Vertex3{Y: 1}
+	})
+	return b.build()
+}
+
+// Unit cube, every side having the whole texture.
+func NewCube() *Obj {
+	b := newMeshBuilder()
+	for _, axis := range []Vertex3{{X: 1}, {X: -1}, {Y: 1}, {Y: -1}, {Z: 1}, {Z: -1}} {
+		// Two directions across the side, making it face outwards.
+		up := Vertex3{Y: 1}
+		if axis.Y != 0 {
+			up = Vertex3{Z: -axis.Y}
+		}
+		right := up.cross(axis)
+
+		b.grid(1, 1, func(u, v float64) (Vertex3, Vertex3) {
+			p := axis.scale(0.5).plus(right.scale(u - 0.5)).plus(up.scale(v - 0.5))
+			return p, axis
+		})
+	}
+	return b.build()
+}
+
+// UV sphere of diameter 1, with segments around and rings from pole to pole.
+func NewSphere(segments, rings int) *Obj {
+	b := newMeshBuilder()
+	b.grid(segments, rings, func(u, v float64) (Vertex3, Vertex3) {
+		theta, phi := math.Pi*(1-v), -2*math.Pi*u
+		n := Vertex3{X: math.Sin(theta) * math.Cos(phi), Y: math.Cos(theta), Z: math.Sin(theta) * math.Sin(phi)}
+		return n.scale(0.5), n
+	})
+	return b.build()
+}
+
+// Sphere of diameter 1 made of nearly equal triangles, subdividing an icosahedron, each level
+// having four times as many faces as the previous one.
+func NewIcosphere(subdivisions int) *Obj {
+	t := (1 + math.Sqrt(5)) / 2
+	corners := []Vertex3{
+		{X: -1, Y: t}, {X: 1, Y: t}, {X: -1, Y: -t}, {X: 1, Y: -t},
+		{Y: -1, Z: t}, {Y: 1, Z: t}, {Y: -1, Z: -t}, {Y: 1, Z: -t},
+		{X: t, Z: -1}, {X: t, Z: 1}, {X: -t, Z: -1}, {X: -t, Z: 1},
+	}
+	triangles := [][3]Vertex3{}
+	for _, f := range [][3]int{
+		{0, 11, 5}, {0, 5, 1}, {0, 1, 7}, {0, 7, 10}, {0, 10, 11},
+		{1, 5, 9}, {5, 11, 4}, {11, 10, 2}, {10, 7, 6}, {7, 1, 8},
+		{3, 9, 4}, {3, 4, 2}, {3, 2, 6}, {3, 6, 8}, {3, 8, 9},
+		{4, 9, 5}, {2, 4, 11}, {6, 2, 10}, {8, 6, 7}, {9, 8, 1},
+	} {
+		triangles = append(triangles, [3]Vertex3{corners[f[0]].normalize(1.0), corners[f[1]].normalize(1.0), corners[f[2]].normalize(1.0)})
+	}
+
+	for i := 0; i < subdivisions; i++ {
+		var subdivided [][3]Vertex3
+		for _, t := range triangles {
+			ab := t[0].plus(t[1]).normalize(1.0)
+			bc := t[1].plus(t[2]).normalize(1.0)
+			ca := t[2].plus(t[0]).normalize(1.0)
+			subdivided = append(subdivided, [3]Vertex3{t[0], ab, ca}, [3]Vertex3{ab, t[1], bc}, [3]Vertex3{ca, bc, t[2]}, [3]Vertex3{ab, bc, ca})
+		}
+		triangles = subdivided
+	}
+
+	// Texture coordinates wrap around like those of UV spheres, triangles across the seam getting
+	// their own vertices on the other side.
+	type vertex struct {
+		position Vertex3
+		uv       Vertex2
+	}
+
+	b := newMeshBuilder()
+	ids := map[vertex]int{}
+	for _, t := range triangles {
+		var uvs [3]Vertex2
+		maxU := 0.0
+		for j, n := range t {
+			uvs[j] = Vertex2{X: math.Mod(1-math.Atan2(n.Z, n.X)/(2*math.Pi), 1), Y: 0.5 + math.Asin(n.Y)/math.Pi}
+			maxU = math.Max(maxU, uvs[j].X)
+		}
+		for j := range uvs {
+			if maxU-uvs[j].X > 0.5 {
+				uvs[j].X++
+			}
+		}
+
+		var face [3]int
+		for j, n := range t {
+			key := vertex{n, uvs[j]}
+			id, ok := ids[key]
+			if !ok {
+				id = b.vertex(n.scale(0.5), n, uvs[j])
+				ids[key] = id
+			}
+			face[j] = id
+		}
+		b.triangle(face[:]...)
+	}
+	return b.build()
+}
+
+// Cylinder of diameter 1 and height 1 standing along Y, closed on both ends.
+func NewCylinder(segments int) *Obj {
+	b := newMeshBuilder()
+	b.grid(segments, 1, func(u, v float64) (Vertex3, Vertex3) {
+		angle := -2 * math.Pi * u
+		n := Vertex3{X: math.Cos(angle), Z: math.Sin(angle)}
+		return Vertex3{X: n.X / 2, Y: v - 0.5, Z: n.Z / 2}, n
+	})
+	b.disk(segments, 0.5, 0.5, true)
+	b.disk(segments, 0.5, -0.5, false)
+	return b.build()
+}
+
+// Cone of diameter 1 and height 1 standing along Y, its base closed.
+func NewCone(segments int) *Obj {
+	b := newMeshBuilder()
+	b.grid(segments, 1, func(u, v float64) (Vertex3, Vertex3) {
+		angle := -2 * math.Pi * u
+		x, z := math.Cos(angle), math.Sin(angle)
+		// Leaning up by the slope of the side, half as wide as high.
+		n := Vertex3{X: x, Y: 0.5, Z: z}.normalize(1.0)
+		r := (1 - v) / 2
+		return Vertex3{X: r * x, Y: v - 0.5, Z: r * z}, n
+	})
+	b.disk(segments, 0.5, -0.5, false)
+	return b.build()
+}
+
+// Torus lying on the XZ plane, radius being from its center to the middle of the tube.
+func NewTorus(radius, tube float64, segments, sides int) *Obj {
+	b := newMeshBuilder()
+	b.grid(segments, sides, func(u, v float64) (Vertex3, Vertex3) {
+		around, across := -2*math.Pi*u, 2*math.Pi*v
+		direction := Vertex3{X: math.Cos(around), Z: math.Sin(around)}
+		n := direction.scale(math.Cos(across)).plus(Vertex3{Y: math.Sin(across)})
+		return direction.scale(radius).plus(n.scale(tube)), n
+	})
+	return b.build()
+}
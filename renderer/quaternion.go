@@ -0,0 +1,115 @@
+package renderer
+
+import "math"
+
+// Rotation as a unit quaternion, interpolating smoothly where Euler angles would wobble or lock.
+type Quaternion struct {
+	X, Y, Z, W float64
+}
+
+func IdentityQuaternion() Quaternion {
+	return Quaternion{W: 1}
+}
+
+// Rotation of angle radians around an axis.
+func NewQuaternion(axis Vertex3, angle float64) Quaternion {
+	a := axis.normalize(1.0)
+	s := math.Sin(angle / 2)
+	return Quaternion{X: a.X * s, Y: a.Y * s, Z: a.Z * s, W: math.Cos(angle / 2)}
+}
+
+func (q Quaternion) dot(o Quaternion) float64 {
+	return q.X*o.X + q.Y*o.Y + q.Z*o.Z + q.W*o.W
+}
+
+// Unit length, the identity when q has none.
+func (q Quaternion) normalize() Quaternion {
+	length := math.Sqrt(q.dot(q))
+	if length < 1e-12 {
+		return IdentityQuaternion()
+	}
+	return Quaternion{X: q.X / length, Y: q.Y / length, Z: q.Z / length, W: q.W / length}
+}
+
+// Spherical linear interpolation, turning at a constant speed along the shortest way around.
+func (q Quaternion) slerp(o Quaternion, t float64) Quaternion {
+	cos := q.dot(o)
+	// q and -q are the same rotation, the other way around being the longest.
+	if cos < 0 {
+		o, cos = Quaternion{X: -o.X, Y: -o.Y, Z: -o.Z, W: -o.W}, -cos
+	}
+
+	// Nearly the same rotation, where the sine below gets too small to divide by.
+	a, b := 1-t, t
+	if cos < 0.9995 {
+		angle := math.Acos(cos)
+		sin := math.Sin(angle)
+		a, b = math.Sin((1-t)*angle)/sin, math.Sin(t*angle)/sin
+	}
+
+	return Quaternion{
+		X: q.X*a + o.X*b,
+		Y: q.Y*a + o.Y*b,
+		Z: q.Z*a + o.Z*b,
+		W: q.W*a + o.W*b,
+	}.normalize()
+}
+
+func (q Quaternion) matrix() Matrix4 {
+	x, y, z, w := q.X, q.Y, q.Z, q.W
+	return Matrix4{
+		1 - 2*(y*y+z*z), 2 * (x*y - z*w), 2 * (x*z + y*w), 0,
+		2 * (x*y + z*w), 1 - 2*(x*x+z*z), 2 * (y*z - x*w), 0,
+		2 * (x*z - y*w), 2 * (y*z + x*w), 1 - 2*(x*x+y*y), 0,
+		0, 0, 0, 1,
+	}
+}
+
+// Rotation of a matrix without scaling, after Shoemake.
+func quaternionFromMatrix(m Matrix4) Quaternion {
+	var q Quaternion
+	switch trace := m.m11 + m.m22 + m.m33; {
+	case trace > 0:
+		s := 0.5 / math.Sqrt(trace+1)
+		q = Quaternion{X: (m.m32 - m.m23) * s, Y: (m.m13 - m.m31) * s, Z: (m.m21 - m.m12) * s, W: 0.25 / s}
+	case m.m11 > m.m22 && m.m11 > m.m33:
+		s := 2 * math.Sqrt(1+m.m11-m.m22-m.m33)
+		q = Quaternion{X: 0.25 * s, Y: (m.m12 + m.m21) / s, Z: (m.m13 + m.m31) / s, W: (m.m32 - m.m23) / s}
+	case m.m22 > m.m33:
+		s := 2 * math.Sqrt(1+m.m22-m.m11-m.m33)
+		q = Quaternion{X: (m.m12 + m.m21) / s, Y: 0.25 * s, Z: (m.m23 + m.m32) / s, W: (m.m13 - m.m31) / s}
+	default:
+		s := 2 * math.Sqrt(1+m.m33-m.m11-m.m22)
+		q = Quaternion{X: (m.m13 + m.m31) / s, Y: (m.m23 + m.m32) / s, Z: 0.25 * s, W: (m.m21 - m.m12) / s}
+	}
+	return q.normalize()
+}
+
+// Translation, then rotation, then scale, like glTF nodes.
+func composeTransform(translation Vertex3, rotation Quaternion, scale Vertex3) Matrix4 {
+	return TranslationMatrix(translation).Multiply(rotation.matrix()).Multiply(ScaleMatrix(scale))
+}
+
+// Inverse of composeTransform, for transforms without shearing.
+func decomposeTransform(m Matrix4) (Vertex3, Quaternion, Vertex3) {
+	translation := Vertex3{X: m.m14, Y: m.m24, Z: m.m34}
+	scale := Vertex3{
+		X: Vertex3{X: m.m11, Y: m.m21, Z: m.m31}.length(),
+		Y: Vertex3{X: m.m12, Y: m.m22, Z: m.m32}.length(),
+		Z: Vertex3{X: m.m13, Y: m.m23, Z: m.m33}.length(),
+	}
+
+	// Mirroring shows up as a negative determinant, taken as a negative scale along X.
+	determinant := m.m11*(m.m22*m.m33-m.m23*m.m32) - m.m12*(m.m21*m.m33-m.m23*m.m31) + m.m13*(m.m21*m.m32-m.m22*m.m31)
+	if determinant < 0 {
+		scale.X = -scale.X
+	}
+
+	rotation := IdentityQuaternion()
+	if scale.X != 0 && scale.Y != 0 && scale.Z != 0 {
+		r := m.Multiply(ScaleMatrix(Vertex3{X: 1 / scale.X, Y: 1 / scale.Y, Z: 1 / scale.Z}))
+		rotation = quaternionFromMatrix(r)
+	}
+
+	return translation, rotation, scale
+}
@@ -0,0 +1,131 @@
+package renderer
+
+import (
+	"image"
+	"math"
+)
+
+// Pixels of a row whose barycentric weights get computed at once.
+const rowChunk = 64
+
+// Finds the pixels covered by triangles, the same ones whatever the algorithm, give or take
+// pixels right on their edges.
+type triangleRasterizer interface {
+	// Calls fragment with the barycentric weights of every pixel of the triangle between min and
+	// max included, once each.
+	rasterize(triangle Triangle, min, max image.Point, fragment func(x, y int, w1, w2, w3 float64))
+}
+
+func newTriangleRasterizer(r Rasterizer) triangleRasterizer {
+	if r == Scanline {
+		return scanlineRasterizer{}
+	}
+	return edgeFunctionRasterizer{}
+}
+
+type edgeFunctionRasterizer struct{}
+
+func (edgeFunctionRasterizer) rasterize(triangle Triangle, min, max image.Point, fragment func(x, y int, w1, w2, w3 float64)) {
+	v1, v2, v3 := triangle.points[0], triangle.points[1], triangle.points[2]
+	rule := triangle.fillRule()
+
+	// Barycentric weights get computed for runs of pixels of a row at once, at their centers.
+	var w1s, w2s, w3s [rowChunk]float64
+
+	for y := min.Y; y <= max.Y; y++ {
+		for x0 := min.X; x0 <= max.X; x0 += rowChunk {
+			n := minInt(rowChunk, max.X-x0+1)
+			barycentricRow(w1s[:n], w2s[:n], w3s[:n], float64(x0)+0.5, float64(y)+0.5, v1, v2, v3)
+
+			for i := 0; i < n; i++ {
+				if rule.covers(w1s[i], w2s[i], w3s[i]) {
+					fragment(x0+i, y, w1s[i], w2s[i], w3s[i])
+				}
+			}
+		}
+	}
+}
+
+type scanlineRasterizer struct{}
+
+// Walks the rows from the lowest vertex to the highest one, between the long edge joining them on
+// one side and the two short edges through the middle vertex on the other. Where edges cross rows
+// only gives an estimate of the ends of spans, which get adjusted to the pixels edge functions
+// would draw, so that both rasterizers follow the same fill rule.
+func (scanlineRasterizer) rasterize(triangle Triangle, min, max image.Point, fragment func(x, y int, w1, w2, w3 float64)) {
+	// Nothing gets drawn of degenerate triangles, like edge functions do.
+	if triangle.signedArea() == 0 {
+		return
+	}
+
+	v1, v2, v3 := triangle.points[0], triangle.points[1], triangle.points[2]
+	rule := triangle.fillRule()
+	covers := func(x, y int) bool {
+		return rule.covers(barycentricAt(float64(x)+0.5, float64(y)+0.5, v1, v2, v3))
+	}
+
+	low, middle, high := triangle.position(0), triangle.position(1), triangle.position(2)
+	if middle.Y < low.Y {
+		low, middle = middle, low
+	}
+	if high.Y < middle.Y {
+		middle, high = high, middle
+	}
+	if middle.Y < low.Y {
+		low, middle = middle, low
+	}
+
+	// Where the edge from a to b crosses the row, a being below.
+	edgeX := func(a, b Vertex2, y float64) float64 {
+		return a.X + (y-a.Y)*(b.X-a.X)/(b.Y-a.Y)
+	}
+
+	// Weights are linear along rows, changing by the same amount from a pixel to the next.
+	denominator := float64((v2.Y-v3.Y)*(v1.X-v3.X)+(v3.X-v2.X)*(v1.Y-v3.Y)) / subpixelScale
+	dw1 := float64(v2.Y-v3.Y) / denominator
+	dw2 := float64(v3.Y-v1.Y) / denominator
+
+	y0 := maxInt(int(math.Ceil(low.Y-0.5)), min.Y)
+	y1 := minInt(int(math.Floor(high.Y-0.5)), max.Y)
+	for y := y0; y <= y1; y++ {
+		center := float64(y) + 0.5
+		long := edgeX(low, high, center)
+		var short float64
+		switch {
+		case center < middle.Y:
+			short = edgeX(low, middle, center)
+		case center > middle.Y:
+			short = edgeX(middle, high, center)
+		default:
+			short = middle.X
+		}
+
+		left, right := math.Min(long, short), math.Max(long, short)
+		x0 := maxInt(int(math.Ceil(left-0.5)), min.X)
+		x1 := minInt(int(math.Floor(right-0.5)), max.X)
+
+		// Rounding of the crossings can be off by a pixel either way.
+		for x0 > min.X && covers(x0-1, y) {
+			x0--
+		}
+		for x0 <= x1 && !covers(x0, y) {
+			x0++
+		}
+		for x1 < max.X && covers(x1+1, y) {
+			x1++
+		}
+		for x1 >= x0 && !covers(x1, y) {
+			x1--
+		}
+		if x0 > x1 {
+			continue
+		}
+
+		w1, w2, _ := barycentricAt(float64(x0)+0.5, center, v1, v2, v3)
+		for x := x0; x <= x1; x++ {
+			fragment(x, y, w1, w2, 1-w1-w2)
+			w1 += dw1
+			w2 += dw2
+		}
+	}
+}
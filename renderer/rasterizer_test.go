@@ -0,0 +1,162 @@
+package renderer
+
+import (
+	"image"
+	"image/color"
+	"math/rand"
+	"testing"
+)
+
+// Triangles of a jittered grid covering the image, vertices at sub-pixel positions, half of them
+// going clockwise.
+func tessellatedTriangles(size, cells int, random *rand.Rand) []Triangle {
+	vertices := make([]image.Point, (cells+1)*(cells+1))
+	// Less than half a cell, for triangles not to overlap.
+	jitter := func() int {
+		cell := size * subpixelScale / cells
+		return random.Intn(cell/2) - cell/4
+	}
+	for y := 0; y <= cells; y++ {
+		for x := 0; x <= cells; x++ {
+			p := image.Point{X: x * size * subpixelScale / cells, Y: y * size * subpixelScale / cells}
+			// Vertices on the border stay there, for the triangles to cover the whole image.
+			if x > 0 && x < cells {
+				p.X += jitter()
+			}
+			if y > 0 && y < cells {
+				p.Y += jitter()
+			}
+			vertices[y*(cells+1)+x] = p
+		}
+	}
+
+	var triangles []Triangle
+	for y := 0; y < cells; y++ {
+		for x := 0; x < cells; x++ {
+			a, b := vertices[y*(cells+1)+x], vertices[y*(cells+1)+x+1]
+			c, d := vertices[(y+1)*(cells+1)+x], vertices[(y+1)*(cells+1)+x+1]
+			triangles = append(triangles, Triangle{points: [3]image.Point{a, b, d}}, Triangle{points: [3]image.Point{a, c, d}})
+		}
+	}
+
+	for i := range triangles {
+		triangles[i].invW = [3]float64{1, 1, 1}
+		triangles[i].material = DefaultMaterial()
+	}
+	return triangles
+}
+
+// Pixels along edges shared by triangles have to be drawn exactly once, by one of them.
+func TestFillRule(t *testing.T) {
+	const size = 64
+	rasterizers := map[string]triangleRasterizer{"edge": edgeFunctionRasterizer{}, "scanline": scanlineRasterizer{}}
+
+	for name, raster := range rasterizers {
+		t.Run(name, func(t *testing.T) {
+			random := rand.New(rand.NewSource(1))
+			for _, cells := range []int{1, 3, 8, 32} {
+				fb := NewFramebuffer(size, size)
+				s := shading{view: ViewOverdraw, overdraw: make([]int, size*size)}
+				for _, triangle := range tessellatedTriangles(size, cells, random) {
+					drawTriangle(fb, triangle, nil, s, false, fb.color.Bounds(), raster)
+				}
+
+				for i, n := range s.overdraw {
+					if n != 1 {
+						t.Fatalf("%d cells: pixel %d,%d drawn %d times", cells, i%size, i/size, n)
+					}
+				}
+			}
+		})
+	}
+}
+
+// Both rasterizers draw the same pixels.
+func TestRasterizersCoverage(t *testing.T) {
+	const size = 48
+	random := rand.New(rand.NewSource(2))
+
+	for i := 0; i < 500; i++ {
+		var triangle Triangle
+		for k := range triangle.points {
+			triangle.points[k] = image.Point{X: random.Intn(size * subpixelScale), Y: random.Intn(size * subpixelScale)}
+		}
+		min, max := triangle.pixelBounds()
+
+		covered := map[image.Point]bool{}
+		edgeFunctionRasterizer{}.rasterize(triangle, min, max, func(x, y int, w1, w2, w3 float64) {
+			covered[image.Point{X: x, Y: y}] = true
+		})
+		scanlineRasterizer{}.rasterize(triangle, min, max, func(x, y int, w1, w2, w3 float64) {
+			p := image.Point{X: x, Y: y}
+			if !covered[p] {
+				t.Fatalf("%v: scanline draws %v, edge functions don't", triangle.points, p)
+			}
+			delete(covered, p)
+		})
+		for p := range covered {
+			t.Fatalf("%v: edge functions draw %v, scanline doesn't", triangle.points, p)
+		}
+	}
+}
+
+// Planes seen from below get culled, unless two-sided, their normals then facing the camera.
+func TestTwoSided(t *testing.T) {
+	camera := NewCamera(Vertex3{Y: -3, Z: 1}, Vertex3{})
+	rect := image.Rect(0, 0, 64, 64)
+
+	material := DefaultMaterial()
+	if triangles := projectTriangles(NewPlane(), material, Identity4(), camera, rect, DefaultOptions(), nil); len(triangles) != 0 {
+		t.Errorf("%d back faces drawn", len(triangles))
+	}
+
+	material.TwoSided = true
+	triangles := projectTriangles(NewPlane(), material, Identity4(), camera, rect, DefaultOptions(), nil)
+	if len(triangles) == 0 {
+		t.Fatal("two-sided back faces culled")
+	}
+	for _, triangle := range triangles {
+		if n := triangle.face.Normals[0]; n.Y >= 0 {
+			t.Errorf("back face normal %v not flipped", n)
+		}
+		if n := triangle.faceNormal(); n.Y >= 0 {
+			t.Errorf("back face plane normal %v not flipped", n)
+		}
+	}
+}
+
+func TestDepthBias(t *testing.T) {
+	// Depth going from 0 to 10 over 10 pixels along X.
+	triangle := Triangle{
+		points: [3]image.Point{{X: 0, Y: 0}, {X: 10 * subpixelScale, Y: 0}, {X: 0, Y: 10 * subpixelScale}},
+		depths: [3]float64{0, 10, 0},
+	}
+	triangle.offset(DepthBias{Constant: 0.5, Slope: 2})
+
+	want := [3]float64{2.5, 12.5, 2.5}
+	if triangle.depths != want {
+		t.Errorf("depths %v, expected %v", triangle.depths, want)
+	}
+
+	// Overlaid edges of a steep triangle show all along, above its own depths.
+	const size = 16
+	steep := Triangle{
+		points: [3]image.Point{{X: 1 * subpixelScale, Y: 1 * subpixelScale}, {X: 15 * subpixelScale, Y: 2 * subpixelScale}, {X: 3 * subpixelScale, Y: 14 * subpixelScale}},
+		depths: [3]float64{10, 200, 60},
+	}
+	zBuffer := make([]float64, size*size)
+	min, max := steep.pixelBounds()
+	edgeFunctionRasterizer{}.rasterize(steep, min, max, func(x, y int, w1, w2, w3 float64) {
+		zBuffer[y*size+x] = w1*steep.depths[0] + w2*steep.depths[1] + w3*steep.depths[2]
+	})
+
+	white := color.RGBA{R: 255, G: 255, B: 255, A: 255}
+	all, overlay := image.NewRGBA(image.Rect(0, 0, size, size)), image.NewRGBA(image.Rect(0, 0, size, size))
+	drawWireframe(all, []Triangle{steep}, nil, white)
+	drawWireframe(overlay, []Triangle{steep}, zBuffer, white)
+	for i := range all.Pix {
+		if all.Pix[i] != overlay.Pix[i] {
+			t.Fatalf("overlay edge hidden at %d,%d", i/4%size, i/4/size)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package renderer
+
+import "math"
+
+// Transparent surfaces a single ray goes through at most, so that stacks of them end.
+const maxTransparentLayers = 32
+
+// Faces of the scene in world space, ready for casting rays against.
+type rayScene struct {
+	bvh *BVH
+	// Material and whether it lets light through, for every face of the hierarchy.
+	materials   []*Material
+	transparent []bool
+	// Distance rays leaving surfaces start from, so that they don't hit them again right away.
+	epsilon float64
+}
+
+func newRayScene(scene *Scene) *rayScene {
+	s := &rayScene{bvh: scene.BVH(), epsilon: 1e-6}
+
+	// Finding out whether textures have transparent texels is slow, it's done once per material.
+	fallback := DefaultMaterial()
+	transparency := map[*Material]bool{}
+	s.materials = make([]*Material, len(s.bvh.Faces))
+	s.transparent = make([]bool, len(s.bvh.Faces))
+	for i, face := range s.bvh.Faces {
+		material := face.Material
+		if material == nil {
+			material = fallback
+		}
+
+		transparent, ok := transparency[material]
+		if !ok {
+			transparent = material.transparent()
+			transparency[material] = transparent
+		}
+
+		s.materials[i], s.transparent[i] = material, transparent
+	}
+
+	// Small enough not to skip over details, large enough to get past rounding errors.
+	if len(s.bvh.Faces) > 0 {
+		bounds := s.bvh.Bounds()
+		s.epsilon = math.Max(1e-6, bounds.Max.minus(bounds.Min).length()*1e-5)
+	}
+
+	return s
+}
+
+// Fraction of the light getting through from a direction, up to a distance, dimmed by the
+// transparent surfaces in the way.
+func (s *rayScene) transmittance(origin, direction Vertex3, distance float64) float64 {
+	r := Ray{Origin: origin, Direction: direction}
+	amount := 1.0
+
+	for layers := 0; layers < maxTransparentLayers; layers++ {
+		hit, ok := s.bvh.intersect(r, distance)
+		if !ok {
+			return amount
+		}
+
+		face := &s.bvh.Faces[hit.Face]
+		w1, w2, w3 := 1-hit.U-hit.V, hit.U, hit.V
+		uv := face.Textures[0].scale(w1).plus(face.Textures[1].scale(w2)).plus(face.Textures[2].scale(w3))
+
+		// Additive surfaces only add light, they don't block any.
+		if s.materials[hit.Face].Blend != AdditiveBlend {
+			amount *= 1 - s.opacity(hit.Face, uv)
+		}
+		if amount <= 0 {
+			return 0
+		}
+
+		step := hit.Distance + s.epsilon
+		r.Origin = r.At(step)
+		distance -= step
+	}
+
+	return 0
+}
+
+// How much of the light a face stops at the given texture coordinates.
+func (s *rayScene) opacity(face int, uv Vertex2) float64 {
+	material := s.materials[face]
+	if material.AlphaCutoff > 0 {
+		if material.cutout(uv) {
+			return 0
+		}
+		return 1
+	}
+	if !s.transparent[face] {
+		return 1
+	}
+
+	return math.Max(0, math.Min(1, material.alpha(uv)))
+}
+
+// How far shadow rays towards the light go, infinitely for directional lights.
+func lightDistance(light Light, position Vertex3) float64 {
+	switch l := light.(type) {
+	case PointLight:
+		return l.Position.minus(position).length()
+	case SpotLight:
+		return l.Position.minus(position).length()
+	}
+
+	return math.Inf(1)
+}
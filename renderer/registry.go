@@ -0,0 +1,77 @@
+package renderer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Loads a model from a file of a format the renderer doesn't know of, registered with
+// RegisterLoader.
+type Loader func(ctx context.Context, filename string) (*Obj, error)
+
+// Colors the surfaces of materials their own way, like a toon shader or a debug view, registered
+// with RegisterShader for scene files to refer to by name. Shading stays on the renderer's side:
+// shaders only get called where lighting would be, per pixel or per vertex depending on the
+// shading mode, and path tracing leaves them out.
+type Shader interface {
+	// Linear color of the fragment, before exposure and tone mapping.
+	Shade(f Fragment) Vertex3
+}
+
+// Loaders and shaders added by the program or its plugins, safe to register from any goroutine.
+var registry = struct {
+	sync.RWMutex
+	loaders map[string]Loader
+	shaders map[string]Shader
+}{
+	loaders: map[string]Loader{},
+	shaders: map[string]Shader{},
+}
+
+// Loads models of files with the extension, like ".stl", with the loader. Cases of extensions
+// don't matter. Registered loaders go before the built-in ones, replacing them for the same
+// extension, as do later registrations for earlier ones.
+func RegisterLoader(extension string, loader Loader) {
+	registry.Lock()
+	defer registry.Unlock()
+	registry.loaders[strings.ToLower(extension)] = loader
+}
+
+// Makes the shader available under the name, replacing any other one registered under it.
+func RegisterShader(name string, shader Shader) {
+	registry.Lock()
+	defer registry.Unlock()
+	registry.shaders[name] = shader
+}
+
+func registeredLoader(extension string) (Loader, bool) {
+	registry.RLock()
+	defer registry.RUnlock()
+	loader, ok := registry.loaders[strings.ToLower(extension)]
+	return loader, ok
+}
+
+// Shader registered under the name.
+func LookupShader(name string) (Shader, bool) {
+	registry.RLock()
+	defer registry.RUnlock()
+	shader, ok := registry.shaders[name]
+	return shader, ok
+}
+
+// Set by the file of the plugin build tag, as loading plugins needs cgo and links the program
+// dynamically.
+var openPlugin func(path string) error
+
+// Loads a Go plugin, a .so file built with -buildmode=plugin, whose init functions register its
+// loaders and shaders. Plugins must be built with the same version of Go, of this package and
+// build tags as the program, and can't be unloaded.
+func LoadPlugin(path string) error {
+	if openPlugin == nil {
+		return errors.New(fmt.Sprintf("plugin %s: built without the plugin tag", path))
+	}
+	return openPlugin(path)
+}
@@ -0,0 +1,12 @@
+//go:build plugin
+
+package renderer
+
+import "plugin"
+
+func init() {
+	openPlugin = func(path string) error {
+		_, err := plugin.Open(path)
+		return err
+	}
+}
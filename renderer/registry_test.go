@@ -0,0 +1,48 @@
+package renderer
+
+import (
+	"context"
+	"testing"
+)
+
+type constantShader Vertex3
+
+func (c constantShader) Shade(f Fragment) Vertex3 {
+	return Vertex3(c)
+}
+
+func TestRegistry(t *testing.T) {
+	RegisterLoader(".TEST", func(ctx context.Context, filename string) (*Obj, error) {
+		return LoadModelContext(ctx, "@cube")
+	})
+	RegisterShader("test", constantShader{X: 1})
+
+	obj, err := LoadModel("box.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(obj.Files) != 1 || obj.Files[0] != "box.test" {
+		t.Errorf("got files %v, want box.test", obj.Files)
+	}
+
+	shader, ok := LookupShader("test")
+	if !ok {
+		t.Fatal("shader not registered")
+	}
+	scene := NewScene()
+	node := NewNode("box")
+	node.Mesh = obj
+	node.Material = DefaultMaterial()
+	node.Material.Shader = shader
+	scene.Root.Add(node)
+
+	options := DefaultOptions()
+	options.Width, options.Height = 32, 32
+	img, err := Render(scene, BestViewCamera(obj), options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := img.RGBAAt(16, 16); c.R != 255 || c.G != 0 || c.B != 0 {
+		t.Errorf("got %v at the center, want red", c)
+	}
+}
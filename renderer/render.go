@@ -0,0 +1,564 @@
+// Package renderer draws 3D scenes into images in software, without a GPU.
+package renderer
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"math"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Renders the scene as seen from the camera into a new image, top row first like image files.
+func Render(scene *Scene, camera Camera, options Options) (*image.RGBA, error) {
+	return RenderContext(context.Background(), scene, camera, options)
+}
+
+// Same as Render, giving up with the error of the context once it's canceled. Path tracing stops
+// within a row of pixels, rasterizing between the steps of the frame.
+func RenderContext(ctx context.Context, scene *Scene, camera Camera, options Options) (*image.RGBA, error) {
+	if err := options.validate(); err != nil {
+		return nil, err
+	}
+
+	rect := options.rect()
+	img := newImage(rect)
+	renderFrame(ctx, wrapFramebuffer(img, nil), scene, camera, options, options.Stats)
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return flipImageVertically(rect, img), nil
+}
+
+// Where a render in passes got to, given to the progress callback of the options after every pass.
+type Progress struct {
+	// Passes done so far, from 1, out of how many there are.
+	Pass, Passes int
+	// Samples per pixel taken so far.
+	Samples int
+	// The image so far, top row first, before post-processing and tone mapping.
+	Image *image.RGBA
+}
+
+// Renders the scene into an image file, PNG, JPEG, PPM, PAM or OpenEXR after its extension.
+// OpenEXR keeps the colors of the frame as they are, beyond what 8 bits can hold.
+func RenderFile(filename string, scene *Scene, camera Camera, options Options) error {
+	return RenderFileContext(context.Background(), filename, scene, camera, options)
+}
+
+// Same as RenderFile, giving up with the error of the context once it's canceled, without writing
+// the file.
+func RenderFileContext(ctx context.Context, filename string, scene *Scene, camera Camera, options Options) error {
+	if err := options.validate(); err != nil {
+		return err
+	}
+
+	rect := options.rect()
+	img := newImage(rect)
+
+	var hdr *hdrImage
+	if strings.ToLower(filepath.Ext(filename)) == ".exr" {
+		hdr = newHDRImage(rect.Dx(), rect.Dy())
+	}
+	renderFrame(ctx, wrapFramebuffer(img, hdr), scene, camera, options, options.Stats)
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if hdr != nil {
+		return saveEXR(hdr.flipVertically(), filename)
+	}
+	return SaveImage(flipImageVertically(rect, img), filename)
+}
+
+func render(img *image.RGBA, scene *Scene, camera Camera, options Options) {
+	renderFrame(context.Background(), wrapFramebuffer(img, nil), scene, camera, options, options.Stats)
+}
+
+// Same as render, drawing into the buffers attached to the framebuffer too. Linear colors of the
+// HDR buffer only go through the post effects working on them, like bloom, before tone mapping.
+// Returns the depth buffer, nil without one, whether a depth buffer is attached or not. What the
+// frame took gets added to the stats when given. Stops early once the context is canceled, leaving
+// the frame unfinished.
+func renderFrame(ctx context.Context, fb *Framebuffer, scene *Scene, camera Camera, options Options, stats *RenderStats) []float64 {
+	// Tone mapping and bloom work on linear colors, kept for them when no HDR buffer is attached.
+	if !fb.Attached(HDRAttachment) && needsLinear(options) {
+		fb.Attach(HDRAttachment)
+		defer fb.Detach(HDRAttachment)
+	}
+
+	if viewport, scissor, ok := options.regions(fb.width, fb.height); ok {
+		return renderViewport(ctx, fb, viewport, scissor, scene, camera, options, stats)
+	}
+
+	img, hdr := fb.color, fb.hdr
+
+	// Path tracing samples every pixel many times already, anti-aliasing comes for free.
+	if options.Backend == PathTracing {
+		start := time.Now()
+		zBuffer := pathTrace(ctx, img, hdr, scene, camera, options)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if options.Grid.Enabled {
+			drawGrid(img, hdr, zBuffer, scene, camera, options.Grid)
+		}
+		stats.since(StageRaster, start)
+
+		start = time.Now()
+		postProcess(img, hdr, zBuffer, camera, options)
+		stats.since(StagePost, start)
+		stats.addGeometry(scene.faceCount(), 0, 0, 0)
+		drawGuides(img, zBuffer, scene, camera, options)
+		return zBuffer
+	}
+
+	if options.AntiAliasing == Supersampling {
+		if factor := supersamplingFactor(options.Samples); factor > 1 {
+			rect := img.Bounds()
+			large := NewFramebuffer(rect.Dx()*factor, rect.Dy()*factor)
+			for _, a := range []Attachment{HDRAttachment, NormalAttachment, ObjectIDAttachment} {
+				if fb.Attached(a) {
+					large.Attach(a)
+				}
+			}
+
+			// Post-processing happens once, at the final resolution, and so do guides, keeping
+			// their lines thin.
+			o := options
+			o.AntiAliasing = NoAntiAliasing
+			o.PostEffects, o.ToneMapping, o.Exposure = nil, NoToneMapping, 0
+			o.BoundingBoxes, o.AxisGizmo, o.VertexNormals = BoundingBoxesOff, false, false
+			o.Toon.Outline *= factor
+			zBuffer := renderFrame(ctx, large, scene, camera, o, stats)
+			if ctx.Err() != nil {
+				return nil
+			}
+
+			start := time.Now()
+			downsample(img, large.color, factor)
+			if zBuffer != nil {
+				zBuffer = downsampleDepth(zBuffer, rect.Dx(), rect.Dy(), factor)
+			}
+			if hdr != nil {
+				*hdr = *large.hdr.downsample(rect.Dx(), rect.Dy())
+			}
+			postProcess(img, hdr, zBuffer, camera, options)
+			fb.downsampleAttributes(large, factor)
+			stats.since(StagePost, start)
+			drawGuides(img, zBuffer, scene, camera, options)
+			return zBuffer
+		}
+	}
+
+	clearImage(img, hdr, options.ClearColor)
+
+	start := time.Now()
+	lights := scene.Lights()
+	shadows := renderShadowMaps(scene, lights, camera, float64(img.Bounds().Dx())/float64(img.Bounds().Dy()), options)
+	var rays *rayScene
+	if options.Shadows.Enabled && options.Shadows.RayTraced {
+		rays = newRayScene(scene)
+	}
+	stats.since(StageShadows, start)
+
+	var grid *lightGrid
+	if bounds, ok := scene.bounds(); ok && options.LightCulling {
+		grid = newLightGrid(lights, bounds)
+	}
+
+	if scene.Skybox != nil {
+		drawSkybox(img, hdr, scene.Skybox, camera)
+	}
+
+	start = time.Now()
+	triangles := projectScene(scene, camera, img.Bounds(), options, stats)
+	stats.since(StageGeometry, start)
+	if ctx.Err() != nil {
+		return nil
+	}
+
+	// Outlines find creases in the normals.
+	if options.Toon.Outline > 0 && !fb.Attached(NormalAttachment) {
+		fb.Attach(NormalAttachment)
+		defer fb.Detach(NormalAttachment)
+	}
+
+	start = time.Now()
+	if options.Wireframe == WireframeOnly {
+		drawWireframe(img, triangles, nil, color.RGBA{R: 255, G: 255, B: 255, A: 255})
+		stats.since(StageRaster, start)
+		drawGuides(img, nil, scene, camera, options)
+		return nil
+	}
+
+	zBuffer := rasterize(fb, triangles, shading{
+		lights:      lights,
+		grid:        grid,
+		stats:       stats,
+		shadows:     shadows,
+		rays:        rays,
+		ambient:     scene.Ambient,
+		environment: scene.Environment,
+		fog:         scene.Fog,
+		eye:         camera.Position,
+		camera:      camera.viewMatrix(),
+		view:        options.View,
+		mode:        options.Shading,
+
+		vertexColors: options.VertexColors,
+		toon:         options.Toon,
+	}, options)
+
+	if options.Toon.Outline > 0 && zBuffer != nil {
+		drawOutlines(img, hdr, zBuffer, fb.normals, options.Toon)
+	}
+
+	if options.Grid.Enabled {
+		drawGrid(img, hdr, zBuffer, scene, camera, options.Grid)
+	}
+
+	stats.since(StageRaster, start)
+
+	start = time.Now()
+	postProcess(img, hdr, zBuffer, camera, options)
+	stats.since(StagePost, start)
+
+	if options.Wireframe == WireframeOverlay {
+		drawWireframe(img, triangles, zBuffer, color.RGBA{R: 255, G: 255, B: 255, A: 255})
+	}
+	drawGuides(img, zBuffer, scene, camera, options)
+
+	return zBuffer
+}
+
+// Renders the frame into a framebuffer the size of the viewport, then copies the part the scissor
+// lets through over. Returns the depth buffer of the whole framebuffer, keeping the depths of the
+// attached one outside of the scissor.
+func renderViewport(ctx context.Context, fb *Framebuffer, viewport, scissor image.Rectangle, scene *Scene, camera Camera, options Options, stats *RenderStats) []float64 {
+	view := NewFramebuffer(viewport.Dx(), viewport.Dy())
+	for _, a := range []Attachment{HDRAttachment, NormalAttachment, ObjectIDAttachment} {
+		if fb.Attached(a) {
+			view.Attach(a)
+		}
+	}
+
+	o := options
+	o.Width, o.Height = viewport.Dx(), viewport.Dy()
+	o.Viewport, o.Scissor = image.Rectangle{}, image.Rectangle{}
+	zBuffer := renderFrame(ctx, view, scene, camera, o, stats)
+	if ctx.Err() != nil {
+		return nil
+	}
+
+	var depth []float64
+	if zBuffer != nil {
+		depth = make([]float64, fb.width*fb.height)
+		if fb.depth != nil {
+			copy(depth, fb.depth)
+		} else {
+			fillFloat64s(depth, math.Inf(-1))
+		}
+	}
+
+	for y := scissor.Min.Y; y < scissor.Max.Y; y++ {
+		for x := scissor.Min.X; x < scissor.Max.X; x++ {
+			vx, vy := x-viewport.Min.X, y-viewport.Min.Y
+			i, j := y*fb.width+x, vy*view.width+vx
+
+			fb.color.SetRGBA(x, y, view.color.RGBAAt(vx, vy))
+			if fb.hdr != nil {
+				fb.hdr.pixels[i] = view.hdr.pixels[j]
+			}
+			if fb.normals != nil {
+				fb.normals[i] = view.normals[j]
+			}
+			if fb.objects != nil {
+				fb.objects[i] = view.objects[j]
+			}
+			if depth != nil {
+				depth[i] = zBuffer[j]
+			}
+		}
+	}
+
+	return depth
+}
+
+// Every mesh of the scene brought to screen space, counted in the stats when given.
+func projectScene(scene *Scene, camera Camera, rect image.Rectangle, options Options, stats *RenderStats) []Triangle {
+	var triangles []Triangle
+
+	object := 0
+	scene.walkInstances(func(node *Node, mesh *Obj, world Matrix4, instance int) {
+		object++
+		start := len(triangles)
+		triangles = append(triangles, projectTriangles(mesh, node.Material, world, camera, rect, options, stats)...)
+		for i := start; i < len(triangles); i++ {
+			triangles[i].object = object
+			triangles[i].face = node.tint(instance, triangles[i].face)
+			if node.DepthBias != (DepthBias{}) {
+				triangles[i].offset(node.DepthBias)
+			}
+		}
+	})
+
+	return triangles
+}
+
+// Brings every face of the model to screen space, clipped to the view and minus the ones culled.
+// The material, when given, overrides the ones of the model. Faces get counted in the stats when
+// given.
+func projectTriangles(obj *Obj, material *Material, world Matrix4, camera Camera, rect image.Rectangle, options Options, stats *RenderStats) []Triangle {
+	// Map from world space to clip space.
+	aspect := float64(rect.Dx()) / float64(rect.Dy())
+	cameraMatrix := camera.projectionMatrix(aspect).Multiply(camera.viewMatrix())
+	normalMatrix := genNormalMatrix(world)
+	fallback := DefaultMaterial()
+
+	// Map from clip space to screen.
+	screenMatrix := genScreenMatrix(0, 0, rect.Dx(), rect.Dy())
+
+	planes := nearPlanes
+	if options.FrustumClipping {
+		planes = frustumPlanes
+	}
+	culling := cullingPlanes(planes)
+
+	// Nothing to do for meshes entirely off-screen.
+	if obj.Bounds.outside(cameraMatrix.Multiply(world), culling) {
+		stats.addGeometry(len(obj.Faces), len(obj.Faces), 0, 0)
+		return nil
+	}
+
+	obj = obj.lod(cameraMatrix.Multiply(world), rect)
+
+	// Map from an object's local coordinate space into world coordinate space, then into clip
+	// space, in batches.
+	worldPositions := make([]Vertex4, 3*len(obj.Faces))
+	for k, face := range obj.Faces {
+		for i, v := range face.Vertices {
+			worldPositions[3*k+i] = Vertex4{X: v.X, Y: v.Y, Z: v.Z, W: 1}
+		}
+	}
+	transformVertices(world, worldPositions)
+	for i, v := range worldPositions {
+		v3 := v.lower()
+		worldPositions[i] = Vertex4{X: v3.X, Y: v3.Y, Z: v3.Z, W: 1}
+	}
+
+	clipPositions := make([]Vertex4, len(worldPositions))
+	copy(clipPositions, worldPositions)
+	transformVertices(cameraMatrix, clipPositions)
+
+	triangles := make([]Triangle, 0, len(obj.Faces))
+	polygon := make([]clipVertex, 3)
+	culled, cut := 0, 0
+
+	for k, face := range obj.Faces {
+		for i := 0; i < 3; i++ {
+			polygon[i].position = clipPositions[3*k+i]
+		}
+
+		// Same for single faces, before going through the rest of their attributes.
+		if allOutside(polygon, culling) {
+			culled++
+			continue
+		}
+
+		for i := 0; i < 3; i++ {
+			worldVertex := worldPositions[3*k+i]
+
+			polygon[i] = clipVertex{
+				position: clipPositions[3*k+i],
+				vertex:   Vertex3{X: worldVertex.X, Y: worldVertex.Y, Z: worldVertex.Z},
+				texture:  face.Textures[i],
+				normal:   normalMatrix.transformDirection(face.Normals[i]),
+				tangent:  transformTangent(world, face.Tangents[i]),
+				color:    face.Colors[i],
+			}
+		}
+
+		clipped := clipPolygon(polygon, planes)
+		drawn := len(triangles)
+
+		// Whatever is left of the face is a convex polygon, split as a fan of triangles.
+		for i := 1; i+1 < len(clipped); i++ {
+			triangle := screenTriangle(clipped[0], clipped[i], clipped[i+1], screenMatrix)
+			triangle.face.Colored = face.Colored
+			triangle.material = material
+			if triangle.material == nil {
+				triangle.material = face.Material
+			}
+			if triangle.material == nil {
+				triangle.material = fallback
+			}
+
+			if triangle.isBackFacing(options.FrontFace) {
+				if triangle.material.TwoSided {
+					triangle.flip()
+				} else if options.BackfaceCulling {
+					continue
+				}
+			}
+
+			triangles = append(triangles, triangle)
+		}
+
+		if len(triangles) == drawn {
+			culled++
+		} else if stats != nil && !allInside(polygon, planes) {
+			cut++
+		}
+	}
+
+	stats.addGeometry(len(obj.Faces), culled, cut, len(triangles))
+	return triangles
+}
+
+func screenTriangle(a, b, c clipVertex, screenMatrix Matrix4) Triangle {
+	triangle := Triangle{}
+
+	for i, v := range [3]clipVertex{a, b, c} {
+		vertex4 := v.position
+		vertex4.transform(screenMatrix)
+
+		// Bring back 4D into 3D.
+		vertex3 := vertex4.lower()
+
+		triangle.points[i].X = int(math.Round(vertex3.X * subpixelScale))
+		triangle.points[i].Y = int(math.Round(vertex3.Y * subpixelScale))
+		triangle.depths[i] = vertex3.Z
+		triangle.invW[i] = 1 / vertex4.W
+
+		triangle.face.Vertices[i] = v.vertex
+		triangle.face.Textures[i] = v.texture
+		triangle.face.Normals[i] = v.normal
+		triangle.face.Tangents[i] = v.tangent
+		triangle.face.Colors[i] = v.color
+	}
+
+	return triangle
+}
+
+// Turns the normals and tangents of the triangle around, for its back face to get shaded like a
+// front face.
+func (t *Triangle) flip() {
+	t.backFacing = true
+	for i := range t.face.Normals {
+		t.face.Normals[i] = t.face.Normals[i].scale(-1)
+		t.face.Tangents[i] = t.face.Tangents[i].scale(-1)
+	}
+}
+
+// Opaque triangles get drawn first, then transparent ones from back to front so that they blend
+// over what's behind them. Returns the z-buffer, nil with the painter's algorithm.
+func rasterize(fb *Framebuffer, triangles []Triangle, s shading, options Options) []float64 {
+	img, hdr := fb.color, fb.hdr
+	rect := img.Bounds()
+	var zBuffer []float64
+
+	var opaque, transparent []Triangle
+	transparency := map[*Material]bool{}
+	for _, triangle := range triangles {
+		t, ok := transparency[triangle.material]
+		if !ok {
+			t = triangle.material.transparent()
+			transparency[triangle.material] = t
+		}
+
+		if t {
+			transparent = append(transparent, triangle)
+		} else {
+			opaque = append(opaque, triangle)
+		}
+	}
+
+	switch options.Backend {
+	case ZBuffer:
+		zBuffer = make([]float64, rect.Dx()*rect.Dy())
+		fillFloat64s(zBuffer, math.Inf(-1))
+
+	case Painter:
+		// The furthest triangles are drawn first so that the nearest ones get painted over them.
+		sort.SliceStable(opaque, func(i, j int) bool {
+			return opaque[i].averageDepth() < opaque[j].averageDepth()
+		})
+	}
+
+	sort.SliceStable(transparent, func(i, j int) bool {
+		return transparent[i].averageDepth() < transparent[j].averageDepth()
+	})
+
+	// Fragments get counted per pixel rather than per sample.
+	if s.view == ViewOverdraw {
+		s.overdraw = make([]int, rect.Dx()*rect.Dy())
+	}
+
+	if options.AntiAliasing == Multisampling && s.view != ViewOverdraw {
+		if offsets, ok := samplePatterns[options.Samples]; ok {
+			samples := newSampleBuffer(img, hdr, offsets, options.Backend == ZBuffer)
+			var hiz *depthPyramid
+			if samples.depths != nil {
+				hiz = newDepthPyramid(samples.depths, rect.Dx(), rect.Dy(), len(offsets))
+			}
+			drawTiles(rect, opaque, transparent, options.Workers, earlyDepthTest(hiz, func(triangle Triangle, region image.Rectangle, transparent bool) {
+				samples.drawTriangle(fb, triangle, s, transparent, region)
+			}))
+			return samples.resolve(img, hdr)
+		}
+	}
+
+	var hiz *depthPyramid
+	if zBuffer != nil {
+		hiz = newDepthPyramid(zBuffer, rect.Dx(), rect.Dy(), 1)
+	}
+	raster := newTriangleRasterizer(options.Rasterizer)
+	if options.DepthPrepass && zBuffer != nil {
+		drawTiles(rect, opaque, nil, options.Workers, earlyDepthTest(hiz, func(triangle Triangle, region image.Rectangle, transparent bool) {
+			drawTriangleDepth(triangle, zBuffer, rect.Dx(), region, raster)
+		}))
+		s.depthEqual = true
+	}
+	// Transparent triangles still get shaded as they're drawn, blending over the lit surfaces.
+	if options.Deferred && zBuffer != nil && s.view == ViewLit && s.mode == PhongShading {
+		g := newGBuffer(rect.Dx(), rect.Dy())
+		drawTiles(rect, opaque, nil, options.Workers, earlyDepthTest(hiz, func(triangle Triangle, region image.Rectangle, transparent bool) {
+			g.drawTriangle(fb, triangle, zBuffer, s, region, raster)
+		}))
+		g.light(fb, s, options.Workers)
+		opaque = nil
+	}
+	drawTiles(rect, opaque, transparent, options.Workers, earlyDepthTest(hiz, func(triangle Triangle, region image.Rectangle, transparent bool) {
+		drawTriangle(fb, triangle, zBuffer, s, transparent, region, raster)
+	}))
+
+	if s.overdraw != nil {
+		drawOverdraw(img, hdr, s.overdraw)
+	}
+
+	return zBuffer
+}
+
+// Skips the triangles hidden according to the depth pyramid, and keeps it up to date with the
+// opaque ones drawn. Without a pyramid, everything gets drawn.
+func earlyDepthTest(hiz *depthPyramid, draw func(triangle Triangle, region image.Rectangle, transparent bool)) func(triangle Triangle, region image.Rectangle, transparent bool) {
+	if hiz == nil {
+		return draw
+	}
+
+	return func(triangle Triangle, region image.Rectangle, transparent bool) {
+		if hiz.occluded(triangle, region) {
+			return
+		}
+
+		draw(triangle, region, transparent)
+		if !transparent {
+			hiz.invalidate(triangle, region)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package renderer
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+)
+
+// A Scatter instances a source mesh across the surface (or the volume) of a target mesh,
+// e.g. grass on a terrain or pebbles on the ground.
+type Scatter struct {
+	Source *Obj
+	Target *Obj
+
+	// Instances per unit of surface area, or per unit of volume when Volume is set.
+	Density float64
+	Volume  bool
+
+	MinScale float64
+	MaxScale float64
+	Rotate   bool
+	Seed     int64
+}
+
+// The same seed always produces the same instances, so renders are reproducible.
+func (s Scatter) instances() []Matrix4 {
+	rng := rand.New(rand.NewSource(s.Seed))
+
+	if s.Volume {
+		return s.scatterVolume(rng)
+	}
+
+	return s.scatterSurface(rng)
+}
+
+func (s Scatter) scatterSurface(rng *rand.Rand) []Matrix4 {
+	faces := s.Target.Faces
+	if len(faces) == 0 {
+		return nil
+	}
+
+	// Cumulative areas, so that faces get picked proportionally to their size.
+	cumulative := make([]float64, len(faces))
+	total := 0.0
+	for i, face := range faces {
+		total += faceArea(face)
+		cumulative[i] = total
+	}
+
+	count := int(math.Round(total * s.Density))
+	instances := make([]Matrix4, 0, count)
+
+	for i := 0; i < count; i++ {
+		k := sort.SearchFloat64s(cumulative, rng.Float64()*total)
+		if k >= len(faces) {
+			k = len(faces) - 1
+		}
+		face := faces[k]
+
+		// Uniformly distributed point inside the triangle.
+		r1 := math.Sqrt(rng.Float64())
+		r2 := rng.Float64()
+		w1, w2, w3 := 1-r1, r1*(1-r2), r1*r2
+		position := face.Vertices[0].scale(w1).plus(face.Vertices[1].scale(w2)).plus(face.Vertices[2].scale(w3))
+
+		// Instances stand upright on the surface they are scattered on.
+		up := Vertex3{Y: 1}
+		normal := faceNormal(face)
+		align := Identity4()
+		if axis := up.cross(normal); axis.length() > 1e-9 {
+			align = RotationMatrix(axis, math.Acos(math.Max(-1, math.Min(1, up.dot(normal)))))
+		} else if normal.Y < 0 {
+			align = RotationMatrix(Vertex3{X: 1}, math.Pi)
+		}
+
+		instances = append(instances, TranslationMatrix(position).Multiply(align).Multiply(s.randomOrientation(rng)))
+	}
+
+	return instances
+}
+
+func (s Scatter) scatterVolume(rng *rand.Rand) []Matrix4 {
+	min, max := s.Target.bounds()
+	size := max.minus(min)
+
+	// Rejection sampling inside the bounding box, so the volume estimate and the samples agree.
+	count := int(math.Round(size.X * size.Y * size.Z * s.Density))
+	instances := make([]Matrix4, 0, count)
+
+	for i := 0; i < count; i++ {
+		position := Vertex3{
+			X: min.X + rng.Float64()*size.X,
+			Y: min.Y + rng.Float64()*size.Y,
+			Z: min.Z + rng.Float64()*size.Z,
+		}
+
+		if !s.Target.contains(position) {
+			continue
+		}
+
+		instances = append(instances, TranslationMatrix(position).Multiply(s.randomOrientation(rng)))
+	}
+
+	return instances
+}
+
+func (s Scatter) randomOrientation(rng *rand.Rand) Matrix4 {
+	m := Identity4()
+
+	if s.Rotate {
+		m = RotationMatrix(Vertex3{Y: 1}, rng.Float64()*2*math.Pi)
+	}
+
+	scale := s.MinScale + rng.Float64()*(s.MaxScale-s.MinScale)
+	if scale <= 0 {
+		scale = 1
+	}
+
+	return m.Multiply(ScaleMatrix(Vertex3{X: scale, Y: scale, Z: scale}))
+}
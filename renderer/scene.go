@@ -0,0 +1,261 @@
+package renderer
+
+import "math"
+
+// A node places what's attached to it (a mesh, a light or a camera) relative to its parent,
+// so that groups of objects can be moved around together.
+type Node struct {
+	Name string
+	// Local transform, relative to the parent node.
+	Transform Matrix4
+	Children  []*Node
+
+	Mesh *Obj
+	// Overrides the materials of the mesh when set.
+	Material *Material
+	// Deforms the mesh, which then follows its joints instead of the transform of the node.
+	Skin *Skin
+	// How much of every morph target of the mesh is blended in, usually between 0 and 1.
+	MorphWeights []float64
+	Light        Light
+	Camera       *Camera
+	// Copies of the mesh drawn instead of it, all sharing its data, for forests or crowds. The
+	// children of the node aren't copied.
+	Instances []Instance
+	// Pulls the mesh in front of the surfaces it lies on, like decals.
+	DepthBias DepthBias
+}
+
+// Offset of the depths of the triangles of a mesh, like polygon offsets of graphics APIs, so that
+// decals and overlays win the depth test against coplanar surfaces instead of z-fighting with them.
+// Positive biases bring triangles nearer.
+type DepthBias struct {
+	// Added to the depths of all the triangles, in units of the depth buffer, which goes from 0
+	// at the far plane to 255 at the near one.
+	Constant float64
+	// Multiplies the steepest change of depth from one pixel to the next across every triangle,
+	// added too, as surfaces seen at grazing angles need more.
+	Slope float64
+}
+
+// Copy of the mesh of a node, placed relative to the node.
+type Instance struct {
+	Transform Matrix4
+	// Multiplies the vertex colors of the mesh when Colored, or replaces them on meshes without
+	// any.
+	Color   Vertex3
+	Colored bool
+}
+
+type Scene struct {
+	Root *Node
+	// Light coming from everywhere, as an RGB color.
+	Ambient Vertex3
+	// Image-based lighting, optional.
+	Environment *Environment
+	// Drawn behind the scene when set.
+	Skybox *Skybox
+	// Fading surfaces into its color with distance when set.
+	Fog *Fog
+	// Clips animating the nodes, like the ones of glTF files. Still images show the nodes as they
+	// are, clips have to be applied first.
+	Animations []*AnimationClip
+	// Files the scene got loaded from, with the ones of its models, textures and environment, for
+	// the viewer to load it again when they change.
+	Files []string
+}
+
+func NewScene() *Scene {
+	return &Scene{Root: NewNode("root")}
+}
+
+func NewNode(name string) *Node {
+	return &Node{
+		Name:      name,
+		Transform: Identity4(),
+	}
+}
+
+func (n *Node) Add(children ...*Node) {
+	n.Children = append(n.Children, children...)
+}
+
+// Overrides the materials of all the meshes of the node and its descendants.
+func (n *Node) SetMaterial(material *Material) {
+	walkNode(n, Identity4(), func(node *Node, world Matrix4) {
+		if node.Mesh != nil {
+			node.Material = material
+		}
+	})
+}
+
+// Sets the depth bias of all the meshes of the node and its descendants.
+func (n *Node) SetDepthBias(bias DepthBias) {
+	walkNode(n, Identity4(), func(node *Node, world Matrix4) {
+		if node.Mesh != nil {
+			node.DepthBias = bias
+		}
+	})
+}
+
+// Visits every node, parents first, along with the transform from its space to world space.
+func (s *Scene) walk(fn func(node *Node, world Matrix4)) {
+	walkNode(s.Root, Identity4(), fn)
+}
+
+func walkNode(node *Node, parent Matrix4, fn func(node *Node, world Matrix4)) {
+	world := parent.Multiply(node.Transform)
+	fn(node, world)
+
+	for _, child := range node.Children {
+		walkNode(child, world, fn)
+	}
+}
+
+// Visits the nodes with a mesh, along with the mesh as it gets drawn and the transform from its
+// space to world space. Morph targets come blended in, and skinned meshes deformed by their joints,
+// already in world space. Nodes with instances get visited once for each of them.
+func (s *Scene) walkMeshes(fn func(node *Node, mesh *Obj, world Matrix4)) {
+	s.walkInstances(func(node *Node, mesh *Obj, world Matrix4, instance int) {
+		fn(node, mesh, world)
+	})
+}
+
+// Same as walkMeshes, along with the index of the instance of the node, 0 for nodes without
+// instances. The transform places the instance, in world space for skinned meshes.
+func (s *Scene) walkInstances(fn func(node *Node, mesh *Obj, world Matrix4, instance int)) {
+	var worlds map[*Node]Matrix4
+
+	instances := func(node *Node, mesh *Obj, world Matrix4) {
+		if len(node.Instances) == 0 {
+			fn(node, mesh, world, 0)
+			return
+		}
+		for i, instance := range node.Instances {
+			fn(node, mesh, world.Multiply(instance.Transform), i)
+		}
+	}
+
+	s.walk(func(node *Node, world Matrix4) {
+		if node.Mesh == nil {
+			return
+		}
+
+		// Morphing happens in model space, before skinning.
+		mesh := node.Mesh
+		if morphed := mesh.morph(node.MorphWeights); morphed != nil {
+			mesh = morphed
+		}
+		if node.Skin == nil || len(mesh.Weights) != len(mesh.Faces) {
+			instances(node, mesh, world)
+			return
+		}
+
+		// Joints can be anywhere in the hierarchy, visited before or after the mesh.
+		if worlds == nil {
+			worlds = map[*Node]Matrix4{}
+			s.walk(func(node *Node, world Matrix4) {
+				worlds[node] = world
+			})
+		}
+		instances(node, node.Skin.deform(mesh, worlds), Identity4())
+	})
+}
+
+// The face as the instance of the node shows it, with its color.
+func (n *Node) tint(instance int, face Face) Face {
+	if instance >= len(n.Instances) || !n.Instances[instance].Colored {
+		return face
+	}
+
+	c := n.Instances[instance].Color
+	for i := range face.Colors {
+		if face.Colored {
+			face.Colors[i] = face.Colors[i].multiply(c)
+		} else {
+			face.Colors[i] = c
+		}
+	}
+	face.Colored = true
+
+	return face
+}
+
+// The first camera of the scene, in world space.
+func (s *Scene) Camera() (Camera, bool) {
+	var camera *Camera
+
+	s.walk(func(node *Node, world Matrix4) {
+		if node.Camera == nil || camera != nil {
+			return
+		}
+
+		c := *node.Camera
+		c.Position = world.transformPoint(c.Position)
+		c.Target = world.transformPoint(c.Target)
+		c.Up = world.transformDirection(c.Up)
+		camera = &c
+	})
+
+	if camera == nil {
+		return Camera{}, false
+	}
+
+	return *camera, true
+}
+
+// Every light of the scene, in world space.
+func (s *Scene) Lights() []Light {
+	var lights []Light
+
+	s.walk(func(node *Node, world Matrix4) {
+		if node.Light == nil {
+			return
+		}
+
+		lights = append(lights, node.Light.transform(world))
+	})
+
+	return lights
+}
+
+// All the meshes of the scene merged into one, in world space.
+func (s *Scene) Flatten() *Obj {
+	obj := &Obj{}
+
+	s.walkInstances(func(node *Node, mesh *Obj, world Matrix4, instance int) {
+		normalMatrix := genNormalMatrix(world)
+		for _, face := range mesh.Faces {
+			obj.Faces = append(obj.Faces, node.tint(instance, face.transform(world, normalMatrix)))
+		}
+	})
+
+	return obj
+}
+
+// Box around all the meshes of the scene in world space, false when it has none.
+func (s *Scene) bounds() (AABB, bool) {
+	bounds, found := AABB{}, false
+	s.walkMeshes(func(node *Node, mesh *Obj, world Matrix4) {
+		for _, corner := range mesh.Bounds.corners() {
+			p := world.transformPoint(corner)
+			if !found {
+				bounds, found = AABB{Min: p, Max: p}, true
+			}
+			bounds.Min = Vertex3{X: math.Min(bounds.Min.X, p.X), Y: math.Min(bounds.Min.Y, p.Y), Z: math.Min(bounds.Min.Z, p.Z)}
+			bounds.Max = Vertex3{X: math.Max(bounds.Max.X, p.X), Y: math.Max(bounds.Max.Y, p.Y), Z: math.Max(bounds.Max.Z, p.Z)}
+		}
+	})
+	return bounds, found
+}
+
+// Faces of all the meshes of the scene, instances included.
+func (s *Scene) faceCount() int {
+	count := 0
+	s.walk(func(node *Node, world Matrix4) {
+		if node.Mesh != nil {
+			count += len(node.Mesh.Faces) * maxInt(len(node.Instances), 1)
+		}
+	})
+	return count
+}
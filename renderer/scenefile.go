@@ -0,0 +1,975 @@
+package renderer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Declarative description of a render, so that complex scenes are reproducible without code changes.
+// Vectors are [x, y, z] arrays, angles are in degrees and paths are relative to the scene file.
+// Models named with an @ are built-in primitives, like @plane.
+//
+//	{
+//	  "output": {"file": "output.png", "width": 800, "height": 800},
+//	  "camera": {"position": [0, 0, 3], "target": [0, 0, 0], "fov": 45},
+//	  "fog": {"type": "linear", "color": [0.6, 0.7, 0.8], "start": 5, "end": 50},
+//	  "lights": [{"type": "directional", "direction": [0, 0, -1]}, {"type": "point", "position": [1, 1, 1]}],
+//	  "materials": {"skin": {"diffuse": "textures/african_head_diffuse.png", "specular": [0.3, 0.3, 0.3], "shininess": 32}},
+//	  "nodes": [{"name": "head", "model": "models/african_head.obj", "material": "skin", "rotate": [0, 30, 0]}, {"model": "models/face.glb", "morph": {"smile": 0.8}}, {"model": "@cube", "instances": [{"translate": [2, 0, 0], "color": [1, 0, 0]}, {"translate": [4, 0, 0], "scale": 0.5}]}],
+//	  "animations": [{"name": "turntable", "tracks": [{"node": "head", "path": "rotation", "times": [0, 4], "values": [[0, 0, 0], [0, 360, 0]]}], "scripts": [{"node": "light2", "path": "intensity", "values": ["1 + 0.5 * sin(t * 2 * pi)"]}]}],
+//	  "post": [{"type": "bloom", "threshold": 0.8}, {"type": "tonemap", "operator": "aces", "exposure": 0.5}, {"type": "dof", "focus": 3}, {"type": "fxaa"}, {"type": "text", "text": "Head", "x": 8, "y": 8}, {"type": "lut", "file": "film.cube"}]
+//	}
+type sceneFile struct {
+	Output      Output                   `json:"output"`
+	Ambient     sceneVector              `json:"ambient"`
+	Environment *sceneEnvironment        `json:"environment"`
+	Skybox      string                   `json:"skybox"` // Equirectangular or cube cross image
+	Fog         *sceneFog                `json:"fog"`
+	Camera      *sceneCamera             `json:"camera"`
+	Lights      []sceneLight             `json:"lights"`
+	Materials   map[string]sceneMaterial `json:"materials"`
+	Nodes       []sceneNode              `json:"nodes"`
+	Animations  []sceneAnimation         `json:"animations"`
+	Post        []scenePostEffect        `json:"post"`
+}
+
+type Output struct {
+	File   string `json:"file"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+	// Post effects of the scene file, in order.
+	Effects []PostEffect `json:"-"`
+}
+
+// Equirectangular image, .hdr or any other format, lighting metallic-roughness materials.
+type sceneEnvironment struct {
+	File      string  `json:"file"`
+	Intensity float64 `json:"intensity"`
+}
+
+type sceneFog struct {
+	Type  string      `json:"type"` // "linear", "exponential" or "exponential2"
+	Color sceneVector `json:"color"`
+	// Linear fog
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+	// Exponential fog
+	Density float64 `json:"density"`
+}
+
+type sceneCamera struct {
+	Position   sceneVector `json:"position"`
+	Target     sceneVector `json:"target"`
+	Up         sceneVector `json:"up"`
+	Projection string      `json:"projection"` // "perspective" or "orthographic"
+	Fov        float64     `json:"fov"`
+	OrthoSize  float64     `json:"orthoSize"`
+	Near       float64     `json:"near"`
+	Far        float64     `json:"far"`
+}
+
+type sceneLight struct {
+	Type        string      `json:"type"` // "directional", "point" or "spot"
+	Direction   sceneVector `json:"direction"`
+	Position    sceneVector `json:"position"`
+	Intensity   float64     `json:"intensity"`
+	Attenuation []float64   `json:"attenuation"` // [constant, linear, quadratic]
+	InnerAngle  float64     `json:"innerAngle"`
+	OuterAngle  float64     `json:"outerAngle"`
+}
+
+type sceneMaterial struct {
+	Diffuse    string      `json:"diffuse"` // Texture
+	Normal     string      `json:"normal"`  // Tangent-space normal map
+	Color      sceneVector `json:"color"`
+	Ambient    sceneVector `json:"ambient"`
+	Specular   sceneVector `json:"specular"`
+	Shininess  float64     `json:"shininess"`
+	Opacity    *float64    `json:"opacity"`
+	OpacityMap string      `json:"opacityMap"` // Texture, the red channel being the opacity
+	// Discards fragments less opaque than this instead of blending them.
+	AlphaCutoff float64 `json:"alphaCutoff"`
+	// Drawn from both sides, for thin surfaces like leaves.
+	TwoSided bool `json:"twoSided"`
+	// Light given off, linear and as bright as needed, multiplied by the texture if any.
+	Emissive    sceneVector `json:"emissive"`
+	EmissiveMap string      `json:"emissiveMap"` // Texture, white emissive color when only this is set
+	// "additive" adds the material's light to what's behind it, for effects like fire or sparks.
+	Blend string `json:"blend"`
+
+	// Setting any of these switches to metallic-roughness shading, the color being the base color.
+	Metallic             *float64 `json:"metallic"`
+	Roughness            *float64 `json:"roughness"`
+	MetallicRoughnessMap string   `json:"metallicRoughness"` // Texture, glTF layout
+
+	// Light in flat bands like in cartoons, whatever the other parameters say.
+	Toon bool `json:"toon"`
+	// Texture of a lit sphere, shading the material instead of the lights.
+	Matcap string `json:"matcap"`
+	// Name of a shader registered with RegisterShader, replacing the lighting.
+	Shader string `json:"shader"`
+}
+
+// Keyframes and scripts moving nodes around, found by name, the camera of the scene being called
+// "camera" and lights "light1", "light2" and so on. Clips loop at normal speed by default.
+type sceneAnimation struct {
+	Name    string        `json:"name"`
+	Loop    *bool         `json:"loop"`
+	Speed   float64       `json:"speed"`
+	Tracks  []sceneTrack  `json:"tracks"`
+	Scripts []sceneScript `json:"scripts"`
+}
+
+type sceneTrack struct {
+	Node          string        `json:"node"`
+	Path          string        `json:"path"`          // "position", "rotation" or "scale"
+	Interpolation string        `json:"interpolation"` // "linear", "step" or "smooth"
+	Times         []float64     `json:"times"`         // Seconds, in increasing order
+	Values        []sceneVector `json:"values"`        // Euler angles in degrees for rotations
+}
+
+// Expressions of the time t in seconds, see Expression.
+type sceneScript struct {
+	Node   string   `json:"node"`
+	Path   string   `json:"path"`   // "position", "rotation", "scale" or "intensity"
+	Values []string `json:"values"` // X, Y and Z, or the intensity alone
+}
+
+// Settings left out get the same defaults as on the command line.
+type scenePostEffect struct {
+	Type string `json:"type"` // "bloom", "tonemap", "dof", "fxaa", "text", "lut", "vignette", "grain" or "chromatic"
+	// Bloom, and LUTs blended in by the intensity
+	Threshold *float64 `json:"threshold"`
+	Intensity float64  `json:"intensity"`
+	Radius    float64  `json:"radius"` // Also where vignettes start
+	// Vignettes, film grain and chromatic aberration
+	Strength *float64 `json:"strength"`
+	// Tone mapping, "reinhard", "aces", "exposure" or "none" for clamping, with the exposure in
+	// stops
+	Operator string  `json:"operator"`
+	Exposure float64 `json:"exposure"`
+	// Depth of field
+	Focus    float64 `json:"focus"`
+	Aperture float64 `json:"aperture"`
+	// Text, from the top-left corner of the image. The built-in font is used without a font file,
+	// the size then being rounded to multiples of its 7 pixels.
+	Text  string      `json:"text"`
+	X     int         `json:"x"`
+	Y     int         `json:"y"`
+	Color sceneVector `json:"color"`
+	Font  string      `json:"font"`
+	Size  float64     `json:"size"`
+	// Color grading, from a .cube file
+	File string `json:"file"`
+}
+
+type sceneNode struct {
+	Name      string          `json:"name"`
+	Model     string          `json:"model"`
+	Material  string          `json:"material"`
+	Translate sceneVector     `json:"translate"`
+	Rotate    sceneVector     `json:"rotate"`
+	Scale     sceneVector     `json:"scale"`
+	Scatter   []sceneScatter  `json:"scatter"`
+	Instances []sceneInstance `json:"instances"`
+	Children  []sceneNode     `json:"children"`
+	// Weights of the morph targets of glTF models, by name.
+	Morph map[string]float64 `json:"morph"`
+	// [constant, slope], bringing the model in front of coplanar surfaces like decals.
+	DepthBias []float64 `json:"depthBias"`
+}
+
+// Instances of a model strewn over the surface, or through the volume, of the node's model.
+type sceneScatter struct {
+	Model    string    `json:"model"`
+	Material string    `json:"material"`
+	Density  float64   `json:"density"`
+	Volume   bool      `json:"volume"`
+	Scale    []float64 `json:"scale"` // [min, max]
+	Rotate   bool      `json:"rotate"`
+	Seed     int64     `json:"seed"`
+}
+
+// Copy of the model of a node, placed relative to the node. The color multiplies the vertex colors
+// of the model, or replaces them.
+type sceneInstance struct {
+	Translate sceneVector `json:"translate"`
+	Rotate    sceneVector `json:"rotate"`
+	Scale     sceneVector `json:"scale"`
+	Color     sceneVector `json:"color"`
+}
+
+// Written as [x, y, z], or as a single number for all three components.
+type sceneVector []float64
+
+func (v *sceneVector) UnmarshalJSON(data []byte) error {
+	var f float64
+	if err := json.Unmarshal(data, &f); err == nil {
+		*v = sceneVector{f}
+		return nil
+	}
+
+	var components []float64
+	if err := json.Unmarshal(data, &components); err != nil {
+		return err
+	}
+
+	if len(components) != 3 {
+		return errors.New(fmt.Sprintf("vectors need 3 components, got %d", len(components)))
+	}
+
+	*v = components
+	return nil
+}
+
+func (v sceneVector) vertex3(fallback Vertex3) Vertex3 {
+	switch len(v) {
+	case 1:
+		return Vertex3{X: v[0], Y: v[0], Z: v[0]}
+	case 3:
+		return Vertex3{X: v[0], Y: v[1], Z: v[2]}
+	}
+	return fallback
+}
+
+func DefaultOutput() Output {
+	return Output{File: "output.png", Width: 800, Height: 800}
+}
+
+// Models and materials used several times by a scene are only loaded once.
+type sceneLoader struct {
+	ctx       context.Context
+	dir       string
+	materials map[string]sceneMaterial
+	models    map[string]*Obj
+	loaded    map[string]*Material
+	// Of the glTF models.
+	animations []*AnimationClip
+	// Every file loaded so far, along with the scene file.
+	files []string
+}
+
+func LoadScene(filename string) (*Scene, Output, error) {
+	return LoadSceneContext(context.Background(), filename)
+}
+
+// Same as LoadScene, giving up with the error of the context once it's canceled, between models
+// or while reading them like LoadModelContext does.
+func LoadSceneContext(ctx context.Context, filename string) (*Scene, Output, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, Output{}, err
+	}
+	defer file.Close()
+
+	description := sceneFile{Output: DefaultOutput()}
+	if err := json.NewDecoder(file).Decode(&description); err != nil {
+		return nil, Output{}, errors.New(fmt.Sprintf("invalid scene file %s: %s", filename, err))
+	}
+
+	loader := sceneLoader{
+		ctx:       ctx,
+		dir:       filepath.Dir(filename),
+		files:     []string{filename},
+		materials: description.Materials,
+		models:    map[string]*Obj{},
+		loaded:    map[string]*Material{},
+	}
+
+	scene := NewScene()
+	scene.Ambient = description.Ambient.vertex3(Vertex3{})
+
+	if description.Environment != nil {
+		path := filepath.Join(loader.dir, description.Environment.File)
+		loader.files = append(loader.files, path)
+		scene.Environment, err = LoadEnvironment(path)
+		if err != nil {
+			return nil, Output{}, err
+		}
+		if description.Environment.Intensity > 0 {
+			scene.Environment.Intensity = description.Environment.Intensity
+		}
+	}
+
+	if description.Skybox != "" {
+		path := filepath.Join(loader.dir, description.Skybox)
+		loader.files = append(loader.files, path)
+		scene.Skybox, err = LoadSkybox(path)
+		if err != nil {
+			return nil, Output{}, err
+		}
+	}
+
+	if description.Fog != nil {
+		scene.Fog, err = description.Fog.fog()
+		if err != nil {
+			return nil, Output{}, err
+		}
+	}
+
+	if description.Camera != nil {
+		node := NewNode("camera")
+		node.Camera = description.Camera.camera()
+		scene.Root.Add(node)
+	}
+
+	for i, l := range description.Lights {
+		node := NewNode(fmt.Sprintf("light%d", i+1))
+		node.Light, err = l.light()
+		if err != nil {
+			return nil, Output{}, err
+		}
+		scene.Root.Add(node)
+	}
+
+	for _, n := range description.Nodes {
+		if err := ctx.Err(); err != nil {
+			return nil, Output{}, err
+		}
+		node, err := loader.node(n)
+		if err != nil {
+			return nil, Output{}, err
+		}
+		scene.Root.Add(node)
+	}
+
+	for _, p := range description.Post {
+		effect, err := p.effect(loader.dir)
+		if err != nil {
+			return nil, Output{}, err
+		}
+		if p.File != "" {
+			loader.files = append(loader.files, filepath.Join(loader.dir, p.File))
+		}
+		description.Output.Effects = append(description.Output.Effects, effect)
+	}
+
+	for _, a := range description.Animations {
+		clip, err := a.clip(scene)
+		if err != nil {
+			return nil, Output{}, err
+		}
+		scene.Animations = append(scene.Animations, clip)
+	}
+	scene.Animations = append(scene.Animations, loader.animations...)
+	scene.Files = loader.files
+
+	return scene, description.Output, nil
+}
+
+func (c sceneCamera) camera() *Camera {
+	camera := NewCamera(c.Position.vertex3(Vertex3{Z: 3}), c.Target.vertex3(Vertex3{}))
+	camera.Up = c.Up.vertex3(camera.Up)
+
+	if c.Projection == "orthographic" {
+		camera.Projection = Orthographic
+	}
+	if c.Fov > 0 {
+		camera.Fov = c.Fov * math.Pi / 180
+	}
+	if c.OrthoSize > 0 {
+		camera.OrthoSize = c.OrthoSize
+	}
+	if c.Near > 0 {
+		camera.Near = c.Near
+	}
+	if c.Far > 0 {
+		camera.Far = c.Far
+	}
+
+	return &camera
+}
+
+// Light gray by default, like haze on an overcast day.
+func (f sceneFog) fog() (*Fog, error) {
+	fog := &Fog{Color: f.Color.vertex3(Vertex3{X: 0.5, Y: 0.5, Z: 0.5}), Start: f.Start, End: f.End, Density: f.Density}
+
+	switch f.Type {
+	case "", "linear":
+		if f.End <= f.Start {
+			return nil, errors.New(fmt.Sprintf("fog ends at %g before starting at %g", f.End, f.Start))
+		}
+		return fog, nil
+	case "exponential":
+		fog.Mode = ExponentialFog
+	case "exponential2":
+		fog.Mode = ExponentialSquaredFog
+	default:
+		return nil, errors.New(fmt.Sprintf("unknown fog type %q", f.Type))
+	}
+
+	if f.Density <= 0 {
+		return nil, errors.New(fmt.Sprintf("invalid fog density %g", f.Density))
+	}
+	return fog, nil
+}
+
+// Files are relative to the directory given.
+func (p scenePostEffect) effect(dir string) (PostEffect, error) {
+	switch p.Type {
+	case "bloom":
+		bloom := NewBloomEffect()
+		if p.Threshold != nil {
+			bloom.Threshold = *p.Threshold
+		}
+		if p.Intensity > 0 {
+			bloom.Intensity = p.Intensity
+		}
+		if p.Radius > 0 {
+			bloom.Radius = p.Radius
+		}
+		return bloom, nil
+
+	case "tonemap":
+		toneMap := &ToneMapEffect{Exposure: p.Exposure}
+		switch p.Operator {
+		case "", "none":
+		case "reinhard":
+			toneMap.Operator = Reinhard
+		case "aces":
+			toneMap.Operator = ACES
+		case "exposure":
+			toneMap.Operator = ExposureToneMapping
+		default:
+			return nil, errors.New(fmt.Sprintf("unknown tone mapping operator %q", p.Operator))
+		}
+		return toneMap, nil
+
+	case "dof":
+		if p.Focus <= 0 {
+			return nil, errors.New("depth of field needs a focus distance")
+		}
+		dof := NewDepthOfFieldEffect(p.Focus)
+		if p.Aperture > 0 {
+			dof.Aperture = p.Aperture
+		}
+		return dof, nil
+
+	case "fxaa":
+		return FXAAEffect{}, nil
+
+	case "vignette":
+		vignette := NewVignetteEffect()
+		if p.Strength != nil {
+			vignette.Strength = *p.Strength
+		}
+		if p.Radius > 0 {
+			vignette.Radius = p.Radius
+		}
+		return vignette, nil
+
+	case "grain":
+		grain := NewGrainEffect()
+		if p.Strength != nil {
+			grain.Strength = *p.Strength
+		}
+		return grain, nil
+
+	case "chromatic":
+		aberration := NewChromaticAberrationEffect()
+		if p.Strength != nil {
+			aberration.Strength = *p.Strength
+		}
+		return aberration, nil
+
+	case "lut":
+		if p.File == "" {
+			return nil, errors.New("color grading needs a .cube file")
+		}
+		lut, err := LoadLUT(filepath.Join(dir, p.File))
+		if err != nil {
+			return nil, err
+		}
+		effect := NewLUTEffect(lut)
+		if p.Intensity > 0 {
+			effect.Intensity = math.Min(1, p.Intensity)
+		}
+		return effect, nil
+
+	case "text":
+		text := &TextEffect{Text: p.Text, Position: image.Point{X: p.X, Y: p.Y}}
+		if p.Color != nil {
+			text.Color = toRGBA(p.Color.vertex3(Vertex3{X: 1, Y: 1, Z: 1}))
+		}
+		if p.Font != "" {
+			size := p.Size
+			if size <= 0 {
+				size = 16
+			}
+			font, err := LoadFont(filepath.Join(dir, p.Font), size)
+			if err != nil {
+				return nil, err
+			}
+			text.Font = font
+		} else if p.Size > 0 {
+			text.Font = BitmapFont(int(math.Round(p.Size / bitmapGlyphHeight)))
+		}
+		return text, nil
+	}
+
+	return nil, errors.New(fmt.Sprintf("unknown post effect %q", p.Type))
+}
+
+func (l sceneLight) light() (Light, error) {
+	intensity := l.Intensity
+	if intensity == 0 {
+		intensity = 1
+	}
+
+	if l.Type == "" || l.Type == "directional" {
+		return DirectionalLight{Direction: l.Direction.vertex3(Vertex3{Z: -1}), Intensity: intensity}, nil
+	}
+
+	point := newPointLight(l.Position.vertex3(Vertex3{}))
+	point.Intensity = intensity
+	if len(l.Attenuation) == 3 {
+		point.Constant, point.Linear, point.Quadratic = l.Attenuation[0], l.Attenuation[1], l.Attenuation[2]
+	}
+
+	switch l.Type {
+	case "point":
+		return point, nil
+
+	case "spot":
+		spot := newSpotLight(point.Position, l.Direction.vertex3(Vertex3{Z: -1}))
+		spot.PointLight = point
+		if l.InnerAngle > 0 {
+			spot.InnerAngle = l.InnerAngle * math.Pi / 180
+		}
+		if l.OuterAngle > 0 {
+			spot.OuterAngle = l.OuterAngle * math.Pi / 180
+		}
+		return spot, nil
+	}
+
+	return nil, errors.New(fmt.Sprintf("unknown light type %q", l.Type))
+}
+
+func (a sceneAnimation) clip(scene *Scene) (*AnimationClip, error) {
+	clip := &AnimationClip{Name: a.Name, Loop: a.Loop == nil || *a.Loop, Speed: a.Speed}
+
+	find := func(name string) *Node {
+		var node *Node
+		walkNode(scene.Root, Identity4(), func(n *Node, world Matrix4) {
+			if node == nil && n.Name == name {
+				node = n
+			}
+		})
+		return node
+	}
+
+	for _, t := range a.Tracks {
+		node := find(t.Node)
+		if node == nil {
+			return nil, errors.New(fmt.Sprintf("animation %q has a track for unknown node %q", a.Name, t.Node))
+		}
+
+		channel := AnimationChannel{Node: node, Times: t.Times}
+		switch t.Path {
+		case "position":
+			channel.Path = TranslationPath
+		case "rotation":
+			channel.Path = RotationPath
+		case "scale":
+			channel.Path = ScalePath
+		default:
+			return nil, errors.New(fmt.Sprintf("unknown animation path %q", t.Path))
+		}
+
+		if len(t.Times) == 0 || len(t.Times) != len(t.Values) {
+			return nil, errors.New(fmt.Sprintf("animation %q has %d times for %d values", a.Name, len(t.Times), len(t.Values)))
+		}
+		for i := 1; i < len(t.Times); i++ {
+			if t.Times[i] <= t.Times[i-1] {
+				return nil, errors.New(fmt.Sprintf("animation %q has times out of order", a.Name))
+			}
+		}
+
+		fallback := Vertex3{}
+		if channel.Path == ScalePath {
+			fallback = Vertex3{X: 1, Y: 1, Z: 1}
+		}
+		values := make([]Vertex3, len(t.Values))
+		for i, v := range t.Values {
+			values[i] = v.vertex3(fallback)
+		}
+
+		interpolation := t.Interpolation
+		switch interpolation {
+		case "", "linear":
+			channel.Interpolation = LinearInterpolation
+		case "step":
+			channel.Interpolation = StepInterpolation
+		case "smooth":
+			channel.Interpolation = CubicSplineInterpolation
+		default:
+			return nil, errors.New(fmt.Sprintf("unknown interpolation %q", interpolation))
+		}
+
+		// Quaternions take the shortest way between keyframes, half turns apart at most, when
+		// keyframes a full turn apart are meant to spin all the way around.
+		if channel.Path == RotationPath && channel.Interpolation != StepInterpolation {
+			channel.Times, values = subdivideRotations(channel.Times, values)
+		}
+
+		for i, v := range values {
+			value := Vertex4{X: v.X, Y: v.Y, Z: v.Z}
+			if channel.Path == RotationPath {
+				q := quaternionFromMatrix(NewTransform(Vertex3{}, v, Vertex3{X: 1, Y: 1, Z: 1}))
+				// Same rotation as -q, the one closest to the previous keyframe interpolating best.
+				if i > 0 && q.dot(Quaternion{X: channel.Values[i-1].X, Y: channel.Values[i-1].Y, Z: channel.Values[i-1].Z, W: channel.Values[i-1].W}) < 0 {
+					q = Quaternion{X: -q.X, Y: -q.Y, Z: -q.Z, W: -q.W}
+				}
+				value = Vertex4{X: q.X, Y: q.Y, Z: q.Z, W: q.W}
+			}
+			channel.Values = append(channel.Values, value)
+		}
+		if channel.Interpolation == CubicSplineInterpolation {
+			channel.Values = catmullRom(channel.Times, channel.Values)
+		}
+
+		clip.Channels = append(clip.Channels, channel)
+	}
+
+	for _, s := range a.Scripts {
+		node := find(s.Node)
+		if node == nil {
+			return nil, errors.New(fmt.Sprintf("animation %q has a script for unknown node %q", a.Name, s.Node))
+		}
+
+		script := AnimationScript{Node: node}
+		values := 3
+		switch s.Path {
+		case "position":
+			script.Path = TranslationPath
+		case "rotation":
+			script.Path = RotationPath
+		case "scale":
+			script.Path = ScalePath
+		case "intensity":
+			if node.Light == nil {
+				return nil, errors.New(fmt.Sprintf("animation %q has an intensity script for %q, which isn't a light", a.Name, s.Node))
+			}
+			script.Path = IntensityPath
+			values = 1
+		default:
+			return nil, errors.New(fmt.Sprintf("unknown animation path %q", s.Path))
+		}
+
+		if len(s.Values) != values {
+			return nil, errors.New(fmt.Sprintf("animation %q has %d expressions for the %s of %q instead of %d", a.Name, len(s.Values), s.Path, s.Node, values))
+		}
+		for i, source := range s.Values {
+			e, err := ParseExpression(source)
+			if err != nil {
+				return nil, errors.New(fmt.Sprintf("animation %q: %s", a.Name, err))
+			}
+			script.Values[i] = e
+		}
+
+		clip.Scripts = append(clip.Scripts, script)
+	}
+
+	return clip, nil
+}
+
+// Extra keyframes between those over 90 degrees apart, interpolating their Euler angles.
+func subdivideRotations(times []float64, angles []Vertex3) ([]float64, []Vertex3) {
+	subdivided := []float64{times[0]}
+	subdividedAngles := []Vertex3{angles[0]}
+	for i := 1; i < len(times); i++ {
+		d := angles[i].minus(angles[i-1])
+		steps := int(math.Ceil(math.Max(math.Abs(d.X), math.Max(math.Abs(d.Y), math.Abs(d.Z))) / 90))
+		for step := 1; step <= steps; step++ {
+			t := float64(step) / float64(steps)
+			subdivided = append(subdivided, times[i-1]+(times[i]-times[i-1])*t)
+			subdividedAngles = append(subdividedAngles, angles[i-1].lerp(angles[i], t))
+		}
+		if steps == 0 {
+			subdivided = append(subdivided, times[i])
+			subdividedAngles = append(subdividedAngles, angles[i])
+		}
+	}
+	return subdivided, subdividedAngles
+}
+
+func (l *sceneLoader) node(n sceneNode) (*Node, error) {
+	node := NewNode(n.Name)
+
+	node.Transform = NewTransform(
+		n.Translate.vertex3(Vertex3{}),
+		n.Rotate.vertex3(Vertex3{}),
+		n.Scale.vertex3(Vertex3{X: 1, Y: 1, Z: 1}),
+	)
+
+	if len(n.DepthBias) > 2 {
+		return nil, errors.New(fmt.Sprintf("node %q has an invalid depth bias, expected [constant, slope]", n.Name))
+	}
+	var bias DepthBias
+	if len(n.DepthBias) > 0 {
+		bias.Constant = n.DepthBias[0]
+	}
+	if len(n.DepthBias) > 1 {
+		bias.Slope = n.DepthBias[1]
+	}
+
+	if IsAnimated(n.Model) {
+		// Loaded again for every node, as nodes and their animations can't be shared.
+		filename := filepath.Join(l.dir, n.Model)
+		root, clips, err := LoadAnimatedModelContext(l.ctx, filename)
+		if err != nil {
+			return nil, err
+		}
+		l.files = append(l.files, filename)
+		root.SetDepthBias(bias)
+		node.Add(root)
+		l.animations = append(l.animations, clips...)
+
+		if n.Material != "" {
+			material, err := l.material(n.Material)
+			if err != nil {
+				return nil, err
+			}
+			root.SetMaterial(material)
+		}
+
+		for name, weight := range n.Morph {
+			found := false
+			walkNode(root, Identity4(), func(node *Node, world Matrix4) {
+				if node.Mesh == nil {
+					return
+				}
+				if i := node.Mesh.MorphTarget(name); i >= 0 {
+					node.MorphWeights[i] = weight
+					found = true
+				}
+			})
+			if !found {
+				return nil, errors.New(fmt.Sprintf("node %q has no morph target %q", n.Name, name))
+			}
+		}
+	} else if n.Model != "" {
+		var err error
+
+		node.Mesh, err = l.model(n.Model)
+		if err != nil {
+			return nil, err
+		}
+
+		node.Material, err = l.material(n.Material)
+		if err != nil {
+			return nil, err
+		}
+		node.DepthBias = bias
+	}
+
+	for _, s := range n.Scatter {
+		if node.Mesh == nil {
+			return nil, errors.New(fmt.Sprintf("node %q scatters over nothing, it needs a model", n.Name))
+		}
+
+		source, err := l.model(s.Model)
+		if err != nil {
+			return nil, err
+		}
+
+		material, err := l.material(s.Material)
+		if err != nil {
+			return nil, err
+		}
+
+		scatter := Scatter{
+			Source:   source,
+			Target:   node.Mesh,
+			Density:  s.Density,
+			Volume:   s.Volume,
+			MinScale: 1,
+			MaxScale: 1,
+			Rotate:   s.Rotate,
+			Seed:     s.Seed,
+		}
+		if len(s.Scale) == 2 {
+			scatter.MinScale, scatter.MaxScale = s.Scale[0], s.Scale[1]
+		}
+
+		// Instances share the source mesh, only their transforms differ.
+		instances := NewNode(s.Model)
+		instances.Mesh = source
+		instances.Material = material
+		for _, transform := range scatter.instances() {
+			instances.Instances = append(instances.Instances, Instance{Transform: transform})
+		}
+		node.Add(instances)
+	}
+
+	if len(n.Instances) > 0 && node.Mesh == nil {
+		return nil, errors.New(fmt.Sprintf("node %q has instances of nothing, it needs a model", n.Name))
+	}
+	for _, i := range n.Instances {
+		node.Instances = append(node.Instances, Instance{
+			Transform: NewTransform(i.Translate.vertex3(Vertex3{}), i.Rotate.vertex3(Vertex3{}), i.Scale.vertex3(Vertex3{X: 1, Y: 1, Z: 1})),
+			Color:     i.Color.vertex3(Vertex3{}),
+			Colored:   len(i.Color) > 0,
+		})
+	}
+
+	for _, c := range n.Children {
+		child, err := l.node(c)
+		if err != nil {
+			return nil, err
+		}
+		node.Add(child)
+	}
+
+	return node, nil
+}
+
+func (l *sceneLoader) model(path string) (*Obj, error) {
+	if obj, ok := l.models[path]; ok {
+		return obj, nil
+	}
+
+	// Built-in primitives aren't files.
+	filename := path
+	if !strings.HasPrefix(path, "@") {
+		filename = filepath.Join(l.dir, path)
+	}
+
+	obj, err := LoadModelContext(l.ctx, filename)
+	if err != nil {
+		return nil, err
+	}
+	l.files = append(l.files, obj.Files...)
+
+	l.models[path] = obj
+	return obj, nil
+}
+
+// Without a name, models keep the materials from their MTL files.
+func (l *sceneLoader) material(name string) (*Material, error) {
+	if name == "" {
+		return nil, nil
+	}
+
+	if material, ok := l.loaded[name]; ok {
+		return material, nil
+	}
+
+	description, ok := l.materials[name]
+	if !ok {
+		return nil, errors.New(fmt.Sprintf("unknown material %q", name))
+	}
+
+	material := DefaultMaterial()
+	material.Name = name
+	material.Diffuse = description.Color.vertex3(material.Diffuse)
+	material.Ambient = description.Ambient.vertex3(material.Ambient)
+	material.Specular = description.Specular.vertex3(material.Specular)
+	material.Shininess = description.Shininess
+	material.AlphaCutoff = description.AlphaCutoff
+	material.TwoSided = description.TwoSided
+	if description.Opacity != nil {
+		material.Opacity = *description.Opacity
+	}
+
+	if description.Diffuse != "" {
+		var err error
+		material.DiffuseMap, err = l.texture(description.Diffuse)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if description.Normal != "" {
+		var err error
+		material.NormalMap, err = l.texture(description.Normal)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if description.EmissiveMap != "" {
+		var err error
+		material.Emissive = Vertex3{X: 1, Y: 1, Z: 1}
+		material.EmissiveMap, err = l.texture(description.EmissiveMap)
+		if err != nil {
+			return nil, err
+		}
+	}
+	material.Emissive = description.Emissive.vertex3(material.Emissive)
+
+	switch description.Blend {
+	case "", "alpha":
+	case "additive":
+		material.Blend = AdditiveBlend
+	default:
+		return nil, errors.New(fmt.Sprintf("material %q has unknown blend mode %q", name, description.Blend))
+	}
+
+	if description.OpacityMap != "" {
+		var err error
+		material.OpacityMap, err = l.texture(description.OpacityMap)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if description.Metallic != nil {
+		material.Model = MetallicRoughness
+		material.Metallic = *description.Metallic
+	}
+	if description.Roughness != nil {
+		material.Model = MetallicRoughness
+		material.Roughness = *description.Roughness
+	}
+	if description.MetallicRoughnessMap != "" {
+		var err error
+		material.Model = MetallicRoughness
+		material.MetallicRoughnessMap, err = l.texture(description.MetallicRoughnessMap)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if description.Toon {
+		material.Model = Toon
+	}
+
+	if description.Matcap != "" {
+		var err error
+		material.Model = Matcap
+		material.MatcapMap, err = l.texture(description.Matcap)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if description.Shader != "" {
+		var ok bool
+		material.Shader, ok = LookupShader(description.Shader)
+		if !ok {
+			return nil, errors.New(fmt.Sprintf("material %q has unknown shader %q", name, description.Shader))
+		}
+	}
+
+	l.loaded[name] = material
+	return material, nil
+}
+
+// Textures are resolved relative to the scene file, debug ones like @checker aside.
+func (l *sceneLoader) texture(path string) (image.Image, error) {
+	if strings.HasPrefix(path, "@") {
+		return LoadTextureContext(l.ctx, path)
+	}
+
+	filename := filepath.Join(l.dir, path)
+	l.files = append(l.files, filename)
+	return LoadTextureContext(l.ctx, filename)
+}
@@ -0,0 +1,354 @@
+package renderer
+
+import (
+	"image"
+	"image/color"
+	"math"
+)
+
+// Everything fragments need to know about the frame, besides their own triangle.
+type shading struct {
+	lights []Light
+	// Only the lights reaching the cell of a point in this grid light it, when set.
+	grid *lightGrid
+	// Shadow map of each light, nil for the ones not casting shadows.
+	shadows []*shadowMap
+	// Scene shadow rays get cast against instead, when set.
+	rays *rayScene
+	// Ambient light of the scene.
+	ambient Vertex3
+	// Replaces the ambient light for metallic-roughness materials when set.
+	environment *Environment
+	// Of the scene, when it has any.
+	fog *Fog
+	// Position of the camera in world space, for specular highlights.
+	eye Vertex3
+	// Map from world space to the space of the camera, for matcaps.
+	camera Matrix4
+	view   View
+	mode   ShadingMode
+	// Fragments written to every pixel so far, with the overdraw view.
+	overdraw []int
+	// Counting the fragments shaded, when set.
+	stats *RenderStats
+	// Opaque fragments only get drawn where the z-buffer holds their depth already, after a depth
+	// pre-pass.
+	depthEqual bool
+
+	vertexColors VertexColors
+	toon         ToonOptions
+}
+
+// Lit colors go to the image in sRGB, tone mapping coming later with the post effects, debug views
+// show their values as they are.
+func (s shading) encode(c Vertex3, alpha float64) color.RGBA {
+	if s.view == ViewLit || s.view == ViewTextured {
+		c = linearToSRGB(c)
+	}
+	return toPremultipliedRGBA(c, alpha)
+}
+
+// Color of the fragment of the triangle with these perspective-correct weights, before it gets
+// clamped between 0 and 1 and premultiplied by alpha by encode. Fragments discarded by alpha
+// testing have an alpha of 0.
+func (s shading) shadeLinear(triangle Triangle, w1, w2, w3, depth float64) (Vertex3, float64) {
+	face := triangle.face
+	material := triangle.material
+
+	switch s.view {
+	case ViewDepth:
+		// The screen matrix maps depth between 0 and 255.
+		d := float64(uint8(math.Max(0, math.Min(255, depth)))) / 255
+		return Vertex3{X: d, Y: d, Z: d}, 1
+
+	case ViewFlat:
+		intensity := lightIntensity(s.lights, triangle.faceNormal(), interpolatePosition(face, w1, w2, w3))
+		c := float64(uint8(200*intensity)) / 255
+		return Vertex3{X: c, Y: c, Z: c}, 1
+
+	case ViewNormals:
+		return encodeDirection(interpolateNormal(face, w1, w2, w3)), 1
+
+	case ViewTangents:
+		t := face.Tangents[0].scale(w1).plus(face.Tangents[1].scale(w2)).plus(face.Tangents[2].scale(w3))
+		return encodeDirection(Vertex3{X: t.X, Y: t.Y, Z: t.Z}), 1
+
+	case ViewOverdraw:
+		// Colored once all fragments are counted.
+		return Vertex3{}, 1
+
+	case ViewUV:
+		uv := face.Textures[0].scale(w1).plus(face.Textures[1].scale(w2)).plus(face.Textures[2].scale(w3))
+		return Vertex3{X: uv.X - math.Floor(uv.X), Y: uv.Y - math.Floor(uv.Y)}, 1
+	}
+
+	// Interpolate texture based on barycentric weights
+	uv := Vertex2{
+		X: w1*face.Textures[0].X + w2*face.Textures[1].X + w3*face.Textures[2].X,
+		Y: w1*face.Textures[0].Y + w2*face.Textures[1].Y + w3*face.Textures[2].Y,
+	}
+
+	alpha := material.alpha(uv)
+	if material.AlphaCutoff > 0 {
+		if alpha < material.AlphaCutoff {
+			return Vertex3{}, 0
+		}
+		alpha = 1
+	}
+
+	texel := surfaceTexel(material, face, uv, w1, w2, w3, s.vertexColors)
+	albedo := material.Diffuse.multiply(texel)
+
+	if s.view == ViewTextured {
+		return albedo, alpha
+	}
+
+	position := interpolatePosition(face, w1, w2, w3)
+
+	// Lit beforehand, the texture modulating the interpolated colors.
+	if s.mode != PhongShading {
+		lit := triangle.lit[0].scale(w1).plus(triangle.lit[1].scale(w2)).plus(triangle.lit[2].scale(w3))
+		return s.fogged(material, lit.multiply(texel).plus(material.emission(uv)), position), alpha
+	}
+
+	normal := surfaceNormal(material, face, uv, w1, w2, w3)
+	c := s.light(material, albedo, uv, normal, position).plus(material.emission(uv))
+
+	return s.fogged(material, c, position), alpha
+}
+
+// Normal at a point of the face, bent by the normal map of the material when there's one.
+func surfaceNormal(material *Material, face Face, uv Vertex2, w1, w2, w3 float64) Vertex3 {
+	normal := interpolateNormal(face, w1, w2, w3)
+
+	if material.NormalMap != nil {
+		tangent := face.Tangents[0].scale(w1).plus(face.Tangents[1].scale(w2)).plus(face.Tangents[2].scale(w3))
+		normal = perturbNormal(material.NormalMap, uv, normal, tangent)
+	}
+
+	return normal
+}
+
+// Linear color of the diffuse texture and the vertex colors at a point of the face, to be
+// multiplied by the diffuse color of the material.
+func surfaceTexel(material *Material, face Face, uv Vertex2, w1, w2, w3 float64, vertexColors VertexColors) Vertex3 {
+	texel := Vertex3{X: 1, Y: 1, Z: 1}
+	if material.DiffuseMap != nil {
+		texel = srgbToLinear(sampleTexture(material.DiffuseMap, uv))
+	}
+	if face.Colored && vertexColors != IgnoreVertexColors && (material.DiffuseMap == nil || vertexColors == ModulateVertexColors) {
+		color := face.Colors[0].scale(w1).plus(face.Colors[1].scale(w2)).plus(face.Colors[2].scale(w3))
+		texel = texel.multiply(color)
+	}
+
+	return texel
+}
+
+// Lit colors of the vertices of a triangle, the same for all three with flat shading. Textures
+// are left out, to be applied per fragment.
+func (s shading) shadeVertices(triangle Triangle) [3]Vertex3 {
+	face := triangle.face
+	material := triangle.material
+
+	if s.mode == FlatShading {
+		uv := face.Textures[0].lerp(face.Textures[1], 0.5).lerp(face.Textures[2], 1.0/3)
+		c := s.light(material, material.Diffuse, uv, triangle.faceNormal(), interpolatePosition(face, 1.0/3, 1.0/3, 1.0/3))
+		return [3]Vertex3{c, c, c}
+	}
+
+	var lit [3]Vertex3
+	for i := range lit {
+		lit[i] = s.light(material, material.Diffuse, face.Textures[i], face.Normals[i].normalize(1.0), face.Vertices[i])
+	}
+
+	return lit
+}
+
+// Shading model of the material, at a point of its surface.
+func (s shading) light(material *Material, albedo Vertex3, uv Vertex2, normal, position Vertex3) Vertex3 {
+	if material.Shader != nil {
+		return material.Shader.Shade(Fragment{Material: material, Albedo: albedo, UV: uv, Normal: normal, Position: position, Eye: s.eye, Ambient: s.ambient, shading: &s})
+	}
+
+	return s.lightModel(material, albedo, uv, normal, position)
+}
+
+// Lighting of the shading model of the material, leaving its shader out.
+func (s shading) lightModel(material *Material, albedo Vertex3, uv Vertex2, normal, position Vertex3) Vertex3 {
+	if material.Model == Matcap {
+		return s.matcap(material, albedo, normal)
+	}
+	if s.isToon(material) {
+		return s.toonLight(material, albedo, normal, position)
+	}
+	if material.Model == MetallicRoughness {
+		metallic, roughness := material.metallicRoughness(uv)
+		return s.cookTorrance(material, albedo, metallic, roughness, normal, position)
+	}
+
+	return s.blinnPhong(material, albedo, normal, position)
+}
+
+// Point of a surface being shaded by a Shader, in world space.
+type Fragment struct {
+	Material *Material
+	// Diffuse color of the material, times its texture and vertex colors.
+	Albedo Vertex3
+	UV     Vertex2
+	// Of unit length, bent by the normal map.
+	Normal   Vertex3
+	Position Vertex3
+	// Position of the camera.
+	Eye Vertex3
+	// Ambient light of the scene.
+	Ambient Vertex3
+
+	shading *shading
+}
+
+// Calls fn for every light reaching the fragment, with the direction towards it, of unit length,
+// and how much of it does, shadows included.
+func (f Fragment) EachLight(fn func(direction Vertex3, amount float64)) {
+	f.shading.eachLight(f.Position, func(i int, direction Vertex3, amount float64) {
+		fn(direction, amount*f.shading.visibility(i, f.Position, f.Normal))
+	})
+}
+
+// Color the fragment would have without the shader, for shaders changing it rather than
+// replacing it.
+func (f Fragment) Lit() Vertex3 {
+	return f.shading.lightModel(f.Material, f.Albedo, f.UV, f.Normal, f.Position)
+}
+
+// The texel of the matcap the normal faces, seen from the camera, tinted by the albedo.
+func (s shading) matcap(material *Material, albedo, normal Vertex3) Vertex3 {
+	if material.MatcapMap == nil {
+		return albedo
+	}
+
+	n := s.camera.transformDirection(normal).normalize(1.0)
+	uv := Vertex2{X: math.Max(0, math.Min(0.999, n.X*0.5+0.5)), Y: math.Max(0, math.Min(0.999, n.Y*0.5+0.5))}
+	return albedo.multiply(srgbToLinear(sampleTexture(material.MatcapMap, uv)))
+}
+
+// Ambient, plus Lambertian diffuse and Blinn-Phong specular for every light.
+func (s shading) blinnPhong(material *Material, albedo, normal, position Vertex3) Vertex3 {
+	c := material.Ambient.multiply(s.ambient).multiply(albedo)
+	toEye := s.eye.minus(position).normalize(1.0)
+	specular := material.Shininess > 0 && material.Specular != (Vertex3{})
+
+	s.eachLight(position, func(i int, direction Vertex3, amount float64) {
+		amount *= s.visibility(i, position, normal)
+
+		lambert := normal.dot(direction)
+		if lambert <= 0 {
+			return
+		}
+		c = c.plus(albedo.scale(lambert * amount))
+
+		if specular {
+			// The halfway vector lines up with the normal when the light reflects right into the eye.
+			halfway := direction.plus(toEye).normalize(1.0)
+			highlight := math.Pow(math.Max(0, normal.dot(halfway)), material.Shininess)
+			c = c.plus(material.Specular.scale(highlight * amount))
+		}
+	})
+
+	return c
+}
+
+// Brings the normal sampled from a tangent-space normal map into world space, through the basis
+// formed by the tangent, the bitangent and the normal of the surface.
+func perturbNormal(normalMap image.Image, uv Vertex2, normal Vertex3, tangent Vertex4) Vertex3 {
+	t := Vertex3{X: tangent.X, Y: tangent.Y, Z: tangent.Z}
+
+	// Interpolation bends the tangent away from the normal, straighten it back up.
+	t = t.minus(normal.scale(normal.dot(t)))
+	if t.length() < 1e-12 {
+		return normal
+	}
+	t = t.normalize(1.0)
+
+	handedness := 1.0
+	if tangent.W < 0 {
+		handedness = -1.0
+	}
+	b := normal.cross(t).scale(handedness)
+
+	// Colors between 0 and 1 encode components between -1 and 1.
+	sample := sampleTexture(normalMap, uv).scale(2).minus(Vertex3{X: 1, Y: 1, Z: 1})
+
+	return t.scale(sample.X).plus(b.scale(sample.Y)).plus(normal.scale(sample.Z)).normalize(1.0)
+}
+
+// Color of the texture at the given texture coordinates, as RGB between 0 and 1.
+func sampleTexture(texture image.Image, uv Vertex2) Vertex3 {
+	bounds := texture.Bounds()
+	tx := int(uv.X * float64(bounds.Max.X))
+	ty := int(uv.Y * float64(bounds.Max.Y))
+	r, g, b, a := texture.At(tx, ty).RGBA()
+
+	// Colors come premultiplied by their alpha.
+	if a > 0 && a < 0xffff {
+		return Vertex3{X: float64(r) / float64(a), Y: float64(g) / float64(a), Z: float64(b) / float64(a)}
+	}
+
+	return Vertex3{X: float64(r) / 0xffff, Y: float64(g) / 0xffff, Z: float64(b) / 0xffff}
+}
+
+func sampleAlpha(texture image.Image, uv Vertex2) float64 {
+	bounds := texture.Bounds()
+	tx := int(uv.X * float64(bounds.Max.X))
+	ty := int(uv.Y * float64(bounds.Max.Y))
+	_, _, _, a := texture.At(tx, ty).RGBA()
+
+	return float64(a) / 0xffff
+}
+
+// Colors outside of the displayable range get clamped.
+func toRGBA(c Vertex3) color.RGBA {
+	channel := func(v float64) uint8 {
+		return uint8(math.Max(0, math.Min(1, v))*255 + 0.5)
+	}
+
+	return color.RGBA{R: channel(c.X), G: channel(c.Y), B: channel(c.Z), A: 255}
+}
+
+// Partially transparent colors, premultiplied like image/color does.
+func toPremultipliedRGBA(c Vertex3, alpha float64) color.RGBA {
+	alpha = math.Max(0, math.Min(1, alpha))
+	if alpha == 1 {
+		return toRGBA(c)
+	}
+
+	rgba := toRGBA(c.scale(alpha))
+	rgba.A = uint8(alpha*255 + 0.5)
+	return rgba
+}
+
+func (s shading) visibility(light int, position, normal Vertex3) float64 {
+	if s.rays != nil {
+		direction, _ := s.lights[light].illuminate(position)
+
+		// Leaving from the side of the surface facing the light.
+		offset := normal.scale(s.rays.epsilon)
+		if normal.dot(direction) < 0 {
+			offset = offset.scale(-1)
+		}
+		return s.rays.transmittance(position.plus(offset), direction, lightDistance(s.lights[light], position))
+	}
+
+	if light >= len(s.shadows) || s.shadows[light] == nil {
+		return 1
+	}
+	return s.shadows[light].visibility(position, normal)
+}
+
+// Direction as a color, its coordinates mapped from -1..1 to 0..1 like in normal maps. Missing
+// ones, like tangents of meshes without texture coordinates, are black.
+func encodeDirection(d Vertex3) Vertex3 {
+	if d.length() < 1e-12 {
+		return Vertex3{}
+	}
+	return d.normalize(1.0).scale(0.5).plus(Vertex3{X: 0.5, Y: 0.5, Z: 0.5})
+}
@@ -0,0 +1,461 @@
+package renderer
+
+import (
+	"image"
+	"math"
+)
+
+// Depth of the scene as seen from a light.
+type shadowMap struct {
+	// Map from world space to the shadow map, like cameras map to the screen.
+	matrix Matrix4
+	depth  []float64
+	size   int
+	bias   float64
+	pcf    int
+	// Point of view of the light, and its size for soft shadows, none when 0.
+	camera    Camera
+	lightSize float64
+
+	// Maps of the slices of the view with cascaded shadows, the fields above being unused, nearer
+	// ones first, each one used up to its distance from the eye along the view.
+	cascades  []*shadowMap
+	distances []float64
+	eye       Vertex3
+	forward   Vertex3
+
+	// Maps of the six faces of a cube around point lights, the fields above being unused, facing
+	// +X, -X, +Y, -Y, +Z and -Z.
+	faces []*shadowMap
+	light Vertex3
+}
+
+// Largest radius of the penumbras of soft shadows, as a fraction of the size of the maps, and
+// samples on each side of their center at most, spreading out over larger penumbras.
+const (
+	softShadowMaxRadius = 1.0 / 32
+	softShadowSteps     = 6
+)
+
+// Weight of logarithmic splits of the view between cascades, against even ones. Logarithmic
+// splits keep texels the same size on screen, but leave the nearest cascades tiny.
+const cascadeSplitLog = 0.75
+
+// Shadow maps for every light of the scene able to cast shadows, nil for the others, directional
+// lights getting cascades along the view of the camera when asked for.
+func renderShadowMaps(scene *Scene, lights []Light, view Camera, aspect float64, options Options) []*shadowMap {
+	maps := make([]*shadowMap, len(lights))
+	if !options.Shadows.Enabled || options.Shadows.RayTraced {
+		return maps
+	}
+
+	min, max := scene.Flatten().bounds()
+
+	for i, light := range lights {
+		if directional, ok := light.(DirectionalLight); ok && options.Shadows.Cascades > 1 {
+			if m, ok := renderCascades(scene, directional, view, aspect, min, max, options); ok {
+				maps[i] = m
+				continue
+			}
+		}
+
+		if point, ok := light.(PointLight); ok {
+			maps[i] = renderCubeShadowMap(scene, point, min, max, options)
+			continue
+		}
+
+		camera, ok := shadowCamera(light, min, max)
+		if !ok {
+			continue
+		}
+		maps[i] = renderShadowMap(scene, camera, options)
+	}
+
+	return maps
+}
+
+// Shadow maps all around a point light, one per face of a cube. Faces see a little more than a
+// quarter turn, so that filtering doesn't run off their edges.
+func renderCubeShadowMap(scene *Scene, light PointLight, min, max Vertex3, options Options) *shadowMap {
+	center := min.plus(max).scale(0.5)
+	radius := math.Max(max.minus(min).length()/2, 1e-3)
+
+	size := options.Shadows.Resolution
+	if size <= 0 {
+		size = 1024
+	}
+	filtered := options.Shadows.PCF
+	if options.Shadows.LightSize > 0 {
+		filtered = maxInt(filtered, int(math.Ceil(softShadowMaxRadius*float64(size))))
+	}
+	margin := 1 + 2*float64(filtered+1)/float64(size)
+
+	m := &shadowMap{light: light.Position}
+	for _, direction := range cubeFaceDirections {
+		camera := NewCamera(light.Position, light.Position.plus(direction))
+		if direction.Y != 0 {
+			camera.Up = Vertex3{Z: 1}
+		}
+		camera.Fov = 2 * math.Atan(margin)
+		camera.Near = radius / 1000
+		camera.Far = light.Position.minus(center).length() + radius
+		m.faces = append(m.faces, renderShadowMap(scene, camera, options))
+	}
+
+	return m
+}
+
+// Directions the faces of cube shadow maps face.
+var cubeFaceDirections = [6]Vertex3{{X: 1}, {X: -1}, {Y: 1}, {Y: -1}, {Z: 1}, {Z: -1}}
+
+// Splits the view from the near plane to the far end of the scene into slices, each getting a
+// shadow map of its own fit around it. False when none of the scene is in front of the camera.
+func renderCascades(scene *Scene, light DirectionalLight, camera Camera, aspect float64, min, max Vertex3, options Options) (*shadowMap, bool) {
+	forward := camera.Target.minus(camera.Position).normalize(1.0)
+	corners := AABB{Min: min, Max: max}.corners()
+
+	near, far := camera.Near, 0.0
+	for _, c := range corners {
+		far = math.Max(far, c.minus(camera.Position).dot(forward))
+	}
+	far = math.Min(far, camera.Far)
+	if far <= near {
+		return nil, false
+	}
+
+	// Sides of the view, for the corners of its slices.
+	right := forward.cross(camera.Up).normalize(1.0)
+	up := right.cross(forward)
+	halfSize := func(distance float64) (float64, float64) {
+		if camera.Projection == Orthographic {
+			return camera.OrthoSize * aspect, camera.OrthoSize
+		}
+		h := distance * math.Tan(camera.Fov/2)
+		return h * aspect, h
+	}
+
+	m := &shadowMap{eye: camera.Position, forward: forward}
+	n := options.Shadows.Cascades
+	start := near
+	for i := 1; i <= n; i++ {
+		t := float64(i) / float64(n)
+		end := cascadeSplitLog*near*math.Pow(far/near, t) + (1-cascadeSplitLog)*(near+(far-near)*t)
+
+		// Sphere around the slice, the same size whichever way the camera turns.
+		var slice []Vertex3
+		for _, d := range [2]float64{start, end} {
+			w, h := halfSize(d)
+			middle := camera.Position.plus(forward.scale(d))
+			for _, s := range [4][2]float64{{-1, -1}, {1, -1}, {-1, 1}, {1, 1}} {
+				slice = append(slice, middle.plus(right.scale(s[0]*w)).plus(up.scale(s[1]*h)))
+			}
+		}
+		var center Vertex3
+		for _, c := range slice {
+			center = center.plus(c.scale(1.0 / float64(len(slice))))
+		}
+		radius := 0.0
+		for _, c := range slice {
+			radius = math.Max(radius, c.minus(center).length())
+		}
+
+		m.cascades = append(m.cascades, renderShadowMap(scene, cascadeCamera(light, center, radius, corners, options.Shadows.Resolution), options))
+		m.distances = append(m.distances, end)
+		start = end
+	}
+
+	return m, true
+}
+
+// Orthographic view of the light around the sphere, reaching back to everything in the scene that
+// could cast shadows into it. The sphere moves by whole texels, so that shadow edges don't shimmer
+// as the camera moves.
+func cascadeCamera(light DirectionalLight, center Vertex3, radius float64, corners [8]Vertex3, resolution int) Camera {
+	direction := light.Direction.normalize(1.0)
+	camera := NewCamera(Vertex3{}, direction)
+	if math.Abs(direction.Y) > 0.99 {
+		camera.Up = Vertex3{Z: 1}
+	}
+
+	if resolution > 0 {
+		right := direction.cross(camera.Up).normalize(1.0)
+		up := right.cross(direction)
+		texel := 2 * radius / float64(resolution)
+		for _, axis := range [2]Vertex3{right, up} {
+			d := center.dot(axis)
+			center = center.plus(axis.scale(math.Round(d/texel)*texel - d))
+		}
+	}
+
+	// Along the light, from the furthest back of the scene to the far side of the sphere.
+	back, front := -radius, radius
+	for _, c := range corners {
+		t := c.minus(center).dot(direction)
+		back, front = math.Min(back, t), math.Max(front, t)
+	}
+
+	camera.Position = center.plus(direction.scale(back - radius))
+	camera.Target = camera.Position.plus(direction)
+	camera.Projection = Orthographic
+	camera.OrthoSize = radius
+	camera.Near = radius
+	camera.Far = radius + front - back
+	return camera
+}
+
+// Point of view of a light over the scene's bounding box. Only directional and spot lights have
+// one, point lights seeing all around them.
+func shadowCamera(light Light, min, max Vertex3) (Camera, bool) {
+	center := min.plus(max).scale(0.5)
+	radius := math.Max(max.minus(min).length()/2, 1e-3)
+
+	var camera Camera
+	var direction Vertex3
+
+	switch l := light.(type) {
+	case DirectionalLight:
+		// An orthographic view wrapping the whole scene, from outside of it.
+		direction = l.Direction.normalize(1.0)
+		camera = NewCamera(center.minus(direction.scale(2*radius)), center)
+		camera.Projection = Orthographic
+		camera.OrthoSize = radius
+		camera.Near = radius
+		camera.Far = 3 * radius
+
+	case SpotLight:
+		direction = l.Direction.normalize(1.0)
+		camera = NewCamera(l.Position, l.Position.plus(direction))
+		camera.Fov = math.Min(2*l.OuterAngle, math.Pi*0.95)
+		camera.Near = radius / 1000
+		camera.Far = l.Position.minus(center).length() + radius
+
+	default:
+		return Camera{}, false
+	}
+
+	if math.Abs(direction.Y) > 0.99 {
+		camera.Up = Vertex3{Z: 1}
+	}
+
+	return camera, true
+}
+
+func renderShadowMap(scene *Scene, camera Camera, options Options) *shadowMap {
+	size := options.Shadows.Resolution
+	if size <= 0 {
+		size = 1024
+	}
+	rect := image.Rect(0, 0, size, size)
+
+	// Open meshes cast shadows from their back faces too.
+	o := options
+	o.BackfaceCulling = false
+	o.FrustumClipping = true
+	triangles := projectScene(scene, camera, rect, o, nil)
+
+	depth := make([]float64, size*size)
+	fillFloat64s(depth, math.Inf(-1))
+
+	// Additive surfaces only add light, they don't block any.
+	for _, triangle := range triangles {
+		if triangle.material.Blend == AdditiveBlend {
+			continue
+		}
+		drawDepth(triangle, depth, size, size)
+	}
+
+	return &shadowMap{
+		matrix: genScreenMatrix(0, 0, size, size).Multiply(camera.projectionMatrix(1)).Multiply(camera.viewMatrix()),
+		depth:  depth,
+		size:   size,
+		bias:   options.Shadows.Bias,
+		pcf:    options.Shadows.PCF,
+
+		camera:    camera,
+		lightSize: options.Shadows.LightSize,
+	}
+}
+
+// Depth-only version of drawTriangle.
+func drawDepth(triangle Triangle, zBuffer []float64, width, height int) {
+	v1, v2, v3 := triangle.points[0], triangle.points[1], triangle.points[2]
+	rule := triangle.fillRule()
+
+	min, max := triangle.pixelBounds()
+	min.X, min.Y = maxInt(min.X, 0), maxInt(min.Y, 0)
+	max.X, max.Y = minInt(max.X, width-1), minInt(max.Y, height-1)
+
+	for x := min.X; x <= max.X; x++ {
+		for y := min.Y; y <= max.Y; y++ {
+			w1, w2, w3 := barycentricAt(float64(x)+0.5, float64(y)+0.5, v1, v2, v3)
+
+			if rule.covers(w1, w2, w3) {
+				depth := w1*triangle.depths[0] + w2*triangle.depths[1] + w3*triangle.depths[2]
+				if zBuffer[width*y+x] >= depth {
+					continue
+				}
+
+				// Cutouts cast shadows shaped like what's left of them.
+				if triangle.material.AlphaCutoff > 0 {
+					p1, p2, p3 := w1*triangle.invW[0], w2*triangle.invW[1], w3*triangle.invW[2]
+					sum := p1 + p2 + p3
+					uv := triangle.face.Textures[0].scale(p1 / sum).plus(triangle.face.Textures[1].scale(p2 / sum)).plus(triangle.face.Textures[2].scale(p3 / sum))
+					if triangle.material.cutout(uv) {
+						continue
+					}
+				}
+
+				zBuffer[width*y+x] = depth
+			}
+		}
+	}
+}
+
+// Fraction of the light reaching a world space position, 0 when fully in shadow. With PCF, the
+// neighbouring texels get tested too, softening the edges of the shadows. The normal of the
+// surface only matters to soft shadows.
+func (m *shadowMap) visibility(position, normal Vertex3) float64 {
+	if m.faces != nil {
+		// The face the position is the most in front of.
+		d := position.minus(m.light)
+		best, face := math.Inf(-1), 0
+		for i, direction := range cubeFaceDirections {
+			if along := d.dot(direction); along > best {
+				best, face = along, i
+			}
+		}
+		return m.faces[face].visibility(position, normal)
+	}
+
+	if m.cascades != nil {
+		distance := position.minus(m.eye).dot(m.forward)
+		for i, d := range m.distances {
+			if distance <= d || i == len(m.distances)-1 {
+				return m.cascades[i].visibility(position, normal)
+			}
+		}
+	}
+
+	p := m.project(position)
+	if m.lightSize > 0 {
+		return m.softVisibility(position, normal, p)
+	}
+	return m.filter(p, m.pcf, 1, 0, 0)
+}
+
+// Position in the map, in texels, with its depth.
+func (m *shadowMap) project(position Vertex3) Vertex3 {
+	vertex4 := Vertex4{X: position.X, Y: position.Y, Z: position.Z, W: 1}
+	vertex4.transform(m.matrix)
+	return vertex4.lower()
+}
+
+// Fraction of the texels tested around the position that are lit, steps of them on each side,
+// step texels apart. Depths of the surface around the position change by dx and dy per texel.
+func (m *shadowMap) filter(p Vertex3, steps int, step, dx, dy float64) float64 {
+	x, y := int(p.X), int(p.Y)
+	lit, total := 0, 0
+
+	for j := -steps; j <= steps; j++ {
+		for i := -steps; i <= steps; i++ {
+			ox, oy := int(math.Round(float64(i)*step)), int(math.Round(float64(j)*step))
+			sx, sy := x+ox, y+oy
+			total++
+
+			// Outside of the map, nothing casts shadows.
+			if sx < 0 || sy < 0 || sx >= m.size || sy >= m.size || p.Z+float64(ox)*dx+float64(oy)*dy+m.bias >= m.depth[sy*m.size+sx] {
+				lit++
+			}
+		}
+	}
+
+	return float64(lit) / float64(total)
+}
+
+// Percentage-closer soft shadows: the penumbra gets wider the further the position is from what
+// blocks the light, found around it, before filtering over it.
+func (m *shadowMap) softVisibility(position, normal, p Vertex3) float64 {
+	receiver := m.camera.viewDistance(p.Z)
+	orthographic := m.camera.Projection == Orthographic
+
+	// Width of a texel at the distance of the position.
+	texel := 2 * receiver * math.Tan(m.camera.Fov/2) / float64(m.size)
+	if orthographic {
+		texel = 2 * m.camera.OrthoSize / float64(m.size)
+	}
+	// Surfaces sloping away from the light would shadow themselves over wide kernels, their
+	// depths get followed instead of staying the same.
+	dx, dy := m.receiverSlope(position, normal, p, texel)
+
+	// Blockers can only be so far to the side to stand between the position and the light.
+	search := m.lightSize / 2 / texel
+	if orthographic {
+		search = m.lightSize / 2 * (receiver - m.camera.Near) / texel
+	}
+	steps, step := m.softShadowKernel(math.Max(search, float64(m.pcf)))
+
+	x, y := int(p.X), int(p.Y)
+	sum, blockers := 0.0, 0
+	for j := -steps; j <= steps; j++ {
+		for i := -steps; i <= steps; i++ {
+			ox, oy := int(math.Round(float64(i)*step)), int(math.Round(float64(j)*step))
+			sx, sy := x+ox, y+oy
+			if sx < 0 || sy < 0 || sx >= m.size || sy >= m.size {
+				continue
+			}
+			if depth := m.depth[sy*m.size+sx]; depth > p.Z+float64(ox)*dx+float64(oy)*dy+m.bias {
+				sum += m.camera.viewDistance(depth)
+				blockers++
+			}
+		}
+	}
+	if blockers == 0 {
+		return 1
+	}
+
+	// Similar triangles between the light, the blockers and the position, the light of directional
+	// ones covering an angle.
+	blocker := sum / float64(blockers)
+	width := m.lightSize * (receiver - blocker) / blocker
+	if orthographic {
+		width = m.lightSize * (receiver - blocker)
+	}
+
+	steps, step = m.softShadowKernel(math.Max(float64(m.pcf), width/2/texel))
+	return m.filter(p, steps, step, dx, dy)
+}
+
+// Change of depth per texel along both axes of the map over the plane of the surface at the
+// position, none for surfaces seen edge on from the light.
+func (m *shadowMap) receiverSlope(position, normal, p Vertex3, texel float64) (float64, float64) {
+	if normal.length() < 1e-12 {
+		return 0, 0
+	}
+	normal = normal.normalize(1.0)
+
+	// Two directions along the surface, a texel long.
+	axis := Vertex3{X: 1}
+	if math.Abs(normal.X) > 0.9 {
+		axis = Vertex3{Y: 1}
+	}
+	u := normal.cross(axis).normalize(texel)
+	v := normal.cross(u)
+
+	pu, pv := m.project(position.plus(u)).minus(p), m.project(position.plus(v)).minus(p)
+	det := pu.X*pv.Y - pv.X*pu.Y
+	if math.Abs(det) < 1e-6 {
+		return 0, 0
+	}
+	return (pu.Z*pv.Y - pv.Z*pu.Y) / det, (pv.Z*pu.X - pu.Z*pv.X) / det
+}
+
+// Samples on each side of the center of a kernel of the radius in texels, and texels between
+// them, up to the largest radius of penumbras.
+func (m *shadowMap) softShadowKernel(radius float64) (int, float64) {
+	radius = math.Min(radius, softShadowMaxRadius*float64(m.size))
+	steps := int(math.Ceil(radius))
+	if steps <= softShadowSteps {
+		return steps, 1
+	}
+	return softShadowSteps, radius / softShadowSteps
+}
@@ -0,0 +1,95 @@
+package renderer
+
+import "testing"
+
+// Shadow map of the light over a ground plane, with a box floating above its center and two
+// standing on either side of it, viewed from above the ground in front, with the default options
+// changed by options when given.
+func shadowTestScene(light Light, options func(o *Options)) *shadowMap {
+	scene := NewScene()
+	ground := NewNode("ground")
+	ground.Mesh = NewPlane()
+	ground.Transform = NewTransform(Vertex3{}, Vertex3{}, Vertex3{X: 10, Y: 1, Z: 10})
+	scene.Root.Add(ground)
+
+	box := NewNode("box")
+	box.Mesh = NewCube()
+	box.Transform = NewTransform(Vertex3{Y: 2}, Vertex3{}, Vertex3{X: 1, Y: 1, Z: 1})
+	scene.Root.Add(box)
+	for _, x := range []float64{-2, 2} {
+		box := NewNode("box")
+		box.Mesh = NewCube()
+		box.Transform = NewTransform(Vertex3{X: x, Y: 0.5}, Vertex3{}, Vertex3{X: 0.5, Y: 1, Z: 0.5})
+		scene.Root.Add(box)
+	}
+
+	o := DefaultOptions()
+	o.Shadows.Enabled = true
+	if options != nil {
+		options(&o)
+	}
+	return renderShadowMaps(scene, []Light{light}, NewCamera(Vertex3{Y: 3, Z: 8}, Vertex3{}), 1, o)[0]
+}
+
+func TestShadowCascades(t *testing.T) {
+	m := shadowTestScene(DirectionalLight{Direction: Vertex3{Y: -1}}, func(o *Options) {
+		o.Shadows.Cascades = 3
+		o.Shadows.PCF = 0
+	})
+
+	if len(m.cascades) != 3 {
+		t.Fatalf("%d cascades", len(m.cascades))
+	}
+	for i := 1; i < len(m.distances); i++ {
+		if m.distances[i] <= m.distances[i-1] {
+			t.Errorf("cascades end at %v", m.distances)
+		}
+	}
+	if v := m.visibility(Vertex3{X: 2}, Vertex3{Y: 1}); v != 0 {
+		t.Errorf("visibility %v under the box", v)
+	}
+	if v := m.visibility(Vertex3{X: 2, Z: 2}, Vertex3{Y: 1}); v != 1 {
+		t.Errorf("visibility %v beside the box", v)
+	}
+}
+
+func TestPointLightShadows(t *testing.T) {
+	// Between the standing boxes, shadows going opposite ways.
+	light := PointLight{Position: Vertex3{Y: 0.5}, Intensity: 1, Constant: 1}
+	m := shadowTestScene(light, func(o *Options) {
+		o.Shadows.PCF = 0
+	})
+	if m == nil {
+		t.Fatal("no shadow map for the point light")
+	}
+
+	for _, p := range []Vertex3{{X: 3}, {X: -3}} {
+		if v := m.visibility(p, Vertex3{Y: 1}); v != 0 {
+			t.Errorf("visibility %v at %v behind a box", v, p)
+		}
+	}
+	for _, p := range []Vertex3{{Z: 3}, {Z: -3}, {X: 1}} {
+		if v := m.visibility(p, Vertex3{Y: 1}); v != 1 {
+			t.Errorf("visibility %v at %v in the open", v, p)
+		}
+	}
+}
+
+func TestSoftShadows(t *testing.T) {
+	m := shadowTestScene(DirectionalLight{Direction: Vertex3{Y: -1}, Intensity: 1}, func(o *Options) {
+		o.Shadows.Resolution = 512
+		o.Shadows.LightSize = 0.5
+	})
+
+	// Under the floating box, its shadow blurring out from its edges.
+	up := Vertex3{Y: 1}
+	if v := m.visibility(Vertex3{}, up); v != 0 {
+		t.Errorf("visibility %v in the umbra", v)
+	}
+	if v := m.visibility(Vertex3{X: 0.5}, up); v <= 0.2 || v >= 0.8 {
+		t.Errorf("visibility %v in the penumbra", v)
+	}
+	if v := m.visibility(Vertex3{Z: 3}, up); v != 1 {
+		t.Errorf("visibility %v in the open", v)
+	}
+}
@@ -0,0 +1,33 @@
+package renderer
+
+import "image"
+
+// Batch versions of the hot loops of the pipeline, with vectorized implementations where the
+// platform has them (see simd_amd64.go). These are the portable versions, which the vectorized
+// ones match bit for bit.
+
+// Transforms the vertices in place, like Vertex4.transform does for a single one.
+func transformVerticesGeneric(m Matrix4, vertices []Vertex4) {
+	for i := range vertices {
+		vertices[i].transform(m)
+	}
+}
+
+func fillFloat64sGeneric(dst []float64, v float64) {
+	for i := range dst {
+		dst[i] = v
+	}
+}
+
+func fillRGBAGeneric(pix []uint8, r, g, b, a uint8) {
+	for i := 0; i+3 < len(pix); i += 4 {
+		pix[i], pix[i+1], pix[i+2], pix[i+3] = r, g, b, a
+	}
+}
+
+// Barycentric weights of consecutive pixels of a row, starting from x.
+func barycentricRowGeneric(w1, w2, w3 []float64, x, y float64, p1, p2, p3 image.Point) {
+	for i := range w1 {
+		w1[i], w2[i], w3[i] = barycentricAt(x+float64(i), y, p1, p2, p3)
+	}
+}
@@ -0,0 +1,121 @@
+//go:build amd64 && !purego
+
+package renderer
+
+import (
+	"image"
+	"image/color"
+	"unsafe"
+)
+
+// AVX2 works on 4 float64 or 8 uint32 at once. Operations are done in the same order as in the
+// portable versions, without fused multiply-adds, so that results are identical.
+var useAVX2 = hasAVX2()
+
+//go:noescape
+func cpuid(eaxArg, ecxArg uint32) (eax, ebx, ecx, edx uint32)
+
+//go:noescape
+func xgetbv() (eax, edx uint32)
+
+//go:noescape
+func transformVerticesAVX2(columns *Matrix4, vertices *Vertex4, n int)
+
+//go:noescape
+func fillFloat64sAVX2(dst *float64, n int, v float64)
+
+//go:noescape
+func fillUint32sAVX2(dst *uint32, n int, v uint32)
+
+//go:noescape
+func barycentricRowAVX2(row *edgeRow, w1, w2, w3 *float64, n int)
+
+// Coefficients of the barycentric weights along a row of pixels, laid out for the assembly.
+type edgeRow struct {
+	x     float64 // First pixel of the row.
+	v3x   float64
+	a1    float64 // Weight 1 is (a1 * (x - v3x) + b1) / denominator...
+	b1    float64
+	a2    float64 // ...and weight 2 is (a2 * (x - v3x) + b2) / denominator.
+	b2    float64
+	denom float64
+}
+
+func hasAVX2() bool {
+	maxID, _, _, _ := cpuid(0, 0)
+	if maxID < 7 {
+		return false
+	}
+
+	// The CPU supports AVX and the OS saves the YMM registers.
+	_, _, ecx1, _ := cpuid(1, 0)
+	if ecx1&(1<<27) == 0 || ecx1&(1<<28) == 0 {
+		return false
+	}
+	if xcr0, _ := xgetbv(); xcr0&6 != 6 {
+		return false
+	}
+
+	_, ebx7, _, _ := cpuid(7, 0)
+	return ebx7&(1<<5) != 0
+}
+
+func transformVertices(m Matrix4, vertices []Vertex4) {
+	if !useAVX2 || len(vertices) == 0 {
+		transformVerticesGeneric(m, vertices)
+		return
+	}
+
+	// Columns are contiguous in the transpose, ready to be scaled by each component.
+	columns := m.Transpose()
+	transformVerticesAVX2(&columns, &vertices[0], len(vertices))
+}
+
+func fillFloat64s(dst []float64, v float64) {
+	n := len(dst) &^ 3
+	if !useAVX2 || n == 0 {
+		fillFloat64sGeneric(dst, v)
+		return
+	}
+
+	fillFloat64sAVX2(&dst[0], n, v)
+	fillFloat64sGeneric(dst[n:], v)
+}
+
+func fillRGBA(pix []uint8, c color.RGBA) {
+	n := len(pix) / 4 &^ 7
+	if !useAVX2 || n == 0 {
+		fillRGBAGeneric(pix, c.R, c.G, c.B, c.A)
+		return
+	}
+
+	// Little endian, so R comes first in memory.
+	v := uint32(c.R) | uint32(c.G)<<8 | uint32(c.B)<<16 | uint32(c.A)<<24
+	fillUint32sAVX2((*uint32)(unsafe.Pointer(&pix[0])), n, v)
+	fillRGBAGeneric(pix[n*4:], c.R, c.G, c.B, c.A)
+}
+
+func barycentricRow(w1, w2, w3 []float64, x, y float64, p1, p2, p3 image.Point) {
+	n := len(w1) &^ 3
+	if !useAVX2 || n == 0 {
+		barycentricRowGeneric(w1, w2, w3, x, y, p1, p2, p3)
+		return
+	}
+
+	// Same terms as barycentricAt, the ones not depending on x computed once.
+	v1 := Vertex3{X: float64(p1.X) / subpixelScale, Y: float64(p1.Y) / subpixelScale}
+	v2 := Vertex3{X: float64(p2.X) / subpixelScale, Y: float64(p2.Y) / subpixelScale}
+	v3 := Vertex3{X: float64(p3.X) / subpixelScale, Y: float64(p3.Y) / subpixelScale}
+	row := edgeRow{
+		x:     x,
+		v3x:   v3.X,
+		a1:    v2.Y - v3.Y,
+		b1:    (v3.X - v2.X) * (y - v3.Y),
+		a2:    v3.Y - v1.Y,
+		b2:    (v1.X - v3.X) * (y - v3.Y),
+		denom: (v2.Y-v3.Y)*(v1.X-v3.X) + (v3.X-v2.X)*(v1.Y-v3.Y),
+	}
+
+	barycentricRowAVX2(&row, &w1[0], &w2[0], &w3[0], n)
+	barycentricRowGeneric(w1[n:], w2[n:], w3[n:], x+float64(n), y, p1, p2, p3)
+}
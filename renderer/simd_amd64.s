@@ -0,0 +1,149 @@
+//go:build amd64 && !purego
+
+#include "textflag.h"
+
+// func cpuid(eaxArg, ecxArg uint32) (eax, ebx, ecx, edx uint32)
+TEXT ·cpuid(SB), NOSPLIT, $0-24
+	MOVL eaxArg+0(FP), AX
+	MOVL ecxArg+4(FP), CX
+	CPUID
+	MOVL AX, eax+8(FP)
+	MOVL BX, ebx+12(FP)
+	MOVL CX, ecx+16(FP)
+	MOVL DX, edx+20(FP)
+	RET
+
+// func xgetbv() (eax, edx uint32)
+TEXT ·xgetbv(SB), NOSPLIT, $0-8
+	MOVL $0, CX
+	XGETBV
+	MOVL AX, eax+0(FP)
+	MOVL DX, edx+4(FP)
+	RET
+
+// func transformVerticesAVX2(columns *Matrix4, vertices *Vertex4, n int)
+TEXT ·transformVerticesAVX2(SB), NOSPLIT, $0-24
+	MOVQ columns+0(FP), AX
+	MOVQ vertices+8(FP), SI
+	MOVQ n+16(FP), CX
+
+	VMOVUPD 0(AX), Y0
+	VMOVUPD 32(AX), Y1
+	VMOVUPD 64(AX), Y2
+	VMOVUPD 96(AX), Y3
+
+loop:
+	// ((c1 * x + c2 * y) + c3 * z) + c4 * w
+	VBROADCASTSD 0(SI), Y4
+	VMULPD       Y0, Y4, Y4
+	VBROADCASTSD 8(SI), Y5
+	VMULPD       Y1, Y5, Y5
+	VADDPD       Y5, Y4, Y4
+	VBROADCASTSD 16(SI), Y5
+	VMULPD       Y2, Y5, Y5
+	VADDPD       Y5, Y4, Y4
+	VBROADCASTSD 24(SI), Y5
+	VMULPD       Y3, Y5, Y5
+	VADDPD       Y5, Y4, Y4
+	VMOVUPD      Y4, 0(SI)
+
+	ADDQ $32, SI
+	DECQ CX
+	JNZ  loop
+
+	VZEROUPPER
+	RET
+
+// func fillFloat64sAVX2(dst *float64, n int, v float64)
+TEXT ·fillFloat64sAVX2(SB), NOSPLIT, $0-24
+	MOVQ         dst+0(FP), DI
+	MOVQ         n+8(FP), CX
+	VBROADCASTSD v+16(FP), Y0
+	SHRQ         $2, CX
+
+loop:
+	VMOVUPD Y0, 0(DI)
+	ADDQ    $32, DI
+	DECQ    CX
+	JNZ     loop
+
+	VZEROUPPER
+	RET
+
+// func fillUint32sAVX2(dst *uint32, n int, v uint32)
+TEXT ·fillUint32sAVX2(SB), NOSPLIT, $0-20
+	MOVQ         dst+0(FP), DI
+	MOVQ         n+8(FP), CX
+	MOVL         v+16(FP), AX
+	VMOVD        AX, X0
+	VPBROADCASTD X0, Y0
+	SHRQ         $3, CX
+
+loop:
+	VMOVDQU Y0, 0(DI)
+	ADDQ    $32, DI
+	DECQ    CX
+	JNZ     loop
+
+	VZEROUPPER
+	RET
+
+DATA lanes<>+0(SB)/8, $0.0
+DATA lanes<>+8(SB)/8, $1.0
+DATA lanes<>+16(SB)/8, $2.0
+DATA lanes<>+24(SB)/8, $3.0
+GLOBL lanes<>(SB), RODATA|NOPTR, $32
+
+DATA four<>+0(SB)/8, $4.0
+GLOBL four<>(SB), RODATA|NOPTR, $8
+
+// func barycentricRowAVX2(row *edgeRow, w1, w2, w3 *float64, n int)
+TEXT ·barycentricRowAVX2(SB), NOSPLIT, $0-40
+	MOVQ row+0(FP), AX
+	MOVQ w1+8(FP), DI
+	MOVQ w2+16(FP), SI
+	MOVQ w3+24(FP), DX
+	MOVQ n+32(FP), CX
+	SHRQ $2, CX
+
+	// Pixel x coordinates of the four lanes.
+	VBROADCASTSD 0(AX), Y0
+	VADDPD       lanes<>(SB), Y0, Y0
+	VBROADCASTSD four<>(SB), Y1
+
+	VBROADCASTSD 8(AX), Y2  // v3x
+	VBROADCASTSD 16(AX), Y3 // a1
+	VBROADCASTSD 24(AX), Y4 // b1
+	VBROADCASTSD 32(AX), Y5 // a2
+	VBROADCASTSD 40(AX), Y6 // b2
+	VBROADCASTSD 48(AX), Y7 // denominator
+
+loop:
+	VSUBPD Y2, Y0, Y9 // x - v3x
+
+	VMULPD Y3, Y9, Y10
+	VADDPD Y4, Y10, Y10
+
+	VMULPD Y5, Y9, Y11
+	VADDPD Y6, Y11, Y11
+
+	// (denominator - w1) - w2, before dividing all three.
+	VSUBPD Y10, Y7, Y12
+	VSUBPD Y11, Y12, Y12
+
+	VDIVPD  Y7, Y10, Y10
+	VMOVUPD Y10, 0(DI)
+	VDIVPD  Y7, Y11, Y11
+	VMOVUPD Y11, 0(SI)
+	VDIVPD  Y7, Y12, Y12
+	VMOVUPD Y12, 0(DX)
+
+	VADDPD Y1, Y0, Y0
+	ADDQ   $32, DI
+	ADDQ   $32, SI
+	ADDQ   $32, DX
+	DECQ   CX
+	JNZ    loop
+
+	VZEROUPPER
+	RET
@@ -0,0 +1,24 @@
+//go:build !amd64 || purego
+
+package renderer
+
+import (
+	"image"
+	"image/color"
+)
+
+func transformVertices(m Matrix4, vertices []Vertex4) {
+	transformVerticesGeneric(m, vertices)
+}
+
+func fillFloat64s(dst []float64, v float64) {
+	fillFloat64sGeneric(dst, v)
+}
+
+func fillRGBA(pix []uint8, c color.RGBA) {
+	fillRGBAGeneric(pix, c.R, c.G, c.B, c.A)
+}
+
+func barycentricRow(w1, w2, w3 []float64, x, y float64, p1, p2, p3 image.Point) {
+	barycentricRowGeneric(w1, w2, w3, x, y, p1, p2, p3)
+}
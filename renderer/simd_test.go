@@ -0,0 +1,81 @@
+package renderer
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// The vectorized loops have to match the portable ones bit for bit: the fill rule tells which
+// triangle owns a pixel on a shared edge from weights being exactly zero.
+func TestSIMDMatchesGeneric(t *testing.T) {
+	random := rand.New(rand.NewSource(3))
+	same := func(a, b float64) bool {
+		return math.Float64bits(a) == math.Float64bits(b)
+	}
+
+	for i := 0; i < 200; i++ {
+		n := random.Intn(40)
+
+		var rows [16]float64
+		for k := range rows {
+			rows[k] = random.NormFloat64() * 10
+		}
+		m := Matrix4FromRows(rows)
+		vertices := make([]Vertex4, n)
+		for k := range vertices {
+			vertices[k] = Vertex4{X: random.NormFloat64() * 100, Y: random.NormFloat64() * 100, Z: random.NormFloat64(), W: 1}
+		}
+		want := append([]Vertex4{}, vertices...)
+		transformVerticesGeneric(m, want)
+		transformVertices(m, vertices)
+		for k := range vertices {
+			got, w := vertices[k], want[k]
+			if !same(got.X, w.X) || !same(got.Y, w.Y) || !same(got.Z, w.Z) || !same(got.W, w.W) {
+				t.Fatalf("vertex %d of %d transformed to %v, want %v", k, n, got, w)
+			}
+		}
+
+		// Corners on the sub-pixel grid, some pixel centers falling right on edges.
+		var p [3]image.Point
+		for k := range p {
+			p[k] = image.Point{X: random.Intn(64 * subpixelScale), Y: random.Intn(64 * subpixelScale)}
+		}
+		x, y := float64(random.Intn(64))+0.5, float64(random.Intn(64))+0.5
+		w := [6][]float64{}
+		for k := range w {
+			w[k] = make([]float64, n)
+		}
+		barycentricRowGeneric(w[0], w[1], w[2], x, y, p[0], p[1], p[2])
+		barycentricRow(w[3], w[4], w[5], x, y, p[0], p[1], p[2])
+		for k := 0; k < n; k++ {
+			for j := 0; j < 3; j++ {
+				if !same(w[j][k], w[j+3][k]) {
+					t.Fatalf("%v: weight %d at x=%g is %v, want %v", p, j+1, x+float64(k), w[j+3][k], w[j][k])
+				}
+			}
+		}
+
+		depths, wantDepths := make([]float64, n), make([]float64, n)
+		v := random.Float64()
+		fillFloat64sGeneric(wantDepths, v)
+		fillFloat64s(depths, v)
+		for k := range depths {
+			if !same(depths[k], wantDepths[k]) {
+				t.Fatalf("depth %d of %d filled with %v, want %v", k, n, depths[k], v)
+			}
+		}
+
+		c := color.RGBA{R: uint8(random.Intn(256)), G: uint8(random.Intn(256)), B: uint8(random.Intn(256)), A: uint8(random.Intn(256))}
+		pix, wantPix := make([]uint8, 4*n), make([]uint8, 4*n)
+		fillRGBAGeneric(wantPix, c.R, c.G, c.B, c.A)
+		fillRGBA(pix, c)
+		for k := range pix {
+			if pix[k] != wantPix[k] {
+				t.Fatalf("byte %d of %d pixels filled with %d, want %d", k, n, pix[k], wantPix[k])
+			}
+		}
+	}
+}
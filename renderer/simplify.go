@@ -0,0 +1,317 @@
+package renderer
+
+import (
+	"container/heap"
+	"math"
+)
+
+// Sum of squared distances to a set of planes ax + by + cz + d = 0, as the upper half of the
+// symmetric 4x4 matrix of Garland and Heckbert: a², ab, ac, ad, b², bc, bd, c², cd, d².
+type quadric [10]float64
+
+// Borders of open meshes are held in place by planes perpendicular to them, weighted this much
+// more than the faces, otherwise holes would grow as the mesh gets simplified.
+const boundaryWeight = 100
+
+func planeQuadric(normal Vertex3, d, weight float64) quadric {
+	a, b, c := normal.X, normal.Y, normal.Z
+	return quadric{
+		a * a * weight, a * b * weight, a * c * weight, a * d * weight,
+		b * b * weight, b * c * weight, b * d * weight,
+		c * c * weight, c * d * weight,
+		d * d * weight,
+	}
+}
+
+func (q quadric) plus(o quadric) quadric {
+	for i := range q {
+		q[i] += o[i]
+	}
+	return q
+}
+
+func (q quadric) error(v Vertex3) float64 {
+	return q[0]*v.X*v.X + 2*q[1]*v.X*v.Y + 2*q[2]*v.X*v.Z + 2*q[3]*v.X +
+		q[4]*v.Y*v.Y + 2*q[5]*v.Y*v.Z + 2*q[6]*v.Y +
+		q[7]*v.Z*v.Z + 2*q[8]*v.Z +
+		q[9]
+}
+
+// The point of least error, where the gradient vanishes. Flat or straight neighbourhoods don't
+// have a single one.
+func (q quadric) optimum() (Vertex3, bool) {
+	a := [3][3]float64{{q[0], q[1], q[2]}, {q[1], q[4], q[5]}, {q[2], q[5], q[7]}}
+	b := [3]float64{-q[3], -q[6], -q[8]}
+
+	det := determinant3(a)
+	if math.Abs(det) < 1e-12 {
+		return Vertex3{}, false
+	}
+
+	// Cramer's rule.
+	var x [3]float64
+	for i := range x {
+		m := a
+		for j := 0; j < 3; j++ {
+			m[j][i] = b[j]
+		}
+		x[i] = determinant3(m) / det
+	}
+
+	return Vertex3{X: x[0], Y: x[1], Z: x[2]}, true
+}
+
+func determinant3(m [3][3]float64) float64 {
+	return m[0][0]*(m[1][1]*m[2][2]-m[1][2]*m[2][1]) -
+		m[0][1]*(m[1][0]*m[2][2]-m[1][2]*m[2][0]) +
+		m[0][2]*(m[1][0]*m[2][1]-m[1][1]*m[2][0])
+}
+
+// Merging two vertices into one, at the position adding the least error.
+type collapse struct {
+	cost     float64
+	a, b     int
+	position Vertex3
+	// Versions of the vertices when the collapse was computed, it's stale once either changed.
+	versionA, versionB int
+}
+
+type collapseQueue []collapse
+
+func (q collapseQueue) Len() int { return len(q) }
+func (q collapseQueue) Less(i, j int) bool {
+	// Ties are broken by vertex, so that the result doesn't depend on the order of the edges.
+	if q[i].cost != q[j].cost {
+		return q[i].cost < q[j].cost
+	}
+	if q[i].a != q[j].a {
+		return q[i].a < q[j].a
+	}
+	return q[i].b < q[j].b
+}
+func (q collapseQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *collapseQueue) Push(x interface{}) { *q = append(*q, x.(collapse)) }
+func (q *collapseQueue) Pop() interface{} {
+	old := *q
+	c := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return c
+}
+
+// Reduces the mesh to about the given number of faces by collapsing the edges that change its
+// shape the least first. Faces keep the texture coordinates, normals and colors of their corners.
+func simplify(obj *Obj, target int) *Obj {
+	// Faces only know about positions, so corners in the same place are taken as the same vertex.
+	ids := map[Vertex3]int{}
+	var positions []Vertex3
+	faces := make([][3]int, len(obj.Faces))
+	for k, face := range obj.Faces {
+		for i, v := range face.Vertices {
+			id, ok := ids[v]
+			if !ok {
+				id = len(positions)
+				ids[v] = id
+				positions = append(positions, v)
+			}
+			faces[k][i] = id
+		}
+	}
+
+	quadrics := make([]quadric, len(positions))
+	adjacency := make([][]int, len(positions))
+	edges := map[[2]int]int{}
+
+	for k, f := range faces {
+		p0, p1, p2 := positions[f[0]], positions[f[1]], positions[f[2]]
+		normal := p1.minus(p0).cross(p2.minus(p0))
+		area := normal.length() / 2
+
+		for i, id := range f {
+			adjacency[id] = append(adjacency[id], k)
+			edges[edgeKey(id, f[(i+1)%3])]++ // Faces sharing every edge.
+		}
+
+		if area == 0 {
+			continue
+		}
+		normal = normal.normalize(1.0)
+		q := planeQuadric(normal, -normal.dot(p0), area)
+		for _, id := range f {
+			quadrics[id] = quadrics[id].plus(q)
+		}
+	}
+
+	for _, f := range faces {
+		p0, p1, p2 := positions[f[0]], positions[f[1]], positions[f[2]]
+		normal := p1.minus(p0).cross(p2.minus(p0))
+		if normal.length() == 0 {
+			continue
+		}
+
+		for i := range f {
+			a, b := f[i], f[(i+1)%3]
+			if edges[edgeKey(a, b)] != 1 {
+				continue
+			}
+
+			edge := positions[b].minus(positions[a])
+			length := edge.length()
+			if length == 0 {
+				continue
+			}
+			side := edge.cross(normal).normalize(1.0)
+			q := planeQuadric(side, -side.dot(positions[a]), boundaryWeight*length*length)
+			quadrics[a] = quadrics[a].plus(q)
+			quadrics[b] = quadrics[b].plus(q)
+		}
+	}
+
+	versions := make([]int, len(positions))
+	removed := make([]bool, len(positions))
+	alive := make([]bool, len(faces))
+	for k := range alive {
+		alive[k] = true
+	}
+
+	newCollapse := func(a, b int) collapse {
+		q := quadrics[a].plus(quadrics[b])
+		c := collapse{a: a, b: b, versionA: versions[a], versionB: versions[b]}
+
+		// Nearly singular systems throw the optimum far away.
+		middle := positions[a].lerp(positions[b], 0.5)
+		if position, ok := q.optimum(); ok && position.minus(middle).length() <= positions[b].minus(positions[a]).length() {
+			c.position, c.cost = position, q.error(position)
+			return c
+		}
+
+		// Otherwise, the best of the ends and the middle of the edge.
+		c.cost = math.Inf(1)
+		for _, position := range []Vertex3{positions[a], positions[b], middle} {
+			if cost := q.error(position); cost < c.cost {
+				c.position, c.cost = position, cost
+			}
+		}
+		return c
+	}
+
+	queue := &collapseQueue{}
+	for edge := range edges {
+		*queue = append(*queue, newCollapse(edge[0], edge[1]))
+	}
+	heap.Init(queue)
+
+	count := len(faces)
+	for count > target && queue.Len() > 0 {
+		c := heap.Pop(queue).(collapse)
+		if removed[c.a] || removed[c.b] || versions[c.a] != c.versionA || versions[c.b] != c.versionB {
+			continue
+		}
+
+		if flipsFaces(c, faces, alive, adjacency, positions) {
+			continue
+		}
+
+		// b goes away, its faces now go to a unless they had both.
+		positions[c.a] = c.position
+		quadrics[c.a] = quadrics[c.a].plus(quadrics[c.b])
+		removed[c.b] = true
+		versions[c.a]++
+
+		for _, k := range adjacency[c.b] {
+			if !alive[k] {
+				continue
+			}
+
+			f := &faces[k]
+			if f[0] == c.a || f[1] == c.a || f[2] == c.a {
+				alive[k] = false
+				count--
+				continue
+			}
+
+			for i := range f {
+				if f[i] == c.b {
+					f[i] = c.a
+				}
+			}
+			adjacency[c.a] = append(adjacency[c.a], k)
+		}
+		adjacency[c.b] = nil
+
+		// The edges around a have to be weighed again.
+		neighbours := map[int]bool{}
+		faceIds := adjacency[c.a][:0]
+		for _, k := range adjacency[c.a] {
+			if !alive[k] {
+				continue
+			}
+			faceIds = append(faceIds, k)
+			for _, id := range faces[k] {
+				if id != c.a && !neighbours[id] {
+					neighbours[id] = true
+					heap.Push(queue, newCollapse(c.a, id))
+				}
+			}
+		}
+		adjacency[c.a] = faceIds
+	}
+
+	simplified := &Obj{Materials: obj.Materials}
+	for k, f := range faces {
+		if !alive[k] {
+			continue
+		}
+
+		face := obj.Faces[k]
+		for i, id := range f {
+			face.Vertices[i] = positions[id]
+		}
+		simplified.Faces = append(simplified.Faces, face)
+	}
+	simplified.Bounds = simplified.aabb()
+
+	return simplified
+}
+
+func edgeKey(a, b int) [2]int {
+	if a > b {
+		a, b = b, a
+	}
+	return [2]int{a, b}
+}
+
+// Whether moving the ends of the edge to the collapse position would turn faces around, folding
+// the mesh over itself.
+func flipsFaces(c collapse, faces [][3]int, alive []bool, adjacency [][]int, positions []Vertex3) bool {
+	for _, id := range [2]int{c.a, c.b} {
+		for _, k := range adjacency[id] {
+			f := faces[k]
+			if !alive[k] {
+				continue
+			}
+
+			// Faces along the edge disappear.
+			hasA := f[0] == c.a || f[1] == c.a || f[2] == c.a
+			hasB := f[0] == c.b || f[1] == c.b || f[2] == c.b
+			if hasA && hasB {
+				continue
+			}
+
+			var before, after [3]Vertex3
+			for i, v := range f {
+				before[i], after[i] = positions[v], positions[v]
+				if v == id {
+					after[i] = c.position
+				}
+			}
+
+			n1 := before[1].minus(before[0]).cross(before[2].minus(before[0]))
+			n2 := after[1].minus(after[0]).cross(after[2].minus(after[0]))
+			if n1.dot(n2) <= 0 {
+				return true
+			}
+		}
+	}
+
+	return false
+}
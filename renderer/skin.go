@@ -0,0 +1,75 @@
+package renderer
+
+// Joints moving a vertex of a skinned mesh, up to four, and how much each one does.
+type JointWeights struct {
+	// Indices in the joints of the skin.
+	Joints [4]int
+	// Adding up to 1.
+	Weights [4]float64
+}
+
+// Skeleton deforming a mesh, every joint being a node of the scene that drags the vertices bound
+// to it along as it moves.
+type Skin struct {
+	Joints []*Node
+	// Transforms from the space of the mesh to the space of every joint, as they were when the
+	// mesh got bound to them.
+	InverseBindMatrices []Matrix4
+}
+
+// The mesh in world space, every vertex moved by the blend of the transforms of its joints since
+// the bind pose. Done on the CPU before rasterization, like GPUs do in vertex shaders.
+func (s *Skin) deform(obj *Obj, worlds map[*Node]Matrix4) *Obj {
+	joints := make([]Matrix4, len(s.Joints))
+	for i, joint := range s.Joints {
+		joints[i] = worlds[joint]
+		if i < len(s.InverseBindMatrices) {
+			joints[i] = joints[i].Multiply(s.InverseBindMatrices[i])
+		}
+	}
+
+	skinned := &Obj{Faces: make([]Face, len(obj.Faces)), Materials: obj.Materials, Weights: obj.Weights}
+	for k, face := range obj.Faces {
+		for i := 0; i < 3; i++ {
+			m := blendJoints(joints, obj.Weights[k][i])
+			face.Vertices[i] = m.transformPoint(face.Vertices[i])
+			// Joints rarely scale, let alone non-uniformly, sparing an inverse per vertex.
+			if n := m.transformDirection(face.Normals[i]); n.length() > 0 {
+				face.Normals[i] = n.normalize(1.0)
+			}
+			face.Tangents[i] = transformTangent(m, face.Tangents[i])
+		}
+		skinned.Faces[k] = face
+	}
+	skinned.Bounds = skinned.aabb()
+
+	return skinned
+}
+
+// Linear blend skinning, the weighted sum of the transforms of the joints.
+func blendJoints(joints []Matrix4, weights JointWeights) Matrix4 {
+	var m Matrix4
+	total := 0.0
+	for i, joint := range weights.Joints {
+		w := weights.Weights[i]
+		if w == 0 || joint < 0 || joint >= len(joints) {
+			continue
+		}
+		m = m.plusScaled(joints[joint], w)
+		total += w
+	}
+
+	if total == 0 {
+		return Identity4()
+	}
+	return m
+}
+
+func (m Matrix4) plusScaled(o Matrix4, s float64) Matrix4 {
+	return Matrix4{
+		m.m11 + o.m11*s, m.m12 + o.m12*s, m.m13 + o.m13*s, m.m14 + o.m14*s,
+		m.m21 + o.m21*s, m.m22 + o.m22*s, m.m23 + o.m23*s, m.m24 + o.m24*s,
+		m.m31 + o.m31*s, m.m32 + o.m32*s, m.m33 + o.m33*s, m.m34 + o.m34*s,
+		m.m41 + o.m41*s, m.m42 + o.m42*s, m.m43 + o.m43*s, m.m44 + o.m44*s,
+	}
+}
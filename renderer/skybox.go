@@ -0,0 +1,120 @@
+package renderer
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"math"
+)
+
+type SkyboxLayout int
+
+const (
+	// Latitude-longitude image, twice as wide as it is high, like environment maps.
+	EquirectangularLayout SkyboxLayout = iota
+	// Cube map unfolded as a horizontal cross, four faces wide and three faces high:
+	//
+	//	     +Y
+	//	-X   -Z   +X   +Z
+	//	     -Y
+	//
+	// so that the default view, towards -Z, looks at the center of the cross.
+	CubeCrossLayout
+)
+
+// Distant surroundings, drawn behind everything else as if infinitely far away.
+type Skybox struct {
+	Image  *hdrImage
+	Layout SkyboxLayout
+}
+
+// The layout is guessed from the proportions of the image.
+func LoadSkybox(filename string) (*Skybox, error) {
+	img, err := loadHDRImage(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case img.width == 2*img.height:
+		return &Skybox{Image: img, Layout: EquirectangularLayout}, nil
+	case img.width*3 == img.height*4 && img.width%4 == 0:
+		return &Skybox{Image: img, Layout: CubeCrossLayout}, nil
+	}
+
+	return nil, errors.New(fmt.Sprintf("%s is neither an equirectangular (2:1) nor a cube cross (4:3) image", filename))
+}
+
+// Color seen in a direction.
+func (s *Skybox) sample(direction Vertex3) Vertex3 {
+	if s.Layout == EquirectangularLayout {
+		return s.Image.sample(equirectangularUV(direction))
+	}
+
+	// The axis the direction points the most along picks the face, the two others give the position on it.
+	x, y, z := math.Abs(direction.X), math.Abs(direction.Y), math.Abs(direction.Z)
+	var cell image.Point
+	var u, v float64
+
+	switch {
+	case z >= x && z >= y && direction.Z < 0:
+		cell, u, v = image.Point{X: 1, Y: 1}, direction.X/z, direction.Y/z
+	case z >= x && z >= y:
+		cell, u, v = image.Point{X: 3, Y: 1}, -direction.X/z, direction.Y/z
+	case x >= y && direction.X > 0:
+		cell, u, v = image.Point{X: 2, Y: 1}, direction.Z/x, direction.Y/x
+	case x >= y:
+		cell, u, v = image.Point{X: 0, Y: 1}, -direction.Z/x, direction.Y/x
+	case direction.Y > 0:
+		cell, u, v = image.Point{X: 1, Y: 0}, direction.X/y, direction.Z/y
+	default:
+		cell, u, v = image.Point{X: 1, Y: 2}, direction.X/y, -direction.Z/y
+	}
+
+	// Bilinear filtering, without crossing over to the neighbouring faces.
+	size := s.Image.width / 4
+	fx := (u+1)/2*float64(size) - 0.5
+	fy := (1-v)/2*float64(size) - 0.5
+	x0, y0 := math.Floor(fx), math.Floor(fy)
+
+	texel := func(x, y int) Vertex3 {
+		x = minInt(maxInt(x, 0), size-1)
+		y = minInt(maxInt(y, 0), size-1)
+		return s.Image.at(cell.X*size+x, cell.Y*size+y)
+	}
+
+	top := texel(int(x0), int(y0)).lerp(texel(int(x0)+1, int(y0)), fx-x0)
+	bottom := texel(int(x0), int(y0)+1).lerp(texel(int(x0)+1, int(y0)+1), fx-x0)
+
+	return top.lerp(bottom, fy-y0)
+}
+
+// Fills the image with the sky seen through every pixel, before anything else gets drawn.
+func drawSkybox(img *image.RGBA, hdr *hdrImage, skybox *Skybox, camera Camera) {
+	rect := img.Bounds()
+	aspect := float64(rect.Dx()) / float64(rect.Dy())
+
+	// From clip space back to world space.
+	inverse, ok := camera.projectionMatrix(aspect).Multiply(camera.viewMatrix()).Inverse()
+	if !ok {
+		return
+	}
+
+	for y := 0; y < rect.Dy(); y++ {
+		for x := 0; x < rect.Dx(); x++ {
+			ndcX := (float64(x)+0.5)/float64(rect.Dx())*2 - 1
+			ndcY := (float64(y)+0.5)/float64(rect.Dy())*2 - 1
+
+			near := Vertex4{X: ndcX, Y: ndcY, Z: 1, W: 1}
+			far := Vertex4{X: ndcX, Y: ndcY, Z: -1, W: 1}
+			near.transform(inverse)
+			far.transform(inverse)
+
+			c := skybox.sample(far.lower().minus(near.lower()))
+			img.Set(x, y, toRGBA(linearToSRGB(c)))
+			if hdr != nil {
+				hdr.set(x, y, c)
+			}
+		}
+	}
+}
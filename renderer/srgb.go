@@ -0,0 +1,72 @@
+package renderer
+
+import (
+	"image/color"
+	"math"
+)
+
+// Light adds up in linear space, where lighting gets computed, but images store colors in sRGB,
+// which spends more of the 8 bits of a channel on dark shades, like eyes do. Textures and LDR
+// images get decoded from sRGB when read, and lit colors encoded to it when written.
+
+// Entries of the conversion tables, interpolated in between.
+const srgbTableSize = 4096
+
+var linearToSRGBTable, srgbToLinearTable = srgbTables()
+
+func srgbTables() (encode, decode *[srgbTableSize + 1]float64) {
+	encode, decode = new([srgbTableSize + 1]float64), new([srgbTableSize + 1]float64)
+
+	for i := range encode {
+		v := float64(i) / srgbTableSize
+
+		if v <= 0.0031308 {
+			encode[i] = v * 12.92
+		} else {
+			encode[i] = 1.055*math.Pow(v, 1/2.4) - 0.055
+		}
+
+		if v <= 0.04045 {
+			decode[i] = v / 12.92
+		} else {
+			decode[i] = math.Pow((v+0.055)/1.055, 2.4)
+		}
+	}
+
+	return encode, decode
+}
+
+// Values outside of 0 to 1 get clamped.
+func lookupSRGB(table *[srgbTableSize + 1]float64, v float64) float64 {
+	if !(v > 0) {
+		return 0
+	}
+	if v >= 1 {
+		return 1
+	}
+
+	f := v * srgbTableSize
+	i := int(f)
+	return table[i] + (table[i+1]-table[i])*(f-float64(i))
+}
+
+func linearToSRGB(c Vertex3) Vertex3 {
+	return Vertex3{
+		X: lookupSRGB(linearToSRGBTable, c.X),
+		Y: lookupSRGB(linearToSRGBTable, c.Y),
+		Z: lookupSRGB(linearToSRGBTable, c.Z),
+	}
+}
+
+func srgbToLinear(c Vertex3) Vertex3 {
+	return Vertex3{
+		X: lookupSRGB(srgbToLinearTable, c.X),
+		Y: lookupSRGB(srgbToLinearTable, c.Y),
+		Z: lookupSRGB(srgbToLinearTable, c.Z),
+	}
+}
+
+// Linear color of an 8-bit sRGB one.
+func decodeSRGB(c color.RGBA) Vertex3 {
+	return srgbToLinear(Vertex3{X: float64(c.R) / 255, Y: float64(c.G) / 255, Z: float64(c.B) / 255})
+}
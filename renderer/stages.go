@@ -0,0 +1,104 @@
+package renderer
+
+import (
+	"image"
+	"image/color"
+)
+
+// The image of one pipeline stage, top row first.
+type Stage struct {
+	Name  string
+	Image *image.RGBA
+}
+
+// Renders the same frame once per pipeline stage, which is great for teaching and for finding out
+// at which point an artifact gets introduced.
+func RenderStages(scene *Scene, camera Camera, options Options) []Stage {
+	var stages []Stage
+	rect := options.rect()
+	white := color.RGBA{R: 255, G: 255, B: 255, A: 255}
+
+	// Raw vertices of the scene, looking down the Z axis.
+	img := newImage(rect)
+	obj := scene.Flatten()
+	min, max := obj.bounds()
+	center := min.plus(max).scale(0.5)
+	radius := max.minus(min).length() / 2
+	if radius == 0 {
+		radius = 1
+	}
+	fit := genScreenMatrix(0, 0, rect.Dx(), rect.Dy()).Multiply(ScaleMatrix(Vertex3{X: 1 / radius, Y: 1 / radius, Z: 1 / radius})).Multiply(TranslationMatrix(center.scale(-1)))
+	for _, face := range obj.Faces {
+		for _, v := range face.Vertices {
+			vertex4 := Vertex4{X: v.X, Y: v.Y, Z: v.Z, W: 1}
+			vertex4.transform(fit)
+			img.Set(int(vertex4.X), int(vertex4.Y), white)
+		}
+	}
+	stages = append(stages, Stage{"vertices", img})
+
+	// Vertices after projection through the camera.
+	img = newImage(rect)
+	noCulling := options
+	noCulling.BackfaceCulling = false
+	for _, triangle := range projectScene(scene, camera, rect, noCulling, nil) {
+		for i := range triangle.points {
+			p := triangle.pixel(i)
+			img.Set(p.X, p.Y, white)
+		}
+	}
+	stages = append(stages, Stage{"projected", img})
+
+	// Vertices of the triangles that survived clipping and culling.
+	img = newImage(rect)
+	triangles := projectScene(scene, camera, rect, options, nil)
+	for _, triangle := range triangles {
+		for i := range triangle.points {
+			p := triangle.pixel(i)
+			img.Set(p.X, p.Y, white)
+		}
+	}
+	stages = append(stages, Stage{"clipped", img})
+
+	// Wireframe
+	img = newImage(rect)
+	drawWireframe(img, triangles, nil, white)
+	stages = append(stages, Stage{"wireframe", img})
+
+	// Fragment stages, from the depth alone up to the fully lit result.
+	views := []struct {
+		name string
+		view View
+	}{
+		{"depth", ViewDepth},
+		{"flat", ViewFlat},
+		{"textured", ViewTextured},
+		{"lit", ViewLit},
+	}
+	for _, v := range views {
+		img = newImage(rect)
+		o := options
+		o.View = v.view
+		o.PostEffects, o.ToneMapping, o.Exposure = nil, NoToneMapping, 0
+		if o.AntiAliasing == FXAA {
+			o.AntiAliasing = NoAntiAliasing
+		}
+		render(img, scene, camera, o)
+		stages = append(stages, Stage{v.name, img})
+	}
+
+	// The lit result through the post effects and tone mapping, when there are any.
+	o := options
+	o.View = ViewLit
+	if len(postEffects(o)) > 0 {
+		img = newImage(rect)
+		render(img, scene, camera, o)
+		stages = append(stages, Stage{"post-processed", img})
+	}
+
+	for i := range stages {
+		stages[i].Image = flipImageVertically(rect, stages[i].Image)
+	}
+
+	return stages
+}
@@ -0,0 +1,96 @@
+package renderer
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// Steps of rendering a frame, timed by RenderStats.
+type RenderStage int
+
+const (
+	StageShadows RenderStage = iota
+	// Projection, clipping and culling of the triangles.
+	StageGeometry
+	// Rasterization and shading, or tracing the paths of the path tracing backend.
+	StageRaster
+	StagePost
+	stageCount
+)
+
+func (s RenderStage) String() string {
+	switch s {
+	case StageShadows:
+		return "shadows"
+	case StageGeometry:
+		return "geometry"
+	case StageRaster:
+		return "raster"
+	case StagePost:
+		return "post"
+	}
+	return fmt.Sprintf("RenderStage(%d)", int(s))
+}
+
+// What rendering took, counted when the options point to one. Frames rendered with the same stats
+// add up, like the viewports of a layout or the faces of a panorama.
+type RenderStats struct {
+	// Faces of the meshes, instances included, at the level of detail they got drawn with.
+	Triangles int
+	// Faces left out whole, off-screen, facing away or clipped away entirely.
+	Culled int
+	// Faces cut by the near plane, or the sides of the view with frustum clipping, the polygons
+	// left of them being split back into triangles.
+	Clipped int
+	// Triangles rasterized, more than the faces left when clipping splits them.
+	Drawn int
+	// Fragments shaded, the pixels lit with deferred shading. Pixels get shaded once for every
+	// triangle drawn over them, and once for all their samples with multisampling. Path tracing
+	// doesn't count them.
+	Fragments int64
+	// Time taken by every stage.
+	Stages [stageCount]time.Duration
+}
+
+// Adds the time since start to a stage. Frames rendered without stats pass nil.
+func (s *RenderStats) since(stage RenderStage, start time.Time) {
+	if s != nil {
+		s.Stages[stage] += time.Since(start)
+	}
+}
+
+// Counts the faces of a mesh projected into the triangles drawn.
+func (s *RenderStats) addGeometry(faces, culled, clipped, drawn int) {
+	if s != nil {
+		s.Triangles += faces
+		s.Culled += culled
+		s.Clipped += clipped
+		s.Drawn += drawn
+	}
+}
+
+// Counts fragments shaded, by workers drawing in parallel.
+func (s *RenderStats) addFragments(n int) {
+	if s != nil && n > 0 {
+		atomic.AddInt64(&s.Fragments, int64(n))
+	}
+}
+
+// Time taken by all the stages.
+func (s *RenderStats) Total() time.Duration {
+	var total time.Duration
+	for _, d := range s.Stages {
+		total += d
+	}
+	return total
+}
+
+// Report of the stats, one per line.
+func (s *RenderStats) String() string {
+	text := fmt.Sprintf("triangles %9d\nculled    %9d\nclipped   %9d\ndrawn     %9d\nfragments %9d", s.Triangles, s.Culled, s.Clipped, s.Drawn, s.Fragments)
+	for stage, duration := range s.Stages {
+		text += fmt.Sprintf("\n%-9s %9.1f ms", RenderStage(stage), milliseconds(duration))
+	}
+	return text + fmt.Sprintf("\n%-9s %9.1f ms", "total", milliseconds(s.Total()))
+}
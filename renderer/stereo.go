@@ -0,0 +1,64 @@
+package renderer
+
+import (
+	"image"
+	"image/draw"
+)
+
+type StereoMode int
+
+const (
+	// Red-cyan glasses: red comes from the left eye, green and blue from the right one.
+	Anaglyph StereoMode = iota
+	// Left eye on the left half and right eye on the right half, for parallel viewing and
+	// stereoscopic displays. The image is twice as wide as the options say.
+	SideBySide
+)
+
+// Renders the scene as seen by two eyes apart from each other by separation, on both sides of the
+// camera. 0 picks a thirtieth of the distance to the target, the usual rule of thumb for
+// comfortable depth. The eyes turn towards the target, which appears at the depth of the screen,
+// nearer things popping out of it.
+func RenderStereo(scene *Scene, camera Camera, mode StereoMode, separation float64, options Options) (*image.RGBA, error) {
+	if err := options.validate(); err != nil {
+		return nil, err
+	}
+
+	forward := camera.Target.minus(camera.Position)
+	if separation == 0 {
+		separation = forward.length() / 30
+	}
+	right := forward.cross(camera.Up).normalize(separation / 2)
+
+	left, err := Render(scene, eyeCamera(camera, right.scale(-1)), options)
+	if err != nil {
+		return nil, err
+	}
+	rightEye, err := Render(scene, eyeCamera(camera, right), options)
+	if err != nil {
+		return nil, err
+	}
+
+	rect := options.rect()
+	if mode == SideBySide {
+		img := image.NewRGBA(image.Rect(0, 0, 2*rect.Dx(), rect.Dy()))
+		draw.Draw(img, rect, left, image.Point{}, draw.Src)
+		draw.Draw(img, rect.Add(image.Point{X: rect.Dx()}), rightEye, image.Point{}, draw.Src)
+		return img, nil
+	}
+
+	img := image.NewRGBA(rect)
+	for i := 0; i < len(img.Pix); i += 4 {
+		img.Pix[i] = left.Pix[i]
+		img.Pix[i+1] = rightEye.Pix[i+1]
+		img.Pix[i+2] = rightEye.Pix[i+2]
+		img.Pix[i+3] = uint8(maxInt(int(left.Pix[i+3]), int(rightEye.Pix[i+3])))
+	}
+	return img, nil
+}
+
+// The camera moved sideways by offset, still looking at its target.
+func eyeCamera(camera Camera, offset Vertex3) Camera {
+	camera.Position = camera.Position.plus(offset)
+	return camera
+}
@@ -0,0 +1,145 @@
+package renderer
+
+import (
+	"image"
+	"math"
+	"sync/atomic"
+)
+
+// Corners of the image darkened, like through old or cheap lenses, drawing the eye to the center.
+type VignetteEffect struct {
+	// How dark the corners get, from 0 for not at all to 1 for black.
+	Strength float64
+	// Where the darkening starts, as a fraction of the distance from the center to the corners.
+	Radius float64
+}
+
+func NewVignetteEffect() *VignetteEffect {
+	return &VignetteEffect{Strength: 0.5, Radius: 0.5}
+}
+
+func (v *VignetteEffect) Apply(frame *Frame) {
+	vignette(frame.Image, *v)
+}
+
+func vignette(img *image.RGBA, options VignetteEffect) {
+	rect := img.Bounds()
+	center := Vertex2{X: float64(rect.Min.X+rect.Max.X) / 2, Y: float64(rect.Min.Y+rect.Max.Y) / 2}
+	corner := math.Hypot(float64(rect.Dx())/2, float64(rect.Dy())/2)
+	if corner == 0 {
+		return
+	}
+
+	radius := math.Min(options.Radius, 0.99)
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			d := math.Hypot(float64(x)+0.5-center.X, float64(y)+0.5-center.Y) / corner
+			t := math.Max(0, math.Min(1, (d-radius)/(1-radius)))
+			darkening := options.Strength * t * t * (3 - 2*t)
+			c := img.RGBAAt(x, y)
+			if darkening <= 0 || c.A == 0 {
+				continue
+			}
+
+			// Light gets dimmed rather than the encoded values.
+			alpha := float64(c.A) / 255
+			encoded := Vertex3{X: float64(c.R), Y: float64(c.G), Z: float64(c.B)}.scale(1 / (255 * alpha))
+			dimmed := linearToSRGB(srgbToLinear(encoded).scale(1 - darkening))
+			img.SetRGBA(x, y, toPremultipliedRGBA(dimmed, alpha))
+		}
+	}
+}
+
+// Noise over the image like the grain of film, strongest in the midtones. Every frame gets
+// different grain, so that videos don't look like they were shot through a dirty lens, stills
+// always getting the grain of their seed.
+type GrainEffect struct {
+	// Standard deviation of the noise in the midtones, as a fraction of white.
+	Strength float64
+	Seed     uint64
+
+	// Frames grained so far.
+	frames atomic.Uint64
+}
+
+func NewGrainEffect() *GrainEffect {
+	return &GrainEffect{Strength: 0.04}
+}
+
+func (g *GrainEffect) Apply(frame *Frame) {
+	grain(frame.Image, g.Strength, g.Seed+g.frames.Add(1)-1)
+}
+
+func grain(img *image.RGBA, strength float64, seed uint64) {
+	rect := img.Bounds()
+	random := &splitMix{state: seed * 0x9e3779b97f4a7c15}
+
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			// Close enough to a normal distribution with a standard deviation of 1.
+			noise := (random.float() + random.float() + random.float() + random.float() - 2) * math.Sqrt(3)
+
+			c := img.RGBAAt(x, y)
+			if c.A == 0 {
+				continue
+			}
+
+			alpha := float64(c.A) / 255
+			encoded := Vertex3{X: float64(c.R), Y: float64(c.G), Z: float64(c.B)}.scale(1 / (255 * alpha))
+			l := luminance(encoded)
+			amount := noise * strength * 4 * l * (1 - l)
+			grained := encoded.plus(Vertex3{X: amount, Y: amount, Z: amount})
+			img.SetRGBA(x, y, toPremultipliedRGBA(grained, alpha))
+		}
+	}
+}
+
+// Red and blue spreading apart toward the edges of the image, like through lenses that don't
+// focus all wavelengths the same.
+type ChromaticAberrationEffect struct {
+	// How far red and blue get from green in the corners, as a fraction of the height of the
+	// image.
+	Strength float64
+}
+
+func NewChromaticAberrationEffect() *ChromaticAberrationEffect {
+	return &ChromaticAberrationEffect{Strength: 0.003}
+}
+
+func (a *ChromaticAberrationEffect) Apply(frame *Frame) {
+	chromaticAberration(frame.Image, *a)
+}
+
+func chromaticAberration(img *image.RGBA, options ChromaticAberrationEffect) {
+	rect := img.Bounds()
+	width, height := rect.Dx(), rect.Dy()
+	corner := math.Hypot(float64(width)/2, float64(height)/2)
+	if corner == 0 {
+		return
+	}
+
+	colors := make([]Vertex3, width*height)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			c := img.RGBAAt(rect.Min.X+x, rect.Min.Y+y)
+			colors[y*width+x] = Vertex3{X: float64(c.R), Y: float64(c.G), Z: float64(c.B)}
+		}
+	}
+
+	// Red gets magnified and blue shrunk, both around the center.
+	scale := options.Strength * float64(height) / corner
+	cx, cy := float64(width)/2-0.5, float64(height)/2-0.5
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			dx, dy := float64(x)-cx, float64(y)-cy
+			red := sampleBilinear(colors, width, height, cx+dx*(1-scale), cy+dy*(1-scale))
+			blue := sampleBilinear(colors, width, height, cx+dx*(1+scale), cy+dy*(1+scale))
+
+			c := img.RGBAAt(rect.Min.X+x, rect.Min.Y+y)
+			// Premultiplied channels can't go over alpha.
+			c.R = uint8(math.Min(float64(c.A), red.X+0.5))
+			c.B = uint8(math.Min(float64(c.A), blue.Z+0.5))
+			img.SetRGBA(rect.Min.X+x, rect.Min.Y+y, c)
+		}
+	}
+}
@@ -0,0 +1,29 @@
+package renderer
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestStylizeEffects(t *testing.T) {
+	gray := color.RGBA{R: 128, G: 128, B: 128, A: 255}
+	img := image.NewRGBA(image.Rect(0, 0, 64, 64))
+	for i := 0; i < len(img.Pix); i += 4 {
+		img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3] = gray.R, gray.G, gray.B, gray.A
+	}
+
+	// Nothing to spread apart without edges.
+	NewChromaticAberrationEffect().Apply(&Frame{Image: img})
+	if c := img.RGBAAt(0, 0); c != gray {
+		t.Errorf("chromatic aberration changed a flat image to %v", c)
+	}
+
+	NewVignetteEffect().Apply(&Frame{Image: img})
+	if c := img.RGBAAt(32, 32); c != gray {
+		t.Errorf("vignette changed the center to %v", c)
+	}
+	if c := img.RGBAAt(0, 0); c.R >= gray.R {
+		t.Errorf("vignette left the corner at %v", c)
+	}
+}
@@ -0,0 +1,29 @@
+package renderer
+
+import (
+	"image"
+	"image/color"
+)
+
+// Text drawn over the finished frame, for labels, captions and annotations.
+type TextEffect struct {
+	Text string
+	// Top-left corner of the first line, in pixels from the top-left corner of the image.
+	Position image.Point
+	// White when nil.
+	Color color.Color
+	// The built-in font when nil, scaled up with larger images to stay readable.
+	Font *Font
+}
+
+func (t *TextEffect) Apply(frame *Frame) {
+	c, font := t.Color, t.Font
+	if c == nil {
+		c = color.White
+	}
+	if font == nil {
+		font = BitmapFont(frame.Image.Bounds().Dy() / 400)
+	}
+
+	newFlippedCanvas(frame.Image).DrawText(font, frame.Image.Bounds().Min.Add(t.Position), t.Text, c)
+}
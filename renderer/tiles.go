@@ -0,0 +1,87 @@
+package renderer
+
+import (
+	"image"
+	"runtime"
+	"sync"
+)
+
+// Pixels per side of the tiles the image gets split into, small enough to balance the work
+// between workers and large enough to keep binning cheap.
+const tileSize = 64
+
+// Splits the image into tiles, bins the triangles of every tile and draws the tiles in parallel,
+// each worker owning the pixels of the tiles it draws. Triangles keep their order within a tile,
+// so the result is the same as drawing them one after another.
+func drawTiles(rect image.Rectangle, opaque, transparent []Triangle, workers int, draw func(triangle Triangle, region image.Rectangle, transparent bool)) {
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+
+	if workers == 1 {
+		for _, triangle := range opaque {
+			draw(triangle, rect, false)
+		}
+		for _, triangle := range transparent {
+			draw(triangle, rect, true)
+		}
+		return
+	}
+
+	columns := (rect.Dx() + tileSize - 1) / tileSize
+	rows := (rect.Dy() + tileSize - 1) / tileSize
+	opaqueBins := binTriangles(opaque, columns, rows)
+	transparentBins := binTriangles(transparent, columns, rows)
+
+	tiles := make(chan int, columns*rows)
+	for k := 0; k < columns*rows; k++ {
+		if len(opaqueBins[k]) > 0 || len(transparentBins[k]) > 0 {
+			tiles <- k
+		}
+	}
+	close(tiles)
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for k := range tiles {
+				x, y := k%columns*tileSize, k/columns*tileSize
+				region := image.Rect(x, y, x+tileSize, y+tileSize).Intersect(rect)
+
+				for _, i := range opaqueBins[k] {
+					draw(opaque[i], region, false)
+				}
+				for _, i := range transparentBins[k] {
+					draw(transparent[i], region, true)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// Indices of the triangles whose bounding box overlaps every tile.
+func binTriangles(triangles []Triangle, columns, rows int) [][]int {
+	bins := make([][]int, columns*rows)
+
+	for i, triangle := range triangles {
+		min, max := triangle.pixelBounds()
+
+		x0, y0 := maxInt(min.X/tileSize, 0), maxInt(min.Y/tileSize, 0)
+		x1, y1 := minInt(max.X/tileSize, columns-1), minInt(max.Y/tileSize, rows-1)
+		if min.X < 0 && max.X < 0 || min.Y < 0 && max.Y < 0 {
+			continue
+		}
+
+		for y := y0; y <= y1; y++ {
+			for x := x0; x <= x1; x++ {
+				bins[y*columns+x] = append(bins[y*columns+x], i)
+			}
+		}
+	}
+
+	return bins
+}
@@ -0,0 +1,93 @@
+package renderer
+
+import (
+	"image"
+	"math"
+)
+
+// How lit colors, which can be brighter than white, get brought into the displayable range.
+type ToneMapping int
+
+const (
+	// Colors brighter than white get clamped, losing detail in highlights.
+	NoToneMapping ToneMapping = iota
+	// c / (1 + c), compressing highlights while leaving dark colors about the same.
+	Reinhard
+	// Filmic curve fitted to the ACES reference rendering transform, with more contrast and
+	// saturation than Reinhard.
+	ACES
+	// 1 - e^-c, like film responding to the amount of light it gets.
+	ExposureToneMapping
+)
+
+// Applied to linear colors, before sRGB encoding.
+type toneMapper struct {
+	operator ToneMapping
+	// Factor the colors are multiplied by beforehand.
+	exposure float64
+}
+
+// Brings the colors of the frame into the displayable range, from the linear colors the frame
+// got rendered with when it has them, as they are in the image otherwise. Effects working on
+// linear colors, like bloom, go before it, and the ones working on the image after it. Frames
+// keeping their linear colors have transparent surfaces blended in linear light, like light does,
+// rather than over the sRGB colors of the image.
+type ToneMapEffect struct {
+	Operator ToneMapping
+	// In stops, every one doubling the light. 0 leaves colors as they are.
+	Exposure float64
+}
+
+func NewToneMapEffect(operator ToneMapping) *ToneMapEffect {
+	return &ToneMapEffect{Operator: operator}
+}
+
+func (e *ToneMapEffect) Apply(frame *Frame) {
+	t := toneMapper{operator: e.Operator, exposure: math.Exp2(e.Exposure)}
+	if frame.hdr == nil || frame.toneMapped {
+		toneMapImage(frame.Image, t)
+	} else {
+		frame.develop(t)
+	}
+	frame.toneMapped = true
+}
+
+// Tone maps the colors the image holds, already clamped to white.
+func toneMapImage(img *image.RGBA, t toneMapper) {
+	rect := img.Bounds()
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			c := img.RGBAAt(x, y)
+			if c.A == 0 {
+				continue
+			}
+
+			alpha := float64(c.A) / 255
+			linear := srgbToLinear(Vertex3{X: float64(c.R), Y: float64(c.G), Z: float64(c.B)}.scale(1 / (255 * alpha)))
+			img.SetRGBA(x, y, toPremultipliedRGBA(linearToSRGB(t.apply(linear)), alpha))
+		}
+	}
+}
+
+func (t toneMapper) apply(c Vertex3) Vertex3 {
+	if t.exposure != 1 {
+		c = c.scale(t.exposure)
+	}
+
+	switch t.operator {
+	case Reinhard:
+		return Vertex3{X: c.X / (1 + c.X), Y: c.Y / (1 + c.Y), Z: c.Z / (1 + c.Z)}
+	case ACES:
+		return Vertex3{X: acesFilm(c.X), Y: acesFilm(c.Y), Z: acesFilm(c.Z)}
+	case ExposureToneMapping:
+		return Vertex3{X: 1 - math.Exp(-c.X), Y: 1 - math.Exp(-c.Y), Z: 1 - math.Exp(-c.Z)}
+	}
+
+	return c
+}
+
+// Krzysztof Narkowicz's fit of the ACES curve.
+func acesFilm(x float64) float64 {
+	x = math.Max(x, 0)
+	return math.Min(1, x*(2.51*x+0.03)/(x*(2.43*x+0.59)+0.14))
+}
@@ -0,0 +1,43 @@
+package renderer
+
+import (
+	"bytes"
+	"testing"
+)
+
+// Tone mapping set in the options is the same as a ToneMapEffect going after bloom and before the
+// effects working on the image.
+func TestToneMapEffect(t *testing.T) {
+	bloom, fxaa := NewBloomEffect(), FXAAEffect{}
+	options := DefaultOptions()
+	options.ToneMapping = ACES
+	options.PostEffects = []PostEffect{bloom, fxaa}
+
+	effects := postEffects(options)
+	if len(effects) != 3 || effects[0] != bloom || effects[2] != fxaa {
+		t.Fatalf("effects %v, expected tone mapping between bloom and FXAA", effects)
+	}
+	if e, ok := effects[1].(*ToneMapEffect); !ok || e.Operator != ACES {
+		t.Fatalf("effect %v, expected ACES tone mapping", effects[1])
+	}
+
+	implicit := renderGolden(t, "pbr.json", func(o *Options) { o.ToneMapping = ACES })
+	explicit := renderGolden(t, "pbr.json", func(o *Options) { o.PostEffects = []PostEffect{NewToneMapEffect(ACES)} })
+	if !bytes.Equal(implicit.Pix, explicit.Pix) {
+		t.Error("images differ between tone mapping options and effect")
+	}
+}
+
+func TestToneMapSceneEffect(t *testing.T) {
+	effect, err := scenePostEffect{Type: "tonemap", Operator: "aces", Exposure: 1}.effect("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e, ok := effect.(*ToneMapEffect); !ok || *e != (ToneMapEffect{Operator: ACES, Exposure: 1}) {
+		t.Errorf("effect %v, expected ACES tone mapping one stop up", effect)
+	}
+
+	if _, err := (scenePostEffect{Type: "tonemap", Operator: "filmic"}).effect(""); err == nil {
+		t.Error("unknown operator accepted")
+	}
+}
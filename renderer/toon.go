@@ -0,0 +1,137 @@
+package renderer
+
+import (
+	"image"
+	"image/color"
+	"math"
+)
+
+const (
+	// Normals of neighboring pixels further apart than this cosine make a crease, about 40 degrees.
+	outlineCrease = 0.75
+	// Depth difference of neighboring pixels making an edge, in the 0 to 255 range of the depth
+	// buffer, for surfaces in front of others facing the same way.
+	outlineDepth = 1.5
+)
+
+// Non-photorealistic cel shading, like in cartoons: light in a few flat bands, and dark lines
+// along silhouettes and creases. Only used by the z-buffer backend.
+type ToonOptions struct {
+	// Every material shaded in bands, as if their model was Toon.
+	Enabled bool
+	// Bands of light, from unlit to fully lit, at least 2. 3 when 0.
+	Bands int
+	// Width in pixels of the lines drawn where depth or normals change sharply, for every material
+	// whether toon shaded or not, twice as wide along creases. None when 0.
+	Outline int
+	// Black when nil.
+	OutlineColor color.Color
+}
+
+func (o ToonOptions) bands() int {
+	if o.Bands < 2 {
+		return 3
+	}
+	return o.Bands
+}
+
+// Whether the material gets toon shaded.
+func (s shading) isToon(material *Material) bool {
+	return s.toon.Enabled || material.Model == Toon
+}
+
+// Ambient, plus the diffuse light of all the lights rounded down to a band, plus hard highlights.
+func (s shading) toonLight(material *Material, albedo, normal, position Vertex3) Vertex3 {
+	c := material.Ambient.multiply(s.ambient).multiply(albedo)
+	toEye := s.eye.minus(position).normalize(1.0)
+	shininess := math.Max(material.Shininess, 1)
+
+	diffuse, specular := 0.0, 0.0
+	s.eachLight(position, func(i int, direction Vertex3, amount float64) {
+		amount *= s.visibility(i, position, normal)
+		lambert := normal.dot(direction)
+		if lambert <= 0 {
+			return
+		}
+		diffuse += lambert * amount
+
+		halfway := direction.plus(toEye).normalize(1.0)
+		specular += math.Pow(math.Max(0, normal.dot(halfway)), shininess) * amount
+	})
+
+	bands := float64(s.toon.bands())
+	band := math.Min(1, math.Floor(diffuse*bands)/(bands-1))
+	c = c.plus(albedo.scale(band))
+	if specular > 0.5 {
+		c = c.plus(material.Specular)
+	}
+
+	return c
+}
+
+// Lines along the silhouettes and creases of what got drawn, rows going from the bottom up like
+// the buffers. Lines go on the side of edges nearer to the camera, hugging what's in front.
+func drawOutlines(img *image.RGBA, hdr *hdrImage, zBuffer []float64, normals []Vertex3, options ToonOptions) {
+	rect := img.Bounds()
+	width, height := rect.Dx(), rect.Dy()
+
+	line := color.RGBA{A: 255}
+	if options.OutlineColor != nil {
+		line = color.RGBAModel.Convert(options.OutlineColor).(color.RGBA)
+	}
+
+	drawn := func(i int) bool {
+		return !math.IsInf(zBuffer[i], -1)
+	}
+	// Whether the pixel at i sits on an edge with its neighbor at j, on its nearer side. Depth
+	// changes steadily across flat surfaces, it takes the neighbor at k on the other side to tell
+	// whether it does.
+	edge := func(i, j, k int) bool {
+		if !drawn(i) {
+			return false
+		}
+		if !drawn(j) {
+			return true
+		}
+		if zBuffer[i] < zBuffer[j] {
+			return false
+		}
+		if k >= 0 && drawn(k) && math.Abs(zBuffer[j]-2*zBuffer[i]+zBuffer[k]) > outlineDepth {
+			return true
+		}
+		return normals != nil && normals[i].dot(normals[j]) < outlineCrease
+	}
+
+	// Neighbors on all four sides, as far as lines are wide.
+	reach := options.Outline
+	index := func(x, y int) int {
+		if x < 0 || y < 0 || x >= width || y >= height {
+			return -1
+		}
+		return y*width + x
+	}
+	outlined := make([]bool, width*height)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			i := y*width + x
+			for _, d := range [4]image.Point{{X: -reach}, {X: reach}, {Y: -reach}, {Y: reach}} {
+				j, k := index(x+d.X, y+d.Y), index(x-d.X, y-d.Y)
+				if j >= 0 && edge(i, j, k) {
+					outlined[i] = true
+					break
+				}
+			}
+		}
+	}
+
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			if outlined[y*width+x] {
+				img.SetRGBA(rect.Min.X+x, rect.Min.Y+y, line)
+				if hdr != nil {
+					hdr.set(x, y, decodeSRGB(line))
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,299 @@
+package renderer
+
+import (
+	"image"
+	"image/color"
+	"math"
+)
+
+// Bits of the fractional part of the fixed point screen positions of vertices, snapping them to
+// 1/256 of a pixel like GPUs do. Edges shared by triangles then get tested exactly the same way
+// on both sides.
+const (
+	subpixelBits  = 8
+	subpixelScale = 1 << subpixelBits
+)
+
+type Triangle struct {
+	// Screen positions of the vertices, in fixed point.
+	points [3]image.Point
+	depths [3]float64
+	invW   [3]float64 // For perspective-correct interpolation.
+
+	// World space attributes of the vertices.
+	face     Face
+	material *Material
+	// Mesh the triangle belongs to, or instance of it, numbered from 1 in the order of the scene
+	// graph.
+	object int
+	// Lit colors of the vertices, with Gouraud and flat shading.
+	lit [3]Vertex3
+	// Back face of a two-sided material, its normals and tangents already flipped.
+	backFacing bool
+}
+
+// Without a z-buffer, every fragment of the triangle gets drawn and the caller is responsible for ordering.
+// Transparent triangles are depth tested without updating the z-buffer, and blended over what's behind.
+// Fully transparent fragments, like the ones discarded by alpha testing, leave everything untouched.
+// Only the pixels inside the region get drawn, so that parts of the image can be drawn in parallel.
+// Linear colors also go to the HDR image when there's one, unclamped, and opaque fragments to the
+// auxiliary buffers attached.
+func drawTriangle(fb *Framebuffer, triangle Triangle, zBuffer []float64, s shading, transparent bool, region image.Rectangle, raster triangleRasterizer) {
+	img, hdr := fb.color, fb.hdr
+	width := img.Bounds().Dx()
+	region = region.Intersect(img.Bounds())
+
+	// Only the part of the bounding box inside the region gets scanned.
+	min, max := triangle.pixelBounds()
+	min.X, min.Y = maxInt(min.X, region.Min.X), maxInt(min.Y, region.Min.Y)
+	max.X, max.Y = minInt(max.X, region.Max.X-1), minInt(max.Y, region.Max.Y-1)
+	if min.X > max.X || min.Y > max.Y {
+		return
+	}
+
+	if s.mode != PhongShading && s.view == ViewLit {
+		triangle.lit = s.shadeVertices(triangle)
+	}
+
+	shaded := 0
+	raster.rasterize(triangle, min, max, func(x, y int, w1, w2, w3 float64) {
+		// Interpolate depth based on barycentric weights
+		depth := w1*triangle.depths[0] + w2*triangle.depths[1] + w3*triangle.depths[2]
+
+		// Drawing according to Z-buffer
+		if zBuffer != nil {
+			if s.depthEqual && !transparent {
+				if zBuffer[width*y+x] != depth {
+					return
+				}
+			} else if zBuffer[width*y+x] >= depth {
+				return
+			}
+		}
+
+		// Attributes aren't linear in screen space under perspective, but divided by w they are.
+		p1, p2, p3 := w1*triangle.invW[0], w2*triangle.invW[1], w3*triangle.invW[2]
+		sum := p1 + p2 + p3
+		p1, p2, p3 = p1/sum, p2/sum, p3/sum
+
+		linear, alpha := s.shadeLinear(triangle, p1, p2, p3, depth)
+		shaded++
+		c := s.encode(linear, alpha)
+		if c.A == 0 {
+			return
+		}
+		if hdr != nil {
+			hdr.set(x, y, blendLinear(hdr.at(x, y), linear, alpha, triangle.material.Blend))
+		}
+		if zBuffer != nil && !transparent {
+			zBuffer[width*y+x] = depth
+		}
+		// What's behind transparent fragments shows through them.
+		if !transparent {
+			fb.writeFragment(x, y, triangle, p1, p2, p3)
+		}
+		if s.overdraw != nil {
+			s.overdraw[width*y+x]++
+		}
+		if triangle.material.Blend == AdditiveBlend {
+			c = blendAdd(c, img.RGBAAt(x, y))
+		} else if c.A < 255 {
+			c = blendOver(c, img.RGBAAt(x, y))
+		}
+		img.SetRGBA(x, y, c)
+	})
+	s.stats.addFragments(shaded)
+}
+
+// Depth-only version of drawTriangle, for depth pre-passes. Fragments get the depths they get when
+// drawn, for the depth-equal test to find them again. Cutouts leave holes like they do when drawn.
+func drawTriangleDepth(triangle Triangle, zBuffer []float64, width int, region image.Rectangle, raster triangleRasterizer) {
+	region = region.Intersect(image.Rect(0, 0, width, len(zBuffer)/width))
+
+	min, max := triangle.pixelBounds()
+	min.X, min.Y = maxInt(min.X, region.Min.X), maxInt(min.Y, region.Min.Y)
+	max.X, max.Y = minInt(max.X, region.Max.X-1), minInt(max.Y, region.Max.Y-1)
+	if min.X > max.X || min.Y > max.Y {
+		return
+	}
+
+	raster.rasterize(triangle, min, max, func(x, y int, w1, w2, w3 float64) {
+		depth := w1*triangle.depths[0] + w2*triangle.depths[1] + w3*triangle.depths[2]
+		if zBuffer[width*y+x] >= depth {
+			return
+		}
+
+		if triangle.material.AlphaCutoff > 0 {
+			p1, p2, p3 := w1*triangle.invW[0], w2*triangle.invW[1], w3*triangle.invW[2]
+			sum := p1 + p2 + p3
+			uv := triangle.face.Textures[0].scale(p1 / sum).plus(triangle.face.Textures[1].scale(p2 / sum)).plus(triangle.face.Textures[2].scale(p3 / sum))
+			if triangle.material.cutout(uv) {
+				return
+			}
+		}
+
+		zBuffer[width*y+x] = depth
+	})
+}
+
+// Porter-Duff over, with premultiplied colors. Highlights can be brighter than their alpha,
+// adding light, hence the clamping.
+func blendOver(src, dst color.RGBA) color.RGBA {
+	k := 255 - uint32(src.A)
+	channel := func(s, d uint8) uint8 {
+		c := uint32(s) + (uint32(d)*k+127)/255
+		if c > 255 {
+			return 255
+		}
+		return uint8(c)
+	}
+
+	return color.RGBA{R: channel(src.R, dst.R), G: channel(src.G, dst.G), B: channel(src.B, dst.B), A: channel(src.A, dst.A)}
+}
+
+// Premultiplied colors added together, covering as much as over would.
+func blendAdd(src, dst color.RGBA) color.RGBA {
+	channel := func(s, d uint8) uint8 {
+		if c := uint32(s) + uint32(d); c < 255 {
+			return uint8(c)
+		}
+		return 255
+	}
+
+	over := blendOver(src, dst)
+	return color.RGBA{R: channel(src.R, dst.R), G: channel(src.G, dst.G), B: channel(src.B, dst.B), A: over.A}
+}
+
+// Linear color over dst, the way the blend mode of its material combines them.
+func blendLinear(dst, c Vertex3, alpha float64, mode BlendMode) Vertex3 {
+	switch {
+	case mode == AdditiveBlend:
+		return dst.plus(c.scale(alpha))
+	case alpha >= 1:
+		return c
+	}
+	return c.scale(alpha).plus(dst.scale(1 - alpha))
+}
+
+func (t Triangle) averageDepth() float64 {
+	return (t.depths[0] + t.depths[1] + t.depths[2]) / 3
+}
+
+// Pixel the vertex is in.
+func (t Triangle) pixel(i int) image.Point {
+	return image.Point{X: t.points[i].X >> subpixelBits, Y: t.points[i].Y >> subpixelBits}
+}
+
+// Screen position of the vertex, in pixels.
+func (t Triangle) position(i int) Vertex2 {
+	return Vertex2{X: float64(t.points[i].X) / subpixelScale, Y: float64(t.points[i].Y) / subpixelScale}
+}
+
+// Pixels whose centers are inside the bounding box of the triangle, min and max included. There
+// are none when min is past max.
+func (t Triangle) pixelBounds() (image.Point, image.Point) {
+	const half = subpixelScale / 2
+	min, max := boundingBox(t.points[0], t.points[1], t.points[2])
+
+	// Shifting rounds down, negative coordinates included.
+	return image.Point{X: (min.X - half + subpixelScale - 1) >> subpixelBits, Y: (min.Y - half + subpixelScale - 1) >> subpixelBits},
+		image.Point{X: (max.X - half) >> subpixelBits, Y: (max.Y - half) >> subpixelBits}
+}
+
+// Which edges own the pixel centers right on them, following the top-left rule: an edge shared
+// by two triangles belongs to only one of them, the one below or to the right of it, so that
+// pixels along it are neither drawn twice nor left out. Edges are the ones opposite each vertex.
+type fillRule [3]bool
+
+func (t Triangle) fillRule() fillRule {
+	var rule fillRule
+	area := t.signedArea()
+
+	for i := range rule {
+		d := t.points[(i+2)%3].Sub(t.points[(i+1)%3])
+		if area < 0 {
+			d = d.Mul(-1)
+		}
+		// Going counter-clockwise with Y up, top edges go left and left edges go down.
+		rule[i] = d.Y == 0 && d.X < 0 || d.Y < 0
+	}
+
+	return rule
+}
+
+// Whether a point with these barycentric weights belongs to the triangle. Exactly zero weights
+// put it on an edge, only the edges of the rule owning it.
+func (r fillRule) covers(w1, w2, w3 float64) bool {
+	return (w1 > 0 || w1 == 0 && r[0]) && (w2 > 0 || w2 == 0 && r[1]) && (w3 > 0 || w3 == 0 && r[2])
+}
+
+// Twice the signed area of the triangle on screen, positive when its points go counter-clockwise.
+func (t Triangle) signedArea() int {
+	a, b, c := t.points[0], t.points[1], t.points[2]
+	return (b.X-a.X)*(c.Y-a.Y) - (c.X-a.X)*(b.Y-a.Y)
+}
+
+func (t Triangle) isBackFacing(frontFace Winding) bool {
+	if frontFace == Clockwise {
+		return t.signedArea() > 0
+	}
+	return t.signedArea() < 0
+}
+
+// Normal of the plane of the triangle, facing the same side as its normals.
+func (t Triangle) faceNormal() Vertex3 {
+	if t.backFacing {
+		return faceNormal(t.face).scale(-1)
+	}
+	return faceNormal(t.face)
+}
+
+// Brings the triangle nearer by the bias, or further with a negative one.
+func (t *Triangle) offset(bias DepthBias) {
+	offset := bias.Constant
+	if bias.Slope != 0 {
+		// Gradient of depth over the plane of the triangle, in pixels.
+		p := [3]Vertex2{t.position(0), t.position(1), t.position(2)}
+		area := (p[1].X-p[0].X)*(p[2].Y-p[0].Y) - (p[2].X-p[0].X)*(p[1].Y-p[0].Y)
+		if area != 0 {
+			d1, d2 := t.depths[1]-t.depths[0], t.depths[2]-t.depths[0]
+			dx := (d1*(p[2].Y-p[0].Y) - d2*(p[1].Y-p[0].Y)) / area
+			dy := (d2*(p[1].X-p[0].X) - d1*(p[2].X-p[0].X)) / area
+			offset += bias.Slope * math.Max(math.Abs(dx), math.Abs(dy))
+		}
+	}
+
+	for i := range t.depths {
+		t.depths[i] += offset
+	}
+}
+
+func boundingBox(v1, v2, v3 image.Point) (image.Point, image.Point) {
+	min := image.Point{
+		X: minInt(minInt(v1.X, v2.X), v3.X),
+		Y: minInt(minInt(v1.Y, v2.Y), v3.Y),
+	}
+
+	max := image.Point{
+		X: maxInt(maxInt(v1.X, v2.X), v3.X),
+		Y: maxInt(maxInt(v1.Y, v2.Y), v3.Y),
+	}
+
+	return min, max
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	} else {
+		return b
+	}
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	} else {
+		return b
+	}
+}
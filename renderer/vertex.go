@@ -0,0 +1,142 @@
+package renderer
+
+import "math"
+
+type Vertex2 struct {
+	X float64
+	Y float64
+}
+
+func (v Vertex2) plus(o Vertex2) Vertex2 {
+	return Vertex2{X: v.X + o.X, Y: v.Y + o.Y}
+}
+
+func (v Vertex2) scale(s float64) Vertex2 {
+	return Vertex2{X: v.X * s, Y: v.Y * s}
+}
+
+func (v Vertex2) lerp(o Vertex2, t float64) Vertex2 {
+	return Vertex2{
+		X: v.X + (o.X-v.X)*t,
+		Y: v.Y + (o.Y-v.Y)*t,
+	}
+}
+
+type Vertex3 struct {
+	X float64
+	Y float64
+	Z float64
+}
+
+func (v Vertex3) plus(o Vertex3) Vertex3 {
+	return Vertex3{
+		X: v.X + o.X,
+		Y: v.Y + o.Y,
+		Z: v.Z + o.Z,
+	}
+}
+
+func (v Vertex3) minus(o Vertex3) Vertex3 {
+	return Vertex3{
+		X: v.X - o.X,
+		Y: v.Y - o.Y,
+		Z: v.Z - o.Z,
+	}
+}
+
+func (v Vertex3) scale(s float64) Vertex3 {
+	return Vertex3{
+		X: v.X * s,
+		Y: v.Y * s,
+		Z: v.Z * s,
+	}
+}
+
+// Component-wise product, for colors.
+func (v Vertex3) multiply(o Vertex3) Vertex3 {
+	return Vertex3{
+		X: v.X * o.X,
+		Y: v.Y * o.Y,
+		Z: v.Z * o.Z,
+	}
+}
+
+func (v Vertex3) dot(o Vertex3) float64 {
+	return v.X*o.X + v.Y*o.Y + v.Z*o.Z
+}
+
+func (v Vertex3) length() float64 {
+	return math.Sqrt(v.dot(v))
+}
+
+func (v Vertex3) lerp(o Vertex3, t float64) Vertex3 {
+	return v.plus(o.minus(v).scale(t))
+}
+
+func (v Vertex3) cross(o Vertex3) Vertex3 {
+	return Vertex3{
+		X: (v.Y * o.Z) - (v.Z * o.Y),
+		Y: (v.Z * o.X) - (v.X * o.Z),
+		Z: (v.X * o.Y) - (v.Y * o.X),
+	}
+}
+
+type Vertex4 struct {
+	X float64
+	Y float64
+	Z float64
+	W float64
+}
+
+func (v Vertex3) normalize(target float64) Vertex3 {
+	n := target / math.Sqrt(v.X*v.X+v.Y*v.Y+v.Z*v.Z)
+	return Vertex3{
+		X: v.X * n,
+		Y: v.Y * n,
+		Z: v.Z * n,
+	}
+}
+
+func (v *Vertex4) transform(m Matrix4) {
+	*v = Vertex4{
+		X: v.X*m.m11 + v.Y*m.m12 + v.Z*m.m13 + v.W*m.m14,
+		Y: v.X*m.m21 + v.Y*m.m22 + v.Z*m.m23 + v.W*m.m24,
+		Z: v.X*m.m31 + v.Y*m.m32 + v.Z*m.m33 + v.W*m.m34,
+		W: v.X*m.m41 + v.Y*m.m42 + v.Z*m.m43 + v.W*m.m44,
+	}
+}
+
+func (v Vertex4) scale(s float64) Vertex4 {
+	return Vertex4{
+		X: v.X * s,
+		Y: v.Y * s,
+		Z: v.Z * s,
+		W: v.W * s,
+	}
+}
+
+func (v Vertex4) plus(o Vertex4) Vertex4 {
+	return Vertex4{
+		X: v.X + o.X,
+		Y: v.Y + o.Y,
+		Z: v.Z + o.Z,
+		W: v.W + o.W,
+	}
+}
+
+func (v Vertex4) lerp(o Vertex4, t float64) Vertex4 {
+	return Vertex4{
+		X: v.X + (o.X-v.X)*t,
+		Y: v.Y + (o.Y-v.Y)*t,
+		Z: v.Z + (o.Z-v.Z)*t,
+		W: v.W + (o.W-v.W)*t,
+	}
+}
+
+func (v Vertex4) lower() Vertex3 {
+	return Vertex3{
+		X: v.X / v.W,
+		Y: v.Y / v.W,
+		Z: v.Z / v.W,
+	}
+}
@@ -0,0 +1,151 @@
+package renderer
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"io"
+	"math"
+	"os"
+	"os/exec"
+)
+
+type VideoFormat int
+
+const (
+	// Frames of bare RGBA pixels, top row first, for ffmpeg's -f rawvideo -pix_fmt rgba.
+	RawVideo VideoFormat = iota
+	// JPEG images one after another, for ffmpeg's -f mjpeg.
+	MJPEG
+)
+
+// Writes rendered frames one after another, in a format ffmpeg can read from a pipe.
+type videoWriter struct {
+	w      io.Writer
+	format VideoFormat
+	// Top row first, unlike rendered images.
+	flipped []byte
+}
+
+func newVideoWriter(w io.Writer, format VideoFormat) *videoWriter {
+	return &videoWriter{w: w, format: format}
+}
+
+func (v *videoWriter) writeFrame(img *image.RGBA) error {
+	rect := img.Bounds()
+
+	if v.format == MJPEG {
+		return jpeg.Encode(v.w, flipImageVertically(rect, img), &jpeg.Options{Quality: jpegQuality})
+	}
+
+	row := rect.Dx() * 4
+	if len(v.flipped) != row*rect.Dy() {
+		v.flipped = make([]byte, row*rect.Dy())
+	}
+	for y := 0; y < rect.Dy(); y++ {
+		copy(v.flipped[(rect.Dy()-1-y)*row:], img.Pix[y*img.Stride:y*img.Stride+row])
+	}
+
+	_, err := v.w.Write(v.flipped)
+	return err
+}
+
+// Video encoded by an ffmpeg process, reading raw frames from a pipe.
+type FFmpegEncoder struct {
+	cmd   *exec.Cmd
+	stdin io.WriteCloser
+}
+
+// The format of the video follows the extension of the file, usually .mp4.
+func StartFFmpeg(filename string, width, height int, fps float64) (*FFmpegEncoder, error) {
+	cmd := exec.Command("ffmpeg", "-y", "-loglevel", "error",
+		"-f", "rawvideo", "-pix_fmt", "rgba", "-s", fmt.Sprintf("%dx%d", width, height), "-r", fmt.Sprint(fps), "-i", "-",
+		// Most players only handle 4:2:0 chroma subsampling, which needs even dimensions.
+		"-vf", "pad=ceil(iw/2)*2:ceil(ih/2)*2", "-pix_fmt", "yuv420p",
+		filename)
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := cmd.Start(); err != nil {
+		return nil, errors.New(fmt.Sprintf("unable to start ffmpeg: %s", err))
+	}
+
+	return &FFmpegEncoder{cmd: cmd, stdin: stdin}, nil
+}
+
+func (e *FFmpegEncoder) Write(p []byte) (int, error) {
+	return e.stdin.Write(p)
+}
+
+// Waits for ffmpeg to finish writing the video.
+func (e *FFmpegEncoder) Close() error {
+	if err := e.stdin.Close(); err != nil {
+		return err
+	}
+	return e.cmd.Wait()
+}
+
+// The camera turned around its target, about the up axis, by the given angle in radians.
+func turntableCamera(camera Camera, angle float64) Camera {
+	rotation := RotationMatrix(camera.Up, angle)
+	camera.Position = camera.Target.plus(rotation.transformDirection(camera.Position.minus(camera.Target)))
+	return camera
+}
+
+// Renders a full turn of the camera around its target into the writer, one frame after another.
+func RenderTurntable(w io.Writer, format VideoFormat, frames int, scene *Scene, camera Camera, options Options) error {
+	if err := options.validate(); err != nil {
+		return err
+	}
+
+	rect := options.rect()
+	video := newVideoWriter(w, format)
+	for i := 0; i < frames; i++ {
+		img := newImage(rect)
+		render(img, scene, turntableCamera(camera, 2*math.Pi*float64(i)/float64(frames)), options)
+
+		if err := video.writeFrame(img); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Renders frames of an animation as it plays into the writer, at a frame rate. The camera of the
+// scene, when it has one, follows its node, the camera given being used otherwise.
+func RenderAnimation(w io.Writer, format VideoFormat, frames int, frameRate float64, player *AnimationPlayer, scene *Scene, camera Camera, options Options) error {
+	if err := options.validate(); err != nil {
+		return err
+	}
+
+	rect := options.rect()
+	video := newVideoWriter(w, format)
+	for i := 0; i < frames; i++ {
+		if i > 0 {
+			player.Update(1 / frameRate)
+		} else {
+			player.Clip.Apply(player.Time)
+		}
+
+		frameCamera := camera
+		if c, ok := scene.Camera(); ok {
+			frameCamera = c
+		}
+
+		img := newImage(rect)
+		render(img, scene, frameCamera, options)
+
+		if err := video.writeFrame(img); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,359 @@
+package renderer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"math"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// How long messages of the viewer stay on screen.
+const messageDuration = 4 * time.Second
+
+// Interactive mode: the scene gets rendered again every frame, as seen by a camera moved around by
+// the controller. Keys bound to options toggle them, like tab the wireframe, G the ground grid, B
+// bounding boxes, X the axis gizmo, N vertex normals and V debug views, F3 the debug overlay,
+// escape quits. F12 saves a screenshot into the working directory, F11 the depth, normals and
+// object IDs of the frame. F1 shows a panel of settings, like the exposure or the direction of
+// the sun. Clicking selects what's under the mouse, outlining it. The first animation of the
+// scene plays, looping or not as its clip says. Models dropped onto the window replace the ones of
+// the scene, or get added to them with shift held.
+type viewer struct {
+	window     Window
+	controller Controller
+	options    Options
+	quit       bool
+	overlay    bool
+	shift      bool
+	settings   bool
+	gui        *gui
+	// Saved along with the next frame.
+	screenshot, buffers bool
+	// Shown at the bottom of the frame until the deadline.
+	message      string
+	messageUntil time.Time
+	// Stopped once models get dropped, the scene no longer being the one of the files.
+	watcher *fileWatcher
+
+	scene     *Scene
+	camera    *Camera
+	player    *AnimationPlayer
+	picker    *Picker
+	selection *Pick
+}
+
+// With reload, the scene gets loaded again through it whenever one of its files changes, the
+// camera staying where it is. It stays as it was when that fails, like with files half written.
+func RunViewer(window Window, scene *Scene, camera Camera, controller Controller, options Options, maxFPS float64, reload func() (*Scene, error)) {
+	v := &viewer{window: window, controller: controller, options: options, camera: &camera, gui: newGUI()}
+	v.setScene(scene)
+	var img *image.RGBA
+
+	if reload != nil {
+		v.watcher = newFileWatcher(scene.Files)
+		defer func() {
+			if v.watcher != nil {
+				v.watcher.close()
+			}
+		}()
+	}
+
+	var loop *FrameLoop
+	loop = newFrameLoop(func(dt float64) {
+		if v.watcher != nil && v.watcher.changed(time.Now()) {
+			if scene, err := reload(); err == nil {
+				v.setScene(scene)
+				v.watcher.close()
+				v.watcher = newFileWatcher(scene.Files)
+			}
+		}
+
+		controller.Update(&camera, dt)
+		if v.player != nil {
+			v.player.Update(dt)
+		}
+	}, func(alpha float64) {
+		// Windows can be resized at any time.
+		size := window.Size()
+		if img == nil || img.Bounds().Size() != size {
+			img = newImage(image.Rectangle{Max: size})
+		}
+		start := time.Now()
+		stats := &RenderStats{}
+		zBuffer := renderFrame(context.Background(), wrapFramebuffer(img, nil), v.scene, camera, v.options, stats)
+		v.save(img, camera)
+		if v.selection != nil {
+			drawSelection(img, zBuffer, v.scene, camera, *v.selection, v.options)
+		}
+		if v.overlay {
+			drawOverlay(img, stats, loop.FPS, time.Since(start))
+		}
+		if time.Now().Before(v.messageUntil) {
+			drawMessage(img, v.message)
+		}
+		if v.settings {
+			v.drawSettings(img)
+		}
+	})
+	loop.MaxFPS = maxFPS
+	loop.Present = func() {
+		window.Present(img)
+	}
+
+	loop.Run(func() bool {
+		return window.Poll(v) && !v.quit
+	})
+}
+
+// Starts over with the scene, its first animation playing from the start and nothing selected.
+func (v *viewer) setScene(scene *Scene) {
+	v.scene = scene
+	v.player = nil
+	if len(scene.Animations) > 0 {
+		v.player = NewAnimationPlayer(scene.Animations[0])
+	}
+	v.picker, v.selection = nil, nil
+}
+
+func (v *viewer) Drag(button MouseButton, from, to image.Point, viewport image.Rectangle) {
+	if v.settings && button == MouseLeft && v.gui.drag(from, to, viewport) {
+		return
+	}
+	v.controller.Drag(button, from, to, viewport)
+}
+
+func (v *viewer) Click(button MouseButton, at image.Point, viewport image.Rectangle) {
+	if v.settings && button == MouseLeft && v.gui.click(at, viewport) {
+		return
+	}
+
+	if button == MouseLeft {
+		// Picking goes against the scene as it is, which animations keep changing.
+		if v.picker == nil || v.player != nil {
+			v.picker = NewPicker(v.scene)
+		}
+
+		v.selection = nil
+		if pick, ok := v.picker.Pick(*v.camera, viewport, at); ok {
+			v.selection = &pick
+		}
+	}
+
+	v.controller.Click(button, at, viewport)
+}
+
+func (v *viewer) Scroll(delta float64) {
+	v.controller.Scroll(delta)
+}
+
+func (v *viewer) Key(key Key, pressed bool) {
+	if key == KeyEscape {
+		v.quit = true
+		return
+	}
+
+	if key == KeyShift {
+		v.shift = pressed
+	}
+
+	if pressed && key == KeyF3 {
+		v.overlay = !v.overlay
+		return
+	}
+
+	if pressed && key == KeyF1 {
+		v.settings = !v.settings
+		return
+	}
+
+	if pressed && (key == KeyF12 || key == KeyF11) {
+		v.screenshot = v.screenshot || key == KeyF12
+		v.buffers = v.buffers || key == KeyF11
+		return
+	}
+
+	if pressed && v.options.toggle(key) {
+		return
+	}
+
+	v.controller.Key(key, pressed)
+}
+
+// Models dropped replace the ones of the scene, the camera framing them, or get added to them
+// with shift held. Lights, the camera and the environment of the scene stay.
+func (v *viewer) Drop(files []string) {
+	scene := *v.scene
+	root := *scene.Root
+	scene.Root = &root
+
+	if !v.shift {
+		root.Children = nil
+		for _, child := range v.scene.Root.Children {
+			if !hasMesh(child) {
+				root.Children = append(root.Children, child)
+			}
+		}
+		scene.Animations, scene.Files = nil, nil
+	}
+
+	var names []string
+	for _, file := range files {
+		node, clips, err := loadDroppedModel(file)
+		if err != nil {
+			v.show(fmt.Sprintf("Unable to load %s: %s", filepath.Base(file), err))
+			return
+		}
+		root.Children = append(root.Children, node)
+		scene.Animations = append(scene.Animations, clips...)
+		if node.Mesh != nil {
+			scene.Files = append(scene.Files, node.Mesh.Files...)
+		} else {
+			scene.Files = append(scene.Files, file)
+		}
+		names = append(names, filepath.Base(file))
+	}
+
+	if !v.shift {
+		*v.camera = BestViewCamera(scene.Flatten())
+		if c, ok := v.controller.(interface{ reset(camera Camera) }); ok {
+			c.reset(*v.camera)
+		}
+	}
+
+	if v.watcher != nil {
+		v.watcher.close()
+		v.watcher = nil
+	}
+	v.setScene(&scene)
+	v.show(fmt.Sprintf("Loaded %s", strings.Join(names, ", ")))
+}
+
+// Settings changed from the panel apply from the next frame on.
+func (v *viewer) drawSettings(img *image.RGBA) {
+	g := v.gui
+	g.begin(img)
+
+	// Angles of the first directional light, in degrees, as it shines.
+	if sun := v.sun(); sun != nil {
+		light := sun.Light.(DirectionalLight)
+		d := light.Direction.normalize(1.0)
+		yaw, pitch := math.Atan2(d.X, d.Z)*180/math.Pi, math.Asin(math.Max(-1, math.Min(1, d.Y)))*180/math.Pi
+		turned := g.slider("sun yaw", &yaw, -180, 180)
+		if g.slider("sun pitch", &pitch, -90, 90) || turned {
+			yaw, pitch = yaw*math.Pi/180, pitch*math.Pi/180
+			light.Direction = Vertex3{X: math.Cos(pitch) * math.Sin(yaw), Y: math.Sin(pitch), Z: math.Cos(pitch) * math.Cos(yaw)}
+			sun.Light = light
+		}
+	}
+
+	g.slider("exposure", &v.options.Exposure, -4, 4)
+
+	shading := int(v.options.Shading)
+	if g.choice("shading", &shading, []string{"phong", "gouraud", "flat"}) {
+		v.options.Shading = ShadingMode(shading)
+	}
+
+	wireframe := v.options.Wireframe == WireframeOverlay
+	if g.checkbox("wireframe", &wireframe) {
+		v.options.Wireframe = WireframeOff
+		if wireframe {
+			v.options.Wireframe = WireframeOverlay
+		}
+	}
+
+	g.end()
+}
+
+// Node of the first directional light of the scene, nil without any.
+func (v *viewer) sun() *Node {
+	var sun *Node
+	walkNode(v.scene.Root, Identity4(), func(node *Node, world Matrix4) {
+		if _, ok := node.Light.(DirectionalLight); ok && sun == nil {
+			sun = node
+		}
+	})
+	return sun
+}
+
+// Writes the frame when asked to, as it got rendered without the selection and the overlay, named
+// after the time down to the millisecond so presses in a row don't overwrite each other. Buffers
+// get rendered again, into a framebuffer with them attached.
+func (v *viewer) save(img *image.RGBA, camera Camera) {
+	screenshot, buffers := v.screenshot, v.buffers
+	if !screenshot && !buffers {
+		return
+	}
+	v.screenshot, v.buffers = false, false
+	name := "screenshot-" + time.Now().Format("20060102-150405.000")
+
+	var files []string
+	if screenshot {
+		filename := name + ".png"
+		if err := SaveImage(flipImageVertically(img.Bounds(), img), filename); err != nil {
+			v.show(fmt.Sprintf("Unable to save %s: %s", filename, err))
+			return
+		}
+		files = append(files, filename)
+	}
+
+	if buffers {
+		size := img.Bounds().Size()
+		fb := NewFramebuffer(size.X, size.Y)
+		attachments := []Attachment{DepthAttachment, NormalAttachment, ObjectIDAttachment}
+		for _, a := range attachments {
+			fb.Attach(a)
+		}
+		renderFrame(context.Background(), fb, v.scene, camera, v.options, nil)
+
+		for _, a := range attachments {
+			filename := fmt.Sprintf("%s-%s.png", name, a)
+			if err := fb.Save(a, filename); err != nil {
+				v.show(fmt.Sprintf("Unable to save %s: %s", filename, err))
+				return
+			}
+			files = append(files, filename)
+		}
+	}
+
+	v.show(fmt.Sprintf("Saved %s", strings.Join(files, ", ")))
+}
+
+func (v *viewer) show(message string) {
+	v.message = message
+	v.messageUntil = time.Now().Add(messageDuration)
+}
+
+func loadDroppedModel(filename string) (*Node, []*AnimationClip, error) {
+	node := NewNode(filepath.Base(filename))
+
+	if IsAnimated(filename) {
+		root, clips, err := LoadAnimatedModel(filename)
+		if err != nil {
+			return nil, nil, err
+		}
+		node.Add(root)
+		return node, clips, nil
+	}
+
+	var err error
+	node.Mesh, err = LoadModel(filename)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(node.Mesh.Faces) == 0 {
+		return nil, nil, errors.New("no faces")
+	}
+	return node, nil, nil
+}
+
+func hasMesh(node *Node) bool {
+	found := false
+	walkNode(node, Identity4(), func(node *Node, world Matrix4) {
+		found = found || node.Mesh != nil
+	})
+	return found
+}
@@ -0,0 +1,106 @@
+package renderer
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+)
+
+// Part of a frame showing the scene through its own camera, like the views of modeling tools.
+type Viewport struct {
+	// In pixels of the frame, Y going down.
+	Rect   image.Rectangle
+	Camera Camera
+	// Written in the top-left corner of the viewport, unless empty.
+	Label string
+}
+
+var viewportBorderColor = color.RGBA{R: 90, G: 90, B: 90, A: 255}
+
+// Renders every viewport into its part of a single image as big as the options say, top row
+// first, outlined so that they stand apart. Options apply to all viewports alike, their sizes
+// aside.
+func RenderViewports(scene *Scene, viewports []Viewport, options Options) (*image.RGBA, error) {
+	if err := options.validate(); err != nil {
+		return nil, err
+	}
+
+	frame := newImage(options.rect())
+	if err := renderViewports(frame, scene, viewports, options); err != nil {
+		return nil, err
+	}
+
+	return frame, nil
+}
+
+// Same as RenderViewports, into a frame of any size, top row first.
+func renderViewports(frame *image.RGBA, scene *Scene, viewports []Viewport, options Options) error {
+	canvas := NewCanvas(frame)
+	for _, viewport := range viewports {
+		rect := viewport.Rect.Intersect(frame.Bounds())
+		if rect.Empty() {
+			continue
+		}
+
+		o := options
+		o.Width, o.Height = rect.Dx(), rect.Dy()
+		img, err := Render(scene, viewport.Camera, o)
+		if err != nil {
+			return err
+		}
+		draw.Draw(frame, rect, img, image.Point{}, draw.Src)
+
+		canvas.DrawRect(rect, viewportBorderColor)
+		if viewport.Label != "" {
+			canvas.DrawText(overlayFont, rect.Min.Add(image.Point{X: 4, Y: 4}), viewport.Label, color.White)
+		}
+	}
+
+	return nil
+}
+
+// Top, front and right orthographic views of the scene and the perspective of the camera, in a
+// two by two grid over a frame of the given size, like the quad view of modeling tools.
+func QuadViewports(scene *Scene, camera Camera, width, height int) []Viewport {
+	w, h := width/2, height/2
+	aspect := float64(w) / float64(maxInt(h, 1))
+
+	return []Viewport{
+		{Rect: image.Rect(0, 0, w, h), Camera: OrthographicCamera(scene, Vertex3{Y: -1}, aspect), Label: "Top"},
+		{Rect: image.Rect(w, 0, width, h), Camera: camera, Label: "Perspective"},
+		{Rect: image.Rect(0, h, w, height), Camera: OrthographicCamera(scene, Vertex3{Z: -1}, aspect), Label: "Front"},
+		{Rect: image.Rect(w, h, width, height), Camera: OrthographicCamera(scene, Vertex3{X: -1}, aspect), Label: "Right"},
+	}
+}
+
+// Orthographic camera looking at the whole scene along a direction, with a little margin around it.
+// Looking straight up or down, the top of the view faces -Z, the front of the scene being at the
+// bottom.
+func OrthographicCamera(scene *Scene, direction Vertex3, aspect float64) Camera {
+	direction = direction.normalize(1.0)
+	bounds, ok := scene.bounds()
+	if !ok {
+		bounds = AABB{Min: Vertex3{X: -1, Y: -1, Z: -1}, Max: Vertex3{X: 1, Y: 1, Z: 1}}
+	}
+	center := bounds.Min.plus(bounds.Max).scale(0.5)
+	radius := math.Max(bounds.Max.minus(bounds.Min).length()/2, 1e-3)
+
+	camera := NewCamera(center.minus(direction.scale(2*radius)), center)
+	camera.Projection = Orthographic
+	if math.Abs(direction.Y) > 0.99 {
+		camera.Up = Vertex3{Z: -1}
+	}
+	camera.Near, camera.Far = radius/2, 4*radius
+
+	// Just enough of the view for the corners of the bounds, whichever way it's wider.
+	view := camera.viewMatrix()
+	size := 0.0
+	for _, corner := range bounds.corners() {
+		p := view.transformPoint(corner)
+		size = math.Max(size, math.Max(math.Abs(p.Y), math.Abs(p.X)/aspect))
+	}
+	camera.OrthoSize = 1.1 * math.Max(size, 1e-3)
+
+	return camera
+}
@@ -0,0 +1,38 @@
+package renderer
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestViewport(t *testing.T) {
+	scene, _, camera, options := sphereTestScene()
+	options.ClearColor = color.RGBA{R: 255, A: 255}
+	want, err := Render(scene, camera, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The same view in the bottom-right corner of a larger frame, cut in half by the scissor.
+	options.Width, options.Height = 64, 64
+	options.Viewport = image.Rect(32, 32, 64, 64)
+	options.Scissor = image.Rect(0, 0, 48, 64)
+	fb := NewFramebuffer(64, 64)
+	if err := RenderFramebuffer(fb, scene, camera, options); err != nil {
+		t.Fatal(err)
+	}
+	img, _ := fb.Image(ColorAttachment)
+
+	for y := 0; y < 64; y++ {
+		for x := 0; x < 64; x++ {
+			expected := color.RGBA{A: 255}
+			if x >= 32 && x < 48 && y >= 32 {
+				expected = want.RGBAAt(x-32, y-32)
+			}
+			if c := img.RGBAAt(x, y); c != expected {
+				t.Fatalf("got %v at %d,%d, want %v", c, x, y, expected)
+			}
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package renderer
+
+import (
+	"os"
+	"time"
+)
+
+// Changes to files only get reported once they stopped for this long, as tools often write them
+// in several steps.
+const watchSettle = 200 * time.Millisecond
+
+// How often files get looked at for changes, without fsnotify.
+const watchPollInterval = 250 * time.Millisecond
+
+// Set by the file of the fsnotify build tag, as it needs a library the renderer doesn't otherwise
+// depend on. Files get polled for changes of their size and modification time without it.
+var newFileNotifier func(files []string) (fileNotifier, error)
+
+// Tells when files change.
+type fileNotifier interface {
+	// Whether any of the files changed since the last call, without blocking.
+	changed(now time.Time) bool
+	close()
+}
+
+// Files watched for changes, like the ones of the scene in the viewer.
+type fileWatcher struct {
+	notifier fileNotifier
+	// When the files last changed, zero once reported.
+	last time.Time
+}
+
+// Falls back to polling when notifications can't be set up, like with too many files open.
+func newFileWatcher(files []string) *fileWatcher {
+	if newFileNotifier != nil {
+		if notifier, err := newFileNotifier(files); err == nil {
+			return &fileWatcher{notifier: notifier}
+		}
+	}
+	return &fileWatcher{notifier: newFilePoller(files)}
+}
+
+// Whether the files changed since the last time it said so, once they stopped changing.
+func (w *fileWatcher) changed(now time.Time) bool {
+	if w.notifier.changed(now) {
+		w.last = now
+	}
+
+	if w.last.IsZero() || now.Sub(w.last) < watchSettle {
+		return false
+	}
+	w.last = time.Time{}
+	return true
+}
+
+func (w *fileWatcher) close() {
+	w.notifier.close()
+}
+
+type filePoller struct {
+	files  []string
+	stamps []fileStamp
+	next   time.Time
+}
+
+// Missing files have a zero stamp, their creation counting as a change.
+type fileStamp struct {
+	size     int64
+	modified time.Time
+}
+
+func newFilePoller(files []string) *filePoller {
+	p := &filePoller{files: files, stamps: make([]fileStamp, len(files))}
+	for i, file := range files {
+		p.stamps[i] = statFile(file)
+	}
+	return p
+}
+
+func (p *filePoller) changed(now time.Time) bool {
+	if now.Before(p.next) {
+		return false
+	}
+	p.next = now.Add(watchPollInterval)
+
+	changed := false
+	for i, file := range p.files {
+		if stamp := statFile(file); stamp != p.stamps[i] {
+			p.stamps[i] = stamp
+			changed = true
+		}
+	}
+	return changed
+}
+
+func (p *filePoller) close() {}
+
+func statFile(filename string) fileStamp {
+	info, err := os.Stat(filename)
+	if err != nil {
+		return fileStamp{}
+	}
+	return fileStamp{size: info.Size(), modified: info.ModTime()}
+}
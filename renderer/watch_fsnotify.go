@@ -0,0 +1,78 @@
+//go:build fsnotify
+
+package renderer
+
+import (
+	"path/filepath"
+	"sync/atomic"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+func init() {
+	newFileNotifier = newFSNotifier
+}
+
+// Notified by the operating system rather than polling. Directories get watched rather than the
+// files themselves, as editors often save by replacing files, which loses watches on them.
+type fsNotifier struct {
+	watcher *fsnotify.Watcher
+	dirty   atomic.Bool
+}
+
+func newFSNotifier(files []string) (fileNotifier, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+
+	watched := map[string]bool{}
+	dirs := map[string]bool{}
+	for _, file := range files {
+		file, err := filepath.Abs(file)
+		if err != nil {
+			watcher.Close()
+			return nil, err
+		}
+		watched[file] = true
+
+		dir := filepath.Dir(file)
+		if !dirs[dir] {
+			if err := watcher.Add(dir); err != nil {
+				watcher.Close()
+				return nil, err
+			}
+			dirs[dir] = true
+		}
+	}
+
+	n := &fsNotifier{watcher: watcher}
+	go func() {
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if watched[filepath.Clean(event.Name)] && event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename|fsnotify.Remove) != 0 {
+					n.dirty.Store(true)
+				}
+			case _, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+			}
+		}
+	}()
+
+	return n, nil
+}
+
+func (n *fsNotifier) changed(now time.Time) bool {
+	return n.dirty.Swap(false)
+}
+
+func (n *fsNotifier) close() {
+	n.watcher.Close()
+}
@@ -0,0 +1,48 @@
+package renderer
+
+import (
+	"errors"
+	"fmt"
+	"image"
+)
+
+// A native window showing rendered frames, as opened by one of the backends built in.
+type Window interface {
+	// Pixels of the area frames get shown in.
+	Size() image.Point
+	// Shows a frame, Y going up like in rendered images. With vsync, waits for the vertical blank
+	// when the backend supports it.
+	Present(img *image.RGBA)
+	// Reports what the user did since the last call, false once the window got closed.
+	Poll(input Input) bool
+}
+
+// Opens a window, runs the function with it and closes it. Some windowing libraries need to keep
+// control of the thread they're started from for as long as they run, hence the callback.
+type windowBackend func(title string, width, height int, vsync bool, run func(window Window)) error
+
+// Backends register themselves from the files of their build tags, as they need libraries the
+// renderer doesn't otherwise depend on.
+var windowBackends = map[string]windowBackend{}
+
+// Backends in order of preference, when more than one got built in. Native ones come first.
+var windowPreference = []string{"glfw", "shiny"}
+
+// Either backend names one explicitly, or it's "auto" for the preferred one built in.
+func RunWindow(backend string, title string, width, height int, vsync bool, run func(window Window)) error {
+	if backend != "auto" {
+		open, ok := windowBackends[backend]
+		if !ok {
+			return errors.New(fmt.Sprintf("window backend %q not built in, rebuild with -tags %s", backend, backend))
+		}
+		return open(title, width, height, vsync, run)
+	}
+
+	for _, name := range windowPreference {
+		if open, ok := windowBackends[name]; ok {
+			return open(title, width, height, vsync, run)
+		}
+	}
+
+	return errors.New("built without any window backend, rebuild with -tags glfw or -tags shiny")
+}
@@ -0,0 +1,174 @@
+//go:build glfw
+
+package renderer
+
+import (
+	"image"
+	"runtime"
+
+	"github.com/go-gl/gl/v2.1/gl"
+	"github.com/go-gl/glfw/v3.3/glfw"
+)
+
+func init() {
+	// GLFW has to be used from the main thread, which is the one running init.
+	runtime.LockOSThread()
+
+	windowBackends["glfw"] = runGLFWWindow
+}
+
+// Frames get drawn to the window with glDrawPixels, which is plenty for a software renderer.
+type glfwWindow struct {
+	window *glfw.Window
+
+	// Set during Poll, for the callbacks.
+	input  Input
+	cursor image.Point
+	held   map[MouseButton]bool
+	// Where each button held got pressed, telling clicks from drags.
+	pressed map[MouseButton]image.Point
+}
+
+var glfwKeys = map[glfw.Key]Key{
+	glfw.KeyW:          KeyW,
+	glfw.KeyA:          KeyA,
+	glfw.KeyS:          KeyS,
+	glfw.KeyD:          KeyD,
+	glfw.KeyQ:          KeyQ,
+	glfw.KeyE:          KeyE,
+	glfw.KeyLeftShift:  KeyShift,
+	glfw.KeyRightShift: KeyShift,
+	glfw.KeyTab:        KeyTab,
+	glfw.KeyEscape:     KeyEscape,
+	glfw.KeyF3:         KeyF3,
+	glfw.KeyG:          KeyG,
+	glfw.KeyB:          KeyB,
+	glfw.KeyX:          KeyX,
+	glfw.KeyN:          KeyN,
+	glfw.KeyV:          KeyV,
+	glfw.KeyF11:        KeyF11,
+	glfw.KeyF12:        KeyF12,
+	glfw.KeyF1:         KeyF1,
+}
+
+var glfwButtons = map[glfw.MouseButton]MouseButton{
+	glfw.MouseButtonLeft:   MouseLeft,
+	glfw.MouseButtonMiddle: MouseMiddle,
+	glfw.MouseButtonRight:  MouseRight,
+}
+
+func runGLFWWindow(title string, width, height int, vsync bool, run func(window Window)) error {
+	if err := glfw.Init(); err != nil {
+		return err
+	}
+	defer glfw.Terminate()
+
+	glfw.WindowHint(glfw.ContextVersionMajor, 2)
+	glfw.WindowHint(glfw.ContextVersionMinor, 1)
+	window, err := glfw.CreateWindow(width, height, title, nil, nil)
+	if err != nil {
+		return err
+	}
+	defer window.Destroy()
+
+	window.MakeContextCurrent()
+	if err := gl.Init(); err != nil {
+		return err
+	}
+
+	if vsync {
+		glfw.SwapInterval(1)
+	} else {
+		glfw.SwapInterval(0)
+	}
+
+	w := &glfwWindow{window: window, held: map[MouseButton]bool{}, pressed: map[MouseButton]image.Point{}}
+	window.SetKeyCallback(w.onKey)
+	window.SetMouseButtonCallback(w.onMouseButton)
+	window.SetCursorPosCallback(w.onCursorPos)
+	window.SetScrollCallback(w.onScroll)
+	window.SetDropCallback(w.onDrop)
+
+	run(w)
+	return nil
+}
+
+// The framebuffer, which has more pixels than the window on high density displays.
+func (w *glfwWindow) Size() image.Point {
+	width, height := w.window.GetFramebufferSize()
+	return image.Point{X: width, Y: height}
+}
+
+// glDrawPixels starts from the bottom row, just like rendered images.
+func (w *glfwWindow) Present(img *image.RGBA) {
+	size := w.Size()
+	rect := img.Bounds()
+
+	gl.Viewport(0, 0, int32(size.X), int32(size.Y))
+	gl.ClearColor(0, 0, 0, 1)
+	gl.Clear(gl.COLOR_BUFFER_BIT)
+
+	gl.RasterPos2f(-1, -1)
+	gl.PixelZoom(float32(size.X)/float32(rect.Dx()), float32(size.Y)/float32(rect.Dy()))
+	gl.PixelStorei(gl.UNPACK_ROW_LENGTH, int32(img.Stride/4))
+	gl.DrawPixels(int32(rect.Dx()), int32(rect.Dy()), gl.RGBA, gl.UNSIGNED_BYTE, gl.Ptr(img.Pix))
+
+	w.window.SwapBuffers()
+}
+
+func (w *glfwWindow) Poll(input Input) bool {
+	w.input = input
+	glfw.PollEvents()
+	w.input = nil
+
+	return !w.window.ShouldClose()
+}
+
+func (w *glfwWindow) onKey(window *glfw.Window, key glfw.Key, scancode int, action glfw.Action, mods glfw.ModifierKey) {
+	k, ok := glfwKeys[key]
+	if !ok || action == glfw.Repeat {
+		return
+	}
+
+	w.input.Key(k, action == glfw.Press)
+}
+
+func (w *glfwWindow) onMouseButton(window *glfw.Window, button glfw.MouseButton, action glfw.Action, mods glfw.ModifierKey) {
+	b, ok := glfwButtons[button]
+	if !ok {
+		return
+	}
+
+	w.held[b] = action == glfw.Press
+	if action == glfw.Press {
+		w.pressed[b] = w.cursor
+	} else if w.pressed[b] == w.cursor {
+		width, height := window.GetSize()
+		w.input.Click(b, w.cursor, image.Rect(0, 0, width, height))
+	}
+}
+
+// Mouse coordinates are in window pixels, Y going down.
+func (w *glfwWindow) onCursorPos(window *glfw.Window, x, y float64) {
+	cursor := image.Point{X: int(x), Y: int(y)}
+	width, height := window.GetSize()
+	viewport := image.Rect(0, 0, width, height)
+
+	for _, button := range []MouseButton{MouseLeft, MouseMiddle, MouseRight} {
+		if w.held[button] {
+			w.input.Drag(button, w.cursor, cursor, viewport)
+		}
+	}
+
+	w.cursor = cursor
+}
+
+func (w *glfwWindow) onScroll(window *glfw.Window, dx, dy float64) {
+	w.input.Scroll(dy)
+}
+
+func (w *glfwWindow) onDrop(window *glfw.Window, names []string) {
+	if input, ok := w.input.(DropInput); ok {
+		input.Drop(names)
+	}
+}
@@ -0,0 +1,189 @@
+//go:build shiny
+
+package renderer
+
+import (
+	"image"
+
+	"golang.org/x/exp/shiny/driver"
+	"golang.org/x/exp/shiny/screen"
+	"golang.org/x/mobile/event/key"
+	"golang.org/x/mobile/event/lifecycle"
+	"golang.org/x/mobile/event/mouse"
+	"golang.org/x/mobile/event/size"
+)
+
+func init() {
+	windowBackends["shiny"] = runShinyWindow
+}
+
+// Pure Go, for when cgo isn't an option. Shiny has no control over vsync.
+type shinyWindow struct {
+	screen screen.Screen
+	window screen.Window
+	buffer screen.Buffer
+	size   image.Point
+	closed bool
+
+	// Shiny only hands events out through a blocking call, so they get queued to be polled.
+	events chan interface{}
+	cursor image.Point
+	held   map[MouseButton]bool
+	// Where each button held got pressed, telling clicks from drags.
+	pressed map[MouseButton]image.Point
+}
+
+var shinyKeys = map[key.Code]Key{
+	key.CodeW:          KeyW,
+	key.CodeA:          KeyA,
+	key.CodeS:          KeyS,
+	key.CodeD:          KeyD,
+	key.CodeQ:          KeyQ,
+	key.CodeE:          KeyE,
+	key.CodeLeftShift:  KeyShift,
+	key.CodeRightShift: KeyShift,
+	key.CodeTab:        KeyTab,
+	key.CodeEscape:     KeyEscape,
+	key.CodeF3:         KeyF3,
+	key.CodeG:          KeyG,
+	key.CodeB:          KeyB,
+	key.CodeX:          KeyX,
+	key.CodeN:          KeyN,
+	key.CodeV:          KeyV,
+	key.CodeF11:        KeyF11,
+	key.CodeF12:        KeyF12,
+	key.CodeF1:         KeyF1,
+}
+
+var shinyButtons = map[mouse.Button]MouseButton{
+	mouse.ButtonLeft:   MouseLeft,
+	mouse.ButtonMiddle: MouseMiddle,
+	mouse.ButtonRight:  MouseRight,
+}
+
+func runShinyWindow(title string, width, height int, vsync bool, run func(window Window)) error {
+	var err error
+
+	driver.Main(func(s screen.Screen) {
+		var window screen.Window
+		window, err = s.NewWindow(&screen.NewWindowOptions{Title: title, Width: width, Height: height})
+		if err != nil {
+			return
+		}
+		defer window.Release()
+
+		w := &shinyWindow{
+			screen:  s,
+			window:  window,
+			size:    image.Point{X: width, Y: height},
+			events:  make(chan interface{}, 256),
+			held:    map[MouseButton]bool{},
+			pressed: map[MouseButton]image.Point{},
+		}
+		go func() {
+			for {
+				e := window.NextEvent()
+				w.events <- e
+				if e, ok := e.(lifecycle.Event); ok && e.To == lifecycle.StageDead {
+					return
+				}
+			}
+		}()
+
+		run(w)
+
+		if w.buffer != nil {
+			w.buffer.Release()
+		}
+	})
+
+	return err
+}
+
+func (w *shinyWindow) Size() image.Point {
+	return w.size
+}
+
+// Shiny images go down from the top, unlike rendered ones.
+func (w *shinyWindow) Present(img *image.RGBA) {
+	rect := img.Bounds()
+	if w.buffer == nil || w.buffer.Size() != rect.Size() {
+		if w.buffer != nil {
+			w.buffer.Release()
+		}
+
+		var err error
+		w.buffer, err = w.screen.NewBuffer(rect.Size())
+		if err != nil {
+			w.buffer = nil
+			return
+		}
+	}
+
+	dst := w.buffer.RGBA()
+	for y := 0; y < rect.Dy(); y++ {
+		copy(dst.Pix[(rect.Dy()-1-y)*dst.Stride:], img.Pix[y*img.Stride:y*img.Stride+rect.Dx()*4])
+	}
+
+	w.window.Upload(image.Point{}, w.buffer, w.buffer.Bounds())
+	w.window.Publish()
+}
+
+func (w *shinyWindow) Poll(input Input) bool {
+	for {
+		select {
+		case e := <-w.events:
+			w.handle(e, input)
+		default:
+			return !w.closed
+		}
+	}
+}
+
+func (w *shinyWindow) handle(e interface{}, input Input) {
+	switch e := e.(type) {
+	case lifecycle.Event:
+		if e.To == lifecycle.StageDead {
+			w.closed = true
+		}
+
+	case size.Event:
+		w.size = image.Point{X: e.WidthPx, Y: e.HeightPx}
+
+	case key.Event:
+		k, ok := shinyKeys[e.Code]
+		if !ok || e.Direction == key.DirNone {
+			return
+		}
+		input.Key(k, e.Direction == key.DirPress)
+
+	// Mouse coordinates are in window pixels, Y going down.
+	case mouse.Event:
+		switch e.Button {
+		case mouse.ButtonWheelUp:
+			input.Scroll(1)
+			return
+		case mouse.ButtonWheelDown:
+			input.Scroll(-1)
+			return
+		}
+
+		cursor := image.Point{X: int(e.X), Y: int(e.Y)}
+		if b, ok := shinyButtons[e.Button]; ok {
+			w.held[b] = e.Direction == mouse.DirPress
+			switch {
+			case e.Direction == mouse.DirPress:
+				w.pressed[b] = cursor
+			case e.Direction == mouse.DirRelease && w.pressed[b] == cursor:
+				input.Click(b, cursor, image.Rectangle{Max: w.size})
+			}
+		}
+
+		for _, button := range []MouseButton{MouseLeft, MouseMiddle, MouseRight} {
+			if w.held[button] && cursor != w.cursor {
+				input.Drag(button, w.cursor, cursor, image.Rectangle{Max: w.size})
+			}
+		}
+		w.cursor = cursor
+	}
+}
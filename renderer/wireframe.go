@@ -0,0 +1,75 @@
+package renderer
+
+import (
+	"image"
+	"image/color"
+)
+
+// Depth offset letting edges show on top of the triangles they belong to, steep ones needing more
+// as their depth changes much from one pixel of an edge to the next.
+var wireframeBias = DepthBias{Constant: 1, Slope: 1}
+
+// Draws the edges of the triangles. Given a z-buffer, edges hidden behind other triangles are left out.
+func drawWireframe(img *image.RGBA, triangles []Triangle, zBuffer []float64, col color.RGBA) {
+	for _, triangle := range triangles {
+		if zBuffer != nil {
+			triangle.offset(wireframeBias)
+		}
+		for i := 0; i < 3; i++ {
+			j := (i + 1) % 3
+			a, b := triangle.pixel(i), triangle.pixel(j)
+
+			if zBuffer == nil {
+				drawLine(img, a.X, a.Y, b.X, b.Y, col)
+				continue
+			}
+			drawDepthLine(img, zBuffer, a, b, triangle.depths[i], triangle.depths[j], col)
+		}
+	}
+}
+
+// Bresenham's line algorithm, with the depth interpolated along the line and tested against the z-buffer.
+func drawDepthLine(img *image.RGBA, zBuffer []float64, a, b image.Point, depthA, depthB float64, col color.RGBA) {
+	width := img.Bounds().Dx()
+	height := img.Bounds().Dy()
+
+	dx, dy := b.X-a.X, b.Y-a.Y
+	sx, sy := 1, 1
+	if dx < 0 {
+		dx, sx = -dx, -1
+	}
+	if dy < 0 {
+		dy, sy = -dy, -1
+	}
+
+	// Every step moves one pixel along the major axis.
+	steps := maxInt(dx, dy)
+	e := dx - dy
+	x, y := a.X, a.Y
+
+	for i := 0; ; i++ {
+		if x >= 0 && y >= 0 && x < width && y < height {
+			depth := depthA
+			if steps > 0 {
+				depth += (depthB - depthA) * float64(i) / float64(steps)
+			}
+			if depth >= zBuffer[width*y+x] {
+				img.Set(x, y, col)
+			}
+		}
+
+		if x == b.X && y == b.Y {
+			break
+		}
+
+		e2 := 2 * e
+		if e2 > -dy {
+			e -= dy
+			x += sx
+		}
+		if e2 < dx {
+			e += dx
+			y += sy
+		}
+	}
+}
@@ -0,0 +1,324 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/nitrix/render/renderer"
+)
+
+// Thumbnails can't be larger than this, in either dimension.
+const maxThumbnailSize = 4096
+
+// Renders models sent over HTTP into PNG thumbnails, for asset management backends.
+//
+// Models are either uploaded as the "model" field of a multipart form, or given by "path"
+// relative to the root directory. Uploads are OBJ, PLY, binary glTF or glTF with its buffers and
+// images embedded as data URIs, coming alone: material libraries of OBJ files are left out, and
+// glTF files referring to other files are turned down. Other parameters are optional: "width" and "height" in pixels,
+// "eye" and "target" as x,y,z for the camera, framing the model from its best side without them,
+// "fov" in degrees and "aa" for anti-aliasing. Work stops when clients hang up before getting their
+// thumbnail.
+//
+//	curl -F model=@models/african_head.obj 'localhost:8080/render?width=256&height=256' > thumbnail.png
+func serve(args []string) {
+	flags := commandFlags("serve", "[-addr :8080] [-root dir]")
+	addr := flags.String("addr", ":8080", "address to listen on")
+	root := flags.String("root", "", "directory models can be given from by path, only uploads being accepted without it")
+	maxUpload := flags.Int64("max-upload", 64<<20, "largest model accepted, in bytes")
+	flags.Parse(args)
+
+	http.Handle("/render", &thumbnailHandler{root: *root, maxUpload: *maxUpload})
+
+	log.Println("Listening on", *addr)
+	log.Fatalln(http.ListenAndServe(*addr, nil))
+}
+
+type thumbnailHandler struct {
+	root      string
+	maxUpload int64
+}
+
+// An error along with the status code it's answered with.
+type httpError struct {
+	status int
+	err    error
+}
+
+func (e *httpError) Error() string {
+	return e.err.Error()
+}
+
+func badRequest(format string, a ...interface{}) error {
+	return &httpError{http.StatusBadRequest, errors.New(fmt.Sprintf(format, a...))}
+}
+
+func (h *thumbnailHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, h.maxUpload)
+
+	img, err := h.render(r)
+	// Clients gone before their thumbnail is ready get nothing, what was left of the work dropped.
+	if r.Context().Err() != nil {
+		log.Println("Dropped render:", r.Context().Err())
+		return
+	}
+	if err != nil {
+		status := http.StatusInternalServerError
+		var httpErr *httpError
+		if errors.As(err, &httpErr) {
+			status = httpErr.status
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/png")
+	if err := png.Encode(w, img); err != nil {
+		log.Println("Unable to send thumbnail:", err)
+	}
+}
+
+func (h *thumbnailHandler) render(r *http.Request) (*image.RGBA, error) {
+	mesh, err := h.model(r)
+	if err != nil {
+		return nil, err
+	}
+
+	options := renderer.DefaultOptions()
+	options.Width, options.Height = 256, 256
+	if options.Width, err = formInt(r, "width", options.Width); err != nil {
+		return nil, err
+	}
+	if options.Height, err = formInt(r, "height", options.Height); err != nil {
+		return nil, err
+	}
+	if options.Width > maxThumbnailSize || options.Height > maxThumbnailSize {
+		return nil, badRequest("thumbnails are at most %dx%d", maxThumbnailSize, maxThumbnailSize)
+	}
+
+	switch r.FormValue("aa") {
+	case "", "none":
+	case "ssaa":
+		options.AntiAliasing = renderer.Supersampling
+	case "msaa":
+		options.AntiAliasing = renderer.Multisampling
+	case "fxaa":
+		options.AntiAliasing = renderer.FXAA
+	default:
+		return nil, badRequest("unknown anti-aliasing %q", r.FormValue("aa"))
+	}
+
+	scene := renderer.NewScene()
+	node := renderer.NewNode("model")
+	node.Mesh = mesh
+	sun := renderer.NewNode("sun")
+	sun.Light = renderer.DirectionalLight{Direction: renderer.Vertex3{Z: -1}, Intensity: 1}
+	scene.Root.Add(node, sun)
+
+	camera := renderer.BestViewCamera(scene.Flatten())
+	if eye := r.FormValue("eye"); eye != "" {
+		position, err := parseVertex3(eye)
+		if err != nil {
+			return nil, badRequest("eye: %s", err)
+		}
+		target := camera.Target
+		if t := r.FormValue("target"); t != "" {
+			if target, err = parseVertex3(t); err != nil {
+				return nil, badRequest("target: %s", err)
+			}
+		}
+		camera = renderer.NewCamera(position, target)
+	}
+	if fov := r.FormValue("fov"); fov != "" {
+		degrees, err := strconv.ParseFloat(fov, 64)
+		if err != nil || degrees <= 0 || degrees >= 180 {
+			return nil, badRequest("invalid field of view %q", fov)
+		}
+		camera.Fov = degrees * math.Pi / 180
+	}
+
+	img, err := renderer.RenderContext(r.Context(), scene, camera, options)
+	if err != nil {
+		return nil, badRequest("%s", err)
+	}
+
+	return img, nil
+}
+
+// The uploaded model, or the one found at the path under the root directory.
+func (h *thumbnailHandler) model(r *http.Request) (*renderer.Obj, error) {
+	file, header, err := r.FormFile("model")
+	if err == nil {
+		defer file.Close()
+		return loadUploadedModel(r.Context(), file, header.Filename)
+	}
+	if err != http.ErrMissingFile && err != http.ErrNotMultipart {
+		return nil, badRequest("invalid upload: %s", err)
+	}
+
+	path := r.FormValue("path")
+	if path == "" {
+		return nil, badRequest("missing model, upload one or give its path")
+	}
+	if h.root == "" {
+		return nil, &httpError{http.StatusForbidden, errors.New("models can only be uploaded")}
+	}
+
+	// Cleaned as an absolute path first, so that it can't climb out of the root.
+	filename := filepath.Join(h.root, filepath.FromSlash(filepath.Clean("/"+path)))
+	if _, err := os.Stat(filename); err != nil {
+		return nil, &httpError{http.StatusNotFound, errors.New(fmt.Sprintf("no model at %s", path))}
+	}
+
+	return loadModel(r.Context(), filename, path)
+}
+
+// Loaders read from files, so uploads go through a temporary one with the same extension.
+func loadUploadedModel(ctx context.Context, upload io.Reader, name string) (*renderer.Obj, error) {
+	ext := strings.ToLower(filepath.Ext(name))
+	if ext != ".obj" && ext != ".ply" && ext != ".gltf" && ext != ".glb" {
+		return nil, badRequest("unsupported model %q, expected OBJ, PLY or glTF", name)
+	}
+
+	file, err := os.CreateTemp("", "render-*"+ext)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(file.Name())
+
+	switch ext {
+	case ".obj":
+		err = copyWithoutMaterials(file, upload)
+	case ".gltf", ".glb":
+		err = copyEmbeddedGLTF(file, upload)
+	default:
+		_, err = io.Copy(file, upload)
+	}
+	if err != nil {
+		file.Close()
+		return nil, badRequest("invalid upload: %s", err)
+	}
+	if err := file.Close(); err != nil {
+		return nil, err
+	}
+
+	return loadModel(ctx, file.Name(), name)
+}
+
+// Loads the model, only telling clients it failed under the name they know it by, as loader
+// errors show where files are on the server.
+func loadModel(ctx context.Context, filename, name string) (*renderer.Obj, error) {
+	mesh, err := renderer.LoadModelContext(ctx, filename)
+	if err != nil {
+		log.Printf("Unable to load %s: %s", name, err)
+		return nil, badRequest("unable to load model %q", name)
+	}
+
+	return mesh, nil
+}
+
+// Copies an OBJ file without its material libraries and the materials they define, as libraries
+// are looked up relative to the file and could be anywhere on the server.
+func copyWithoutMaterials(w io.Writer, r io.Reader) error {
+	out := bufio.NewWriter(w)
+	in := bufio.NewReader(r)
+	for {
+		line, err := in.ReadString('\n')
+		if fields := strings.Fields(line); len(fields) == 0 || (fields[0] != "mtllib" && fields[0] != "usemtl") {
+			if _, err := out.WriteString(line); err != nil {
+				return err
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return out.Flush()
+}
+
+// Copies a glTF file, binary or not, as long as its buffers and images are embedded in it rather
+// than next to it, where they could be anywhere on the server.
+func copyEmbeddedGLTF(w io.Writer, r io.Reader) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	// The JSON chunk comes first in binary files.
+	content := data
+	if len(data) >= 12 && string(data[:4]) == "glTF" {
+		if len(data) < 20 || binary.LittleEndian.Uint32(data[16:]) != 0x4e4f534a {
+			return errors.New("binary glTF not starting with JSON")
+		}
+		length := int(binary.LittleEndian.Uint32(data[12:]))
+		if length > len(data)-20 {
+			return errors.New("truncated binary glTF")
+		}
+		content = data[20 : 20+length]
+	}
+
+	var file struct {
+		Buffers []struct {
+			URI string `json:"uri"`
+		} `json:"buffers"`
+		Images []struct {
+			URI string `json:"uri"`
+		} `json:"images"`
+	}
+	if err := json.Unmarshal(content, &file); err != nil {
+		return errors.New(fmt.Sprintf("invalid glTF: %s", err))
+	}
+	var uris []string
+	for _, b := range file.Buffers {
+		uris = append(uris, b.URI)
+	}
+	for _, i := range file.Images {
+		uris = append(uris, i.URI)
+	}
+	for _, uri := range uris {
+		if uri != "" && !strings.HasPrefix(uri, "data:") {
+			return errors.New(fmt.Sprintf("glTF refers to %q, buffers and images have to be embedded", uri))
+		}
+	}
+
+	_, err = io.Copy(w, bytes.NewReader(data))
+	return err
+}
+
+func formInt(r *http.Request, name string, fallback int) (int, error) {
+	value := r.FormValue(name)
+	if value == "" {
+		return fallback, nil
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil || n <= 0 {
+		return 0, badRequest("invalid %s %q", name, value)
+	}
+
+	return n, nil
+}